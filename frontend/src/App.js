@@ -54,6 +54,11 @@ let locationOptions = [
 ];
 const IndexOfStartingLocationOption = 1; // Palestine
 
+// REACT_APP_MAP_OPTIONS_URL points the frontend at another source of maps, e.g. the cli's serve command
+const mapOptionsUrl =
+  process.env.REACT_APP_MAP_OPTIONS_URL ||
+  "https://cdn.conflictnightlight.com/conflict-nightlight-bounded-map-options.json";
+
 function App() {
   const startingConfig =
     locationOptions[IndexOfStartingLocationOption].configuration;
@@ -78,9 +83,7 @@ function App() {
   useEffect(() => {
     const fetchMapOptions = async () => {
       try {
-        const response = await fetch(
-          "https://cdn.conflictnightlight.com/conflict-nightlight-bounded-map-options.json",
-        );
+        const response = await fetch(mapOptionsUrl);
         const fetchedMapOptions = await response.json();
         setAllMapOptions(fetchedMapOptions);
 
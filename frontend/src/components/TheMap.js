@@ -36,6 +36,10 @@ const getMapHeight = (width) => {
   }
 };
 
+// Self hosted maps are published as an xyz url template instead of a mapbox tileset
+const rasterSource = (url) =>
+  url.includes("{z}") ? { tiles: [url], tileSize: 256 } : { url };
+
 export const TheMap = ({
   selectedMap,
   mapSide,
@@ -89,7 +93,7 @@ export const TheMap = ({
         <Source
           id="mapbox-terrain"
           type="raster"
-          url={selectedMap.url}
+          {...rasterSource(selectedMap.url)}
           key={selectedMap.key}
         >
           <Layer {...nightlightOverview} />
@@ -17,8 +17,20 @@ export default function YearMonthSelect({ mapOptions, selectedMap, setMap }) {
       >
         <Select value={selectedMap} onChange={handleChange} autoWidth>
           {mapOptions.map((object, i) => (
-            <MenuItem value={object} key={i}>
+            <MenuItem
+              value={object}
+              key={i}
+              title={
+                object.anomalies?.length > 0
+                  ? "Suspected outage, the radiance dropped well below the previous months"
+                  : object.low_confidence
+                  ? "Low confidence, clouds covered much of the region this month"
+                  : undefined
+              }
+            >
               {object.display_name}
+              {object.anomalies?.length > 0 ? " ⚠" : ""}
+              {object.low_confidence ? " ☁" : ""}
             </MenuItem>
           ))}
         </Select>
@@ -30,6 +30,7 @@ resource "aws_lambda_function" "conflict_nightlight_map_controller_lambda_functi
       CORRELATION_ID_KEY     = var.correlation_id_key
       SOURCE_KEY_URL         = var.source_url_key
       SHAPE_FILE_BUCKET      = aws_s3_bucket.shape_files.bucket
+      MAP_STATUS_BUCKET      = aws_s3_bucket.map_status.bucket
     }
   }
   s3_bucket     = aws_s3_bucket.zip_deployables.bucket
@@ -56,14 +57,13 @@ resource "aws_lambda_function" "conflict_nightlight_python_lambda_function" {
   memory_size = 3000 # Prevents the lambda from crashing when cropping the tif
   environment {
     variables = {
-      WRITE_DIR                         = "/tmp"
-      RAW_TIF_BUCKET                    = aws_s3_bucket.raw_tif.bucket
-      CORRELATION_ID_KEY_NAME           = var.correlation_id_key
-      CREATE_MAP_PRODUCT_REQUEST_QUEUE  = aws_sqs_queue.create_map_product_request_queue.name
-      PUBLISH_MAP_PRODUCT_REQUEST_QUEUE = aws_sqs_queue.publish_map_product_request_queue.name
-      PROCESSED_TIF_BUCKET_NAME         = aws_s3_bucket.processed_tif.bucket
-      CONFLICT_NIGHTLIGHT_SECRETS_KEY   = aws_secretsmanager_secret.conflict_nightlight-secrets.name
-      USES_DEBUG_LOGGER                 = "false"
+      WRITE_DIR                        = "/tmp"
+      RAW_TIF_BUCKET                   = aws_s3_bucket.raw_tif.bucket
+      CORRELATION_ID_KEY_NAME          = var.correlation_id_key
+      CREATE_MAP_PRODUCT_REQUEST_QUEUE = aws_sqs_queue.create_map_product_request_queue.name
+      PROCESSED_TIF_BUCKET_NAME        = aws_s3_bucket.processed_tif.bucket
+      CONFLICT_NIGHTLIGHT_SECRETS_KEY  = aws_secretsmanager_secret.conflict_nightlight-secrets.name
+      USES_DEBUG_LOGGER                = "false"
     }
   }
   function_name = "${var.prefix}-${var.python_lambda}-function"
@@ -97,12 +97,23 @@ resource "aws_lambda_function" "conflict_nightlight_map_publisher_lambda_functio
   reserved_concurrent_executions = 1
   environment {
     variables = {
-      WRITE_DIR                 = "/tmp"
-      FRONTEND_MAP_OPTIONS_JSON = "conflict-nightlight-bounded-map-options.json"
-      PROCESSED_TIF_BUCKET_NAME = aws_s3_bucket.processed_tif.bucket
-      CORRELATION_ID_KEY        = var.correlation_id_key
-      SOURCE_KEY_URL            = var.source_url_key
-      CDN_BUCKET_NAME           = aws_s3_bucket.cdn.bucket
+      WRITE_DIR                    = "/tmp"
+      FRONTEND_MAP_OPTIONS_JSON    = "conflict-nightlight-bounded-map-options.json"
+      FRONTEND_MAP_STATS_JSON      = "conflict-nightlight-map-stats.json"
+      FRONTEND_REGION_STATS_JSON   = "conflict-nightlight-region-stats.json"
+      FRONTEND_ANOMALIES_JSON      = "conflict-nightlight-anomalies.json"
+      ATTACH_ANOMALIES_TO_FRONTEND = tostring(var.attach_anomalies_to_frontend)
+      MIN_CLOUD_FREE_COVERAGE      = var.min_cloud_free_coverage
+      LOW_COVERAGE_ACTION          = var.low_coverage_action
+      RAW_TIF_BUCKET               = aws_s3_bucket.raw_tif.bucket
+      PROCESSED_TIF_BUCKET_NAME    = aws_s3_bucket.processed_tif.bucket
+      CORRELATION_ID_KEY           = var.correlation_id_key
+      SOURCE_KEY_URL               = var.source_url_key
+      CDN_BUCKET_NAME              = aws_s3_bucket.cdn.bucket
+      MAP_STATUS_BUCKET            = aws_s3_bucket.map_status.bucket
+      SHAPE_FILE_BUCKET            = aws_s3_bucket.shape_files.bucket
+      TILE_SERVER                  = var.tile_server
+      TILES_BASE_URL               = "https://cdn.${var.domain_name}"
     }
   }
   s3_bucket     = aws_s3_bucket.zip_deployables.bucket
@@ -111,7 +122,9 @@ resource "aws_lambda_function" "conflict_nightlight_map_publisher_lambda_functio
   architectures = ["arm64"]
   handler       = "handler"
   role          = aws_iam_role.lambda.arn
-  timeout       = 50
+  # Publishing waits until mapbox has processed the upload, which takes a few minutes for large tifs
+  timeout       = 300
+  memory_size   = 512 # The xyz tile server renders and uploads the tiles with several workers
 }
 
 resource "aws_lambda_event_source_mapping" "conflict_nightlight_publisher_lambda_event_source" {
@@ -119,3 +132,65 @@ resource "aws_lambda_event_source_mapping" "conflict_nightlight_publisher_lambda
   function_name    = aws_lambda_function.conflict_nightlight_map_publisher_lambda_function.arn
   batch_size       = 1
 }
+
+# -----------------------------------------------------------------------------------
+#                                Map Auto Publisher
+# -----------------------------------------------------------------------------------
+resource "aws_lambda_function" "conflict_nightlight_map_auto_publisher_lambda_function" {
+  ephemeral_storage {
+    size = 512 # Min 512 MB and the Max 10240 MB
+  }
+  function_name = "${var.prefix}-${var.map_auto_publisher}-function"
+  # Publishing rewrites the frontend json, running one at a time prevents concurrent updates from overwriting each other
+  reserved_concurrent_executions = 1
+  environment {
+    variables = {
+      WRITE_DIR                    = "/tmp"
+      FRONTEND_MAP_OPTIONS_JSON    = "conflict-nightlight-bounded-map-options.json"
+      FRONTEND_MAP_STATS_JSON      = "conflict-nightlight-map-stats.json"
+      FRONTEND_REGION_STATS_JSON   = "conflict-nightlight-region-stats.json"
+      FRONTEND_ANOMALIES_JSON      = "conflict-nightlight-anomalies.json"
+      ATTACH_ANOMALIES_TO_FRONTEND = tostring(var.attach_anomalies_to_frontend)
+      MIN_CLOUD_FREE_COVERAGE      = var.min_cloud_free_coverage
+      LOW_COVERAGE_ACTION          = var.low_coverage_action
+      RAW_TIF_BUCKET               = aws_s3_bucket.raw_tif.bucket
+      PROCESSED_TIF_BUCKET_NAME    = aws_s3_bucket.processed_tif.bucket
+      CORRELATION_ID_KEY           = var.correlation_id_key
+      SOURCE_KEY_URL               = var.source_url_key
+      CDN_BUCKET_NAME              = aws_s3_bucket.cdn.bucket
+      MAP_STATUS_BUCKET            = aws_s3_bucket.map_status.bucket
+      SHAPE_FILE_BUCKET            = aws_s3_bucket.shape_files.bucket
+      AUTO_PUBLISH_BOUNDS          = var.auto_publish_bounds
+      AUTO_PUBLISH_MAP_TYPES       = var.auto_publish_map_types
+      TILE_SERVER                  = var.tile_server
+      TILES_BASE_URL               = "https://cdn.${var.domain_name}"
+    }
+  }
+  s3_bucket     = aws_s3_bucket.zip_deployables.bucket
+  s3_key        = "map_auto_publisher/latest.zip"
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+  handler       = "handler"
+  role          = aws_iam_role.lambda.arn
+  # Publishing waits until mapbox has processed the upload, which takes a few minutes for large tifs
+  timeout       = 300
+  memory_size   = 512 # The xyz tile server renders and uploads the tiles with several workers
+}
+
+resource "aws_lambda_permission" "conflict_nightlight_map_auto_publisher_allow_processed_tif_bucket" {
+  statement_id  = "AllowExecutionFromProcessedTifBucket"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.conflict_nightlight_map_auto_publisher_lambda_function.arn
+  principal     = "s3.amazonaws.com"
+  source_arn    = aws_s3_bucket.processed_tif.arn
+}
+
+resource "aws_s3_bucket_notification" "processed_tif_created_notification" {
+  bucket = aws_s3_bucket.processed_tif.id
+  lambda_function {
+    lambda_function_arn = aws_lambda_function.conflict_nightlight_map_auto_publisher_lambda_function.arn
+    events              = ["s3:ObjectCreated:*"]
+    filter_suffix       = ".tif"
+  }
+  depends_on = [aws_lambda_permission.conflict_nightlight_map_auto_publisher_allow_processed_tif_bucket]
+}
@@ -70,6 +70,42 @@ data "aws_iam_policy_document" "processed_tif_bucket_policy_document" {
   }
 }
 
+# ----------------------------------------------------------------------
+#                       MAP STATUS
+# ----------------------------------------------------------------------
+resource "aws_s3_bucket" "map_status" {
+  bucket = "${var.prefix}-${var.map_status_bucket_name}"
+}
+
+resource "aws_s3_bucket_policy" "map_status_bucket_policy" {
+  bucket = aws_s3_bucket.map_status.id
+  policy = data.aws_iam_policy_document.map_status_bucket_policy_document.json
+}
+
+resource "aws_s3_bucket_public_access_block" "map_status_bucket_block_public_access" {
+  bucket                  = aws_s3_bucket.map_status.id
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+data "aws_iam_policy_document" "map_status_bucket_policy_document" {
+  statement {
+    actions = [
+      "s3:*"
+    ]
+    resources = [
+      aws_s3_bucket.map_status.arn,
+      "${aws_s3_bucket.map_status.arn}/*",
+    ]
+    principals {
+      identifiers = ["*"]
+      type        = "AWS"
+    }
+  }
+}
+
 # ----------------------------------------------------------------------
 #                       SHAPE FILES
 # ----------------------------------------------------------------------
@@ -123,7 +159,7 @@ resource "null_resource" "create_temp_zip" {
   }
 
   provisioner "local-exec" {
-    command = "aws s3 cp ${path.module}/templates/fake_zip/latest.zip s3://${aws_s3_bucket.zip_deployables.bucket}/map_controller/latest.zip && aws s3 cp ${path.module}/templates/fake_zip/latest.zip s3://${aws_s3_bucket.zip_deployables.bucket}/map_publisher/latest.zip"
+    command = "aws s3 cp ${path.module}/templates/fake_zip/latest.zip s3://${aws_s3_bucket.zip_deployables.bucket}/map_controller/latest.zip && aws s3 cp ${path.module}/templates/fake_zip/latest.zip s3://${aws_s3_bucket.zip_deployables.bucket}/map_publisher/latest.zip && aws s3 cp ${path.module}/templates/fake_zip/latest.zip s3://${aws_s3_bucket.zip_deployables.bucket}/map_auto_publisher/latest.zip"
   }
 
   depends_on = [aws_s3_bucket.zip_deployables]
@@ -107,7 +107,7 @@ resource "aws_sqs_queue" "publish_map_product_request_queue" {
   max_message_size           = 2048
   message_retention_seconds  = 86400
   receive_wait_time_seconds  = 10
-  visibility_timeout_seconds = 60
+  visibility_timeout_seconds = 301
 
   redrive_policy = jsonencode({
     deadLetterTargetArn = aws_sqs_queue.new_processed_tif_file_notification_dlq.arn
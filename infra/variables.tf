@@ -22,6 +22,12 @@ variable "processed_tif_bucket_name" {
   default     = "processed-tif"
 }
 
+variable "map_status_bucket_name" {
+  description = "The name of the s3 bucket used to store where each map is in the pipeline"
+  type        = string
+  default     = "map-status"
+}
+
 variable "shape_files_bucket_name" {
   description = "The name of the s3 bucket used to store the shape files"
   type        = string
@@ -64,6 +70,48 @@ variable "map_publisher" {
   default     = "map-publisher"
 }
 
+variable "map_auto_publisher" {
+  description = "The name of the lambda function that publishes maps as soon as they are processed"
+  type        = string
+  default     = "map-auto-publisher"
+}
+
+variable "auto_publish_bounds" {
+  description = "Comma separated bounds that are published as soon as they are processed, * for all bounds"
+  type        = string
+  default     = "*"
+}
+
+variable "auto_publish_map_types" {
+  description = "Comma separated map types that are published as soon as they are processed, * for all map types"
+  type        = string
+  default     = "*"
+}
+
+variable "attach_anomalies_to_frontend" {
+  description = "Whether the suspected outages are added to the map options of the frontend json"
+  type        = bool
+  default     = false
+}
+
+variable "min_cloud_free_coverage" {
+  description = "The minimum fraction of the bounds that has to be cloud free for a map to be published normally, empty disables the check"
+  type        = string
+  default     = ""
+}
+
+variable "low_coverage_action" {
+  description = "What happens to a map below the min_cloud_free_coverage, flag publishes it as low confidence and refuse does not publish it"
+  type        = string
+  default     = "flag"
+}
+
+variable "tile_server" {
+  description = "Where the maps are published, mapbox hosts them as tilesets and xyz cuts them into png tiles in the cdn bucket"
+  type        = string
+  default     = "mapbox"
+}
+
 variable "zip_deployables_bucket_name" {
   description = "The bucket name that contains the deployable zip files"
   type        = string
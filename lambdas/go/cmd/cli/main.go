@@ -3,69 +3,131 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/externalmapsrepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/frontendmapdatarepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/internalmapsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatusrepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/maptileserverrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/services"
 	"github.com/BaronBonet/conflict-nightlight/internal/handlers"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/BaronBonet/conflict-nightlight/internal/wiring"
 	"go.uber.org/zap"
 )
 
 func main() {
 	ctx := infrastructure.NewContext()
 	logger := adapters.NewZapLogger(zap.NewDevelopmentConfig(), false)
+	if err := wiring.LoadBoundsRegistry(); err != nil {
+		logger.Fatal(ctx, "Error when loading the bounds config", "error", err)
+	}
 	writeDir := infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp")
 	externalMapsRepo := externalmapsrepo.NewEogdataExternalMapsRepository(logger, fmt.Sprintf("%s/eog_cache", writeDir))
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-central-1"))
-	if err != nil {
-		logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
+	var awsClient awsclient.AWSClient
+	var internalRawMapsRepo, internalProcessedMapsRepo ports.InternalMapRepo
+	// The maps can be kept on the local filesystem, e.g. a copy of the buckets, to run the cli without AWS, the json
+	// the other repos keep in s3 is then kept in buckets/
+	if internalMapsDir := infrastructure.GetEnvOrDefault("INTERNAL_MAPS_DIR", ""); internalMapsDir != "" {
+		awsClient = awsclient.NewFilesystemAWSClient(filepath.Join(internalMapsDir, "buckets"))
+		internalRawMapsRepo = internalmapsrepo.NewFilesystemInternalMapsRepository(
+			logger,
+			filepath.Join(internalMapsDir, "raw"),
+			infrastructure.GetEnvOrDefault("SOURCE_URL_KEY", "source-url"),
+			filepath.Join(internalMapsDir, "spool"),
+			writeDir,
+		)
+		internalProcessedMapsRepo = internalmapsrepo.NewFilesystemInternalMapsRepository(
+			logger,
+			filepath.Join(internalMapsDir, "processed"),
+			infrastructure.GetEnvOrDefault("SOURCE_KEY_URL", "source-url"),
+			"",
+			writeDir,
+		)
+	} else {
+		var err error
+		awsClient, err = awsclient.NewAWSClient(ctx, awsclient.ConfigFromEnv())
+		if err != nil {
+			logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
+		}
+		internalRawMapsRepo = internalmapsrepo.NewAWSInternalMapsRepository(
+			logger,
+			infrastructure.GetEnvOrDefault("RAW_TIF_BUCKET", "conflict-nightlight-raw-tif"),
+			infrastructure.GetEnvOrDefault("SOURCE_URL_KEY", "source-url"),
+			infrastructure.GetEnvOrDefault(
+				"DOWNLOAD_RAW_TIF_QUEUE",
+				"conflict-nightlight-download-and-crop-raw-tif-request",
+			),
+			writeDir,
+			awsClient,
+		)
+		internalProcessedMapsRepo = internalmapsrepo.NewAWSInternalMapsRepository(
+			logger,
+			infrastructure.GetEnvOrDefault("PROCESSED_TIF_BUCKET_NAME", "conflict-nightlight-processed-tif"),
+			infrastructure.GetEnvOrDefault("SOURCE_KEY_URL", "source-url"),
+			"",
+			infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp"),
+			awsClient,
+		)
 	}
-	awsClient := awsclient.NewAWSClient(cfg)
-	internalRawMapsRepo := internalmapsrepo.NewAWSInternalMapsRepository(
+	frontendMapDataRepo := frontendmapdatarepo.NewS3FrontendMapDataRepo(
 		logger,
-		infrastructure.GetEnvOrDefault("RAW_TIF_BUCKET", "conflict-nightlight-raw-tif"),
-		infrastructure.GetEnvOrDefault("SOURCE_URL_KEY", "source-url"),
-		infrastructure.GetEnvOrDefault(
-			"DOWNLOAD_RAW_TIF_QUEUE",
-			"conflict-nightlight-download-and-crop-raw-tif-request",
-		),
-		writeDir,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
+		infrastructure.GetEnvOrDefault("FRONTEND_MAP_OPTIONS_JSON", "conflict-nightlight-bounded-map-options.json"),
 		awsClient,
 	)
-	internalProcessedMapsRepo := internalmapsrepo.NewAWSInternalMapsRepository(
+	// The tile server is only created by the commands that use it, e.g. serve renders the tiles itself and creating
+	// the mapbox tile server would read the mapbox token from AWS
+	tileServerRepo := maptileserverrepo.NewLazyTileServerRepo(func() (ports.MapTileServerRepo, error) {
+		tileServerConfig, err := maptileserverrepo.ConfigFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("reading the tile server config: %w", err)
+		}
+		return maptileserverrepo.NewTileServerRepo(ctx, logger, tileServerConfig, awsClient)
+	})
+	mapStatusRepo := mapstatusrepo.NewS3MapStatusRepo(
 		logger,
-		infrastructure.GetEnvOrDefault("PROCESSED_TIF_BUCKET_NAME", "conflict-nightlight-processed-tif"),
-		infrastructure.GetEnvOrDefault("SOURCE_KEY_URL", "source-url"),
-		"",
-		infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp"),
+		infrastructure.GetEnvOrDefault("MAP_STATUS_BUCKET", "conflict-nightlight-map-status"),
 		awsClient,
 	)
-	frontendMapDataRepo := frontendmapdatarepo.NewS3FrontendMapDataRepo(
-		ctx,
+	mapStatsRepo := mapstatsrepo.NewS3MapStatsRepo(
 		logger,
 		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
-		infrastructure.GetEnvOrDefault("FRONTEND_MAP_OPTIONS_JSON", "conflict-nightlight-bounded-map-options.json"),
-	)
-	mapboxTileServerRepo := maptileserverrepo.NewMapboxTileServerRepo(
-		ctx,
-		logger,
-		infrastructure.GetEnvOrDefault("CONFLICT_NIGHTLIGHT_SECRETS_KEY", "conflict-nightlight-secrets"),
+		infrastructure.GetEnvOrDefault("FRONTEND_MAP_STATS_JSON", "conflict-nightlight-map-stats.json"),
+		awsClient,
 	)
+	subRegionRepo := wiring.NewSubRegionRepo(logger, awsClient)
+	regionStatsRepo := wiring.NewRegionStatsRepo(logger, awsClient)
+	anomalyRepo := wiring.NewAnomalyRepo(logger, awsClient)
+	qualityGate, err := wiring.QualityGate()
+	if err != nil {
+		logger.Fatal(ctx, "Error when parsing the quality gate", "error", err)
+	}
 	service := services.NewOrchestratorService(
 		logger,
 		externalMapsRepo,
 		internalRawMapsRepo,
 		internalProcessedMapsRepo,
 		frontendMapDataRepo,
-		mapboxTileServerRepo,
+		tileServerRepo,
+		mapStatusRepo,
+		mapStatsRepo,
+		subRegionRepo,
+		regionStatsRepo,
+		anomalyRepo,
+		wiring.AnomalyConfig(),
+		qualityGate,
 	)
-	handler := handlers.NewCLIHandler(ctx, service)
+	tileHandler := handlers.NewTileHTTPHandler(logger, service, frontendmapdatarepo.EncodeMapOptions)
+	// Only the s3 repos index their maps in a manifest
+	rawManifest, _ := internalRawMapsRepo.(handlers.ManifestRebuilder)
+	processedManifest, _ := internalProcessedMapsRepo.(handlers.ManifestRebuilder)
+	handler := handlers.NewCLIHandler(ctx, service, tileHandler, rawManifest, processedManifest)
 	if err := handler.Run(os.Args); err != nil {
 		logger.Fatal(ctx, "Could not run CLI handler", "error", err)
 	}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/externalmapsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/frontendmapdatarepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/internalmapsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatusrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/maptileserverrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/services"
+	"github.com/BaronBonet/conflict-nightlight/internal/handlers"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/BaronBonet/conflict-nightlight/internal/wiring"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.uber.org/zap"
+)
+
+func main() {
+	logger := adapters.NewZapLogger(zap.NewProductionConfig(), false)
+	ctx := context.Background()
+	if err := wiring.LoadBoundsRegistry(); err != nil {
+		logger.Fatal(ctx, "Error when loading the bounds config", "error", err)
+	}
+	writeDir := infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp")
+	externalMapsRepo := externalmapsrepo.NewEogdataExternalMapsRepository(logger, fmt.Sprintf("%s/eog_cache", writeDir))
+	awsClient, err := awsclient.NewAWSClient(ctx, awsclient.ConfigFromEnv())
+	if err != nil {
+		logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
+	}
+	internalRawMapsRepo := internalmapsrepo.NewAWSInternalMapsRepository(
+		logger,
+		infrastructure.GetEnvOrDefault("RAW_TIF_BUCKET", "conflict-nightlight-raw-tif"),
+		infrastructure.GetEnvOrDefault("SOURCE_URL_KEY", "source-url"),
+		infrastructure.GetEnvOrDefault(
+			"DOWNLOAD_RAW_TIF_QUEUE",
+			"conflict-nightlight-download-and-crop-raw-tif-request",
+		),
+		writeDir,
+		awsClient,
+	)
+	internalProcessedMapsRepo := internalmapsrepo.NewAWSInternalMapsRepository(
+		logger,
+		infrastructure.GetEnvOrDefault("PROCESSED_TIF_BUCKET_NAME", "conflict-nightlight-processed-tif"),
+		infrastructure.GetEnvOrDefault("SOURCE_KEY_URL", "source-url"),
+		"",
+		infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp"),
+		awsClient,
+	)
+	frontendMapDataRepo := frontendmapdatarepo.NewS3FrontendMapDataRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
+		infrastructure.GetEnvOrDefault("FRONTEND_MAP_OPTIONS_JSON", "conflict-nightlight-bounded-map-options.json"),
+		awsClient,
+	)
+	tileServerConfig, err := maptileserverrepo.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal(ctx, "Error when reading the tile server config", "error", err)
+	}
+	tileServerRepo, err := maptileserverrepo.NewTileServerRepo(ctx, logger, tileServerConfig, awsClient)
+	if err != nil {
+		logger.Fatal(ctx, "Error when creating the tile server", "error", err)
+	}
+	mapStatusRepo := mapstatusrepo.NewS3MapStatusRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("MAP_STATUS_BUCKET", "conflict-nightlight-map-status"),
+		awsClient,
+	)
+	mapStatsRepo := mapstatsrepo.NewS3MapStatsRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
+		infrastructure.GetEnvOrDefault("FRONTEND_MAP_STATS_JSON", "conflict-nightlight-map-stats.json"),
+		awsClient,
+	)
+	subRegionRepo := wiring.NewSubRegionRepo(logger, awsClient)
+	regionStatsRepo := wiring.NewRegionStatsRepo(logger, awsClient)
+	anomalyRepo := wiring.NewAnomalyRepo(logger, awsClient)
+	qualityGate, err := wiring.QualityGate()
+	if err != nil {
+		logger.Fatal(ctx, "Error when parsing the quality gate", "error", err)
+	}
+	service := services.NewOrchestratorService(
+		logger,
+		externalMapsRepo,
+		internalRawMapsRepo,
+		internalProcessedMapsRepo,
+		frontendMapDataRepo,
+		tileServerRepo,
+		mapStatusRepo,
+		mapStatsRepo,
+		subRegionRepo,
+		regionStatsRepo,
+		anomalyRepo,
+		wiring.AnomalyConfig(),
+		qualityGate,
+	)
+	// Every map is published unless the bounds and map types are narrowed down, e.g. "UkraineAndAround" and
+	// "monthly,annual", the same default as the terraform variables
+	policy, err := domain.ParseAutoPublishPolicy(
+		infrastructure.GetEnvOrDefault("AUTO_PUBLISH_BOUNDS", "*"),
+		infrastructure.GetEnvOrDefault("AUTO_PUBLISH_MAP_TYPES", "*"),
+	)
+	if err != nil {
+		logger.Fatal(ctx, "Error when parsing the auto publish policy", "error", err)
+	}
+	lambdaHandler := handlers.NewProcessedMapCreatedLambdaHandler(logger, service, policy)
+	lambda.Start(lambdaHandler.HandleEvent)
+}
@@ -9,25 +9,29 @@ import (
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/externalmapsrepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/frontendmapdatarepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/internalmapsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatusrepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/maptileserverrepo"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/services"
 	"github.com/BaronBonet/conflict-nightlight/internal/handlers"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/BaronBonet/conflict-nightlight/internal/wiring"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"go.uber.org/zap"
 )
 
 func main() {
 	logger := adapters.NewZapLogger(zap.NewProductionConfig(), false)
 	ctx := context.Background()
+	if err := wiring.LoadBoundsRegistry(); err != nil {
+		logger.Fatal(ctx, "Error when loading the bounds config", "error", err)
+	}
 	writeDir := infrastructure.GetEnvOrDefault("WRITE_DIR", "/tmp")
 	externalMapsRepo := externalmapsrepo.NewEogdataExternalMapsRepository(logger, fmt.Sprintf("%s/eog_cache", writeDir))
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-central-1"))
+	awsClient, err := awsclient.NewAWSClient(ctx, awsclient.ConfigFromEnv())
 	if err != nil {
 		logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
 	}
-	awsClient := awsclient.NewAWSClient(cfg)
 	internalRawMapsRepo := internalmapsrepo.NewAWSInternalMapsRepository(
 		logger,
 		infrastructure.GetEnvOrDefault("RAW_TIF_BUCKET", "conflict-nightlight-raw-tif"),
@@ -48,23 +52,51 @@ func main() {
 		awsClient,
 	)
 	frontendMapDataRepo := frontendmapdatarepo.NewS3FrontendMapDataRepo(
-		ctx,
 		logger,
 		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
 		infrastructure.GetEnvOrDefault("FRONTEND_MAP_OPTIONS_JSON", "conflict-nightlight-bounded-map-options.json"),
+		awsClient,
 	)
-	mapboxTileServerRepo := maptileserverrepo.NewMapboxTileServerRepo(
-		ctx,
+	tileServerConfig, err := maptileserverrepo.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal(ctx, "Error when reading the tile server config", "error", err)
+	}
+	tileServerRepo, err := maptileserverrepo.NewTileServerRepo(ctx, logger, tileServerConfig, awsClient)
+	if err != nil {
+		logger.Fatal(ctx, "Error when creating the tile server", "error", err)
+	}
+	mapStatusRepo := mapstatusrepo.NewS3MapStatusRepo(
 		logger,
-		infrastructure.GetEnvOrDefault("CONFLICT_NIGHTLIGHT_SECRETS_KEY", "conflict-nightlight-secrets"),
+		infrastructure.GetEnvOrDefault("MAP_STATUS_BUCKET", "conflict-nightlight-map-status"),
+		awsClient,
 	)
+	mapStatsRepo := mapstatsrepo.NewS3MapStatsRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
+		infrastructure.GetEnvOrDefault("FRONTEND_MAP_STATS_JSON", "conflict-nightlight-map-stats.json"),
+		awsClient,
+	)
+	subRegionRepo := wiring.NewSubRegionRepo(logger, awsClient)
+	regionStatsRepo := wiring.NewRegionStatsRepo(logger, awsClient)
+	anomalyRepo := wiring.NewAnomalyRepo(logger, awsClient)
+	qualityGate, err := wiring.QualityGate()
+	if err != nil {
+		logger.Fatal(ctx, "Error when parsing the quality gate", "error", err)
+	}
 	service := services.NewOrchestratorService(
 		logger,
 		externalMapsRepo,
 		internalRawMapsRepo,
 		internalProcessedMapsRepo,
 		frontendMapDataRepo,
-		mapboxTileServerRepo,
+		tileServerRepo,
+		mapStatusRepo,
+		mapStatsRepo,
+		subRegionRepo,
+		regionStatsRepo,
+		anomalyRepo,
+		wiring.AnomalyConfig(),
+		qualityGate,
 	)
 	lambdaHandler := handlers.NewMapPublisherLambdaHandler(logger, service)
 	lambda.Start(lambdaHandler.HandleEvent)
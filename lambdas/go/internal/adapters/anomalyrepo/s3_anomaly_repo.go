@@ -0,0 +1,172 @@
+package anomalyrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type anomalyEntry struct {
+	// Key is the same key as the one of the map options in the frontend json
+	Key              string    `json:"key"`
+	Bounds           int       `json:"bounds"`
+	RegionID         string    `json:"region_id,omitempty"`
+	RegionName       string    `json:"region_name,omitempty"`
+	MeanRadiance     float64   `json:"mean_radiance"`
+	RollingBaseline  float64   `json:"rolling_baseline"`
+	SeasonalBaseline float64   `json:"seasonal_baseline,omitempty"`
+	Change           float64   `json:"change"`
+	DetectedAt       time.Time `json:"detected_at"`
+}
+
+// s3AnomalyRepo keeps the anomalies in a single json next to the frontend map options
+type s3AnomalyRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+	objectKey  string
+}
+
+func NewS3AnomalyRepo(
+	logger ports.Logger,
+	bucketName string,
+	objectKey string,
+	awsClient awsclient.AWSClient,
+) ports.AnomalyRepo {
+	return &s3AnomalyRepo{logger: logger, bucketName: bucketName, objectKey: objectKey, awsClient: awsClient}
+}
+
+func (repo *s3AnomalyRepo) List(ctx context.Context) ([]domain.Anomaly, error) {
+	anomalies, err := repo.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortAnomalies(anomalies)
+	return anomalies, nil
+}
+
+func (repo *s3AnomalyRepo) UpsertMap(ctx context.Context, id domain.MapID, anomalies []domain.Anomaly) error {
+	existing, err := repo.read(ctx)
+	if err != nil {
+		return err
+	}
+	updated := existing[:0]
+	for _, a := range existing {
+		if a.ID != id {
+			updated = append(updated, a)
+		}
+	}
+	if len(updated) == len(existing) && len(anomalies) == 0 {
+		return nil
+	}
+	return repo.write(ctx, append(updated, anomalies...))
+}
+
+func (repo *s3AnomalyRepo) Replace(ctx context.Context, anomalies []domain.Anomaly) error {
+	return repo.write(ctx, append([]domain.Anomaly(nil), anomalies...))
+}
+
+func (repo *s3AnomalyRepo) DeleteMap(ctx context.Context, id domain.MapID) error {
+	return repo.UpsertMap(ctx, id, nil)
+}
+
+// read returns no anomalies when the json does not exist yet
+func (repo *s3AnomalyRepo) read(ctx context.Context) ([]domain.Anomaly, error) {
+	object, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the anomalies from s3", "error", err)
+		return nil, err
+	}
+	var entries []anomalyEntry
+	if err = json.Unmarshal(object, &entries); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling the anomalies", "error", err)
+		return nil, err
+	}
+	anomalies := make([]domain.Anomaly, 0, len(entries))
+	for _, entry := range entries {
+		id, err := domain.ParseMapID(entry.Key)
+		if err != nil {
+			repo.logger.Warn(ctx, "The key of the anomaly was not in the expected format", "key", entry.Key)
+			continue
+		}
+		anomalies = append(anomalies, domain.Anomaly{
+			ID:               id,
+			RegionID:         entry.RegionID,
+			RegionName:       entry.RegionName,
+			MeanRadiance:     entry.MeanRadiance,
+			RollingBaseline:  entry.RollingBaseline,
+			SeasonalBaseline: entry.SeasonalBaseline,
+			Change:           entry.Change,
+			DetectedAt:       entry.DetectedAt,
+		})
+	}
+	return anomalies, nil
+}
+
+func (repo *s3AnomalyRepo) write(ctx context.Context, anomalies []domain.Anomaly) error {
+	sortAnomalies(anomalies)
+	entries := make([]anomalyEntry, 0, len(anomalies))
+	for _, a := range anomalies {
+		entries = append(entries, anomalyEntry{
+			Key:              a.ID.String(),
+			Bounds:           int(a.ID.Bounds),
+			RegionID:         a.RegionID,
+			RegionName:       a.RegionName,
+			MeanRadiance:     a.MeanRadiance,
+			RollingBaseline:  a.RollingBaseline,
+			SeasonalBaseline: a.SeasonalBaseline,
+			Change:           a.Change,
+			DetectedAt:       a.DetectedAt,
+		})
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when marshalling the anomalies", "error", err)
+		return err
+	}
+	if err = repo.awsClient.UploadToS3(ctx, repo.bucketName, repo.objectKey, bytes.NewReader(data)); err != nil {
+		repo.logger.Error(ctx, "Error when uploading the anomalies to s3", "error", err)
+		return err
+	}
+	return nil
+}
+
+// sortAnomalies orders the anomalies by bounds and date, the anomaly of the whole bounds comes before the ones of
+// its sub-regions
+func sortAnomalies(anomalies []domain.Anomaly) {
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		a, b := anomalies[i], anomalies[j]
+		if a.ID.Bounds != b.ID.Bounds {
+			return a.ID.Bounds < b.ID.Bounds
+		}
+		if a.ID.Date != b.ID.Date {
+			return dateBefore(a.ID.Date, b.ID.Date)
+		}
+		if a.ID != b.ID {
+			return a.ID.String() < b.ID.String()
+		}
+		return a.RegionID < b.RegionID
+	})
+}
+
+func dateBefore(a, b domain.Date) bool {
+	if a.Year != b.Year {
+		return a.Year < b.Year
+	}
+	if a.Month != b.Month {
+		return a.Month < b.Month
+	}
+	return a.Day < b.Day
+}
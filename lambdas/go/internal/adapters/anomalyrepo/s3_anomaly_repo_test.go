@@ -0,0 +1,71 @@
+package anomalyrepo
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnomaly(month time.Month, regionID string) domain.Anomaly {
+	return domain.Anomaly{
+		ID: domain.MapID{
+			Provider: domain.MapProviderEogdata,
+			MapType:  domain.MapTypeMonthly,
+			Bounds:   domain.BoundsUkraineAndAround,
+			Date:     domain.Date{Day: 1, Month: month, Year: 2023},
+		},
+		RegionID:        regionID,
+		MeanRadiance:    2,
+		RollingBaseline: 4,
+		Change:          -0.5,
+		DetectedAt:      time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestS3AnomalyRepo_UpsertMapAndDeleteMap(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3AnomalyRepo(ports.NewMockLogger(t), "test-bucket", "anomalies.json", mockAWSClient)
+
+	var uploaded []byte
+	mockAWSClient.On("UploadToS3", ctx, "test-bucket", "anomalies.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			data, err := io.ReadAll(args.Get(3).(io.Reader))
+			require.NoError(t, err)
+			uploaded = data
+		}).
+		Return(nil)
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(nil, &types.NoSuchKey{}).Once()
+	february := []domain.Anomaly{newTestAnomaly(2, "kharkiv"), newTestAnomaly(2, "")}
+	require.NoError(t, repo.UpsertMap(ctx, february[0].ID, february))
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(uploaded, nil).Once()
+	january := newTestAnomaly(1, "")
+	require.NoError(t, repo.UpsertMap(ctx, january.ID, []domain.Anomaly{january}))
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(uploaded, nil).Once()
+	anomalies, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Anomaly{january, february[1], february[0]}, anomalies)
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.DeleteMap(ctx, february[0].ID))
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(uploaded, nil).Once()
+	anomalies, err = repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Anomaly{january}, anomalies)
+
+	// Nothing is written when a map without anomalies stays without anomalies
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "anomalies.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.UpsertMap(ctx, february[0].ID, nil))
+	mockAWSClient.AssertNumberOfCalls(t, "UploadToS3", 3)
+}
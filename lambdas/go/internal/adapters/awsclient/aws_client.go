@@ -1,30 +1,82 @@
 package awsclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"time"
 
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
+// ErrObjectNotFound is returned by HeadObjectInS3 when there is no object with the key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrPreconditionFailed is returned by UploadToS3IfMatch when another writer changed the object since it was read
+var ErrPreconditionFailed = errors.New("the object was changed by another writer")
+
+// ErrMetadataKeyNotFound is returned by GetObjectMetadataInS3 when the object exists without the metadata key
+var ErrMetadataKeyNotFound = errors.New("metadata key not found on the object")
+
 //go:generate mockery --name=AWSClient
 type AWSClient interface {
 	UploadToS3(ctx context.Context, bucket, key string, data io.Reader) error
+	// UploadToS3WithContentType is UploadToS3 for objects that are served to browsers, e.g. the png tiles
+	UploadToS3WithContentType(ctx context.Context, bucket, key, contentType string, data io.Reader) error
 	GetFromS3(ctx context.Context, bucket, key string) ([]byte, error)
+	// GetFromS3WithETag is GetFromS3 that also returns the etag, so the object can be written back with
+	// UploadToS3IfMatch
+	GetFromS3WithETag(ctx context.Context, bucket, key string) ([]byte, string, error)
+	// UploadToS3IfMatch only writes the object while its etag is still etag, an empty etag only creates an object that
+	// does not exist yet. ErrPreconditionFailed when another writer got there first
+	UploadToS3IfMatch(ctx context.Context, bucket, key, etag string, data []byte) error
+	// GetToWriter streams the object into the writer and returns the number of bytes that were written
+	GetToWriter(ctx context.Context, bucket, key string, w io.Writer) (int64, error)
+	// Download streams the object into a file at localPath, an interrupted download is resumed from where it stopped
+	// and the file is removed when it does not end up with the size of the object
+	Download(ctx context.Context, bucket, key, localPath string) (int64, error)
 	DeleteFromS3(ctx context.Context, bucket, key string) error
-	ListObjectsInS3(ctx context.Context, bucket string) ([]string, error)
+	// ListObjectsInS3 returns every key that starts with the prefix, an empty prefix lists the whole bucket
+	ListObjectsInS3(ctx context.Context, bucket, prefix string) ([]string, error)
+	// PaginateObjectsInS3 lists the keys that start with the prefix one page at a time, so a large bucket does not
+	// have to be held in memory at once
+	PaginateObjectsInS3(bucket, prefix string) ObjectPages
+	// AnyObjectInS3 reports whether a key starts with the prefix, it lists a single key
+	AnyObjectInS3(ctx context.Context, bucket, prefix string) (bool, error)
 	GetObjectMetadataInS3(ctx context.Context, bucket, key, metadataKey string) (*string, error)
+	// HeadObjectInS3 returns the size, last modification and metadata of an object without downloading it,
+	// ErrObjectNotFound when it does not exist
+	HeadObjectInS3(ctx context.Context, bucket, key string) (*ObjectInfo, error)
 	GetSecretFromSecretsManager(ctx context.Context, secretKey string) (secrets interface{}, err error)
 	PublishMessageToSQS(ctx context.Context, queueName string, message interface{}) error
 }
 
+// ObjectPages iterates over the pages of a listing, a page holds at most 1000 keys
+//
+//go:generate mockery --name=ObjectPages
+type ObjectPages interface {
+	HasMorePages() bool
+	NextPage(ctx context.Context) ([]string, error)
+}
+
+// ObjectInfo describes an object as HeadObjectInS3 found it
+type ObjectInfo struct {
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
 //go:generate mockery --name=SecretsManagerClientInterface
 type SecretsManagerClientInterface interface {
 	GetSecretValue(
@@ -38,41 +90,95 @@ type awsClient struct {
 	s3Client             *s3.Client
 	secretsManagerClient SecretsManagerClientInterface
 	sqsClient            *sqs.Client
+	// partSize is the size of the parts of a multipart upload, multipartPartSize when it is zero
+	partSize int
 }
 
-func NewAWSClient(cfg aws.Config) AWSClient {
-	return &awsClient{
-		s3Client:             s3.NewFromConfig(cfg),
-		secretsManagerClient: secretsmanager.NewFromConfig(cfg),
-		sqsClient:            sqs.NewFromConfig(cfg),
+// UploadToS3 streams the data to s3, data that is larger than a part is uploaded in parts so only one part is held
+// in memory at a time
+func (c *awsClient) UploadToS3(ctx context.Context, bucket, key string, data io.Reader) error {
+	return c.UploadToS3WithContentType(ctx, bucket, key, "", data)
+}
+
+func (c *awsClient) UploadToS3WithContentType(
+	ctx context.Context,
+	bucket, key, contentType string,
+	data io.Reader,
+) error {
+	// The buffer grows with the data instead of holding a whole part, many small objects, e.g. the png tiles, are
+	// uploaded at the same time
+	partSize := c.uploadPartSize()
+	var first bytes.Buffer
+	n, err := first.ReadFrom(io.LimitReader(data, int64(partSize)))
+	if err != nil {
+		return err
+	}
+	if n < int64(partSize) {
+		_, err = c.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(first.Bytes()),
+			ContentType: optionalString(contentType),
+		})
+		return err
 	}
+	// The capacity of the first part is the part size, the following parts are read into it
+	part := first.Bytes()
+	return c.uploadMultipart(ctx, bucket, key, contentType, part[:partSize:partSize], data)
 }
 
-func (c *awsClient) UploadToS3(ctx context.Context, bucket, key string, data io.Reader) error {
-	_, err := c.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-		Body:   data,
-	})
-	return err
+// optionalString returns nil for an empty string so s3 falls back to its default
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
 }
 
+// GetFromS3 reads the whole object into memory, use GetToWriter or Download for the tifs
 func (c *awsClient) GetFromS3(ctx context.Context, bucket, key string) ([]byte, error) {
+	var body bytes.Buffer
+	if _, err := c.GetToWriter(ctx, bucket, key, &body); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func (c *awsClient) GetFromS3WithETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
 	result, err := c.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer result.Body.Close()
-
-	body, err := io.ReadAll(result.Body)
+	data, err := io.ReadAll(result.Body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	return data, aws.ToString(result.ETag), nil
+}
 
-	return body, nil
+func (c *awsClient) UploadToS3IfMatch(ctx context.Context, bucket, key, etag string, data []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	_, err := c.s3Client.PutObject(ctx, input)
+	// s3 answers a write that lost the race with 412, or with 409 while the other write is still in progress
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) && (responseErr.HTTPStatusCode() == http.StatusPreconditionFailed ||
+		responseErr.HTTPStatusCode() == http.StatusConflict) {
+		return fmt.Errorf("%w: s3://%s/%s", ErrPreconditionFailed, bucket, key)
+	}
+	return err
 }
 
 func (c *awsClient) DeleteFromS3(ctx context.Context, bucket, key string) error {
@@ -84,19 +190,56 @@ func (c *awsClient) DeleteFromS3(ctx context.Context, bucket, key string) error
 	return err
 }
 
-func (c *awsClient) ListObjectsInS3(ctx context.Context, bucket string) ([]string, error) {
+func (c *awsClient) ListObjectsInS3(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var objectKeys []string
+	pages := c.PaginateObjectsInS3(bucket, prefix)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objectKeys = append(objectKeys, page...)
+	}
+	return objectKeys, nil
+}
+
+func (c *awsClient) PaginateObjectsInS3(bucket, prefix string) ObjectPages {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	return &objectPages{paginator: s3.NewListObjectsV2Paginator(c.s3Client, input)}
+}
+
+func (c *awsClient) AnyObjectInS3(ctx context.Context, bucket, prefix string) (bool, error) {
 	result, err := c.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(1),
 	})
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	return len(result.Contents) > 0, nil
+}
 
-	var objectKeys []string
+type objectPages struct {
+	paginator *s3.ListObjectsV2Paginator
+}
+
+func (p *objectPages) HasMorePages() bool {
+	return p.paginator.HasMorePages()
+}
+
+func (p *objectPages) NextPage(ctx context.Context) ([]string, error) {
+	result, err := p.paginator.NextPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objectKeys := make([]string, 0, len(result.Contents))
 	for _, item := range result.Contents {
 		objectKeys = append(objectKeys, *item.Key)
 	}
-
 	return objectKeys, nil
 }
 
@@ -111,11 +254,36 @@ func (c *awsClient) GetObjectMetadataInS3(ctx context.Context, bucket, key, meta
 
 	link := objectInfo.Metadata[metadataKey]
 	if link == "" {
-		return nil, fmt.Errorf("metadata key not found on the object: %s", metadataKey)
+		return nil, fmt.Errorf("%w: %s", ErrMetadataKeyNotFound, metadataKey)
 	}
 
 	return &link, nil
 }
+
+func (c *awsClient) HeadObjectInS3(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	objectInfo, err := c.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	// A head request has no body, so s3 only answers a missing key with the status code
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: s3://%s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Size:     aws.ToInt64(objectInfo.ContentLength),
+		ETag:     aws.ToString(objectInfo.ETag),
+		Metadata: objectInfo.Metadata,
+	}
+	if objectInfo.LastModified != nil {
+		info.LastModified = *objectInfo.LastModified
+	}
+	return info, nil
+}
+
 func (c *awsClient) GetSecretFromSecretsManager(ctx context.Context, secretKey string) (interface{}, error) {
 	value, err := c.secretsManagerClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
 		SecretId: &secretKey,
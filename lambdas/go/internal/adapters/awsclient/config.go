@@ -0,0 +1,89 @@
+package awsclient
+
+import (
+	"context"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const DefaultRegion = "eu-central-1"
+
+// Config selects where the AWS services are reached, an empty endpoint uses the AWS endpoint of the region. The
+// endpoints make it possible to run the pipeline against stand-ins, e.g. MinIO for s3 and ElasticMQ for sqs
+type Config struct {
+	Region string
+	// Endpoint is used for every service that has no endpoint of its own, e.g. http://localhost:4566 for localstack
+	Endpoint               string
+	S3Endpoint             string
+	SQSEndpoint            string
+	SecretsManagerEndpoint string
+	// UsePathStyle addresses the buckets as endpoint/bucket/key instead of bucket.endpoint/key, MinIO requires it
+	UsePathStyle bool
+}
+
+// ConfigFromEnv reads the region and the addressing of the buckets, the sdk reads the endpoints itself from
+// AWS_ENDPOINT_URL and the per service variables, e.g. AWS_ENDPOINT_URL_S3
+func ConfigFromEnv() Config {
+	return Config{
+		Region:       infrastructure.GetEnvOrDefault("AWS_REGION", DefaultRegion),
+		UsePathStyle: infrastructure.GetEnvOrDefault("AWS_S3_USE_PATH_STYLE", "false") == "true",
+	}
+}
+
+// NewAWSClient creates the client every adapter shares, the s3, sqs and secrets manager clients are all created
+// from the same config
+func NewAWSClient(ctx context.Context, c Config) (AWSClient, error) {
+	cfg, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &awsClient{
+		s3Client: s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.UsePathStyle = c.UsePathStyle
+			if endpoint := c.endpointFor(s3.ServiceID); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		}),
+		secretsManagerClient: secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+			if endpoint := c.endpointFor(secretsmanager.ServiceID); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		}),
+		sqsClient: sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+			if endpoint := c.endpointFor(sqs.ServiceID); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		}),
+	}, nil
+}
+
+func (c Config) load(ctx context.Context) (aws.Config, error) {
+	region := c.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	return config.LoadDefaultConfig(ctx, config.WithRegion(region))
+}
+
+// endpointFor returns the endpoint configured for the service, an empty endpoint leaves it to the sdk, which uses the
+// endpoint from the environment or else the one of the region
+func (c Config) endpointFor(service string) string {
+	var endpoint string
+	switch service {
+	case s3.ServiceID:
+		endpoint = c.S3Endpoint
+	case sqs.ServiceID:
+		endpoint = c.SQSEndpoint
+	case secretsmanager.ServiceID:
+		endpoint = c.SecretsManagerEndpoint
+	}
+	if endpoint == "" {
+		return c.Endpoint
+	}
+	return endpoint
+}
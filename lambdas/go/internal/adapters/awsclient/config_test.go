@@ -0,0 +1,96 @@
+package awsclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_S3_USE_PATH_STYLE", "true")
+
+	assert.Equal(t, Config{Region: "us-west-2", UsePathStyle: true}, ConfigFromEnv())
+}
+
+func TestConfig_EndpointFor(t *testing.T) {
+	c := Config{Endpoint: "http://localhost:4566", S3Endpoint: "http://localhost:9000"}
+
+	assert.Equal(t, "http://localhost:9000", c.endpointFor(s3.ServiceID))
+	assert.Equal(t, "http://localhost:4566", c.endpointFor(sqs.ServiceID))
+	assert.Empty(t, Config{}.endpointFor(secretsmanager.ServiceID), "without an endpoint the sdk picks one")
+}
+
+func TestNewAWSClient_EndpointFromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio-secret")
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+	t.Setenv("AWS_S3_USE_PATH_STYLE", "true")
+
+	client, err := NewAWSClient(context.Background(), ConfigFromEnv())
+	require.NoError(t, err)
+	data, err := client.GetFromS3(context.Background(), "test-bucket", "MapProviderEogdata/2022_1_1.tif")
+
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, "/test-bucket/MapProviderEogdata/2022_1_1.tif", requestedPath)
+}
+
+func TestNewAWSClient_PathStyleEndpoint(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio-secret")
+	var requestedPath, requestedHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath, requestedHost = r.URL.Path, r.Host
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	client, err := NewAWSClient(context.Background(), Config{S3Endpoint: server.URL, UsePathStyle: true})
+	require.NoError(t, err)
+	data, err := client.GetFromS3(context.Background(), "test-bucket", "MapProviderEogdata/2022_1_1.tif")
+
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, "/test-bucket/MapProviderEogdata/2022_1_1.tif", requestedPath)
+	assert.Equal(t, server.Listener.Addr().String(), requestedHost)
+}
+
+func TestNewAWSClient_ListObjectsInS3Paginates(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio-secret")
+	var prefixes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefixes = append(prefixes, r.URL.Query().Get("prefix"))
+		if r.URL.Query().Get("continuation-token") == "" {
+			_, _ = w.Write([]byte(`<ListBucketResult><IsTruncated>true</IsTruncated>` +
+				`<NextContinuationToken>page-2</NextContinuationToken>` +
+				`<Contents><Key>MapProviderEogdata/a.tif</Key></Contents></ListBucketResult>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<ListBucketResult><IsTruncated>false</IsTruncated>` +
+			`<Contents><Key>MapProviderEogdata/b.tif</Key></Contents></ListBucketResult>`))
+	}))
+	defer server.Close()
+
+	client, err := NewAWSClient(context.Background(), Config{S3Endpoint: server.URL, UsePathStyle: true})
+	require.NoError(t, err)
+	keys, err := client.ListObjectsInS3(context.Background(), "test-bucket", "MapProviderEogdata/")
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"MapProviderEogdata/a.tif", "MapProviderEogdata/b.tif"}, keys)
+	assert.Equal(t, []string{"MapProviderEogdata/", "MapProviderEogdata/"}, prefixes)
+}
@@ -0,0 +1,229 @@
+package awsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrNotAvailableOffline is returned by the filesystem client for the services that only exist on AWS
+var ErrNotAvailableOffline = errors.New("not available without AWS")
+
+// filesystemClient keeps every bucket in a directory of its own below rootDir, so the repos that store their json in
+// s3, e.g. the map statuses and the frontend json, run without AWS. Objects have no metadata, and sqs and secrets
+// manager are not available
+type filesystemClient struct {
+	rootDir string
+	// mu makes the conditional writes of the process atomic, other processes are not locked out
+	mu sync.Mutex
+}
+
+func NewFilesystemAWSClient(rootDir string) AWSClient {
+	return &filesystemClient{rootDir: rootDir}
+}
+
+func (c *filesystemClient) UploadToS3(ctx context.Context, bucket, key string, data io.Reader) error {
+	return c.UploadToS3WithContentType(ctx, bucket, key, "", data)
+}
+
+func (c *filesystemClient) UploadToS3WithContentType(_ context.Context, bucket, key, _ string, data io.Reader) error {
+	path := c.pathOf(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// The object is written next to its key first, so a reader never sees a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *filesystemClient) GetFromS3(_ context.Context, bucket, key string) ([]byte, error) {
+	data, err := os.ReadFile(c.pathOf(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, noSuchKey(bucket, key)
+	}
+	return data, err
+}
+
+// GetFromS3WithETag returns the md5 of the object as its etag, like s3 does for the objects that were not uploaded in
+// parts
+func (c *filesystemClient) GetFromS3WithETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	data, err := c.GetFromS3(ctx, bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, etagOf(data), nil
+}
+
+func (c *filesystemClient) UploadToS3IfMatch(ctx context.Context, bucket, key, etag string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, current, err := c.GetFromS3WithETag(ctx, bucket, key)
+	var nsk *s3types.NoSuchKey
+	if errors.As(err, &nsk) {
+		current, err = "", nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != etag {
+		return fmt.Errorf("%w: s3://%s/%s", ErrPreconditionFailed, bucket, key)
+	}
+	return c.UploadToS3(ctx, bucket, key, bytes.NewReader(data))
+}
+
+func (c *filesystemClient) GetToWriter(_ context.Context, bucket, key string, w io.Writer) (int64, error) {
+	file, err := os.Open(c.pathOf(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, noSuchKey(bucket, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(w, file)
+}
+
+func (c *filesystemClient) Download(ctx context.Context, bucket, key, localPath string) (int64, error) {
+	file, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.GetToWriter(ctx, bucket, key, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteFromS3 succeeds for a key that does not exist, like s3 does
+func (c *filesystemClient) DeleteFromS3(_ context.Context, bucket, key string) error {
+	if err := os.Remove(c.pathOf(bucket, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ListObjectsInS3 returns the keys in the order s3 lists them, a bucket that was never written to is empty
+func (c *filesystemClient) ListObjectsInS3(_ context.Context, bucket, prefix string) ([]string, error) {
+	bucketDir := filepath.Join(c.rootDir, bucket)
+	var keys []string
+	err := filepath.WalkDir(bucketDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == bucketDir {
+			return fs.SkipAll
+		}
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		rel, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (c *filesystemClient) PaginateObjectsInS3(bucket, prefix string) ObjectPages {
+	return &filesystemPages{client: c, bucket: bucket, prefix: prefix}
+}
+
+func (c *filesystemClient) AnyObjectInS3(ctx context.Context, bucket, prefix string) (bool, error) {
+	keys, err := c.ListObjectsInS3(ctx, bucket, prefix)
+	return len(keys) > 0, err
+}
+
+func (c *filesystemClient) GetObjectMetadataInS3(
+	ctx context.Context,
+	bucket, key, metadataKey string,
+) (*string, error) {
+	if _, err := c.HeadObjectInS3(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMetadataKeyNotFound, metadataKey)
+}
+
+func (c *filesystemClient) HeadObjectInS3(_ context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := os.Stat(c.pathOf(bucket, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: s3://%s/%s", ErrObjectNotFound, bucket, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+func (c *filesystemClient) GetSecretFromSecretsManager(_ context.Context, secretKey string) (interface{}, error) {
+	return nil, fmt.Errorf("the secret %s is %w", secretKey, ErrNotAvailableOffline)
+}
+
+func (c *filesystemClient) PublishMessageToSQS(_ context.Context, queueName string, _ interface{}) error {
+	return fmt.Errorf("the queue %s is %w", queueName, ErrNotAvailableOffline)
+}
+
+func (c *filesystemClient) pathOf(bucket, key string) string {
+	return filepath.Join(c.rootDir, bucket, filepath.FromSlash(key))
+}
+
+// noSuchKey is the error s3 answers a get of a missing key with, the repos recognise it as a missing object
+func noSuchKey(bucket, key string) error {
+	return &s3types.NoSuchKey{Message: aws.String(fmt.Sprintf("s3://%s/%s does not exist", bucket, key))}
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// filesystemPages lists the whole prefix as a single page
+type filesystemPages struct {
+	client *filesystemClient
+	bucket string
+	prefix string
+	done   bool
+}
+
+func (p *filesystemPages) HasMorePages() bool {
+	return !p.done
+}
+
+func (p *filesystemPages) NextPage(ctx context.Context) ([]string, error) {
+	p.done = true
+	return p.client.ListObjectsInS3(ctx, p.bucket, p.prefix)
+}
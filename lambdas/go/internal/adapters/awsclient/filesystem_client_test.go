@@ -0,0 +1,92 @@
+package awsclient
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystemClient(t *testing.T) {
+	ctx := context.Background()
+	client := NewFilesystemAWSClient(t.TempDir())
+
+	require.NoError(t, client.UploadToS3(ctx, "cdn", "tiles/a/5/18/10.png", strings.NewReader("tile")))
+	require.NoError(t, client.UploadToS3(ctx, "cdn", "map-options.json", strings.NewReader("[]")))
+	require.NoError(t, client.UploadToS3(ctx, "status", "a.json", strings.NewReader("{}")))
+
+	data, err := client.GetFromS3(ctx, "cdn", "tiles/a/5/18/10.png")
+	require.NoError(t, err)
+	assert.Equal(t, "tile", string(data))
+	keys, err := client.ListObjectsInS3(ctx, "cdn", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"map-options.json", "tiles/a/5/18/10.png"}, keys)
+	exists, err := client.AnyObjectInS3(ctx, "cdn", "tiles/b/")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	info, err := client.HeadObjectInS3(ctx, "cdn", "map-options.json")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Size)
+
+	require.NoError(t, client.DeleteFromS3(ctx, "cdn", "map-options.json"))
+	require.NoError(t, client.DeleteFromS3(ctx, "cdn", "map-options.json"))
+	_, err = client.GetFromS3(ctx, "cdn", "map-options.json")
+	var nsk *s3types.NoSuchKey
+	assert.True(t, errors.As(err, &nsk), "a missing object is reported like s3 reports it")
+	_, err = client.HeadObjectInS3(ctx, "cdn", "map-options.json")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestFilesystemClient_UnknownBucketIsEmpty(t *testing.T) {
+	client := NewFilesystemAWSClient(t.TempDir())
+
+	keys, err := client.ListObjectsInS3(context.Background(), "status", "")
+
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestFilesystemClient_Download(t *testing.T) {
+	ctx := context.Background()
+	client := NewFilesystemAWSClient(t.TempDir())
+	require.NoError(t, client.UploadToS3(ctx, "raw", "a.tif", strings.NewReader("tif")))
+	localPath := filepath.Join(t.TempDir(), "a.tif")
+
+	n, err := client.Download(ctx, "raw", "a.tif", localPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	_, err = client.Download(ctx, "raw", "b.tif", localPath)
+	assert.Error(t, err)
+	assert.NoFileExists(t, localPath)
+}
+
+func TestFilesystemClient_AWSOnlyServices(t *testing.T) {
+	client := NewFilesystemAWSClient(t.TempDir())
+
+	_, err := client.GetSecretFromSecretsManager(context.Background(), "mapbox")
+	assert.ErrorIs(t, err, ErrNotAvailableOffline)
+	assert.ErrorIs(t, client.PublishMessageToSQS(context.Background(), "queue", nil), ErrNotAvailableOffline)
+}
+
+func TestFilesystemClient_UploadToS3IfMatch(t *testing.T) {
+	ctx := context.Background()
+	client := NewFilesystemAWSClient(t.TempDir())
+
+	require.NoError(t, client.UploadToS3IfMatch(ctx, "raw", "manifest.json", "", []byte("v1")))
+	assert.ErrorIs(t, client.UploadToS3IfMatch(ctx, "raw", "manifest.json", "", []byte("v1")), ErrPreconditionFailed,
+		"an empty etag only creates the object")
+	_, etag, err := client.GetFromS3WithETag(ctx, "raw", "manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, client.UploadToS3IfMatch(ctx, "raw", "manifest.json", etag, []byte("v2")))
+	assert.ErrorIs(t, client.UploadToS3IfMatch(ctx, "raw", "manifest.json", etag, []byte("v3")), ErrPreconditionFailed)
+
+	data, err := client.GetFromS3(ctx, "raw", "manifest.json")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+}
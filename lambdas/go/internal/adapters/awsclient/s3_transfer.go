@@ -0,0 +1,152 @@
+package awsclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// multipartPartSize is the size of the parts of a multipart upload, s3 requires at least 5 MiB for every part but
+// the last one
+const multipartPartSize = 8 * 1024 * 1024
+
+// downloadAttempts is how often Download requests the object, the first request included, before it gives up
+const downloadAttempts = 5
+
+func (c *awsClient) uploadPartSize() int {
+	if c.partSize > 0 {
+		return c.partSize
+	}
+	return multipartPartSize
+}
+
+// uploadMultipart uploads the first part and then the rest of the data part by part, an upload that failed is
+// aborted so s3 does not keep the parts that were already uploaded
+func (c *awsClient) uploadMultipart(
+	ctx context.Context,
+	bucket, key, contentType string,
+	first []byte,
+	rest io.Reader,
+) error {
+	created, err := c.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: optionalString(contentType),
+	})
+	if err != nil {
+		return err
+	}
+	parts, err := c.uploadParts(ctx, bucket, key, created.UploadId, first, rest)
+	if err != nil {
+		_, abortErr := c.s3Client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		return errors.Join(err, abortErr)
+	}
+	_, err = c.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+func (c *awsClient) uploadParts(
+	ctx context.Context,
+	bucket, key string,
+	uploadID *string,
+	first []byte,
+	rest io.Reader,
+) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+	part := first
+	for partNumber := int32(1); len(part) > 0; partNumber++ {
+		uploaded, err := c.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(bucket),
+			Key:        aws.String(key),
+			UploadId:   uploadID,
+			PartNumber: aws.Int32(partNumber),
+			Body:       bytes.NewReader(part),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("uploading part %d of %s: %w", partNumber, key, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(partNumber)})
+
+		n, err := io.ReadFull(rest, part[:cap(part)])
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		part = part[:n]
+	}
+	return parts, nil
+}
+
+func (c *awsClient) GetToWriter(ctx context.Context, bucket, key string, w io.Writer) (int64, error) {
+	return c.getRange(ctx, bucket, key, 0, "", w)
+}
+
+func (c *awsClient) Download(ctx context.Context, bucket, key, localPath string) (int64, error) {
+	info, err := c.HeadObjectInS3(ctx, bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var written int64
+	var lastErr error
+	for attempt := 0; attempt < downloadAttempts && written < info.Size && ctx.Err() == nil; attempt++ {
+		// The etag makes s3 refuse the range of an object that was replaced since the download started
+		n, err := c.getRange(ctx, bucket, key, written, info.ETag, file)
+		written += n
+		lastErr = err
+	}
+	if written != info.Size {
+		_ = os.Remove(localPath)
+		if lastErr == nil {
+			lastErr = ctx.Err()
+		}
+		return written, fmt.Errorf("downloaded %d of the %d bytes of %s: %v", written, info.Size, key, lastErr)
+	}
+	return written, nil
+}
+
+// getRange streams the object from the offset on, a request without an etag accepts any version of the object
+func (c *awsClient) getRange(
+	ctx context.Context,
+	bucket, key string,
+	offset int64,
+	etag string,
+	w io.Writer,
+) (int64, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+	result, err := c.s3Client.GetObject(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Body.Close()
+	return io.Copy(w, result.Body)
+}
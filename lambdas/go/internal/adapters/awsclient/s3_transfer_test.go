@@ -0,0 +1,243 @@
+package awsclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client that reaches the handler as its s3 endpoint
+func newTestClient(t *testing.T, handler http.HandlerFunc) *awsClient {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio-secret")
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := NewAWSClient(context.Background(), Config{S3Endpoint: server.URL, UsePathStyle: true})
+	require.NoError(t, err)
+	return client.(*awsClient)
+}
+
+// recorder keeps the requests the fake s3 received as "METHOD query body"
+type recorder struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *recorder) record(req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, strings.TrimSpace(req.Method+" "+req.URL.RawQuery+" "+string(body)))
+}
+
+func TestUploadToS3_SmallDataIsPutAtOnce(t *testing.T) {
+	var requests recorder
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+	})
+	client.partSize = 8
+
+	require.NoError(t, client.UploadToS3(context.Background(), "bucket", "key", strings.NewReader("small")))
+
+	assert.Equal(t, []string{"PUT x-id=PutObject small"}, requests.requests)
+}
+
+func TestUploadToS3_SmallDataDoesNotAllocateAPart(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	client.partSize = 512 * 1024 * 1024
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	require.NoError(t, client.UploadToS3(context.Background(), "bucket", "key", strings.NewReader("a png tile")))
+
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(client.partSize/8))
+}
+
+func TestUploadToS3_LargeDataIsUploadedInParts(t *testing.T) {
+	var requests recorder
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		switch {
+		case r.URL.Query().Has("uploads"):
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload-1</UploadId>` +
+				`</InitiateMultipartUploadResult>`))
+		case r.Method == http.MethodPut:
+			w.Header().Set("ETag", `"etag-`+r.URL.Query().Get("partNumber")+`"`)
+		default:
+			_, _ = w.Write([]byte(`<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`))
+		}
+	})
+	client.partSize = 4
+
+	require.NoError(t, client.UploadToS3(context.Background(), "bucket", "key", strings.NewReader("0123456789")))
+
+	require.Len(t, requests.requests, 5)
+	assert.Equal(t, "POST uploads=", requests.requests[0])
+	assert.Equal(t, "PUT partNumber=1&uploadId=upload-1&x-id=UploadPart 0123", requests.requests[1])
+	assert.Equal(t, "PUT partNumber=2&uploadId=upload-1&x-id=UploadPart 4567", requests.requests[2])
+	assert.Equal(t, "PUT partNumber=3&uploadId=upload-1&x-id=UploadPart 89", requests.requests[3])
+	assert.Contains(t, requests.requests[4], "POST uploadId=upload-1")
+	assert.Equal(t, 3, strings.Count(requests.requests[4], "<Part>"))
+}
+
+func TestUploadToS3_AFailedPartAbortsTheUpload(t *testing.T) {
+	var requests recorder
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.record(r)
+		switch {
+		case r.URL.Query().Has("uploads"):
+			_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>upload-1</UploadId>` +
+				`</InitiateMultipartUploadResult>`))
+		case r.URL.Query().Get("partNumber") == "2":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code></Error>`))
+		}
+	})
+	client.partSize = 4
+
+	err := client.UploadToS3(context.Background(), "bucket", "key", strings.NewReader("0123456789"))
+
+	assert.ErrorContains(t, err, "uploading part 2 of key")
+	assert.Equal(t, "DELETE uploadId=upload-1&x-id=AbortMultipartUpload", requests.requests[len(requests.requests)-1])
+}
+
+func TestDownload_ResumesAnInterruptedDownload(t *testing.T) {
+	content := "0123456789"
+	var ranges []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "10")
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Match"))
+		if r.Header.Get("Range") == "" {
+			// The connection drops after the first 4 bytes
+			w.Header().Set("Content-Length", "10")
+			_, _ = w.Write([]byte(content[:4]))
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(content[4:]))
+	})
+	localPath := filepath.Join(t.TempDir(), "map.tif")
+
+	written, err := client.Download(context.Background(), "bucket", "key", localPath)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), written)
+	assert.Equal(t, []string{` "v1"`, `bytes=4- "v1"`}, ranges)
+	data, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestDownload_RemovesAnIncompleteFile(t *testing.T) {
+	attempts := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		if r.Method == http.MethodHead {
+			return
+		}
+		attempts++
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		_ = conn.Close()
+	})
+	localPath := filepath.Join(t.TempDir(), "map.tif")
+
+	_, err := client.Download(context.Background(), "bucket", "key", localPath)
+
+	assert.ErrorContains(t, err, "downloaded 0 of the 10 bytes of key")
+	assert.Equal(t, downloadAttempts, attempts)
+	assert.NoFileExists(t, localPath)
+}
+
+func TestUploadToS3WithContentType(t *testing.T) {
+	var contentType string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+	})
+
+	require.NoError(t, client.UploadToS3WithContentType(context.Background(), "bucket", "0/0/0.png", "image/png",
+		strings.NewReader("png")))
+
+	assert.Equal(t, "image/png", contentType)
+}
+
+func TestHeadObjectInS3_MissingObject(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := client.HeadObjectInS3(context.Background(), "bucket", "key")
+
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestAnyObjectInS3_ListsASingleKey(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("max-keys"))
+		keys := ""
+		if r.URL.Query().Get("prefix") == "tiles/a/" {
+			keys = "<Contents><Key>tiles/a/5/18/10.png</Key></Contents>"
+		}
+		_, _ = io.WriteString(w, `<ListBucketResult>`+keys+`</ListBucketResult>`)
+	})
+
+	exists, err := client.AnyObjectInS3(context.Background(), "bucket", "tiles/a/")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = client.AnyObjectInS3(context.Background(), "bucket", "tiles/b/")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestUploadToS3IfMatch(t *testing.T) {
+	var conditions []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-Match")+r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-Match") == `"stale"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`<Error><Code>PreconditionFailed</Code></Error>`))
+		}
+	})
+
+	require.NoError(t, client.UploadToS3IfMatch(context.Background(), "bucket", "manifest.json", "", []byte("{}")))
+	require.NoError(t, client.UploadToS3IfMatch(context.Background(), "bucket", "manifest.json", `"v1"`, []byte("{}")))
+	err := client.UploadToS3IfMatch(context.Background(), "bucket", "manifest.json", `"stale"`, []byte("{}"))
+
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+	assert.Equal(t, []string{"*", `"v1"`, `"stale"`}, conditions)
+}
+
+func TestGetFromS3WithETag(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("{}"))
+	})
+
+	data, etag, err := client.GetFromS3WithETag(context.Background(), "bucket", "manifest.json")
+
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+	assert.Equal(t, `"v1"`, etag)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +16,11 @@ import (
 	"github.com/gocolly/colly"
 )
 
+var (
+	dailyDatePattern  = regexp.MustCompile(`_d([0-9]{8})[._]`)
+	annualYearPattern = regexp.MustCompile(`_([0-9]{4})_global_`)
+)
+
 type eogdataRepo struct {
 	logger  ports.Logger
 	scraper *colly.Collector
@@ -39,7 +46,12 @@ func (repo *eogdataRepo) List(ctx context.Context, bounds domain.Bounds, mapType
 		return nil, err
 	}
 
-	var urls []string
+	urlToScrape, err := mapTypeToUrl(mapType)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
 	repo.scraper.OnRequest(func(r *colly.Request) {
 		repo.logger.Debug(ctx, "Visiting url", "url", r.URL.String())
 	})
@@ -47,23 +59,83 @@ func (repo *eogdataRepo) List(ctx context.Context, bounds domain.Bounds, mapType
 	repo.scraper.OnHTML("tr.even, tr.odd", func(e *colly.HTMLElement) {
 		url := e.ChildAttr("td.indexcolicon > a", "href")
 		url = e.Request.AbsoluteURL(url)
-		if isTgzLink(url) {
-			urls = append(urls, url)
-		} else {
+		if isSubdirectoryLink(url, *urlToScrape) {
 			if err := repo.scraper.Visit(url); err != nil {
 				repo.logger.Error(ctx, "Error when trying to visit a URL", "error", err, "url", url)
 			}
+			return
 		}
+		links = append(links, url)
 	})
-	urlToScrape, err := mapTypeToUrl(mapType)
-	if err != nil {
-		return nil, err
-	}
 	if err := repo.scraper.Visit(*urlToScrape); err != nil {
 		repo.logger.Error(ctx, "Error when trying to visit a URL", "error", err, "url", *urlToScrape)
 		return nil, err
 	}
+	return mapsFromLinks(links, bounds, mapType, *tileId)
+}
+
+// mapsFromLinks turns the files of the index pages into maps, the coverage rasters are attached to their maps
+func mapsFromLinks(
+	links []string,
+	bounds domain.Bounds,
+	mapType domain.MapType,
+	tileId EogdataTileId,
+) ([]domain.Map, error) {
+	var urls, coverageUrls []string
+	for _, link := range links {
+		if isCoverageLink(link) {
+			coverageUrls = append(coverageUrls, link)
+		} else if isSourceLink(link, mapType) {
+			urls = append(urls, link)
+		}
+	}
+
+	var sourceMaps []domain.Map
+	var err error
+	switch mapType {
+	case domain.MapTypeDaily:
+		sourceMaps, err = dailyMapsFromLinks(urls, bounds)
+	case domain.MapTypeAnnual:
+		sourceMaps, err = annualMapsFromLinks(urls, bounds)
+	default:
+		sourceMaps, err = monthlyMapsFromLinks(urls, bounds, tileId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	attachCoverageLinks(sourceMaps, coverageUrls)
+	return sourceMaps, nil
+}
+
+// attachCoverageLinks pairs every map with the cloud-free coverage raster that is published next to its composite,
+// the two share the directory and the filename up to the product suffix. The monthly composites are not published
+// next to their coverage raster, both are in the same tgz so the archive is the coverage link
+func attachCoverageLinks(sourceMaps []domain.Map, coverageLinks []string) {
+	coverageLinkPerStem := make(map[string]string, len(coverageLinks))
+	for _, link := range coverageLinks {
+		coverageLinkPerStem[linkStem(link)] = link
+	}
+	for i := range sourceMaps {
+		link := sourceMaps[i].Source.URL
+		coverageLink, ok := coverageLinkPerStem[linkStem(link)]
+		if !ok && isTgzLink(link) {
+			coverageLink = link
+		}
+		sourceMaps[i].Source.CoverageURL = coverageLink
+	}
+}
+
+// linkStem strips the product suffixes from the filename of the link,
+// e.g. .../SVDNB_npp_d20230224.rade9d.tif becomes .../SVDNB_npp_d20230224
+func linkStem(link string) string {
+	directory, filename := path.Split(link)
+	if i := strings.Index(filename, "."); i >= 0 {
+		filename = filename[:i]
+	}
+	return directory + filename
+}
 
+func monthlyMapsFromLinks(urls []string, bounds domain.Bounds, tileId EogdataTileId) ([]domain.Map, error) {
 	var sourceMaps []domain.Map
 	for _, url := range urls {
 		if getTileId(url) == tileId.String() &&
@@ -72,42 +144,88 @@ func (repo *eogdataRepo) List(ctx context.Context, bounds domain.Bounds, mapType
 			if err != nil {
 				return nil, err
 			}
-			sourceMaps = append(sourceMaps, domain.Map{
-				Date:    *date,
-				MapType: mapType,
-				Bounds:  bounds,
-				Source: domain.MapSource{
-					MapProvider: domain.MapProviderEogdata,
-					URL:         url,
-				},
-			})
+			sourceMaps = append(sourceMaps, newEogdataMap(url, *date, domain.MapTypeMonthly, bounds))
+		}
+	}
+	return sourceMaps, nil
+}
+
+// dailyMapsFromLinks returns one map per night, the nightly composites are global so there is no tile to filter on,
+// but a night can be covered by more than one satellite in which case the satellite with the longest record is used.
+func dailyMapsFromLinks(urls []string, bounds domain.Bounds) ([]domain.Map, error) {
+	linkPerNight := make(map[domain.Date]string)
+	for _, url := range urls {
+		date, err := extractDateFromDailyLink(url)
+		if err != nil {
+			return nil, err
+		}
+		existing, ok := linkPerNight[*date]
+		if !ok || satelliteRank(url) < satelliteRank(existing) {
+			linkPerNight[*date] = url
+		}
+	}
+
+	sourceMaps := make([]domain.Map, 0, len(linkPerNight))
+	for date, url := range linkPerNight {
+		sourceMaps = append(sourceMaps, newEogdataMap(url, date, domain.MapTypeDaily, bounds))
+	}
+	sort.Slice(sourceMaps, func(i, j int) bool {
+		return dateToTime(sourceMaps[i].Date).Before(dateToTime(sourceMaps[j].Date))
+	})
+	return sourceMaps, nil
+}
+
+// annualMapsFromLinks returns one map per year, the annual composites are global and are sometimes reprocessed,
+// in which case the link with the latest creation date (the c suffix in the filename) is used.
+func annualMapsFromLinks(urls []string, bounds domain.Bounds) ([]domain.Map, error) {
+	linkPerYear := make(map[int]string)
+	for _, url := range urls {
+		year, err := extractYearFromAnnualLink(url)
+		if err != nil {
+			return nil, err
 		}
+		if existing, ok := linkPerYear[year]; !ok || path.Base(url) > path.Base(existing) {
+			linkPerYear[year] = url
+		}
+	}
+
+	sourceMaps := make([]domain.Map, 0, len(linkPerYear))
+	for year, url := range linkPerYear {
+		sourceMaps = append(sourceMaps, newEogdataMap(url, domain.NewAnnualDate(year), domain.MapTypeAnnual, bounds))
 	}
+	sort.Slice(sourceMaps, func(i, j int) bool {
+		return sourceMaps[i].Date.Year < sourceMaps[j].Date.Year
+	})
 	return sourceMaps, nil
 }
 
+func newEogdataMap(url string, date domain.Date, mapType domain.MapType, bounds domain.Bounds) domain.Map {
+	return domain.Map{
+		Date:    date,
+		MapType: mapType,
+		Bounds:  bounds,
+		Source: domain.MapSource{
+			MapProvider: domain.MapProviderEogdata,
+			URL:         url,
+		},
+	}
+}
+
 // EogdataTileId denotes one of the 6 tiles that the MapProviderEogdata service uses
-// our bounding shape file needs to be mapped to the tile that it is apart of.
+// our bounding shape file needs to be mapped to the tile that it is apart of, this mapping is defined in the
+// domain.BoundsRegistry.
 type EogdataTileId string
 
-const (
-	EOGDATATILEID_TILE2 EogdataTileId = "75N060W"
-)
-
 func (id EogdataTileId) String() string {
 	return string(id)
 }
 
 func boundsToTileID(bounds domain.Bounds) (*EogdataTileId, error) {
-	var tileId EogdataTileId
-	switch bounds {
-	case domain.BoundsUkraineAndAround:
-		tileId = EOGDATATILEID_TILE2
-	case domain.BoundsGazaAndAround:
-		tileId = EOGDATATILEID_TILE2
-	default:
+	definition, ok := domain.GetBoundsRegistry().Get(bounds)
+	if !ok {
 		return nil, fmt.Errorf("unknown Bounds: %s", bounds)
 	}
+	tileId := EogdataTileId(definition.EogdataTile)
 	return &tileId, nil
 }
 
@@ -118,6 +236,8 @@ func mapTypeToUrl(mapType domain.MapType) (*string, error) {
 		urlToScrape = "https://eogdata.mines.edu/nighttime_light/nightly/rade9d/"
 	case domain.MapTypeMonthly:
 		urlToScrape = "https://eogdata.mines.edu/nighttime_light/monthly/v10/"
+	case domain.MapTypeAnnual:
+		urlToScrape = "https://eogdata.mines.edu/nighttime_light/annual/v22/"
 	default:
 		return nil, errors.New("unknown map type")
 	}
@@ -141,12 +261,82 @@ func extractDateFromMonthlyLink(link string) (*domain.Date, error) {
 	}, nil
 }
 
+// extractDateFromDailyLink extracts the night from a rade9d filename, e.g. SVDNB_npp_d20230224.rade9d.tif,
+// only the filename is used because the nightly index is not nested in the same way as the monthly one.
+func extractDateFromDailyLink(link string) (*domain.Date, error) {
+	match := dailyDatePattern.FindStringSubmatch(path.Base(link))
+	if match == nil {
+		return nil, errors.New("the link did not contain a nightly date: " + link)
+	}
+	night, err := time.Parse("20060102", match[1])
+	if err != nil {
+		return nil, errors.New("Error while extracting the night. Error: " + err.Error())
+	}
+	return &domain.Date{
+		Year:  night.Year(),
+		Month: night.Month(),
+		Day:   night.Day(),
+	}, nil
+}
+
+// extractYearFromAnnualLink extracts the year from a VNL v2 filename,
+// e.g. VNL_v22_npp-j01_2022_global_vcmslcfg_c202303062300.average_masked.dat.tif.gz
+func extractYearFromAnnualLink(link string) (int, error) {
+	match := annualYearPattern.FindStringSubmatch(path.Base(link))
+	if match == nil {
+		return 0, errors.New("the link did not contain a year: " + link)
+	}
+	year, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, errors.New("Error while extracting the year. Error: " + err.Error())
+	}
+	return year, nil
+}
+
 func getTileId(link string) string {
 	r, _ := regexp.Compile("[0-9]{2}[NS][0-9]{3}[EW]")
 	return r.FindString(link)
 }
 
+func isSourceLink(link string, mapType domain.MapType) bool {
+	switch mapType {
+	case domain.MapTypeDaily:
+		return strings.HasSuffix(link, ".rade9d.tif")
+	case domain.MapTypeAnnual:
+		return strings.HasSuffix(link, ".average_masked.dat.tif.gz")
+	default:
+		return isTgzLink(link)
+	}
+}
+
+// isCoverageLink matches the cloud-free coverage rasters,
+// e.g. SVDNB_npp_20230101-20230131_75N060W_vcmcfg_v10_c202302080600.cf_cvg.tif
+func isCoverageLink(link string) bool {
+	return strings.Contains(path.Base(link), ".cf_cvg.") &&
+		(strings.HasSuffix(link, ".tif") || strings.HasSuffix(link, ".tif.gz"))
+}
+
+// isSubdirectoryLink prevents the scraper from following the parent directory links of the index pages
+func isSubdirectoryLink(link string, root string) bool {
+	return strings.HasSuffix(link, "/") && strings.HasPrefix(link, root) && link != root
+}
+
 func isTgzLink(link string) bool {
 	s := strings.Split(link, ".")
 	return len(s) > 0 && s[len(s)-1] == "tgz"
 }
+
+// satelliteRank orders the satellites that produce nightly composites, lower is preferred
+func satelliteRank(link string) int {
+	satellites := []string{"_npp_", "_j01_", "_j02_"}
+	for i, satellite := range satellites {
+		if strings.Contains(path.Base(link), satellite) {
+			return i
+		}
+	}
+	return len(satellites)
+}
+
+func dateToTime(d domain.Date) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
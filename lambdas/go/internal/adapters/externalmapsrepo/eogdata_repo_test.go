@@ -2,6 +2,11 @@ package externalmapsrepo
 
 import (
 	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEogdataRepo_GetTileId(t *testing.T) {
@@ -32,3 +37,150 @@ func TestEogdataRepo_GetTileId(t *testing.T) {
 		})
 	}
 }
+
+func TestEogdataRepo_BoundsToTileID(t *testing.T) {
+	tileId, err := boundsToTileID(domain.BoundsGazaAndAround)
+	if err != nil {
+		t.Fatalf("Expected no error but got %s", err)
+	}
+	if tileId.String() != "75N060W" {
+		t.Errorf("Expected 75N060W but got %s", tileId)
+	}
+	if _, err := boundsToTileID(domain.Bounds(999)); err == nil {
+		t.Errorf("Expected an error for bounds that are not in the registry")
+	}
+}
+
+func TestEogdataRepo_ExtractDateFromDailyLink(t *testing.T) {
+	testCases := []struct {
+		link     string
+		expected domain.Date
+	}{
+		{
+			link:     "https://eogdata.mines.edu/nighttime_light/nightly/rade9d/SVDNB_npp_d20220224.rade9d.tif",
+			expected: domain.Date{Day: 24, Month: time.February, Year: 2022},
+		},
+		{
+			link:     "https://eogdata.mines.edu/nighttime_light/nightly/rade9d/2023/SVDNB_j01_d20231007_t2259443_e0005049_b30358_c20231008005436.rade9d.tif",
+			expected: domain.Date{Day: 7, Month: time.October, Year: 2023},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.link, func(t *testing.T) {
+			date, err := extractDateFromDailyLink(tc.link)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, *date)
+		})
+	}
+
+	_, err := extractDateFromDailyLink("https://eogdata.mines.edu/nighttime_light/nightly/rade9d/README.txt")
+	assert.Error(t, err)
+}
+
+func TestEogdataRepo_DailyMapsFromLinks(t *testing.T) {
+	root := "https://eogdata.mines.edu/nighttime_light/nightly/rade9d/"
+	links := []string{
+		root + "SVDNB_j01_d20220225.rade9d.tif",
+		root + "SVDNB_j01_d20220224.rade9d.tif",
+		root + "SVDNB_npp_d20220224.rade9d.tif",
+	}
+
+	maps, err := dailyMapsFromLinks(links, domain.BoundsUkraineAndAround)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Map{
+		{
+			Date:    domain.Date{Day: 24, Month: time.February, Year: 2022},
+			MapType: domain.MapTypeDaily,
+			Bounds:  domain.BoundsUkraineAndAround,
+			Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: links[2]},
+		},
+		{
+			Date:    domain.Date{Day: 25, Month: time.February, Year: 2022},
+			MapType: domain.MapTypeDaily,
+			Bounds:  domain.BoundsUkraineAndAround,
+			Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: links[0]},
+		},
+	}, maps)
+}
+
+func TestEogdataRepo_IsSubdirectoryLink(t *testing.T) {
+	root := "https://eogdata.mines.edu/nighttime_light/nightly/rade9d/"
+	assert.True(t, isSubdirectoryLink(root+"2023/", root))
+	assert.False(t, isSubdirectoryLink("https://eogdata.mines.edu/nighttime_light/nightly/", root))
+	assert.False(t, isSubdirectoryLink(root, root))
+	assert.False(t, isSubdirectoryLink(root+"SVDNB_npp_d20220224.rade9d.tif", root))
+}
+
+func TestEogdataRepo_AnnualMapsFromLinks(t *testing.T) {
+	root := "https://eogdata.mines.edu/nighttime_light/annual/v22/"
+	links := []string{
+		root + "2022/VNL_v22_npp-j01_2022_global_vcmslcfg_c202303062300.average_masked.dat.tif.gz",
+		root + "2021/VNL_v21_npp_2021_global_vcmslcfg_c202205302300.average_masked.dat.tif.gz",
+		root + "2021/VNL_v22_npp_2021_global_vcmslcfg_c202303062300.average_masked.dat.tif.gz",
+	}
+
+	maps, err := annualMapsFromLinks(links, domain.BoundsUkraineAndAround)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Map{
+		{
+			Date:    domain.NewAnnualDate(2021),
+			MapType: domain.MapTypeAnnual,
+			Bounds:  domain.BoundsUkraineAndAround,
+			Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: links[2]},
+		},
+		{
+			Date:    domain.NewAnnualDate(2022),
+			MapType: domain.MapTypeAnnual,
+			Bounds:  domain.BoundsUkraineAndAround,
+			Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: links[0]},
+		},
+	}, maps)
+
+	assert.True(t, isSourceLink(links[0], domain.MapTypeAnnual))
+	assert.False(t, isSourceLink(root+"2022/VNL_v22_npp-j01_2022_global_vcmslcfg_c202303062300.cf_cvg.dat.tif.gz", domain.MapTypeAnnual))
+}
+
+func TestEogdataRepo_AttachCoverageLinks(t *testing.T) {
+	monthly := "https://eogdata.mines.edu/nighttime_light/monthly/v10/2023/202301/vcmcfg/"
+	annual := "https://eogdata.mines.edu/nighttime_light/annual/v22/2022/"
+	sourceMaps := []domain.Map{
+		{Source: domain.MapSource{URL: monthly + "SVDNB_npp_20230101-20230131_75N060W_vcmcfg_v10_c202302080600.tgz"}},
+		{Source: domain.MapSource{URL: annual + "VNL_v22_npp-j01_2022_global_vcmslcfg_c202303062300.average_masked.dat.tif.gz"}},
+		{Source: domain.MapSource{URL: monthly + "SVDNB_npp_20230101-20230131_00N060W_vcmcfg_v10_c202302080600.tgz"}},
+	}
+	coverageLinks := []string{
+		monthly + "SVDNB_npp_20230101-20230131_75N060W_vcmcfg_v10_c202302080600.cf_cvg.tif",
+		annual + "VNL_v22_npp-j01_2022_global_vcmslcfg_c202303062300.cf_cvg.dat.tif.gz",
+		// The same filename in another directory belongs to another composite
+		annual + "other/SVDNB_npp_20230101-20230131_00N060W_vcmcfg_v10_c202302080600.cf_cvg.tif",
+	}
+	for _, link := range coverageLinks {
+		assert.True(t, isCoverageLink(link), link)
+		assert.False(t, isSourceLink(link, domain.MapTypeMonthly), link)
+	}
+
+	attachCoverageLinks(sourceMaps, coverageLinks)
+
+	assert.Equal(t, coverageLinks[0], sourceMaps[0].Source.CoverageURL)
+	assert.Equal(t, coverageLinks[1], sourceMaps[1].Source.CoverageURL)
+	assert.Equal(t, sourceMaps[2].Source.URL, sourceMaps[2].Source.CoverageURL, "the archive holds the coverage")
+}
+
+func TestEogdataRepo_MapsFromMonthlyLinks(t *testing.T) {
+	// The files of https://eogdata.mines.edu/nighttime_light/monthly/v10/2023/202301/vcmcfg/, the coverage raster
+	// of every tile is only published inside its tgz
+	directory := "https://eogdata.mines.edu/nighttime_light/monthly/v10/2023/202301/vcmcfg/"
+	var links []string
+	for _, tile := range []string{"00N060E", "00N060W", "00N180W", "75N060E", "75N060W", "75N180W"} {
+		links = append(links, directory+"SVDNB_npp_20230101-20230131_"+tile+"_vcmcfg_v10_c202302080600.tgz")
+	}
+
+	maps, err := mapsFromLinks(links, domain.BoundsUkraineAndAround, domain.MapTypeMonthly, "75N060W")
+
+	require.NoError(t, err)
+	require.Len(t, maps, 1)
+	assert.Equal(t, domain.Date{Day: 1, Month: time.January, Year: 2023}, maps[0].Date)
+	assert.Equal(t, links[4], maps[0].Source.URL)
+	assert.Equal(t, links[4], maps[0].Source.CoverageURL)
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	conflict_nightlightv1 "github.com/BaronBonet/conflict-nightlight/generated/conflict_nightlight/v1"
@@ -14,10 +15,15 @@ import (
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+const (
+	monthlyDisplayNameLayout = "Jan 2006"
+	dailyDisplayNameLayout   = "2 Jan 2006"
+	annualDisplayNameLayout  = "2006"
+)
+
 type s3FrontendMapDataRepo struct {
 	logger     ports.Logger
 	awsClient  awsclient.AWSClient
@@ -26,16 +32,11 @@ type s3FrontendMapDataRepo struct {
 }
 
 func NewS3FrontendMapDataRepo(
-	ctx context.Context,
 	logger ports.Logger,
 	bucketName string,
 	objectKey string,
+	awsClient awsclient.AWSClient,
 ) ports.FrontendMapDataRepo {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-central-1"))
-	if err != nil {
-		logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
-	}
-	awsClient := awsclient.NewAWSClient(cfg)
 	return &s3FrontendMapDataRepo{logger: logger, bucketName: bucketName, objectKey: objectKey, awsClient: awsClient}
 }
 
@@ -64,10 +65,20 @@ func (repo *s3FrontendMapDataRepo) List(ctx context.Context) ([]domain.Published
 	for _, boundedMaps := range boundedMapOptionsList {
 		repo.logger.Debug(ctx, "Found bounded map", "bounds", boundedMaps.GetBounds())
 		for _, mapOptions := range boundedMaps.GetMapsOptions() {
-			publishedMaps = append(publishedMaps, domain.PublishedMap{
-				Map: prototransformers.ProtoToDomain(mapOptions.GetMap()),
-				Url: mapOptions.GetUrl(),
-			})
+			m, err := mapOptionsToDomain(mapOptions)
+			if err != nil {
+				repo.logger.Warn(ctx, "The map of the option could not be identified", "key", mapOptions.GetKey())
+				continue
+			}
+			publishedMap := domain.PublishedMap{
+				Map:           m,
+				Url:           mapOptions.GetUrl(),
+				LowConfidence: mapOptions.GetLowConfidence(),
+			}
+			if coverage := mapOptions.GetCloudFreeCoverage(); coverage > 0 {
+				publishedMap.CloudFreeCoverage = &coverage
+			}
+			publishedMaps = append(publishedMaps, publishedMap)
 		}
 	}
 	return publishedMaps, nil
@@ -83,34 +94,32 @@ func (repo *s3FrontendMapDataRepo) Delete(ctx context.Context, m domain.Map) err
 		return err
 	}
 
-	mapKey := m.String()
+	mapID := m.ID()
 	found := false
-	// Iterate through each bounded map options
-	for i, boundedMaps := range boundedMapOptionsList {
-		mapOptions := boundedMaps.GetMapsOptions()
-		for j, option := range mapOptions {
-			if option.GetKey() == mapKey {
-				// Remove the map option from the slice
-				boundedMaps.MapsOptions = append(mapOptions[:j], mapOptions[j+1:]...)
+	remainingBoundedMapOptions := boundedMapOptionsList[:0]
+	for _, boundedMaps := range boundedMapOptionsList {
+		// Remove every option of the map, options written in the legacy format can exist next to the new ones
+		remainingOptions := boundedMaps.GetMapsOptions()[:0]
+		for _, option := range boundedMaps.GetMapsOptions() {
+			if matchesMapID(option, mapID) {
 				found = true
-				// If this was the last map option in this bounds, remove the entire bounded map option
-				if len(boundedMaps.GetMapsOptions()) == 0 {
-					boundedMapOptionsList = append(boundedMapOptionsList[:i], boundedMapOptionsList[i+1:]...)
-				}
-				break
+			} else {
+				remainingOptions = append(remainingOptions, option)
 			}
 		}
-		if found {
-			break
+		boundedMaps.MapsOptions = remainingOptions
+		// If this was the last map option in this bounds, remove the entire bounded map option
+		if len(boundedMaps.GetMapsOptions()) > 0 {
+			remainingBoundedMapOptions = append(remainingBoundedMapOptions, boundedMaps)
 		}
 	}
 
 	if !found {
-		repo.logger.Info(ctx, "The map was not found in the json file", "map", mapKey)
+		repo.logger.Info(ctx, "The map was not found in the json file", "map", mapID.String())
 		return nil
 	}
 
-	jsonForFrontend, err = json.Marshal(boundedMapOptionsList)
+	jsonForFrontend, err = json.Marshal(remainingBoundedMapOptions)
 	if err != nil {
 		repo.logger.Error(ctx, "Error when marshalling the json file", "error", err)
 		return err
@@ -126,6 +135,32 @@ func (repo *s3FrontendMapDataRepo) Delete(ctx context.Context, m domain.Map) err
 	return nil
 }
 
+// PlanDelete returns the keys of the frontend entries that belong to the map
+func (repo *s3FrontendMapDataRepo) PlanDelete(ctx context.Context, m domain.Map) ([]string, error) {
+	jsonForFrontend, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var boundedMapOptionsList []*conflict_nightlightv1.BoundedMapOptions
+	if err = json.Unmarshal(jsonForFrontend, &boundedMapOptionsList); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling s3 object content", "error", err)
+		return nil, err
+	}
+	var keys []string
+	for _, boundedMaps := range boundedMapOptionsList {
+		for _, option := range boundedMaps.GetMapsOptions() {
+			if matchesMapID(option, m.ID()) {
+				keys = append(keys, option.GetKey())
+			}
+		}
+	}
+	return keys, nil
+}
+
 func (repo *s3FrontendMapDataRepo) Upsert(ctx context.Context, m domain.PublishedMap) error {
 	jsonForFrontend, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
 
@@ -147,24 +182,63 @@ func (repo *s3FrontendMapDataRepo) Upsert(ctx context.Context, m domain.Publishe
 		}
 	}
 
-	date := time.Date(m.Map.Date.Year, time.Month(m.Map.Date.Month), m.Map.Date.Day, 0, 0, 0, 0, time.UTC)
-	protoMap := prototransformers.DomainToProto(m.Map)
+	boundedMapOptionsList = updateBoundedMapOptions(boundedMapOptionsList, newMapOptions(m))
+
+	updatedJSON, err := json.Marshal(boundedMapOptionsList)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when marshalling updated map options list", "error", err)
+		return err
+	}
 
-	newOption := conflict_nightlightv1.MapOptions{
-		DisplayName: fmt.Sprintf("%s %d", date.Format("Jan"), m.Map.Date.Year),
-		Url:         m.Url,
-		Key:         m.Map.String(),
-		Map:         &protoMap,
+	err = repo.awsClient.UploadToS3(ctx, repo.bucketName, repo.objectKey, bytes.NewReader(updatedJSON))
+	if err != nil {
+		repo.logger.Error(ctx, "Error when uploading updated json file back to s3", "error", err)
+		return err
+	}
+	return nil
+}
+
+// SetAnomalies replaces the anomalies of every map option, options of maps without anomalies are cleared
+func (repo *s3FrontendMapDataRepo) SetAnomalies(ctx context.Context, anomalies []domain.Anomaly) error {
+	jsonForFrontend, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get json file from s3", "error", err)
+		return err
+	}
+	var boundedMapOptionsList []*conflict_nightlightv1.BoundedMapOptions
+	if err = json.Unmarshal(jsonForFrontend, &boundedMapOptionsList); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling s3 object content", "error", err)
+		return err
 	}
 
-	boundedMapOptionsList = updateBoundedMapOptions(boundedMapOptionsList, &newOption)
+	flags := make(map[domain.MapID][]*conflict_nightlightv1.AnomalyFlag)
+	for _, anomaly := range anomalies {
+		flags[anomaly.ID] = append(flags[anomaly.ID], &conflict_nightlightv1.AnomalyFlag{
+			RegionId:   anomaly.RegionID,
+			RegionName: anomaly.RegionName,
+			Change:     anomaly.Change,
+		})
+	}
+	for _, boundedMaps := range boundedMapOptionsList {
+		for _, option := range boundedMaps.GetMapsOptions() {
+			m, err := mapOptionsToDomain(option)
+			if err != nil {
+				option.Anomalies = nil
+				continue
+			}
+			option.Anomalies = flags[m.ID()]
+		}
+	}
 
 	updatedJSON, err := json.Marshal(boundedMapOptionsList)
 	if err != nil {
 		repo.logger.Error(ctx, "Error when marshalling updated map options list", "error", err)
 		return err
 	}
-
 	err = repo.awsClient.UploadToS3(ctx, repo.bucketName, repo.objectKey, bytes.NewReader(updatedJSON))
 	if err != nil {
 		repo.logger.Error(ctx, "Error when uploading updated json file back to s3", "error", err)
@@ -173,6 +247,30 @@ func (repo *s3FrontendMapDataRepo) Upsert(ctx context.Context, m domain.Publishe
 	return nil
 }
 
+// EncodeMapOptions returns the json the frontend reads for the maps, in the format the repo stores it in
+func EncodeMapOptions(maps []domain.PublishedMap) ([]byte, error) {
+	boundedMapOptionsList := make([]*conflict_nightlightv1.BoundedMapOptions, 0)
+	for _, m := range maps {
+		boundedMapOptionsList = updateBoundedMapOptions(boundedMapOptionsList, newMapOptions(m))
+	}
+	return json.Marshal(boundedMapOptionsList)
+}
+
+func newMapOptions(m domain.PublishedMap) *conflict_nightlightv1.MapOptions {
+	protoMap := prototransformers.DomainToProto(m.Map)
+	option := &conflict_nightlightv1.MapOptions{
+		DisplayName:   createDisplayName(m.Map),
+		Url:           m.Url,
+		Key:           m.Map.ID().String(),
+		Map:           &protoMap,
+		LowConfidence: m.LowConfidence,
+	}
+	if m.CloudFreeCoverage != nil {
+		option.CloudFreeCoverage = *m.CloudFreeCoverage
+	}
+	return option
+}
+
 func updateBoundedMapOptions(
 	boundedMapOptions []*conflict_nightlightv1.BoundedMapOptions, newOption *conflict_nightlightv1.MapOptions,
 ) []*conflict_nightlightv1.BoundedMapOptions {
@@ -182,25 +280,43 @@ func updateBoundedMapOptions(
 			mapOptions := updateMapOptionsList(boundedMapOption.GetMapsOptions(), newOption)
 			found = true
 			boundedMapOptions[i].MapsOptions = mapOptions
+			setBoundsDefinition(boundedMapOptions[i])
 			break
 		}
 	}
 	if !found {
-		boundedMapOptions = append(boundedMapOptions, &conflict_nightlightv1.BoundedMapOptions{
+		boundedMapOption := &conflict_nightlightv1.BoundedMapOptions{
 			Bounds:      newOption.GetMap().GetBounds(),
 			MapsOptions: []*conflict_nightlightv1.MapOptions{newOption},
-		})
+		}
+		setBoundsDefinition(boundedMapOption)
+		boundedMapOptions = append(boundedMapOptions, boundedMapOption)
 	}
 	return boundedMapOptions
 }
 
+// setBoundsDefinition copies the region information from the bounds registry, so the frontend does not need to
+// know about every region in advance
+func setBoundsDefinition(boundedMapOption *conflict_nightlightv1.BoundedMapOptions) {
+	definition, ok := domain.GetBoundsRegistry().Get(domain.Bounds(boundedMapOption.GetBounds()))
+	if !ok {
+		return
+	}
+	boundedMapOption.DisplayName = definition.DisplayName
+	boundedMapOption.Bbox = definition.BBox[:]
+}
+
 func updateMapOptionsList(mapOptionsList []*conflict_nightlightv1.MapOptions,
 	newOption *conflict_nightlightv1.MapOptions) []*conflict_nightlightv1.MapOptions {
-	// Check if there is an object with the same key, and if there is,
+	// Check if there is an object for the same map, and if there is,
 	// replace that object with the new one in mapOptionsList
 	found := false
 	for i, option := range mapOptionsList {
-		if option.GetKey() == newOption.GetKey() {
+		if isSameMapOption(option, newOption) {
+			// The anomalies are attached separately, republishing a map keeps them
+			if newOption.Anomalies == nil {
+				newOption.Anomalies = option.GetAnomalies()
+			}
 			mapOptionsList[i] = newOption
 			found = true
 			break
@@ -210,11 +326,68 @@ func updateMapOptionsList(mapOptionsList []*conflict_nightlightv1.MapOptions,
 		mapOptionsList = append(mapOptionsList, newOption)
 	}
 	// Sort mapOptionsList by date
-	sort.Slice(mapOptionsList, func(i, j int) bool {
-		dateI, _ := time.Parse("Jan 2006", mapOptionsList[i].GetDisplayName())
-		dateJ, _ := time.Parse("Jan 2006", mapOptionsList[j].GetDisplayName())
-		return dateI.Before(dateJ)
+	sort.SliceStable(mapOptionsList, func(i, j int) bool {
+		return mapOptionsDate(mapOptionsList[i]).Before(mapOptionsDate(mapOptionsList[j]))
 	})
 
 	return mapOptionsList
 }
+
+// mapOptionsToDomain falls back to the key for options written before the map was part of the options
+func mapOptionsToDomain(option *conflict_nightlightv1.MapOptions) (domain.Map, error) {
+	if option.GetMap() != nil {
+		return prototransformers.ProtoToDomain(option.GetMap()), nil
+	}
+	id, err := domain.ParseMapIDOrLegacyName(option.GetKey())
+	if err != nil {
+		return domain.Map{}, err
+	}
+	return id.ToMap(""), nil
+}
+
+func isSameMapOption(option *conflict_nightlightv1.MapOptions, newOption *conflict_nightlightv1.MapOptions) bool {
+	if option.GetKey() == newOption.GetKey() {
+		return true
+	}
+	if newOption.GetMap() == nil {
+		return false
+	}
+	return matchesMapID(option, prototransformers.ProtoToDomain(newOption.GetMap()).ID())
+}
+
+// matchesMapID compares an option by the identity of its map, options written before the map was part of the
+// options only have their key to go on, which may still be in the legacy format
+func matchesMapID(option *conflict_nightlightv1.MapOptions, id domain.MapID) bool {
+	if option.GetMap() != nil {
+		return prototransformers.ProtoToDomain(option.GetMap()).ID() == id
+	}
+	return option.GetKey() == id.String() || option.GetKey() == id.LegacyName()
+}
+
+// createDisplayName creates the label shown in the frontend, it only includes the parts of the date that are
+// meaningful for the map type
+func createDisplayName(m domain.Map) string {
+	date := time.Date(m.Date.Year, m.Date.Month, m.Date.Day, 0, 0, 0, 0, time.UTC)
+	switch m.MapType {
+	case domain.MapTypeAnnual:
+		return strconv.Itoa(m.Date.Year)
+	case domain.MapTypeDaily:
+		return date.Format(dailyDisplayNameLayout)
+	default:
+		return fmt.Sprintf("%s %d", date.Format("Jan"), m.Date.Year)
+	}
+}
+
+// mapOptionsDate prefers the date of the map, options written before the map was part of the options only have
+// the display name to go on
+func mapOptionsDate(option *conflict_nightlightv1.MapOptions) time.Time {
+	if d := option.GetMap().GetDate(); d != nil {
+		return time.Date(int(d.GetYear()), time.Month(d.GetMonth()), int(d.GetDay()), 0, 0, 0, 0, time.UTC)
+	}
+	for _, layout := range []string{monthlyDisplayNameLayout, dailyDisplayNameLayout, annualDisplayNameLayout} {
+		if date, err := time.Parse(layout, option.GetDisplayName()); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
@@ -3,6 +3,7 @@ package frontendmapdatarepo
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -51,7 +53,7 @@ func TestS3FrontendMapDataRepo_Update(t *testing.T) {
 
 	t.Run("JSON found in S3 bucket and updated", func(t *testing.T) {
 		existingJSON := `[{"maps_options":[{"display_name":"Jan 2023","url":"mapbox://existing-tileset","key":"Daily-UkraineAnd_2023-1-1"}], "bounds": 2}]`
-		expectedJSON := `[{"maps_options":[{"display_name":"Jan 2023","url":"mapbox://existing-tileset","key":"Daily-UkraineAnd_2023-1-1"},{"display_name":"Feb 2023","url":"mapbox://test-tileset","key":"Daily-UkraineAnd_2023-2-1"}], "bounds": 2}]`
+		expectedJSON := `[{"maps_options":[{"display_name":"Jan 2023","url":"mapbox://existing-tileset","key":"Daily-UkraineAnd_2023-1-1"},{"display_name":"1 Feb 2023","url":"mapbox://test-tileset","key":"Daily-UkraineAnd_2023-2-1"}], "bounds": 2}]`
 
 		mockAWSClient.On("GetFromS3", ctx, "test-bucket", "test-key").Return([]byte(existingJSON), nil)
 		mockAWSClient.On("UploadToS3", ctx, "test-bucket", "test-key", mock.AnythingOfType("*bytes.Reader")).
@@ -121,6 +123,45 @@ func TestS3FrontendMapDataRepo_Delete(t *testing.T) {
 	})
 }
 
+func TestS3FrontendMapDataRepo_DeleteRemovesLegacyAndCurrentEntries(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mapRepo := &s3FrontendMapDataRepo{
+		logger:     ports.NewMockLogger(t),
+		awsClient:  mockAWSClient,
+		bucketName: "test-bucket",
+		objectKey:  "test-key",
+	}
+	testMap := domain.Map{
+		Date:    domain.Date{Day: 1, Month: 2, Year: 2023},
+		MapType: domain.MapTypeDaily,
+		Bounds:  domain.BoundsUkraineAndAround,
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+	}
+	existingJSON := `[{"maps_options":[` +
+		`{"display_name":"Feb 2023","url":"mapbox://legacy","key":"Daily-UkraineAnd_2023-2-1"},` +
+		`{"display_name":"Feb 2023","url":"mapbox://current","key":"eog-day-b1-20230201"}], "bounds": 1},` +
+		`{"maps_options":[{"display_name":"Feb 2023","url":"mapbox://gaza","key":"eog-day-b2-20230201"}], "bounds": 2}]`
+	expectedJSON := `[{"maps_options":[` +
+		`{"display_name":"Feb 2023","url":"mapbox://gaza","key":"eog-day-b2-20230201"}], "bounds": 2}]`
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "test-key").Return([]byte(existingJSON), nil)
+	mockAWSClient.On("UploadToS3", ctx, "test-bucket", "test-key", mock.AnythingOfType("*bytes.Reader")).
+		Run(func(args mock.Arguments) {
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(args.Get(3).(*bytes.Reader))
+			assert.JSONEq(t, expectedJSON, buf.String())
+		}).
+		Return(nil)
+
+	keys, err := mapRepo.PlanDelete(ctx, testMap)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Daily-UkraineAnd_2023-2-1", "eog-day-b1-20230201"}, keys)
+
+	require.NoError(t, mapRepo.Delete(ctx, testMap))
+	mockAWSClient.AssertNumberOfCalls(t, "UploadToS3", 1)
+}
+
 func TestS3FrontendMapDataRepo_List(t *testing.T) {
 	ctx := context.Background()
 	mockLogger := ports.NewMockLogger(t)
@@ -266,6 +307,60 @@ func TestS3FrontendMapDataRepo_updateMapOptionsList(t *testing.T) {
 	}
 }
 
+func TestS3FrontendMapDataRepo_createDisplayName(t *testing.T) {
+	date := domain.Date{Day: 24, Month: 2, Year: 2022}
+	assert.Equal(t, "Feb 2022", createDisplayName(domain.Map{MapType: domain.MapTypeMonthly, Date: date}))
+	assert.Equal(t, "24 Feb 2022", createDisplayName(domain.Map{MapType: domain.MapTypeDaily, Date: date}))
+	assert.Equal(
+		t,
+		"2022",
+		createDisplayName(domain.Map{MapType: domain.MapTypeAnnual, Date: domain.NewAnnualDate(2022)}),
+	)
+}
+
+func TestS3FrontendMapDataRepo_updateMapOptionsListSortsByMapDate(t *testing.T) {
+	annual := func(year uint32) *conflict_nightlightv1.Map {
+		return &conflict_nightlightv1.Map{Date: &conflict_nightlightv1.Date{Day: 1, Month: 1, Year: year}}
+	}
+	mapOptionsList := []*conflict_nightlightv1.MapOptions{
+		{DisplayName: "2023", Key: "Annual-UkraineAnd_2023-1-1", Map: annual(2023)},
+		{DisplayName: "2021", Key: "Annual-UkraineAnd_2021-1-1", Map: annual(2021)},
+	}
+	result := updateMapOptionsList(
+		mapOptionsList,
+		&conflict_nightlightv1.MapOptions{DisplayName: "2022", Key: "Annual-UkraineAnd_2022-1-1", Map: annual(2022)},
+	)
+	var displayNames []string
+	for _, option := range result {
+		displayNames = append(displayNames, option.GetDisplayName())
+	}
+	assert.Equal(t, []string{"2021", "2022", "2023"}, displayNames)
+}
+
+func TestS3FrontendMapDataRepo_updateMapOptionsListReplacesLegacyKeys(t *testing.T) {
+	m := domain.Map{
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: "https://example.com/new.tgz"},
+		Date:    domain.Date{Day: 1, Month: 2, Year: 2021},
+		MapType: domain.MapTypeMonthly,
+		Bounds:  domain.BoundsUkraineAndAround,
+	}
+	protoMap := prototransformers.DomainToProto(m)
+	newOption := &conflict_nightlightv1.MapOptions{
+		DisplayName: "Feb 2021",
+		Url:         "mapbox://example3",
+		Key:         m.ID().String(),
+		Map:         &protoMap,
+	}
+	mapOptionsList := []*conflict_nightlightv1.MapOptions{
+		{DisplayName: "Jan 2021", Url: "mapbox://example1", Key: "Monthly-UkraineAnd_2021-1-1"},
+		{DisplayName: "Feb 2021", Url: "mapbox://example2", Key: "Monthly-UkraineAnd_2021-2-1"},
+	}
+
+	result := updateMapOptionsList(mapOptionsList, newOption)
+	assert.Len(t, result, 2)
+	assert.Equal(t, newOption, result[1])
+}
+
 // TODO: implement this test
 // func TestS3FrontendMapDataRepo_updateBoundedMapOptions(t *testing.T) {
 // 	testCases := []*struct {
@@ -275,3 +370,62 @@ func TestS3FrontendMapDataRepo_updateMapOptionsList(t *testing.T) {
 // 		expected       []*conflict_nightlightv1.MapOptions
 // 	}{}
 // }
+
+func TestS3FrontendMapDataRepo_SetAnomalies(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mapRepo := &s3FrontendMapDataRepo{
+		logger:     ports.NewMockLogger(t),
+		awsClient:  mockAWSClient,
+		bucketName: "test-bucket",
+		objectKey:  "test-key",
+	}
+	flaggedMap := domain.Map{
+		Date:    domain.Date{Day: 1, Month: 2, Year: 2023},
+		MapType: domain.MapTypeDaily,
+		Bounds:  domain.BoundsUkraineAndAround,
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+	}
+	existingJSON := `[{"maps_options":[{"display_name":"Jan 2023","url":"mapbox://existing-tileset","key":"Daily-UkraineAnd_2023-1-1","anomalies":[{"change":-0.5}]},{"display_name":"Feb 2023","url":"mapbox://test-tileset","key":"Daily-UkraineAnd_2023-2-1"}], "bounds": 2}]`
+	expectedJSON := `[{"maps_options":[{"display_name":"Jan 2023","url":"mapbox://existing-tileset","key":"Daily-UkraineAnd_2023-1-1"},{"display_name":"Feb 2023","url":"mapbox://test-tileset","key":"Daily-UkraineAnd_2023-2-1","anomalies":[{"region_id":"kharkiv","region_name":"Kharkiv","change":-0.4}]}], "bounds": 2}]`
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "test-key").Return([]byte(existingJSON), nil)
+	mockAWSClient.On("UploadToS3", ctx, "test-bucket", "test-key", mock.AnythingOfType("*bytes.Reader")).
+		Run(func(args mock.Arguments) {
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(args.Get(3).(*bytes.Reader))
+			assert.JSONEq(t, expectedJSON, buf.String())
+		}).
+		Return(nil)
+
+	err := mapRepo.SetAnomalies(ctx, []domain.Anomaly{
+		{ID: flaggedMap.ID(), RegionID: "kharkiv", RegionName: "Kharkiv", Change: -0.4},
+	})
+	require.NoError(t, err)
+}
+
+func TestEncodeMapOptions(t *testing.T) {
+	march := domain.Map{
+		Date:    domain.Date{Day: 1, Month: 3, Year: 2022},
+		MapType: domain.MapTypeMonthly,
+		Bounds:  domain.BoundsUkraineAndAround,
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+	}
+	february := march
+	february.Date.Month = 2
+
+	data, err := EncodeMapOptions([]domain.PublishedMap{
+		{Map: march, Url: "http://localhost:8080/tiles/march/{z}/{x}/{y}.png"},
+		{Map: february, Url: "http://localhost:8080/tiles/february/{z}/{x}/{y}.png"},
+	})
+
+	require.NoError(t, err)
+	var boundedMapOptionsList []*conflict_nightlightv1.BoundedMapOptions
+	require.NoError(t, json.Unmarshal(data, &boundedMapOptionsList))
+	require.Len(t, boundedMapOptionsList, 1)
+	options := boundedMapOptionsList[0].GetMapsOptions()
+	require.Len(t, options, 2)
+	assert.Equal(t, "Feb 2022", options[0].GetDisplayName(), "the options are sorted by date")
+	assert.Equal(t, "http://localhost:8080/tiles/february/{z}/{x}/{y}.png", options[0].GetUrl())
+	assert.Equal(t, february.ID().String(), options[0].GetKey())
+}
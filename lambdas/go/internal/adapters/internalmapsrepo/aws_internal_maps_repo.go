@@ -4,22 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
-	"strings"
-	"sync"
-	"time"
 
 	conflict_nightlightv1 "github.com/BaronBonet/conflict-nightlight/generated/conflict_nightlight/v1"
 	awsclient "github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/mapkeys"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
 	"github.com/google/uuid"
 )
 
+// cloudFreeCoverageMetadataKey is the metadata the python lambda records the cloud-free coverage of the bounds in
+const cloudFreeCoverageMetadataKey = "cloud-free-coverage"
+
 type AWSMapsRepo struct {
 	bucket           Bucket
 	logger           ports.Logger
@@ -56,56 +55,46 @@ func (repo *AWSMapsRepo) List(
 	desiredMapType domain.MapType,
 ) ([]domain.Map, error) {
 
-	objects, err := repo.awsClient.ListObjectsInS3(ctx, repo.bucket.bucketName)
+	entries, _, err := repo.readManifest(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if objects == nil {
-		repo.logger.Warn(ctx, "There were no objects found in the s3 bucket", "bucketName", repo.bucket.bucketName)
-		return nil, nil
+	if entries == nil {
+		// Only the part of the bucket the filters select is listed, the filters are still applied to every key below
+		prefix := mapkeys.Prefix(desiredProvider, desiredBounds, desiredMapType)
+		repo.logger.Warn(ctx, "The bucket has no manifest, the objects are scanned instead, run rebuildManifest",
+			"bucketName", repo.bucket.bucketName, "prefix", prefix)
+		entries, err = repo.scan(ctx, prefix, desiredProvider, desiredBounds, desiredMapType)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var existingMaps []domain.Map
-	var wg sync.WaitGroup
-	mapChan := make(chan *domain.Map)
-
-	for _, objectKey := range objects {
-		wg.Add(1)
-		go func(objKey string) {
-			defer wg.Done()
-			processObjectKey(ctx, repo, objKey, desiredProvider, desiredBounds, desiredMapType, mapChan)
-		}(objectKey)
-	}
-
-	go func() {
-		wg.Wait()
-		close(mapChan)
-	}()
-
-	for resultMap := range mapChan {
-		existingMaps = append(existingMaps, *resultMap)
+	// Different keys, e.g. 2022_1_1.tif and 2022_01_01.tif, can decode to the same map
+	seen := make(map[domain.MapID]struct{})
+	for _, key := range sortedKeys(entries) {
+		id := repo.parseObjectKey(ctx, key)
+		if id == nil ||
+			!isDesiredObject(id.Provider, desiredProvider, id.Bounds, desiredBounds, id.MapType, desiredMapType) {
+			continue
+		}
+		if _, ok := seen[*id]; ok {
+			repo.logger.Warn(ctx, "More than one object was found for the same map", "map", id.String())
+			continue
+		}
+		seen[*id] = struct{}{}
+		existingMaps = append(existingMaps, id.ToMap(entries[key].SourceURL))
 	}
 	return sortMapsByDate(existingMaps), nil
 }
 
+// Download streams the tif straight into the temp file, so the size of a map is not limited by the memory
 func (repo *AWSMapsRepo) Download(ctx context.Context, m domain.Map) (*domain.LocalMap, error) {
-	key := createKeyFromMap(m)
+	key := mapkeys.FromMapID(m.ID())
 	localFilepath := fmt.Sprintf("%s/%s.tif", repo.tmpWriteDir, uuid.NewString())
-	data, err := repo.awsClient.GetFromS3(ctx, repo.bucket.bucketName, key)
-
-	if err != nil {
-		repo.logger.Error(ctx, "Couldn't download file", "key", "bucket", repo.bucket.bucketName, key, "error", err)
-		return nil, err
-	}
-	file, err := os.Create(localFilepath)
-	if err != nil {
-		repo.logger.Error(ctx, "Couldn't create file", "localFilepath", localFilepath, "error", err)
-		return nil, err
-	}
-	defer file.Close()
-	_, err = file.Write(data)
-	if err != nil {
-		repo.logger.Error(ctx, "Couldn't write contents from s3", "key", key, "error", err)
+	if _, err := repo.awsClient.Download(ctx, repo.bucket.bucketName, key, localFilepath); err != nil {
+		repo.logger.Error(ctx, "Couldn't download file", "bucket", repo.bucket.bucketName, "key", key, "error", err)
 		return nil, err
 	}
 	return &domain.LocalMap{
@@ -127,150 +116,44 @@ func (repo *AWSMapsRepo) Create(ctx context.Context, m domain.Map) error {
 }
 
 func (repo *AWSMapsRepo) Delete(ctx context.Context, m domain.Map) error {
-	key := createKeyFromMap(m)
+	key := mapkeys.FromMapID(m.ID())
 	err := repo.awsClient.DeleteFromS3(ctx, repo.bucket.bucketName, key)
 	if err != nil {
 		repo.logger.Error(ctx, "Couldn't delete file", "key", key, "error", err)
 		return err
 	}
-	return nil
+	return repo.removeFromManifest(ctx, key)
 }
 
-func extractDateFromKey(filePath string) (*domain.Date, error) {
-	fileName := filepath.Base(filePath)
-	parts := strings.Split(fileName, ".")[0]
-	dateParts := strings.Split(parts, "_")
-
-	year, err := strconv.Atoi(dateParts[0])
-	if err != nil {
-		return nil, errors.New("failed to parse year. error: " + err.Error())
-	}
-
-	month, err := strconv.Atoi(dateParts[1])
-	if err != nil {
-		return nil, errors.New("failed to parse month. error: " + err.Error())
+// PlanDelete returns the tif of the map when it exists, a map that is not in the bucket has nothing to delete
+func (repo *AWSMapsRepo) PlanDelete(ctx context.Context, m domain.Map) ([]string, error) {
+	key := mapkeys.FromMapID(m.ID())
+	_, err := repo.awsClient.HeadObjectInS3(ctx, repo.bucket.bucketName, key)
+	if errors.Is(err, awsclient.ErrObjectNotFound) {
+		return nil, nil
 	}
-
-	day, err := strconv.Atoi(dateParts[2])
 	if err != nil {
-		return nil, errors.New("failed to parse day. error: " + err.Error())
+		return nil, err
 	}
-	return &domain.Date{
-		Day:   day,
-		Month: time.Month(month),
-		Year:  year,
-	}, nil
+	return []string{fmt.Sprintf("s3://%s/%s", repo.bucket.bucketName, key)}, nil
 }
 
-func processObjectKey(
-	ctx context.Context,
-	repo *AWSMapsRepo,
-	objKey string,
-	desiredProvider domain.MapProvider,
-	desiredBounds domain.Bounds,
-	desiredMapType domain.MapType,
-	mapChan chan<- *domain.Map,
-) {
-	directory := filepath.Dir(objKey)
-	extension := filepath.Ext(objKey)
-	if directory == "" || extension != ".tif" {
-		repo.logger.Warn(ctx, "The objectKey was not in the expected format", "objectKey", objKey)
-		return
+// CloudFreeCoverage reads the coverage that was recorded when the map was cropped, nil when it was not recorded
+func (repo *AWSMapsRepo) CloudFreeCoverage(ctx context.Context, m domain.Map) (*float64, error) {
+	key := mapkeys.FromMapID(m.ID())
+	value, err := repo.awsClient.GetObjectMetadataInS3(ctx, repo.bucket.bucketName, key, cloudFreeCoverageMetadataKey)
+	if errors.Is(err, awsclient.ErrMetadataKeyNotFound) {
+		return nil, nil
 	}
-	provider, bounds, mapType, err := convertStringPathToDomain(directory)
 	if err != nil {
-		repo.logger.Error(
-			ctx,
-			"There was an error when attempting to convert the objectKey to a domain object",
-			"error",
-			err.Error(),
-			"objectKey",
-			objKey,
-		)
-		return
-	}
-	if isDesiredObject(*provider, desiredProvider, *bounds, desiredBounds, *mapType, desiredMapType) {
-		date, err := extractDateFromKey(objKey)
-		if err != nil {
-			repo.logger.Error(
-				ctx,
-				"There was an error when attempting to extract the date from the objectKey",
-				"error",
-				err.Error(),
-				"objectKey",
-				objKey,
-			)
-			return
-		}
-
-		url, err := repo.awsClient.GetObjectMetadataInS3(ctx, repo.bucket.bucketName, objKey, repo.bucket.metadataKey)
-
-		if err != nil {
-			repo.logger.Error(
-				ctx,
-				"There was an error when attempting to get the metadata from the object",
-				"error",
-				err.Error(),
-				"objectKey",
-				objKey,
-				"bucket",
-				repo.bucket.bucketName,
-				"metadataKey",
-				repo.bucket.metadataKey,
-			)
-			return
-		}
-		if url == nil {
-			repo.logger.Warn(
-				ctx,
-				"The url, that should be attached to the objects metadata was not found",
-				"objectKey",
-				objKey,
-				"bucket",
-				repo.bucket.bucketName,
-				"metadataKey",
-				repo.bucket.metadataKey,
-			)
-			u := ""
-			url = &u
-		}
-
-		mapChan <- &domain.Map{
-			Bounds:  *bounds,
-			MapType: *mapType,
-			Date:    *date,
-			Source: domain.MapSource{
-				MapProvider: *provider,
-				URL:         *url,
-			},
-		}
+		repo.logger.Error(ctx, "Couldn't get the cloud-free coverage", "key", key, "error", err)
+		return nil, err
 	}
-}
-
-func createKeyFromMap(m domain.Map) string {
-	d := m.Date
-	s := fmt.Sprintf(
-		"%s/%s/%s/%d_%d_%d.tif",
-		m.Source.MapProvider.String(),
-		m.Bounds.String(),
-		m.MapType.String(),
-		d.Year,
-		d.Month,
-		d.Day,
-	)
-	return s
-}
-
-func convertStringPathToDomain(objectKey string) (*domain.MapProvider, *domain.Bounds, *domain.MapType, error) {
-	parts := strings.Split(objectKey, "/")
-	if len(parts) != 3 {
-		return nil, nil, nil, errors.New("the objectKey was not in the expected format")
+	coverage, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("the cloud-free coverage of %s is not a number: %w", key, err)
 	}
-	objProvider, objBounds, objMapType := parts[0], parts[1], parts[2]
-	provider := domain.StringToMapProvider(objProvider)
-	bounds := domain.StringToBounds(objBounds)
-	mapType := domain.StringToMapType(objMapType)
-	return &provider, &bounds, &mapType, nil
+	return &coverage, nil
 }
 
 func isDesiredObject(
@@ -2,6 +2,7 @@ package internalmapsrepo
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -10,34 +11,13 @@ import (
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-func TestAWSMapsRepo_extractDateFromFilepath(t *testing.T) {
-	d, _ := extractDateFromKey("2022_1_1.tif")
-	assert.Equal(t, &domain.Date{
-		Day:   1,
-		Month: 1,
-		Year:  2022,
-	}, d)
-}
-
-func TestAWSMapsRepo_createFilepathFromDate(t *testing.T) {
-	n := createKeyFromMap(
-		domain.Map{MapType: domain.MapTypeDaily, Bounds: domain.BoundsUkraineAndAround, Source: domain.MapSource{
-			MapProvider: domain.MapProviderEogdata,
-			URL:         "test.com",
-		}, Date: domain.Date{
-			Day:   0,
-			Month: 1,
-			Year:  2022,
-		}},
-	)
-	assert.Equal(t, n, "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2022_1_0.tif")
-}
-
-func TestAWSMapsRepo_List(t *testing.T) {
+func TestAWSMapsRepo_ListWithoutManifest(t *testing.T) {
 	ctx := context.Background()
 	testBucketName := "test-bucket"
 	testMetadataKey := "test-metadata-key"
@@ -55,12 +35,14 @@ func TestAWSMapsRepo_List(t *testing.T) {
 		name          string
 		expectedError error
 		expectedMaps  []domain.Map
+		prefix        string
 		provider      domain.MapProvider
 		mapType       domain.MapType
 		bounds        domain.Bounds
 	}{
 		{
 			name:     "List valid maps",
+			prefix:   "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/",
 			provider: domain.MapProviderEogdata,
 			bounds:   domain.BoundsUkraineAndAround,
 			mapType:  domain.MapTypeDaily,
@@ -87,6 +69,7 @@ func TestAWSMapsRepo_List(t *testing.T) {
 		},
 		{
 			name:     "List valid maps when map type and bounds are not specified",
+			prefix:   "MapProviderEogdata/",
 			provider: domain.MapProviderEogdata,
 			bounds:   domain.BoundsUnspecified,
 			mapType:  domain.MapTypeUnspecified,
@@ -125,9 +108,12 @@ func TestAWSMapsRepo_List(t *testing.T) {
 				testObjects[2],
 			)
 
-			mockAWSClient.On("ListObjectsInS3", ctx, testBucketName).Return(testObjects, nil)
-			mockAWSClient.On("GetObjectMetadataInS3", ctx, testBucketName, mock.AnythingOfType("string"), testMetadataKey).
-				Return(&testMetadata, nil)
+			mockAWSClient.On("GetFromS3WithETag", ctx, testBucketName, ManifestKey).Return(nil, "", &types.NoSuchKey{})
+			mockLogger.On("Warn", ctx, "The bucket has no manifest, the objects are scanned instead, run rebuildManifest",
+				"bucketName", testBucketName, "prefix", tc.prefix)
+			mockAWSClient.On("ListObjectsInS3", ctx, testBucketName, tc.prefix).Return(testObjects, nil)
+			mockAWSClient.On("HeadObjectInS3", mock.Anything, testBucketName, mock.AnythingOfType("string")).
+				Return(&awsclient.ObjectInfo{Size: 1, Metadata: map[string]string{testMetadataKey: testMetadata}}, nil)
 
 			repo := NewAWSInternalMapsRepository(
 				mockLogger,
@@ -177,9 +163,11 @@ func TestAWSMapsRepo_Download(t *testing.T) {
 		},
 	}
 
-	testData := []byte("test-data")
-
-	mockAWSClient.On("GetFromS3", ctx, testBucketName, testKey).Return(testData, nil)
+	mockAWSClient.On("Download", ctx, testBucketName, testKey, mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) {
+			assert.NoError(t, os.WriteFile(args.String(3), []byte("test-data"), 0o644))
+		}).
+		Return(int64(9), nil)
 
 	repo := NewAWSInternalMapsRepository(
 		mockLogger,
@@ -238,3 +226,80 @@ func TestAWSMapsRepo_Create(t *testing.T) {
 
 	mockAWSClient.AssertExpectations(t)
 }
+
+func TestAWSMapsRepo_CloudFreeCoverage(t *testing.T) {
+	ctx := context.Background()
+	testBucketName := "test-bucket"
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewAWSInternalMapsRepository(
+		ports.NewMockLogger(t),
+		testBucketName,
+		"test-metadata-key",
+		"test-queue",
+		"/tmp",
+		mockAWSClient,
+	)
+	recorded := domain.Map{
+		Bounds:  domain.BoundsUkraineAndAround,
+		MapType: domain.MapTypeMonthly,
+		Date:    domain.Date{Day: 1, Month: 1, Year: 2022},
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+	}
+	notRecorded := recorded
+	notRecorded.Date.Month = 2
+
+	coverage := "0.35"
+	mockAWSClient.On("GetObjectMetadataInS3", ctx, testBucketName,
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_1_1.tif", "cloud-free-coverage").
+		Return(&coverage, nil)
+	mockAWSClient.On("GetObjectMetadataInS3", ctx, testBucketName,
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_2_1.tif", "cloud-free-coverage").
+		Return(nil, fmt.Errorf("%w: cloud-free-coverage", awsclient.ErrMetadataKeyNotFound))
+
+	cloudFreeCoverage, err := repo.CloudFreeCoverage(ctx, recorded)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.35, *cloudFreeCoverage)
+
+	cloudFreeCoverage, err = repo.CloudFreeCoverage(ctx, notRecorded)
+	assert.NoError(t, err)
+	assert.Nil(t, cloudFreeCoverage)
+}
+
+func TestAWSMapsRepo_PlanDelete(t *testing.T) {
+	ctx := context.Background()
+	testBucketName := "test-bucket"
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewAWSInternalMapsRepository(
+		ports.NewMockLogger(t),
+		testBucketName,
+		"source-url",
+		"",
+		"/tmp",
+		mockAWSClient,
+	)
+	existing := domain.Map{
+		Bounds:  domain.BoundsUkraineAndAround,
+		MapType: domain.MapTypeMonthly,
+		Date:    domain.Date{Day: 1, Month: 1, Year: 2022},
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+	}
+	missing := existing
+	missing.Date.Month = 2
+
+	mockAWSClient.On("HeadObjectInS3", ctx, testBucketName,
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_1_1.tif").
+		Return(&awsclient.ObjectInfo{Size: 10}, nil)
+	mockAWSClient.On("HeadObjectInS3", ctx, testBucketName,
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_2_1.tif").
+		Return(nil, fmt.Errorf("%w: 2022_2_1.tif", awsclient.ErrObjectNotFound))
+
+	planned, err := repo.PlanDelete(ctx, existing)
+	require.NoError(t, err)
+	assert.Equal(t,
+		[]string{"s3://" + testBucketName + "/MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_1_1.tif"},
+		planned)
+
+	planned, err = repo.PlanDelete(ctx, missing)
+	require.NoError(t, err)
+	assert.Empty(t, planned)
+}
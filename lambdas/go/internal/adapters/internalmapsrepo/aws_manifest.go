@@ -0,0 +1,279 @@
+package internalmapsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	awsclient "github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/mapkeys"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ManifestKey is the object at the root of the bucket that indexes the tifs in it, List reads it instead of listing
+// the bucket. The python lambda adds the maps it saves to it and Delete removes them, every write is conditional on
+// the etag that was read so concurrent writers retry instead of overwriting each other
+const ManifestKey = "manifest.json"
+
+// checksumMetadataKey is the metadata the python lambda records the sha256 of the tif in, so a rebuilt manifest
+// keeps the checksums without downloading the tifs
+const checksumMetadataKey = "sha256"
+
+// scanWorkers bounds the HeadObject calls that are in flight while the bucket is scanned
+const scanWorkers = 16
+
+// manifestRetries bounds how often an update of the manifest is retried after another writer changed it
+const manifestRetries = 5
+
+type manifest struct {
+	Maps map[string]manifestEntry `json:"maps"`
+}
+
+type manifestEntry struct {
+	Key       string    `json:"key"`
+	SourceURL string    `json:"source_url"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"sha256,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RebuildManifest scans the whole bucket and replaces the manifest with what it found, e.g. for a bucket that has no
+// manifest yet or after the tifs were changed without the python lambda
+func (repo *AWSMapsRepo) RebuildManifest(ctx context.Context) (int, error) {
+	started := time.Now()
+	scanned, err := repo.scan(
+		ctx, "", domain.MapProviderUnspecified, domain.BoundsUnspecified, domain.MapTypeUnspecified,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var indexed int
+	err = repo.updateManifest(ctx, func(existing map[string]manifestEntry) (map[string]manifestEntry, bool) {
+		entries := make(map[string]manifestEntry, len(scanned))
+		for key, entry := range scanned {
+			// The timestamps of the tifs only tell when they were last written, a map that was indexed keeps its
+			// creation
+			if previous, ok := existing[key]; ok && !previous.CreatedAt.IsZero() {
+				entry.CreatedAt = previous.CreatedAt
+			}
+			entries[key] = entry
+		}
+		// The maps that were saved while the bucket was scanned are kept, the scan can have missed them
+		for key, previous := range existing {
+			if !previous.UpdatedAt.Before(started) {
+				entries[key] = previous
+			}
+		}
+		indexed = len(entries)
+		return entries, true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return indexed, nil
+}
+
+// readManifest returns nil entries and an empty etag when the bucket has no manifest yet
+func (repo *AWSMapsRepo) readManifest(ctx context.Context) (map[string]manifestEntry, string, error) {
+	object, etag, err := repo.awsClient.GetFromS3WithETag(ctx, repo.bucket.bucketName, ManifestKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, "", nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the manifest from s3",
+			"bucket", repo.bucket.bucketName, "error", err)
+		return nil, "", err
+	}
+	var m manifest
+	if err := json.Unmarshal(object, &m); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling the manifest", "bucket", repo.bucket.bucketName, "error", err)
+		return nil, "", err
+	}
+	if m.Maps == nil {
+		m.Maps = make(map[string]manifestEntry)
+	}
+	return m.Maps, etag, nil
+}
+
+// updateManifest reads the manifest, passes its entries to update and writes what update returns only while the
+// manifest is unchanged, it is read again and update called again when another writer got there first. Nothing is
+// written when update returns false
+func (repo *AWSMapsRepo) updateManifest(
+	ctx context.Context,
+	update func(entries map[string]manifestEntry) (map[string]manifestEntry, bool),
+) error {
+	for attempt := 1; ; attempt++ {
+		entries, etag, err := repo.readManifest(ctx)
+		if err != nil {
+			return err
+		}
+		entries, changed := update(entries)
+		if !changed {
+			return nil
+		}
+		data, err := json.Marshal(manifest{Maps: entries})
+		if err != nil {
+			return err
+		}
+		err = repo.awsClient.UploadToS3IfMatch(ctx, repo.bucket.bucketName, ManifestKey, etag, data)
+		if errors.Is(err, awsclient.ErrPreconditionFailed) && attempt < manifestRetries {
+			repo.logger.Info(ctx, "The manifest was changed by another writer, the update is retried",
+				"bucket", repo.bucket.bucketName, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			repo.logger.Error(ctx, "Error when attempting to write the manifest to s3",
+				"bucket", repo.bucket.bucketName, "error", err)
+		}
+		return err
+	}
+}
+
+// removeFromManifest writes nothing when the bucket has no manifest or the key is not indexed
+func (repo *AWSMapsRepo) removeFromManifest(ctx context.Context, key string) error {
+	return repo.updateManifest(ctx, func(entries map[string]manifestEntry) (map[string]manifestEntry, bool) {
+		if _, ok := entries[key]; !ok {
+			return entries, false
+		}
+		delete(entries, key)
+		return entries, true
+	})
+}
+
+// scan lists the keys below the prefix and reads the metadata of the tifs the filters select, with at most
+// scanWorkers HeadObject calls at a time
+func (repo *AWSMapsRepo) scan(
+	ctx context.Context,
+	prefix string,
+	desiredProvider domain.MapProvider,
+	desiredBounds domain.Bounds,
+	desiredMapType domain.MapType,
+) (map[string]manifestEntry, error) {
+	objects, err := repo.awsClient.ListObjectsInS3(ctx, repo.bucket.bucketName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if objects == nil {
+		repo.logger.Warn(ctx, "There were no objects found in the s3 bucket",
+			"bucketName", repo.bucket.bucketName, "prefix", prefix)
+		return nil, nil
+	}
+
+	var keys []string
+	for _, objectKey := range objects {
+		if objectKey == ManifestKey {
+			continue
+		}
+		id := repo.parseObjectKey(ctx, objectKey)
+		if id != nil && isDesiredObject(
+			id.Provider, desiredProvider, id.Bounds, desiredBounds, id.MapType, desiredMapType,
+		) {
+			keys = append(keys, objectKey)
+		}
+	}
+	heads, err := infrastructure.MapConcurrently(
+		ctx, keys, scanWorkers, func(ctx context.Context, key string) (*manifestEntry, error) {
+			return repo.headObject(ctx, key), nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]manifestEntry)
+	for _, entry := range heads {
+		if entry != nil {
+			entries[entry.Key] = *entry
+		}
+	}
+	return entries, nil
+}
+
+// headObject returns nil when the metadata of the object could not be read
+func (repo *AWSMapsRepo) headObject(ctx context.Context, objKey string) *manifestEntry {
+	info, err := repo.awsClient.HeadObjectInS3(ctx, repo.bucket.bucketName, objKey)
+	if err != nil {
+		repo.logger.Error(
+			ctx,
+			"There was an error when attempting to get the metadata from the object",
+			"error",
+			err.Error(),
+			"objectKey",
+			objKey,
+			"bucket",
+			repo.bucket.bucketName,
+		)
+		return nil
+	}
+	url := info.Metadata[repo.bucket.metadataKey]
+	if url == "" {
+		repo.logger.Warn(
+			ctx,
+			"The url, that should be attached to the objects metadata was not found",
+			"objectKey",
+			objKey,
+			"bucket",
+			repo.bucket.bucketName,
+			"metadataKey",
+			repo.bucket.metadataKey,
+		)
+	}
+	return &manifestEntry{
+		Key:       objKey,
+		SourceURL: url,
+		Size:      info.Size,
+		Checksum:  info.Metadata[checksumMetadataKey],
+		CreatedAt: info.LastModified,
+		UpdatedAt: info.LastModified,
+	}
+}
+
+// parseObjectKey returns nil when the key is not the key of a map
+func (repo *AWSMapsRepo) parseObjectKey(ctx context.Context, objKey string) *domain.MapID {
+	directory := filepath.Dir(objKey)
+	extension := filepath.Ext(objKey)
+	if directory == "" || extension != ".tif" {
+		repo.logger.Warn(ctx, "The objectKey was not in the expected format", "objectKey", objKey)
+		return nil
+	}
+	provider, bounds, mapType, err := mapkeys.ParseDirectory(directory)
+	if err != nil {
+		repo.logger.Error(
+			ctx,
+			"There was an error when attempting to convert the objectKey to a domain object",
+			"error",
+			err.Error(),
+			"objectKey",
+			objKey,
+		)
+		return nil
+	}
+	date, err := mapkeys.ParseDate(objKey)
+	if err != nil {
+		repo.logger.Error(
+			ctx,
+			"There was an error when attempting to extract the date from the objectKey",
+			"error",
+			err.Error(),
+			"objectKey",
+			objKey,
+		)
+		return nil
+	}
+	return &domain.MapID{Provider: *provider, MapType: *mapType, Bounds: *bounds, Date: *date}
+}
+
+func sortedKeys(entries map[string]manifestEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,172 @@
+package internalmapsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDailyKey   = "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2022_1_1.tif"
+	testMonthlyKey = "MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_2_1.tif"
+)
+
+func testManifest(t *testing.T) []byte {
+	created := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(manifest{Maps: map[string]manifestEntry{
+		testDailyKey: {Key: testDailyKey, SourceURL: "https://example.com/daily", Size: 10, Checksum: "abc",
+			CreatedAt: created, UpdatedAt: created},
+		testMonthlyKey: {Key: testMonthlyKey, SourceURL: "https://example.com/monthly", Size: 20, Checksum: "def",
+			CreatedAt: created, UpdatedAt: created},
+	}})
+	require.NoError(t, err)
+	return data
+}
+
+// uploadedManifest returns the manifest of the last UploadToS3IfMatch call
+func uploadedManifest(t *testing.T, client *awsclient.MockAWSClient) map[string]manifestEntry {
+	for i := len(client.Calls) - 1; i >= 0; i-- {
+		if client.Calls[i].Method != "UploadToS3IfMatch" {
+			continue
+		}
+		var m manifest
+		require.NoError(t, json.Unmarshal(client.Calls[i].Arguments.Get(4).([]byte), &m))
+		return m.Maps
+	}
+	t.Fatal("the manifest was not uploaded")
+	return nil
+}
+
+func TestAWSMapsRepo_ListReadsTheManifest(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewAWSInternalMapsRepository(ports.NewMockLogger(t), "test-bucket", "source-url", "", "/tmp", mockAWSClient)
+
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(testManifest(t), `"v1"`, nil)
+
+	maps, err := repo.List(ctx, domain.MapProviderEogdata, domain.BoundsUnspecified, domain.MapTypeMonthly)
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Map{
+		{
+			Bounds:  domain.BoundsUkraineAndAround,
+			MapType: domain.MapTypeMonthly,
+			Date:    domain.Date{Day: 1, Month: 2, Year: 2022},
+			Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: "https://example.com/monthly"},
+		},
+	}, maps)
+	mockAWSClient.AssertNotCalled(t, "ListObjectsInS3", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAWSMapsRepo_DeleteRemovesTheManifestEntry(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewAWSInternalMapsRepository(ports.NewMockLogger(t), "test-bucket", "source-url", "", "/tmp", mockAWSClient)
+	daily, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+
+	mockAWSClient.On("DeleteFromS3", ctx, "test-bucket", testDailyKey).Return(nil)
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(testManifest(t), `"v1"`, nil)
+	mockAWSClient.On("UploadToS3IfMatch", ctx, "test-bucket", ManifestKey, `"v1"`, mock.Anything).Return(nil)
+
+	require.NoError(t, repo.Delete(ctx, daily.ToMap("")))
+
+	entries := uploadedManifest(t, mockAWSClient)
+	assert.Equal(t, []string{testMonthlyKey}, sortedKeys(entries))
+}
+
+func TestAWSMapsRepo_DeleteRetriesWhenTheManifestWasChanged(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mockLogger := ports.NewMockLogger(t)
+	repo := NewAWSInternalMapsRepository(mockLogger, "test-bucket", "source-url", "", "/tmp", mockAWSClient)
+	daily, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+	// Another writer added a map between the first read and the first write
+	newKey := "MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_3_1.tif"
+	var changed manifest
+	require.NoError(t, json.Unmarshal(testManifest(t), &changed))
+	changed.Maps[newKey] = manifestEntry{Key: newKey}
+	changedData, err := json.Marshal(changed)
+	require.NoError(t, err)
+
+	mockAWSClient.On("DeleteFromS3", ctx, "test-bucket", testDailyKey).Return(nil)
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(testManifest(t), `"v1"`, nil).Once()
+	mockAWSClient.On("UploadToS3IfMatch", ctx, "test-bucket", ManifestKey, `"v1"`, mock.Anything).
+		Return(awsclient.ErrPreconditionFailed)
+	mockLogger.On("Info", ctx, "The manifest was changed by another writer, the update is retried",
+		"bucket", "test-bucket", "attempt", 1)
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(changedData, `"v2"`, nil).Once()
+	mockAWSClient.On("UploadToS3IfMatch", ctx, "test-bucket", ManifestKey, `"v2"`, mock.Anything).Return(nil)
+
+	require.NoError(t, repo.Delete(ctx, daily.ToMap("")))
+
+	entries := uploadedManifest(t, mockAWSClient)
+	assert.Equal(t, []string{testMonthlyKey, newKey}, sortedKeys(entries), "the map of the other writer is kept")
+}
+
+func TestAWSMapsRepo_RebuildManifest(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mockLogger := ports.NewMockLogger(t)
+	repo := NewAWSInternalMapsRepository(mockLogger, "test-bucket", "source-url", "", "/tmp", mockAWSClient)
+	modified := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newKey := "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2022_3_1.tif"
+	// The python lambda saved a map while the bucket was scanned
+	savedKey := "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2022_4_1.tif"
+	var existing manifest
+	require.NoError(t, json.Unmarshal(testManifest(t), &existing))
+	saved := time.Now().Add(time.Minute).UTC()
+	existing.Maps[savedKey] = manifestEntry{Key: savedKey, CreatedAt: saved, UpdatedAt: saved}
+	existingData, err := json.Marshal(existing)
+	require.NoError(t, err)
+
+	mockAWSClient.On("ListObjectsInS3", ctx, "test-bucket", "").
+		Return([]string{ManifestKey, testDailyKey, newKey, "notes.txt"}, nil)
+	mockAWSClient.On("HeadObjectInS3", mock.Anything, "test-bucket", mock.AnythingOfType("string")).Return(
+		&awsclient.ObjectInfo{
+			Size:         30,
+			LastModified: modified,
+			Metadata:     map[string]string{"source-url": "https://example.com", "sha256": "xyz"},
+		}, nil)
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(existingData, `"v1"`, nil)
+	mockAWSClient.On("UploadToS3IfMatch", ctx, "test-bucket", ManifestKey, `"v1"`, mock.Anything).Return(nil)
+	mockLogger.On("Warn", ctx, "The objectKey was not in the expected format", "objectKey", "notes.txt")
+
+	count, err := repo.(*AWSMapsRepo).RebuildManifest(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	entries := uploadedManifest(t, mockAWSClient)
+	assert.Equal(t, []string{testDailyKey, newKey, savedKey}, sortedKeys(entries))
+	assert.Equal(t, time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), entries[testDailyKey].CreatedAt,
+		"a map that was indexed keeps its creation")
+	assert.Equal(t, manifestEntry{
+		Key: newKey, SourceURL: "https://example.com", Size: 30, Checksum: "xyz", CreatedAt: modified, UpdatedAt: modified,
+	}, entries[newKey])
+}
+
+func TestAWSMapsRepo_ListFailsWhenTheManifestCannotBeRead(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mockLogger := ports.NewMockLogger(t)
+	repo := NewAWSInternalMapsRepository(mockLogger, "test-bucket", "source-url", "", "/tmp", mockAWSClient)
+
+	mockAWSClient.On("GetFromS3WithETag", ctx, "test-bucket", ManifestKey).Return(nil, "", &types.NoSuchBucket{})
+	mockLogger.On("Error", ctx, "Error when attempting to get the manifest from s3", "bucket", "test-bucket",
+		"error", mock.Anything)
+
+	_, err := repo.List(ctx, domain.MapProviderEogdata, domain.BoundsUnspecified, domain.MapTypeUnspecified)
+
+	var nsb *types.NoSuchBucket
+	assert.ErrorAs(t, err, &nsb)
+}
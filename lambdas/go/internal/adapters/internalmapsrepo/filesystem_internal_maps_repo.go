@@ -0,0 +1,263 @@
+package internalmapsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	conflict_nightlightv1 "github.com/BaronBonet/conflict-nightlight/generated/conflict_nightlight/v1"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/mapkeys"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
+	"github.com/google/uuid"
+)
+
+// sidecarExtension is appended to the path of a tif, the sidecar holds what the s3 object metadata holds in the
+// AWSMapsRepo, e.g. MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2023_1_1.tif.metadata.json
+const sidecarExtension = ".metadata.json"
+
+// FilesystemMapsRepo keeps the maps in a directory with the same layout as the s3 buckets, so the pipeline can be
+// run without AWS, e.g. by pointing the cli at a copy of a bucket
+type FilesystemMapsRepo struct {
+	logger      ports.Logger
+	rootDir     string
+	metadataKey string
+	// spoolDir receives a request file for every created map, instead of the sqs queue the python lambda reads
+	spoolDir    string
+	tmpWriteDir string
+}
+
+// NewFilesystemInternalMapsRepository creates the repo, Create is not supported when the spoolDir is empty, like
+// the processed maps repo which has no queue
+func NewFilesystemInternalMapsRepository(
+	logger ports.Logger,
+	rootDir string,
+	metadataKey string,
+	spoolDir string,
+	tmpWriteDir string,
+) ports.InternalMapRepo {
+	return &FilesystemMapsRepo{
+		logger:      logger,
+		rootDir:     rootDir,
+		metadataKey: metadataKey,
+		spoolDir:    spoolDir,
+		tmpWriteDir: tmpWriteDir,
+	}
+}
+
+func (repo *FilesystemMapsRepo) List(
+	ctx context.Context,
+	desiredProvider domain.MapProvider,
+	desiredBounds domain.Bounds,
+	desiredMapType domain.MapType,
+) ([]domain.Map, error) {
+	var existingMaps []domain.Map
+	// Different files, e.g. 2022_1_1.tif and 2022_01_01.tif, can decode to the same map
+	seen := make(map[domain.MapID]struct{})
+	err := filepath.WalkDir(repo.rootDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".tif" {
+			return nil
+		}
+		key, err := filepath.Rel(repo.rootDir, path)
+		if err != nil {
+			return err
+		}
+		id, err := mapkeys.ToMapID(filepath.ToSlash(key))
+		if err != nil {
+			repo.logger.Warn(ctx, "The file was not in the expected format", "path", path, "error", err)
+			return nil
+		}
+		if !isDesiredObject(id.Provider, desiredProvider, id.Bounds, desiredBounds, id.MapType, desiredMapType) {
+			return nil
+		}
+		if _, ok := seen[id]; ok {
+			repo.logger.Warn(ctx, "More than one file was found for the same map", "map", id.String())
+			return nil
+		}
+		seen[id] = struct{}{}
+		metadata, err := readSidecar(path)
+		if err != nil {
+			repo.logger.Error(ctx, "Error when attempting to read the metadata", "path", path, "error", err)
+			return nil
+		}
+		url, ok := metadata[repo.metadataKey]
+		if !ok {
+			repo.logger.Warn(ctx, "The url, that should be in the metadata of the map was not found", "path", path)
+		}
+		existingMaps = append(existingMaps, id.ToMap(url))
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		repo.logger.Warn(ctx, "The directory of the maps does not exist", "rootDir", repo.rootDir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sortMapsByDate(existingMaps), nil
+}
+
+// Download copies the map to the tmpWriteDir, the caller may remove the local map once it is done with it
+func (repo *FilesystemMapsRepo) Download(ctx context.Context, m domain.Map) (*domain.LocalMap, error) {
+	localFilepath := filepath.Join(repo.tmpWriteDir, uuid.NewString()+".tif")
+	if err := copyFile(repo.pathOf(m), localFilepath); err != nil {
+		repo.logger.Error(ctx, "Couldn't copy the map", "path", repo.pathOf(m), "error", err)
+		return nil, err
+	}
+	return &domain.LocalMap{Filepath: localFilepath, Map: m}, nil
+}
+
+// Create writes the request the python lambda would receive from the sqs queue to a file in the spool directory
+func (repo *FilesystemMapsRepo) Create(ctx context.Context, m domain.Map) error {
+	if repo.spoolDir == "" {
+		return errors.New("creating maps is not supported by this repository")
+	}
+	protoMap := prototransformers.DomainToProto(m)
+	message := conflict_nightlightv1.RequestWrapper_DownloadAndCropRawTifRequest{
+		DownloadAndCropRawTifRequest: &conflict_nightlightv1.DownloadAndCropRawTifRequest{Map: &protoMap},
+	}
+	data, err := json.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(repo.spoolDir, 0o755); err != nil {
+		return err
+	}
+	requestPath := filepath.Join(repo.spoolDir, uuid.NewString()+".json")
+	if err := writeFileAtomically(requestPath, data); err != nil {
+		repo.logger.Error(ctx, "Couldn't write the request", "path", requestPath, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (repo *FilesystemMapsRepo) Delete(ctx context.Context, m domain.Map) error {
+	for _, path := range []string{repo.pathOf(m), repo.pathOf(m) + sidecarExtension} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			repo.logger.Error(ctx, "Couldn't delete file", "path", path, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanDelete returns the files of the map that exist
+func (repo *FilesystemMapsRepo) PlanDelete(_ context.Context, m domain.Map) ([]string, error) {
+	var planned []string
+	for _, path := range []string{repo.pathOf(m), repo.pathOf(m) + sidecarExtension} {
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, path)
+	}
+	return planned, nil
+}
+
+// CloudFreeCoverage reads the coverage from the sidecar of the map, nil when it was not recorded
+func (repo *FilesystemMapsRepo) CloudFreeCoverage(ctx context.Context, m domain.Map) (*float64, error) {
+	metadata, err := readSidecar(repo.pathOf(m))
+	if err != nil {
+		repo.logger.Error(ctx, "Couldn't read the metadata", "path", repo.pathOf(m), "error", err)
+		return nil, err
+	}
+	value, ok := metadata[cloudFreeCoverageMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+	coverage, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("the cloud-free coverage of %s is not a number: %w", repo.pathOf(m), err)
+	}
+	return &coverage, nil
+}
+
+// Save copies a local map into the repository with its source url and, when known, its cloud-free coverage, it does
+// what the python lambda does for the s3 buckets
+func (repo *FilesystemMapsRepo) Save(ctx context.Context, m domain.LocalMap, cloudFreeCoverage *float64) error {
+	path := repo.pathOf(m.Map)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := copyFile(m.Filepath, path); err != nil {
+		repo.logger.Error(ctx, "Couldn't copy the map", "path", path, "error", err)
+		return err
+	}
+	metadata := map[string]string{repo.metadataKey: m.Map.Source.URL}
+	if cloudFreeCoverage != nil {
+		metadata[cloudFreeCoverageMetadataKey] = strconv.FormatFloat(*cloudFreeCoverage, 'f', 4, 64)
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path+sidecarExtension, data)
+}
+
+func (repo *FilesystemMapsRepo) pathOf(m domain.Map) string {
+	return filepath.Join(repo.rootDir, filepath.FromSlash(mapkeys.FromMapID(m.ID())))
+}
+
+// readSidecar returns no metadata when the map has no sidecar, like an s3 object without metadata
+func readSidecar(tifPath string) (map[string]string, error) {
+	data, err := os.ReadFile(tifPath + sidecarExtension)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]string{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("the sidecar of %s could not be parsed: %w", tifPath, err)
+	}
+	return metadata, nil
+}
+
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	destination, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		return err
+	}
+	return destination.Close()
+}
+
+// writeFileAtomically writes to a temporary file first, so a reader of the directory never sees a partial file
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
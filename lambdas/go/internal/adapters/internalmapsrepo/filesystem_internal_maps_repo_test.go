@@ -0,0 +1,138 @@
+package internalmapsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	conflict_nightlightv1 "github.com/BaronBonet/conflict-nightlight/generated/conflict_nightlight/v1"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testFilesystemMap(month time.Month, mapType domain.MapType) domain.Map {
+	return domain.Map{
+		Bounds:  domain.BoundsUkraineAndAround,
+		MapType: mapType,
+		Date:    domain.Date{Day: 1, Month: month, Year: 2022},
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata, URL: "https://example.com/test-url"},
+	}
+}
+
+func writeTestTif(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "map.tif")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestFilesystemMapsRepo_SaveListDownloadDelete(t *testing.T) {
+	ctx := context.Background()
+	rootDir := t.TempDir()
+	repo := NewFilesystemInternalMapsRepository(ports.NewMockLogger(t), rootDir, "source-url", "", t.TempDir())
+	february := testFilesystemMap(2, domain.MapTypeMonthly)
+	january := testFilesystemMap(1, domain.MapTypeMonthly)
+	daily := testFilesystemMap(1, domain.MapTypeDaily)
+	coverage := 0.42
+
+	fsRepo := repo.(*FilesystemMapsRepo)
+	require.NoError(t, fsRepo.Save(ctx, domain.LocalMap{Filepath: writeTestTif(t, "february"), Map: february}, &coverage))
+	require.NoError(t, fsRepo.Save(ctx, domain.LocalMap{Filepath: writeTestTif(t, "january"), Map: january}, nil))
+	require.NoError(t, fsRepo.Save(ctx, domain.LocalMap{Filepath: writeTestTif(t, "daily"), Map: daily}, nil))
+	assert.FileExists(t, filepath.Join(rootDir, "MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2022_2_1.tif"))
+
+	maps, err := repo.List(ctx, domain.MapProviderEogdata, domain.BoundsUkraineAndAround, domain.MapTypeMonthly)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Map{january, february}, maps)
+
+	localMap, err := repo.Download(ctx, february)
+	require.NoError(t, err)
+	content, err := os.ReadFile(localMap.Filepath)
+	require.NoError(t, err)
+	assert.Equal(t, "february", string(content))
+
+	cloudFreeCoverage, err := repo.CloudFreeCoverage(ctx, february)
+	require.NoError(t, err)
+	assert.Equal(t, 0.42, *cloudFreeCoverage)
+	cloudFreeCoverage, err = repo.CloudFreeCoverage(ctx, january)
+	require.NoError(t, err)
+	assert.Nil(t, cloudFreeCoverage)
+
+	planned, err := repo.PlanDelete(ctx, february)
+	require.NoError(t, err)
+	assert.Len(t, planned, 2, "the tif and its sidecar")
+
+	require.NoError(t, repo.Delete(ctx, february))
+	require.NoError(t, repo.Delete(ctx, february), "deleting a missing map is not an error")
+	planned, err = repo.PlanDelete(ctx, february)
+	require.NoError(t, err)
+	assert.Empty(t, planned)
+	maps, err = repo.List(ctx, domain.MapProviderUnspecified, domain.BoundsUnspecified, domain.MapTypeUnspecified)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Map{daily, january}, maps)
+}
+
+func TestFilesystemMapsRepo_ListWithoutSidecar(t *testing.T) {
+	ctx := context.Background()
+	rootDir := t.TempDir()
+	mockLogger := ports.NewMockLogger(t)
+	repo := NewFilesystemInternalMapsRepository(mockLogger, rootDir, "source-url", "", t.TempDir())
+	directory := filepath.Join(rootDir, "MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly")
+	require.NoError(t, os.MkdirAll(directory, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, "2022_1_1.tif"), []byte("copied by hand"), 0o644))
+	mockLogger.On("Warn", mock.Anything, mock.Anything, "path", mock.Anything).Once()
+
+	maps, err := repo.List(ctx, domain.MapProviderEogdata, domain.BoundsUkraineAndAround, domain.MapTypeMonthly)
+
+	require.NoError(t, err)
+	expected := testFilesystemMap(1, domain.MapTypeMonthly)
+	expected.Source.URL = ""
+	assert.Equal(t, []domain.Map{expected}, maps)
+}
+
+func TestFilesystemMapsRepo_ListMissingDirectory(t *testing.T) {
+	mockLogger := ports.NewMockLogger(t)
+	rootDir := filepath.Join(t.TempDir(), "missing")
+	repo := NewFilesystemInternalMapsRepository(mockLogger, rootDir, "source-url", "", t.TempDir())
+	mockLogger.On("Warn", mock.Anything, mock.Anything, "rootDir", rootDir).Once()
+
+	maps, err := repo.List(context.Background(), domain.MapProviderEogdata, domain.BoundsUnspecified, domain.MapTypeUnspecified)
+
+	assert.NoError(t, err)
+	assert.Nil(t, maps)
+}
+
+func TestFilesystemMapsRepo_Create(t *testing.T) {
+	ctx := context.Background()
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	repo := NewFilesystemInternalMapsRepository(ports.NewMockLogger(t), t.TempDir(), "source-url", spoolDir, t.TempDir())
+	m := testFilesystemMap(1, domain.MapTypeMonthly)
+
+	require.NoError(t, repo.Create(ctx, m))
+
+	requests, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	data, err := os.ReadFile(filepath.Join(spoolDir, requests[0].Name()))
+	require.NoError(t, err)
+	protoMap := prototransformers.DomainToProto(m)
+	expected, err := json.Marshal(&conflict_nightlightv1.RequestWrapper_DownloadAndCropRawTifRequest{
+		DownloadAndCropRawTifRequest: &conflict_nightlightv1.DownloadAndCropRawTifRequest{Map: &protoMap},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(data))
+}
+
+func TestFilesystemMapsRepo_CreateWithoutSpool(t *testing.T) {
+	repo := NewFilesystemInternalMapsRepository(ports.NewMockLogger(t), t.TempDir(), "source-url", "", t.TempDir())
+
+	err := repo.Create(context.Background(), testFilesystemMap(1, domain.MapTypeMonthly))
+
+	assert.Error(t, err)
+}
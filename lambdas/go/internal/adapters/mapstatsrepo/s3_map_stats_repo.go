@@ -0,0 +1,214 @@
+package mapstatsrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// boundedMapStats is the time series of a single bounds, the bounds use the same numbers as the frontend map options
+type boundedMapStats struct {
+	Bounds      int             `json:"bounds"`
+	DisplayName string          `json:"display_name,omitempty"`
+	Series      []mapStatsEntry `json:"series"`
+}
+
+type mapStatsEntry struct {
+	// Key is the same key as the one of the map options in the frontend json
+	Key             string    `json:"key"`
+	MapType         string    `json:"map_type"`
+	Date            string    `json:"date"`
+	SumRadiance     float64   `json:"sum_radiance"`
+	MeanRadiance    float64   `json:"mean_radiance"`
+	LitPixelCount   int       `json:"lit_pixel_count"`
+	ValidPixelCount int       `json:"valid_pixel_count"`
+	PixelCount      int       `json:"pixel_count"`
+	Coverage        float64   `json:"coverage"`
+	ComputedAt      time.Time `json:"computed_at"`
+}
+
+func newMapStatsEntry(s domain.MapStats) mapStatsEntry {
+	date := time.Date(s.ID.Date.Year, s.ID.Date.Month, s.ID.Date.Day, 0, 0, 0, 0, time.UTC)
+	return mapStatsEntry{
+		Key:             s.ID.String(),
+		MapType:         strings.ToLower(strings.TrimPrefix(s.ID.MapType.String(), "MapType")),
+		Date:            date.Format(dateLayout),
+		SumRadiance:     s.SumRadiance,
+		MeanRadiance:    s.MeanRadiance,
+		LitPixelCount:   s.LitPixelCount,
+		ValidPixelCount: s.ValidPixelCount,
+		PixelCount:      s.PixelCount,
+		Coverage:        s.Coverage,
+		ComputedAt:      s.ComputedAt,
+	}
+}
+
+func (entry mapStatsEntry) toDomain(id domain.MapID) domain.MapStats {
+	return domain.MapStats{
+		ID:              id,
+		SumRadiance:     entry.SumRadiance,
+		MeanRadiance:    entry.MeanRadiance,
+		LitPixelCount:   entry.LitPixelCount,
+		ValidPixelCount: entry.ValidPixelCount,
+		PixelCount:      entry.PixelCount,
+		Coverage:        entry.Coverage,
+		ComputedAt:      entry.ComputedAt,
+	}
+}
+
+// s3MapStatsRepo keeps the statistics in a single json next to the frontend map options, so the frontend can fetch
+// the whole series at once
+type s3MapStatsRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+	objectKey  string
+}
+
+func NewS3MapStatsRepo(
+	logger ports.Logger,
+	bucketName string,
+	objectKey string,
+	awsClient awsclient.AWSClient,
+) ports.MapStatsRepo {
+	return &s3MapStatsRepo{logger: logger, bucketName: bucketName, objectKey: objectKey, awsClient: awsClient}
+}
+
+func (repo *s3MapStatsRepo) List(ctx context.Context) ([]domain.MapStats, error) {
+	stats, err := repo.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortMapStats(stats)
+	return stats, nil
+}
+
+func (repo *s3MapStatsRepo) Upsert(ctx context.Context, stats domain.MapStats) error {
+	existing, err := repo.read(ctx)
+	if err != nil {
+		return err
+	}
+	updated := existing[:0]
+	for _, s := range existing {
+		if s.ID != stats.ID {
+			updated = append(updated, s)
+		}
+	}
+	return repo.write(ctx, append(updated, stats))
+}
+
+func (repo *s3MapStatsRepo) Replace(ctx context.Context, stats []domain.MapStats) error {
+	// write sorts the statistics, the slice of the caller is left as is
+	return repo.write(ctx, append([]domain.MapStats(nil), stats...))
+}
+
+func (repo *s3MapStatsRepo) Delete(ctx context.Context, id domain.MapID) error {
+	existing, err := repo.read(ctx)
+	if err != nil {
+		return err
+	}
+	remaining := existing[:0]
+	for _, s := range existing {
+		if s.ID != id {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(remaining) == len(existing) {
+		return nil
+	}
+	return repo.write(ctx, remaining)
+}
+
+// read returns no statistics when the json does not exist yet
+func (repo *s3MapStatsRepo) read(ctx context.Context) ([]domain.MapStats, error) {
+	object, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the map stats from s3", "error", err)
+		return nil, err
+	}
+	var boundedStats []boundedMapStats
+	if err = json.Unmarshal(object, &boundedStats); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling the map stats", "error", err)
+		return nil, err
+	}
+	var stats []domain.MapStats
+	for _, bounded := range boundedStats {
+		for _, entry := range bounded.Series {
+			id, err := domain.ParseMapID(entry.Key)
+			if err != nil {
+				repo.logger.Warn(ctx, "The key of the map stats was not in the expected format", "key", entry.Key)
+				continue
+			}
+			stats = append(stats, entry.toDomain(id))
+		}
+	}
+	return stats, nil
+}
+
+func (repo *s3MapStatsRepo) write(ctx context.Context, stats []domain.MapStats) error {
+	sortMapStats(stats)
+	boundedStats := make([]boundedMapStats, 0)
+	for _, s := range stats {
+		if len(boundedStats) == 0 || boundedStats[len(boundedStats)-1].Bounds != int(s.ID.Bounds) {
+			bounded := boundedMapStats{Bounds: int(s.ID.Bounds)}
+			if definition, ok := domain.GetBoundsRegistry().Get(s.ID.Bounds); ok {
+				bounded.DisplayName = definition.DisplayName
+			}
+			boundedStats = append(boundedStats, bounded)
+		}
+		bounded := &boundedStats[len(boundedStats)-1]
+		bounded.Series = append(bounded.Series, newMapStatsEntry(s))
+	}
+	data, err := json.Marshal(boundedStats)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when marshalling the map stats", "error", err)
+		return err
+	}
+	if err = repo.awsClient.UploadToS3(ctx, repo.bucketName, repo.objectKey, bytes.NewReader(data)); err != nil {
+		repo.logger.Error(ctx, "Error when uploading the map stats to s3", "error", err)
+		return err
+	}
+	return nil
+}
+
+// sortMapStats orders the statistics by bounds and then by date, which is the order the frontend charts them in
+func sortMapStats(stats []domain.MapStats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		return mapIDBefore(stats[i].ID, stats[j].ID)
+	})
+}
+
+func mapIDBefore(a, b domain.MapID) bool {
+	if a.Bounds != b.Bounds {
+		return a.Bounds < b.Bounds
+	}
+	if a.Date != b.Date {
+		return dateBefore(a.Date, b.Date)
+	}
+	return a.String() < b.String()
+}
+
+func dateBefore(a, b domain.Date) bool {
+	if a.Year != b.Year {
+		return a.Year < b.Year
+	}
+	if a.Month != b.Month {
+		return a.Month < b.Month
+	}
+	return a.Day < b.Day
+}
@@ -0,0 +1,105 @@
+package mapstatsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStats(bounds domain.Bounds, month time.Month, sum float64) domain.MapStats {
+	return domain.MapStats{
+		ID: domain.MapID{
+			Provider: domain.MapProviderEogdata,
+			MapType:  domain.MapTypeMonthly,
+			Bounds:   bounds,
+			Date:     domain.Date{Day: 1, Month: month, Year: 2023},
+		},
+		SumRadiance:     sum,
+		MeanRadiance:    sum / 4,
+		LitPixelCount:   2,
+		ValidPixelCount: 4,
+		PixelCount:      5,
+		Coverage:        0.8,
+		ComputedAt:      time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// captureUpload stores the json that is uploaded so it can be inspected and read back
+func captureUpload(t *testing.T, mockAWSClient *awsclient.MockAWSClient, uploaded *[]byte) {
+	mockAWSClient.On("UploadToS3", mock.Anything, "test-bucket", "stats.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			data, err := io.ReadAll(args.Get(3).(io.Reader))
+			require.NoError(t, err)
+			*uploaded = data
+		}).
+		Return(nil)
+}
+
+func TestS3MapStatsRepo_UpsertAndList(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatsRepo(ports.NewMockLogger(t), "test-bucket", "stats.json", mockAWSClient)
+
+	var uploaded []byte
+	captureUpload(t, mockAWSClient, &uploaded)
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(nil, &types.NoSuchKey{}).Once()
+	require.NoError(t, repo.Upsert(ctx, newTestStats(domain.BoundsUkraineAndAround, 2, 10)))
+
+	// Later months are added after the earlier ones and an existing month is replaced
+	for _, stats := range []domain.MapStats{
+		newTestStats(domain.BoundsUkraineAndAround, 1, 20),
+		newTestStats(domain.BoundsGazaAndAround, 1, 30),
+		newTestStats(domain.BoundsUkraineAndAround, 2, 40),
+	} {
+		mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+		require.NoError(t, repo.Upsert(ctx, stats))
+	}
+
+	var boundedStats []boundedMapStats
+	require.NoError(t, json.Unmarshal(uploaded, &boundedStats))
+	require.Len(t, boundedStats, 2)
+	assert.Equal(t, int(domain.BoundsUkraineAndAround), boundedStats[0].Bounds)
+	require.Len(t, boundedStats[0].Series, 2)
+	assert.Equal(t, "2023-01-01", boundedStats[0].Series[0].Date)
+	assert.Equal(t, "monthly", boundedStats[0].Series[0].MapType)
+	assert.Equal(t, "eog-mon-b1-20230201", boundedStats[0].Series[1].Key)
+	assert.Equal(t, 40.0, boundedStats[0].Series[1].SumRadiance)
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	stats, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MapStats{
+		newTestStats(domain.BoundsUkraineAndAround, 1, 20),
+		newTestStats(domain.BoundsUkraineAndAround, 2, 40),
+		newTestStats(domain.BoundsGazaAndAround, 1, 30),
+	}, stats)
+}
+
+func TestS3MapStatsRepo_Delete(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatsRepo(ports.NewMockLogger(t), "test-bucket", "stats.json", mockAWSClient)
+
+	var uploaded []byte
+	captureUpload(t, mockAWSClient, &uploaded)
+	first, second := newTestStats(domain.BoundsUkraineAndAround, 1, 20), newTestStats(domain.BoundsUkraineAndAround, 2, 40)
+	require.NoError(t, repo.Replace(ctx, []domain.MapStats{second, first}))
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.Delete(ctx, first.ID))
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	stats, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MapStats{second}, stats)
+}
@@ -0,0 +1,170 @@
+package mapstatsrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// boundedRegionStats holds the time series of every sub-region of a single bounds
+type boundedRegionStats struct {
+	Bounds      int                 `json:"bounds"`
+	DisplayName string              `json:"display_name,omitempty"`
+	Regions     []regionStatsSeries `json:"regions"`
+}
+
+type regionStatsSeries struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Series []mapStatsEntry `json:"series"`
+}
+
+// s3RegionStatsRepo keeps the statistics per sub-region in a single json next to the frontend map options, in the
+// same format as the statistics of the whole maps
+type s3RegionStatsRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+	objectKey  string
+}
+
+func NewS3RegionStatsRepo(
+	logger ports.Logger,
+	bucketName string,
+	objectKey string,
+	awsClient awsclient.AWSClient,
+) ports.RegionStatsRepo {
+	return &s3RegionStatsRepo{logger: logger, bucketName: bucketName, objectKey: objectKey, awsClient: awsClient}
+}
+
+func (repo *s3RegionStatsRepo) List(ctx context.Context) ([]domain.RegionStats, error) {
+	stats, err := repo.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortRegionStats(stats)
+	return stats, nil
+}
+
+func (repo *s3RegionStatsRepo) UpsertMap(ctx context.Context, id domain.MapID, stats []domain.RegionStats) error {
+	existing, err := repo.read(ctx)
+	if err != nil {
+		return err
+	}
+	updated := existing[:0]
+	for _, s := range existing {
+		if s.Stats.ID != id {
+			updated = append(updated, s)
+		}
+	}
+	return repo.write(ctx, append(updated, stats...))
+}
+
+func (repo *s3RegionStatsRepo) Replace(ctx context.Context, stats []domain.RegionStats) error {
+	return repo.write(ctx, append([]domain.RegionStats(nil), stats...))
+}
+
+func (repo *s3RegionStatsRepo) DeleteMap(ctx context.Context, id domain.MapID) error {
+	existing, err := repo.read(ctx)
+	if err != nil {
+		return err
+	}
+	remaining := existing[:0]
+	for _, s := range existing {
+		if s.Stats.ID != id {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(remaining) == len(existing) {
+		return nil
+	}
+	return repo.write(ctx, remaining)
+}
+
+// read returns no statistics when the json does not exist yet
+func (repo *s3RegionStatsRepo) read(ctx context.Context) ([]domain.RegionStats, error) {
+	object, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, repo.objectKey)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the region stats from s3", "error", err)
+		return nil, err
+	}
+	var boundedStats []boundedRegionStats
+	if err = json.Unmarshal(object, &boundedStats); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling the region stats", "error", err)
+		return nil, err
+	}
+	var stats []domain.RegionStats
+	for _, bounded := range boundedStats {
+		for _, region := range bounded.Regions {
+			for _, entry := range region.Series {
+				id, err := domain.ParseMapID(entry.Key)
+				if err != nil {
+					repo.logger.Warn(ctx, "The key of the region stats was not in the expected format",
+						"key", entry.Key)
+					continue
+				}
+				stats = append(stats, domain.RegionStats{
+					RegionID:   region.ID,
+					RegionName: region.Name,
+					Stats:      entry.toDomain(id),
+				})
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (repo *s3RegionStatsRepo) write(ctx context.Context, stats []domain.RegionStats) error {
+	sortRegionStats(stats)
+	boundedStats := make([]boundedRegionStats, 0)
+	for _, s := range stats {
+		if len(boundedStats) == 0 || boundedStats[len(boundedStats)-1].Bounds != int(s.Stats.ID.Bounds) {
+			bounded := boundedRegionStats{Bounds: int(s.Stats.ID.Bounds)}
+			if definition, ok := domain.GetBoundsRegistry().Get(s.Stats.ID.Bounds); ok {
+				bounded.DisplayName = definition.DisplayName
+			}
+			boundedStats = append(boundedStats, bounded)
+		}
+		bounded := &boundedStats[len(boundedStats)-1]
+		if len(bounded.Regions) == 0 || bounded.Regions[len(bounded.Regions)-1].ID != s.RegionID {
+			bounded.Regions = append(bounded.Regions, regionStatsSeries{ID: s.RegionID, Name: s.RegionName})
+		}
+		region := &bounded.Regions[len(bounded.Regions)-1]
+		region.Series = append(region.Series, newMapStatsEntry(s.Stats))
+	}
+	data, err := json.Marshal(boundedStats)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when marshalling the region stats", "error", err)
+		return err
+	}
+	if err = repo.awsClient.UploadToS3(ctx, repo.bucketName, repo.objectKey, bytes.NewReader(data)); err != nil {
+		repo.logger.Error(ctx, "Error when uploading the region stats to s3", "error", err)
+		return err
+	}
+	return nil
+}
+
+// sortRegionStats groups the statistics by bounds and sub-region, each series is ordered by date
+func sortRegionStats(stats []domain.RegionStats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Stats.ID.Bounds != b.Stats.ID.Bounds {
+			return a.Stats.ID.Bounds < b.Stats.ID.Bounds
+		}
+		if a.RegionID != b.RegionID {
+			return a.RegionID < b.RegionID
+		}
+		return mapIDBefore(a.Stats.ID, b.Stats.ID)
+	})
+}
@@ -0,0 +1,63 @@
+package mapstatsrepo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegionStats(regionID string, stats domain.MapStats) domain.RegionStats {
+	return domain.RegionStats{RegionID: regionID, RegionName: "Name of " + regionID, Stats: stats}
+}
+
+func TestS3RegionStatsRepo_UpsertMapAndDeleteMap(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3RegionStatsRepo(ports.NewMockLogger(t), "test-bucket", "stats.json", mockAWSClient)
+	february := newTestStats(domain.BoundsUkraineAndAround, 2, 10)
+	january := newTestStats(domain.BoundsUkraineAndAround, 1, 20)
+
+	var uploaded []byte
+	captureUpload(t, mockAWSClient, &uploaded)
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(nil, &types.NoSuchKey{}).Once()
+	require.NoError(t, repo.UpsertMap(ctx, february.ID, []domain.RegionStats{
+		newTestRegionStats("lviv", february),
+		newTestRegionStats("kharkiv", february),
+	}))
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.UpsertMap(ctx, january.ID, []domain.RegionStats{newTestRegionStats("kharkiv", january)}))
+
+	var boundedStats []boundedRegionStats
+	require.NoError(t, json.Unmarshal(uploaded, &boundedStats))
+	require.Len(t, boundedStats, 1)
+	require.Len(t, boundedStats[0].Regions, 2)
+	assert.Equal(t, "kharkiv", boundedStats[0].Regions[0].ID)
+	assert.Equal(t, "Name of kharkiv", boundedStats[0].Regions[0].Name)
+	require.Len(t, boundedStats[0].Regions[0].Series, 2)
+	assert.Equal(t, "2023-01-01", boundedStats[0].Regions[0].Series[0].Date)
+	assert.Equal(t, "lviv", boundedStats[0].Regions[1].ID)
+
+	// Upserting a map again replaces the statistics of every sub-region of that map
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.UpsertMap(ctx, february.ID, []domain.RegionStats{newTestRegionStats("kharkiv", february)}))
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	stats, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, newTestRegionStats("kharkiv", january), stats[0])
+	assert.Equal(t, newTestRegionStats("kharkiv", february), stats[1])
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	require.NoError(t, repo.DeleteMap(ctx, january.ID))
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "stats.json").Return(uploaded, nil).Once()
+	stats, err = repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.RegionStats{newTestRegionStats("kharkiv", february)}, stats)
+}
@@ -0,0 +1,69 @@
+package mapstatusrepo
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// inMemoryMapStatusRepo is used when the statuses do not need to outlive the process, e.g. in tests or local runs
+type inMemoryMapStatusRepo struct {
+	mu       sync.RWMutex
+	statuses map[domain.MapID]domain.MapStatus
+}
+
+func NewInMemoryMapStatusRepo() ports.MapStatusRepo {
+	return &inMemoryMapStatusRepo{statuses: make(map[domain.MapID]domain.MapStatus)}
+}
+
+func (repo *inMemoryMapStatusRepo) Get(_ context.Context, id domain.MapID) (*domain.MapStatus, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	status, ok := repo.statuses[id]
+	if !ok {
+		return nil, nil
+	}
+	status = copyStatus(status)
+	return &status, nil
+}
+
+func (repo *inMemoryMapStatusRepo) List(_ context.Context) ([]domain.MapStatus, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	statuses := make([]domain.MapStatus, 0, len(repo.statuses))
+	for _, status := range repo.statuses {
+		statuses = append(statuses, copyStatus(status))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID.String() < statuses[j].ID.String()
+	})
+	return statuses, nil
+}
+
+func (repo *inMemoryMapStatusRepo) Upsert(_ context.Context, status domain.MapStatus) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.statuses[status.ID] = copyStatus(status)
+	return nil
+}
+
+func (repo *inMemoryMapStatusRepo) Delete(_ context.Context, id domain.MapID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.statuses, id)
+	return nil
+}
+
+// copyStatus prevents callers from mutating the stored transitions through the map they were handed
+func copyStatus(status domain.MapStatus) domain.MapStatus {
+	status.Transitions = maps.Clone(status.Transitions)
+	if status.LastErrorAt != nil {
+		lastErrorAt := *status.LastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
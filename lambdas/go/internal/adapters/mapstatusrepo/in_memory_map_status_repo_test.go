@@ -0,0 +1,40 @@
+package mapstatusrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryMapStatusRepo(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryMapStatusRepo()
+	first, second := newTestStatus(2), newTestStatus(1)
+
+	require.NoError(t, repo.Upsert(ctx, first))
+	require.NoError(t, repo.Upsert(ctx, second))
+
+	status, err := repo.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, *status)
+
+	// Changing the returned status must not change what is stored
+	status.Transition(domain.MapStageRaw, time.Now())
+	stored, err := repo.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.MapStageDiscovered, stored.Stage)
+	assert.Len(t, stored.Transitions, 1)
+
+	statuses, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MapStatus{second, first}, statuses)
+
+	require.NoError(t, repo.Delete(ctx, first.ID))
+	status, err = repo.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
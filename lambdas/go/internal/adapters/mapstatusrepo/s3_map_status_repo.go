@@ -0,0 +1,111 @@
+package mapstatusrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	statusFileExtension = ".json"
+	// listWorkers is how many statuses List fetches at the same time
+	listWorkers = 16
+)
+
+// s3MapStatusRepo stores one json object per map, keyed by the MapID, so concurrent updates of different maps
+// can never overwrite each other
+type s3MapStatusRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+}
+
+func NewS3MapStatusRepo(logger ports.Logger, bucketName string, awsClient awsclient.AWSClient) ports.MapStatusRepo {
+	return &s3MapStatusRepo{logger: logger, bucketName: bucketName, awsClient: awsClient}
+}
+
+func (repo *s3MapStatusRepo) Get(ctx context.Context, id domain.MapID) (*domain.MapStatus, error) {
+	object, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, createKeyFromMapID(id))
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the map status from s3", "id", id.String(), "error", err)
+		return nil, err
+	}
+	var status domain.MapStatus
+	if err = json.Unmarshal(object, &status); err != nil {
+		repo.logger.Error(ctx, "Error when unmarshalling the map status", "id", id.String(), "error", err)
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (repo *s3MapStatusRepo) List(ctx context.Context) ([]domain.MapStatus, error) {
+	objects, err := repo.awsClient.ListObjectsInS3(ctx, repo.bucketName, "")
+	if err != nil {
+		repo.logger.Error(ctx, "Error when attempting to list the map statuses in s3", "error", err)
+		return nil, err
+	}
+	var ids []domain.MapID
+	for _, objectKey := range objects {
+		name, isStatusFile := strings.CutSuffix(objectKey, statusFileExtension)
+		id, err := domain.ParseMapID(name)
+		if !isStatusFile || err != nil {
+			repo.logger.Warn(ctx, "The objectKey was not in the expected format", "objectKey", objectKey)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	// The statuses are fetched concurrently, every sync lists them all
+	fetched, err := infrastructure.MapConcurrently(ctx, ids, listWorkers, repo.Get)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]domain.MapStatus, 0, len(fetched))
+	for _, status := range fetched {
+		if status != nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID.String() < statuses[j].ID.String()
+	})
+	return statuses, nil
+}
+
+func (repo *s3MapStatusRepo) Upsert(ctx context.Context, status domain.MapStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when marshalling the map status", "id", status.ID.String(), "error", err)
+		return err
+	}
+	err = repo.awsClient.UploadToS3(ctx, repo.bucketName, createKeyFromMapID(status.ID), bytes.NewReader(data))
+	if err != nil {
+		repo.logger.Error(ctx, "Error when uploading the map status to s3", "id", status.ID.String(), "error", err)
+		return err
+	}
+	return nil
+}
+
+func (repo *s3MapStatusRepo) Delete(ctx context.Context, id domain.MapID) error {
+	if err := repo.awsClient.DeleteFromS3(ctx, repo.bucketName, createKeyFromMapID(id)); err != nil {
+		repo.logger.Error(ctx, "Error when deleting the map status from s3", "id", id.String(), "error", err)
+		return err
+	}
+	return nil
+}
+
+func createKeyFromMapID(id domain.MapID) string {
+	return id.String() + statusFileExtension
+}
@@ -0,0 +1,107 @@
+package mapstatusrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStatus(day int) domain.MapStatus {
+	status := domain.NewMapStatus(domain.MapID{
+		Provider: domain.MapProviderEogdata,
+		MapType:  domain.MapTypeDaily,
+		Bounds:   domain.BoundsUkraineAndAround,
+		Date:     domain.Date{Day: day, Month: 1, Year: 2023},
+	})
+	status.Transition(domain.MapStageDiscovered, time.Date(2023, 1, day+1, 0, 0, 0, 0, time.UTC))
+	return status
+}
+
+func TestS3MapStatusRepo_Get(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatusRepo(ports.NewMockLogger(t), "test-bucket", mockAWSClient)
+
+	existing := newTestStatus(1)
+	data, err := json.Marshal(existing)
+	require.NoError(t, err)
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "eog-day-b1-20230101.json").Return(data, nil)
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", "eog-day-b1-20230102.json").Return(nil, &types.NoSuchKey{})
+
+	status, err := repo.Get(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, existing, *status)
+
+	status, err = repo.Get(ctx, newTestStatus(2).ID)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
+
+func TestS3MapStatusRepo_List(t *testing.T) {
+	ctx := context.Background()
+	mockLogger := ports.NewMockLogger(t)
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatusRepo(mockLogger, "test-bucket", mockAWSClient)
+
+	first, second := newTestStatus(1), newTestStatus(2)
+	firstData, _ := json.Marshal(first)
+	secondData, _ := json.Marshal(second)
+	mockAWSClient.On("ListObjectsInS3", ctx, "test-bucket", "").
+		Return([]string{"eog-day-b1-20230102.json", "notes.txt", "eog-day-b1-20230101.json"}, nil)
+	// The statuses are fetched concurrently with a context of their own
+	mockAWSClient.On("GetFromS3", mock.Anything, "test-bucket", "eog-day-b1-20230101.json").Return(firstData, nil)
+	mockAWSClient.On("GetFromS3", mock.Anything, "test-bucket", "eog-day-b1-20230102.json").Return(secondData, nil)
+	mockLogger.On("Warn", ctx, "The objectKey was not in the expected format", "objectKey", "notes.txt").Return()
+
+	statuses, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.MapStatus{first, second}, statuses)
+}
+
+func TestS3MapStatusRepo_Upsert(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatusRepo(ports.NewMockLogger(t), "test-bucket", mockAWSClient)
+
+	status := newTestStatus(1)
+	mockAWSClient.On("UploadToS3", ctx, "test-bucket", "eog-day-b1-20230101.json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			body, err := io.ReadAll(args.Get(3).(*bytes.Reader))
+			require.NoError(t, err)
+			var uploaded domain.MapStatus
+			require.NoError(t, json.Unmarshal(body, &uploaded))
+			assert.Equal(t, status, uploaded)
+		}).
+		Return(nil)
+
+	assert.NoError(t, repo.Upsert(ctx, status))
+}
+
+func TestS3MapStatusRepo_ListFailsWhenAStatusCanNotBeFetched(t *testing.T) {
+	ctx := context.Background()
+	mockLogger := ports.NewMockLogger(t)
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3MapStatusRepo(mockLogger, "test-bucket", mockAWSClient)
+
+	mockAWSClient.On("ListObjectsInS3", ctx, "test-bucket", "").
+		Return([]string{"eog-day-b1-20230101.json"}, nil)
+	mockAWSClient.On("GetFromS3", mock.Anything, "test-bucket", "eog-day-b1-20230101.json").
+		Return(nil, errors.New("access denied"))
+	mockLogger.On("Error", mock.Anything, "Error when attempting to get the map status from s3", "id",
+		"eog-day-b1-20230101", "error", mock.Anything).Return()
+
+	_, err := repo.List(ctx)
+	assert.EqualError(t, err, "access denied")
+}
@@ -0,0 +1,67 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+)
+
+const (
+	KindMapbox = "mapbox"
+	KindXYZ    = "xyz"
+)
+
+// Config selects the tile server the maps are published to, mapbox hosts them as tilesets and xyz cuts them into png
+// tiles that are served from the cdn bucket
+type Config struct {
+	Kind string
+	// SecretsKey is the secret with the mapbox credentials, only mapbox needs it
+	SecretsKey string
+	Mapbox     MapboxClientConfig
+	// BucketName, BaseURL, MinZoom and MaxZoom are where and how deep xyz cuts the tiles
+	BucketName string
+	BaseURL    string
+	MinZoom    int
+	MaxZoom    int
+}
+
+// ConfigFromEnv reads the config from the environment, mapbox is the default so the deployments keep working
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		Kind:       infrastructure.GetEnvOrDefault("TILE_SERVER", KindMapbox),
+		SecretsKey: infrastructure.GetEnvOrDefault("CONFLICT_NIGHTLIGHT_SECRETS_KEY", "conflict-nightlight-secrets"),
+		Mapbox:     MapboxClientConfig{BaseURL: infrastructure.GetEnvOrDefault("MAPBOX_API_URL", DefaultMapboxBaseURL)},
+		BucketName: infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", "conflict-nightlight-cdn"),
+		BaseURL:    infrastructure.GetEnvOrDefault("TILES_BASE_URL", "https://cdn.conflictnightlight.com"),
+	}
+	var err error
+	// The frontend shows the maps from zoom 4 to 9, mapbox gl requests 256 pixel tiles one zoom deeper than it shows
+	if c.MinZoom, err = strconv.Atoi(infrastructure.GetEnvOrDefault("TILES_MIN_ZOOM", "4")); err != nil {
+		return Config{}, fmt.Errorf("invalid TILES_MIN_ZOOM: %w", err)
+	}
+	if c.MaxZoom, err = strconv.Atoi(infrastructure.GetEnvOrDefault("TILES_MAX_ZOOM", "10")); err != nil {
+		return Config{}, fmt.Errorf("invalid TILES_MAX_ZOOM: %w", err)
+	}
+	return c, nil
+}
+
+// NewTileServerRepo creates the tile server the config selects, it fails for an unknown kind
+func NewTileServerRepo(
+	ctx context.Context,
+	logger ports.Logger,
+	c Config,
+	awsClient awsclient.AWSClient,
+) (ports.MapTileServerRepo, error) {
+	switch c.Kind {
+	case KindMapbox:
+		return NewMapboxTileServerRepo(ctx, logger, c.SecretsKey, c.Mapbox, awsClient)
+	case KindXYZ:
+		return NewXYZTileServerRepo(logger, c.BucketName, c.BaseURL, c.MinZoom, c.MaxZoom, awsClient), nil
+	default:
+		return nil, fmt.Errorf("unknown tile server %q, expected %s or %s", c.Kind, KindMapbox, KindXYZ)
+	}
+}
@@ -0,0 +1,63 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// lazyTileServerRepo creates the tile server on its first use, so the commands that never touch it, e.g. serve, do
+// not need what creating it needs, e.g. the mapbox token from secrets manager
+type lazyTileServerRepo struct {
+	newRepo func() (ports.MapTileServerRepo, error)
+	once    sync.Once
+	repo    ports.MapTileServerRepo
+	err     error
+}
+
+// NewLazyTileServerRepo calls newRepo once, on the first call of the tile server. Every call fails with its error
+// when it fails
+func NewLazyTileServerRepo(newRepo func() (ports.MapTileServerRepo, error)) ports.MapTileServerRepo {
+	return &lazyTileServerRepo{newRepo: newRepo}
+}
+
+func (l *lazyTileServerRepo) Publish(ctx context.Context, m domain.LocalMap) (*domain.PublishedMap, error) {
+	repo, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return repo.Publish(ctx, m)
+}
+
+func (l *lazyTileServerRepo) List(ctx context.Context) ([]domain.PublishedMap, error) {
+	repo, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return repo.List(ctx)
+}
+
+func (l *lazyTileServerRepo) Delete(ctx context.Context, m domain.Map) error {
+	repo, err := l.get()
+	if err != nil {
+		return err
+	}
+	return repo.Delete(ctx, m)
+}
+
+func (l *lazyTileServerRepo) PlanDelete(ctx context.Context, m domain.Map) ([]string, error) {
+	repo, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	return repo.PlanDelete(ctx, m)
+}
+
+func (l *lazyTileServerRepo) get() (ports.MapTileServerRepo, error) {
+	l.once.Do(func() {
+		l.repo, l.err = l.newRepo()
+	})
+	return l.repo, l.err
+}
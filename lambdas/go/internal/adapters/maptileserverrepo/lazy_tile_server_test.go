@@ -0,0 +1,39 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyTileServerRepo_CreatesTheTileServerOnItsFirstUse(t *testing.T) {
+	created := 0
+	repo := NewLazyTileServerRepo(func() (ports.MapTileServerRepo, error) {
+		created++
+		return fakes.NewMapTileServerRepo(), nil
+	})
+	assert.Zero(t, created, "the tile server is not created before it is used")
+
+	_, err := repo.List(context.Background())
+	require.NoError(t, err)
+	_, err = repo.PlanDelete(context.Background(), testMapID(t).ToMap(""))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, created)
+}
+
+func TestLazyTileServerRepo_EveryCallFailsWhenTheTileServerCannotBeCreated(t *testing.T) {
+	errNoToken := errors.New("no mapbox token")
+	repo := NewLazyTileServerRepo(func() (ports.MapTileServerRepo, error) {
+		return nil, errNoToken
+	})
+
+	_, err := repo.List(context.Background())
+	assert.ErrorIs(t, err, errNoToken)
+	assert.ErrorIs(t, repo.Delete(context.Background(), testMapID(t).ToMap("")), errNoToken)
+}
@@ -0,0 +1,282 @@
+package maptileserverrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	DefaultMapboxBaseURL = "https://api.mapbox.com"
+	// defaultMapboxRequestTimeout bounds a single request to the mapbox api
+	defaultMapboxRequestTimeout = 30 * time.Second
+	// defaultMapboxStagingTimeout bounds the upload of a tif to the staging bucket of mapbox
+	defaultMapboxStagingTimeout = 10 * time.Minute
+	// mapboxStagingRegion is where the staging bucket of mapbox is, it is the real s3 regardless of the endpoints our
+	// own services use
+	mapboxStagingRegion = "us-east-1"
+)
+
+// MapboxClientConfig is how the mapbox api is reached, the zero value reaches the real api with the default timeouts
+type MapboxClientConfig struct {
+	BaseURL string
+	// HTTPClient sends the requests to the api and the staging bucket, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// RequestTimeout bounds every request to the api, StagingTimeout the upload of a tif to the staging bucket
+	RequestTimeout time.Duration
+	StagingTimeout time.Duration
+	// StagingEndpoint replaces the staging bucket, e.g. with an httptest server, the bucket is then addressed by path
+	StagingEndpoint string
+}
+
+// mapboxClient sends the requests of the tile server to the mapbox api, every request follows the context and
+// failures are returned to the caller
+type mapboxClient struct {
+	baseURL         string
+	username        string
+	accessToken     string
+	httpClient      *http.Client
+	requestTimeout  time.Duration
+	stagingTimeout  time.Duration
+	stagingEndpoint string
+}
+
+// mapboxAPIError is a response of the api with an unexpected status code
+type mapboxAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *mapboxAPIError) Error() string {
+	return fmt.Sprintf("unexpected http status code %d was returned from mapbox api: %s", e.StatusCode, e.Body)
+}
+
+type mapboxTileset struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func nextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+type mapBoxTempCreds struct {
+	AccessKeyId     string `json:"accessKeyId"`
+	Bucket          string `json:"bucket"`
+	Key             string `json:"key"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken"`
+	URL             string `json:"url"`
+}
+
+type uploadStatus struct {
+	Complete bool      `json:"complete"`
+	Tileset  string    `json:"tileset"`
+	Error    *string   `json:"error"`
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Modified time.Time `json:"modified"`
+	Created  time.Time `json:"created"`
+	Owner    string    `json:"owner"`
+	// Progress is the fraction of the upload that was processed, between 0 and 1
+	Progress float64 `json:"progress"`
+}
+
+func newMapboxClient(c MapboxClientConfig, username, accessToken string) *mapboxClient {
+	client := &mapboxClient{
+		baseURL:         strings.TrimSuffix(c.BaseURL, "/"),
+		username:        username,
+		accessToken:     accessToken,
+		httpClient:      c.HTTPClient,
+		requestTimeout:  c.RequestTimeout,
+		stagingTimeout:  c.StagingTimeout,
+		stagingEndpoint: c.StagingEndpoint,
+	}
+	if client.baseURL == "" {
+		client.baseURL = DefaultMapboxBaseURL
+	}
+	if client.httpClient == nil {
+		client.httpClient = http.DefaultClient
+	}
+	if client.requestTimeout <= 0 {
+		client.requestTimeout = defaultMapboxRequestTimeout
+	}
+	if client.stagingTimeout <= 0 {
+		client.stagingTimeout = defaultMapboxStagingTimeout
+	}
+	return client
+}
+
+// uploadCredentials see https://docs.mapbox.com/api/maps/uploads/#retrieve-s3-credentials
+func (c *mapboxClient) uploadCredentials(ctx context.Context) (*mapBoxTempCreds, error) {
+	var tempCreds mapBoxTempCreds
+	path := fmt.Sprintf("/uploads/v1/%s/credentials", c.username)
+	if _, err := c.do(ctx, http.MethodPost, c.baseURL+path, nil, http.StatusOK, &tempCreds); err != nil {
+		return nil, err
+	}
+	if tempCreds.AccessKeyId == "" {
+		return nil, errors.New("there was an issue when extracting the temporary credentials from mapbox")
+	}
+	return &tempCreds, nil
+}
+
+// stage uploads the tif to the bucket the credentials grant access to, mapbox reads it from there
+func (c *mapboxClient) stage(ctx context.Context, localFilepath string, tempCreds mapBoxTempCreds) error {
+	file, err := os.Open(localFilepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	options := s3.Options{
+		Region: mapboxStagingRegion,
+		Credentials: credentials.NewStaticCredentialsProvider(
+			tempCreds.AccessKeyId,
+			tempCreds.SecretAccessKey,
+			tempCreds.SessionToken,
+		),
+		HTTPClient: c.httpClient,
+	}
+	if c.stagingEndpoint != "" {
+		options.BaseEndpoint = aws.String(c.stagingEndpoint)
+		options.UsePathStyle = true
+	}
+	ctx, cancel := withDeadline(ctx, c.stagingTimeout)
+	defer cancel()
+	_, err = s3.New(options).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(tempCreds.Bucket),
+		Key:    aws.String(tempCreds.Key),
+		Body:   file,
+	})
+	return err
+}
+
+// createUpload asks mapbox to turn the staged tif into the tileset, see
+// https://docs.mapbox.com/api/maps/uploads/#create-an-upload
+func (c *mapboxClient) createUpload(
+	ctx context.Context,
+	tempCreds mapBoxTempCreds,
+	tilesetName string,
+) (*uploadStatus, error) {
+	//  tileset: the name passed along to the frontend
+	//  name: what is shown in the mapbox ui
+	requestBody, err := json.Marshal(map[string]string{
+		"url":     fmt.Sprintf("http://%s.s3.amazonaws.com/%s", tempCreds.Bucket, tempCreds.Key),
+		"tileset": fmt.Sprintf("%s.%s", c.username, tilesetName),
+		"name":    tilesetName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var status uploadStatus
+	path := "/uploads/v1/" + c.username
+	if _, err = c.do(ctx, http.MethodPost, c.baseURL+path, requestBody, http.StatusCreated, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// getUploadStatus see https://docs.mapbox.com/api/maps/uploads/#retrieve-upload-status
+func (c *mapboxClient) getUploadStatus(ctx context.Context, uploadID string) (*uploadStatus, error) {
+	var status uploadStatus
+	path := fmt.Sprintf("/uploads/v1/%s/%s", c.username, uploadID)
+	if _, err := c.do(ctx, http.MethodGet, c.baseURL+path, nil, http.StatusOK, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// deleteTileset returns false without an error when mapbox does not know the tileset
+func (c *mapboxClient) deleteTileset(ctx context.Context, tilesetName string) (bool, error) {
+	path := fmt.Sprintf("/tilesets/v1/%s.%s", c.username, tilesetName)
+	_, err := c.do(ctx, http.MethodDelete, c.baseURL+path, nil, http.StatusOK, nil)
+	var apiErr *mapboxAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// firstTilesetsPage is the url listTilesetsPage starts with, the following pages come from the Link header
+func (c *mapboxClient) firstTilesetsPage() string {
+	return fmt.Sprintf("%s/tilesets/v1/%s?limit=500", c.baseURL, c.username)
+}
+
+// listTilesetsPage returns the url of the next page when mapbox has more tilesets, see the Link header in
+// https://docs.mapbox.com/api/overview/#pagination
+func (c *mapboxClient) listTilesetsPage(ctx context.Context, pageURL string) ([]mapboxTileset, string, error) {
+	var tilesets []mapboxTileset
+	header, err := c.do(ctx, http.MethodGet, pageURL, nil, http.StatusOK, &tilesets)
+	if err != nil {
+		return nil, "", err
+	}
+	return tilesets, nextPageURL(header.Get("Link")), nil
+}
+
+// do sends the request with the access token, a json body is sent when body is not nil and the response is decoded
+// into result when it is not nil
+func (c *mapboxClient) do(
+	ctx context.Context,
+	method, rawURL string,
+	body []byte,
+	expectedStatus int,
+	result any,
+) (http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("access_token", c.accessToken)
+	u.RawQuery = query.Encode()
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		// The body explains the failure, e.g. an invalid token, it is cut short in case it is a html error page
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &mapboxAPIError{StatusCode: resp.StatusCode, Body: string(message)}
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return nil, fmt.Errorf("decoding the response of mapbox api: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,226 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMapbox stands in for the mapbox api and its staging bucket, the handlers are keyed by "METHOD path"
+type fakeMapbox struct {
+	mu       sync.Mutex
+	requests []string
+	tokens   []string
+	handlers map[string]http.HandlerFunc
+}
+
+func (f *fakeMapbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + r.URL.Path
+	f.mu.Lock()
+	f.requests = append(f.requests, route)
+	f.tokens = append(f.tokens, r.URL.Query().Get("access_token"))
+	handler, ok := f.handlers[route]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// newTestMapboxRepo returns a repo whose client reaches the fake for both the api and the staging bucket
+func newTestMapboxRepo(t *testing.T, handlers map[string]http.HandlerFunc) (*mapBoxTileServerRepo, *fakeMapbox) {
+	fake := &fakeMapbox{handlers: handlers}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := newMapboxClient(MapboxClientConfig{
+		BaseURL:         server.URL,
+		HTTPClient:      server.Client(),
+		StagingEndpoint: server.URL,
+	}, "user", "token")
+	return &mapBoxTileServerRepo{
+		logger:            fakes.NewLogger(),
+		client:            client,
+		uploadPollBackoff: backoff{initial: time.Millisecond, max: 4 * time.Millisecond},
+	}, fake
+}
+
+var testTempCreds = mapBoxTempCreds{
+	AccessKeyId:     "key-id",
+	SecretAccessKey: "secret",
+	SessionToken:    "session",
+	Bucket:          "staging",
+	Key:             "upload.tif",
+}
+
+func writeTestTif(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "map.tif")
+	require.NoError(t, os.WriteFile(path, []byte("tif"), 0o600))
+	return path
+}
+
+func TestMapboxTileServerRepo_Publish(t *testing.T) {
+	var staged []byte
+	var upload map[string]string
+	repo, fake := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"POST /uploads/v1/user/credentials": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, testTempCreds)
+		},
+		"PUT /staging/upload.tif": func(w http.ResponseWriter, r *http.Request) {
+			staged, _ = io.ReadAll(r.Body)
+		},
+		"POST /uploads/v1/user": func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&upload)
+			writeJSON(w, http.StatusCreated, uploadStatus{ID: "upload-1"})
+		},
+		"GET /uploads/v1/user/upload-1": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, uploadStatus{ID: "upload-1", Complete: true, Tileset: "user.eog-day-b1-20220101"})
+		},
+	})
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+
+	published, err := repo.Publish(context.Background(), domain.LocalMap{Filepath: writeTestTif(t), Map: id.ToMap("")})
+
+	require.NoError(t, err)
+	assert.Equal(t, "mapbox://user.eog-day-b1-20220101", published.Url)
+	assert.Equal(t, "tif", string(staged))
+	assert.Equal(t, map[string]string{
+		"url":     "http://staging.s3.amazonaws.com/upload.tif",
+		"tileset": "user.eog-day-b1-20220101",
+		"name":    "eog-day-b1-20220101",
+	}, upload)
+	assert.Equal(t, []string{"token", "", "token", "token"}, fake.tokens,
+		"the token is sent to the api but not to the staging bucket")
+}
+
+func TestMapboxTileServerRepo_PublishReturnsAFailedStagingUpload(t *testing.T) {
+	repo, _ := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"POST /uploads/v1/user/credentials": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, testTempCreds)
+		},
+		"PUT /staging/upload.tif": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code></Error>`))
+		},
+	})
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+
+	_, err = repo.Publish(context.Background(), domain.LocalMap{Filepath: writeTestTif(t), Map: id.ToMap("")})
+
+	assert.ErrorContains(t, err, "AccessDenied")
+}
+
+func TestMapboxTileServerRepo_DeleteIgnoresMissingTilesets(t *testing.T) {
+	repo, fake := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"DELETE /tilesets/v1/user.eog-day-b1-20220101": func(w http.ResponseWriter, r *http.Request) {},
+	})
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(context.Background(), id.ToMap("")))
+
+	assert.Equal(t, []string{
+		"DELETE /tilesets/v1/user.eog-day-b1-20220101",
+		"DELETE /tilesets/v1/user." + id.LegacyName(),
+	}, fake.requests)
+}
+
+func TestMapboxTileServerRepo_DeleteReturnsAnAPIError(t *testing.T) {
+	repo, _ := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"DELETE /tilesets/v1/user.eog-day-b1-20220101": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"Not Authorized - Invalid Token"}`))
+		},
+	})
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+
+	err = repo.Delete(context.Background(), id.ToMap(""))
+
+	var apiErr *mapboxAPIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Body, "Invalid Token")
+}
+
+func TestMapboxTileServerRepo_ListFollowsThePages(t *testing.T) {
+	var serverURL string
+	repo, _ := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"GET /tilesets/v1/user": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("start") == "" {
+				w.Header().Set("Link", "<"+serverURL+`/tilesets/v1/user?start=page-2&limit=500>; rel="next"`)
+				writeJSON(w, http.StatusOK, []mapboxTileset{{ID: "user.eog-day-b1-20220101"}})
+				return
+			}
+			writeJSON(w, http.StatusOK, []mapboxTileset{{ID: "user.eog-day-b1-20220102"}, {ID: "user.terrain"}})
+		},
+	})
+	serverURL = repo.client.baseURL
+
+	published, err := repo.List(context.Background())
+
+	require.NoError(t, err)
+	require.Len(t, published, 2)
+	assert.Equal(t, "mapbox://user.eog-day-b1-20220101", published[0].Url)
+	assert.Equal(t, "mapbox://user.eog-day-b1-20220102", published[1].Url)
+}
+
+func TestMapboxClient_RequestsTimeOut(t *testing.T) {
+	release := make(chan struct{})
+	repo, _ := newTestMapboxRepo(t, map[string]http.HandlerFunc{
+		"GET /uploads/v1/user/upload-1": func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		},
+	})
+	defer close(release)
+	repo.client.requestTimeout = 10 * time.Millisecond
+
+	_, err := repo.client.getUploadStatus(context.Background(), "upload-1")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewMapboxTileServerRepo_ReturnsMissingSecrets(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	mockAWSClient.On("GetSecretFromSecretsManager", ctx, "secrets").
+		Return(map[string]interface{}{"mapboxUsername": "user"}, nil).Once()
+	mockAWSClient.On("GetSecretFromSecretsManager", ctx, "secrets").
+		Return(nil, errors.New("throttled")).Once()
+
+	_, err := NewMapboxTileServerRepo(ctx, fakes.NewLogger(), "secrets", MapboxClientConfig{}, mockAWSClient)
+	assert.ErrorContains(t, err, "are not filled")
+
+	_, err = NewMapboxTileServerRepo(ctx, fakes.NewLogger(), "secrets", MapboxClientConfig{}, mockAWSClient)
+	assert.ErrorContains(t, err, "throttled")
+}
+
+func TestNextPageURL(t *testing.T) {
+	header := `<https://api.mapbox.com/tilesets/v1/user?start=abc&limit=500>; rel="next", ` +
+		`<https://api.mapbox.com/tilesets/v1/user?limit=500>; rel="first"`
+	assert.Equal(t, "https://api.mapbox.com/tilesets/v1/user?start=abc&limit=500", nextPageURL(header))
+	assert.Equal(t, "", nextPageURL(`<https://api.mapbox.com/tilesets/v1/user?limit=500>; rel="first"`))
+	assert.Equal(t, "", nextPageURL(""))
+}
@@ -1,30 +1,36 @@
 package maptileserverrepo
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/mitchellh/mapstructure"
 )
 
+// uploadTimeout bounds how long Publish waits for mapbox to process an upload when the context has no earlier deadline
+const uploadTimeout = 15 * time.Minute
+
+// deadlineMargin is the time left when the requests to mapbox give up before the deadline of the context, i.e. the
+// end of the lambda invocation, so the failure can still be recorded before lambda stops the invocation
+const deadlineMargin = 10 * time.Second
+
+// backoff is how long to wait between two requests, the wait doubles after every request up to max
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+var defaultUploadPollBackoff = backoff{initial: 2 * time.Second, max: 30 * time.Second}
+
 type mapBoxTileServerRepo struct {
-	logger    ports.Logger
-	awsClient awsclient.AWSClient
-	secrets   mapboxSecrets
+	logger            ports.Logger
+	client            *mapboxClient
+	uploadPollBackoff backoff
 }
 
 type mapboxSecrets struct {
@@ -32,294 +38,166 @@ type mapboxSecrets struct {
 	MapboxUsername    string `json:"mapboxUsername"`
 }
 
-func NewMapboxTileServerRepo(ctx context.Context, logger ports.Logger, secretsKey string) ports.MapTileServerRepo {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-central-1"))
-	if err != nil {
-		logger.Fatal(ctx, "Error when attempting to load the aws config", "error", err)
-	}
-	awsClient := awsclient.NewAWSClient(cfg)
+// NewMapboxTileServerRepo reads the mapbox credentials from the secret, the client config selects how the api is
+// reached
+func NewMapboxTileServerRepo(
+	ctx context.Context,
+	logger ports.Logger,
+	secretsKey string,
+	clientConfig MapboxClientConfig,
+	awsClient awsclient.AWSClient,
+) (ports.MapTileServerRepo, error) {
 	rawSecrets, err := awsClient.GetSecretFromSecretsManager(ctx, secretsKey)
 	if err != nil {
-		logger.Fatal(ctx, "Error when getting secrets from secrets manager", "secretKey", secretsKey, "error", err)
+		return nil, fmt.Errorf("getting the secret %s from secrets manager: %w", secretsKey, err)
 	}
 	var secrets mapboxSecrets
-	err = mapstructure.Decode(rawSecrets, &secrets)
-	if err != nil {
-		logger.Fatal(ctx, "Error when getting secrets from secrets manager", "secretKey", secretsKey, "error", err)
+	if err = mapstructure.Decode(rawSecrets, &secrets); err != nil {
+		return nil, fmt.Errorf("decoding the secret %s: %w", secretsKey, err)
 	}
 	if secrets.MapboxPublicToken == "" || secrets.MapboxUsername == "" {
-		logger.Fatal(ctx, "Secrets were not filled", "secretKey", secretsKey)
+		return nil, fmt.Errorf("the mapbox token or username of the secret %s are not filled", secretsKey)
 	}
-	return &mapBoxTileServerRepo{logger: logger, secrets: secrets, awsClient: awsClient}
+	return &mapBoxTileServerRepo{
+		logger:            logger,
+		client:            newMapboxClient(clientConfig, secrets.MapboxUsername, secrets.MapboxPublicToken),
+		uploadPollBackoff: defaultUploadPollBackoff,
+	}, nil
 }
 
 func (repo *mapBoxTileServerRepo) Publish(ctx context.Context, m domain.LocalMap) (*domain.PublishedMap, error) {
-	tempCreds, err := repo.getMapboxTempCreds(ctx, repo.secrets.MapboxUsername, repo.secrets.MapboxPublicToken)
+	tempCreds, err := repo.client.uploadCredentials(ctx)
 	if err != nil {
 		repo.logger.Error(ctx, "Error when getting temp creds from mapbox", "error", err)
 		return nil, err
 	}
-	if tempCreds == nil {
-		repo.logger.Error(ctx, "Error when getting temp creds from mapbox", "error", errors.New("temp creds were nil"))
-		return nil, errors.New("temp creds were nil")
+	repo.logger.Debug(ctx, "Uploading tif to Mapbox's temp s3 bucket", "localFilepath", m.Filepath)
+	if err = repo.client.stage(ctx, m.Filepath, *tempCreds); err != nil {
+		repo.logger.Error(ctx, "Error when uploading the tif to mapbox's s3 bucket", "error", err)
+		return nil, err
 	}
-	repo.uploadToMapboxTempS3(ctx, m.Filepath, *tempCreds)
 	repo.logger.Debug(ctx, "Uploaded to mapbox's temp s3 succeeded, attempting to notify mapbox.")
-	tileset, err := repo.uploadToMapbox(
-		ctx,
-		*tempCreds,
-		repo.secrets.MapboxPublicToken,
-		repo.secrets.MapboxUsername,
-		m.Map.String(),
-	)
+	status, err := repo.client.createUpload(ctx, *tempCreds, m.Map.ID().String())
 	if err != nil {
 		repo.logger.Error(ctx, "Error when uploading to mapbox", "error", err)
 		return nil, err
 	}
-	repo.logger.Info(ctx, "Mapbox was updated with a new tileset, updating frontend.", "tilesetName", tileset)
-	return &domain.PublishedMap{Map: m.Map, Url: fmt.Sprintf("mapbox://%s", tileset)}, nil
-}
-
-func (repo *mapBoxTileServerRepo) Delete(ctx context.Context, m domain.Map) error {
-	repo.logger.Info(ctx, "Deleting map from mapbox", "map", m.String())
-	tilesetID := fmt.Sprintf("%s.%s", repo.secrets.MapboxUsername, m.String())
-
-	url := fmt.Sprintf("https://api.mapbox.com/tilesets/v1/%s", tilesetID)
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		repo.logger.Error(ctx, "Error when creating request for deleting Mapbox tileset", "error", err)
-		return err
-	}
-
-	query := req.URL.Query()
-	query.Add("access_token", repo.secrets.MapboxPublicToken)
-	req.URL.RawQuery = query.Encode()
-
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+	// Mapbox processes the upload after it was accepted, until then the tileset does not exist and the frontend would
+	// show a broken layer
+	waitCtx, cancel := withDeadline(ctx, uploadTimeout)
+	defer cancel()
+	status, err = repo.waitForUpload(waitCtx, status, repo.client.getUploadStatus)
 	if err != nil {
-		repo.logger.Error(ctx, "Error when performing http.Client request for deleting Mapbox tileset", "error", err)
-		return err
-	}
-
-	defer func(Body io.ReadCloser) {
-		if err := Body.Close(); err != nil {
-			repo.logger.Error(
-				ctx,
-				"There was an error when trying to close the response body from the mapbox api",
-				"error",
-				err,
-			)
-		}
-	}(resp.Body)
-
-	if resp.StatusCode != 200 {
-		repo.logger.Error(
-			ctx,
-			"Deleting the tileset via the Mapbox API resulted in an unexpected http status code",
-			"statusCode",
-			resp.StatusCode,
-			"responseBody",
-			resp.Body,
-		)
-		return errors.New("unexpected http status code was returned from Mapbox API")
+		repo.logger.Error(ctx, "Error when waiting for mapbox to process the upload", "error", err)
+		return nil, err
 	}
-	return nil
-}
-
-type mapBoxTempCreds struct {
-	AccessKeyId     string `json:"accessKeyId"`
-	Bucket          string `json:"bucket"`
-	Key             string `json:"key"`
-	SecretAccessKey string `json:"secretAccessKey"`
-	SessionToken    string `json:"sessionToken"`
-	URL             string `json:"url"`
+	repo.logger.Info(ctx, "Mapbox was updated with a new tileset, updating frontend.", "tilesetName", status.Tileset)
+	return &domain.PublishedMap{Map: m.Map, Url: fmt.Sprintf("mapbox://%s", status.Tileset)}, nil
 }
 
-type uploadStatus struct {
-	Complete bool      `json:"complete"`
-	Tileset  string    `json:"tileset"`
-	Error    *string   `json:"error"`
-	ID       string    `json:"id"`
-	Name     string    `json:"name"`
-	Modified time.Time `json:"modified"`
-	Created  time.Time `json:"created"`
-	Owner    string    `json:"owner"`
-	Progress int       `json:"progress"`
+// withDeadline bounds the context by the timeout and by its own deadline minus deadlineMargin, whichever is earlier
+func withDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Add(-deadlineMargin).Before(deadline) {
+		deadline = ctxDeadline.Add(-deadlineMargin)
+	}
+	return context.WithDeadline(ctx, deadline)
 }
 
-func (repo *mapBoxTileServerRepo) getMapboxTempCreds(
+// waitForUpload asks mapbox for the status of the upload with backoff until it is complete or failed, it gives up
+// when the context is done
+func (repo *mapBoxTileServerRepo) waitForUpload(
 	ctx context.Context,
-	username string,
-	token string,
-) (*mapBoxTempCreds, error) {
-	url := fmt.Sprintf("https://api.mapbox.com/uploads/v1/%s/credentials?access_token=%s", username, token)
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		repo.logger.Error(ctx, "Error when creating request for mapbox Credentials", "error", err)
-		return nil, err
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		repo.logger.Error(ctx, "Error when performing http.Client request for mapbox Credentials", "error", err)
-		return nil, err
-	}
+	status *uploadStatus,
+	getStatus func(ctx context.Context, uploadID string) (*uploadStatus, error),
+) (*uploadStatus, error) {
+	wait := repo.uploadPollBackoff.initial
+	for {
+		if status.Error != nil {
+			return nil, fmt.Errorf("mapbox failed to process the upload %s: %s", status.ID, *status.Error)
+		}
+		if status.Complete {
+			return status, nil
+		}
+		repo.logger.Debug(ctx, "Waiting for mapbox to process the upload", "uploadID", status.ID,
+			"progress", status.Progress, "wait", wait.String())
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("the upload %s was %.0f%% processed when waiting for it stopped: %w",
+				status.ID, status.Progress*100, ctx.Err())
+		case <-timer.C:
+		}
+		wait = min(wait*2, repo.uploadPollBackoff.max)
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+		next, err := getStatus(ctx, status.ID)
 		if err != nil {
-			return
+			// The upload itself is not affected, the status is requested again after the next wait
+			repo.logger.Warn(ctx, "Error when getting the status of the upload from mapbox",
+				"uploadID", status.ID, "error", err)
+			continue
 		}
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		repo.logger.Error(ctx, "Error when reading the response from mapbox", "error", err)
-		return nil, err
-	}
-
-	var tempCreds mapBoxTempCreds
-	if err = json.Unmarshal(body, &tempCreds); err != nil {
-		repo.logger.Error(ctx, "Error when unmarshalling mapbox credentials", "error", err)
-		return nil, err
-	}
-
-	if tempCreds.AccessKeyId == "" {
-		repo.logger.Error(ctx, "There was an issue when extracting the temporary credentials", "response", string(body))
-		return nil, errors.New("there was an issue when extracting the temporary credentials from mapbox")
+		status = next
 	}
-	return &tempCreds, nil
 }
 
-func (repo *mapBoxTileServerRepo) uploadToMapboxTempS3(
-	ctx context.Context,
-	localFilepath string,
-	tempAWSCreds mapBoxTempCreds,
-) {
-	repo.logger.Debug(ctx, "Uploading tif to Mapbox's temp s3 bucket", "localFilepath", localFilepath)
-	cfg, err := config.LoadDefaultConfig(
-		ctx,
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				tempAWSCreds.AccessKeyId,
-				tempAWSCreds.SecretAccessKey,
-				tempAWSCreds.SessionToken,
-			),
-		),
-	)
-	if err != nil {
-		repo.logger.Fatal(
-			ctx,
-			"There was an error when attempting to create a config from the temp mapbox credentials.",
-			"error",
-			err,
-		)
-	}
-	cfg.Region = "us-east-1"
-
-	s3Client := s3.NewFromConfig(cfg)
-
-	file, err := os.Open(localFilepath)
-	if err != nil {
-		repo.logger.Fatal(
-			ctx,
-			"Error when attempting to open the file to upload to mapbox",
-			"file to upload",
-			localFilepath,
-			"error",
-			err,
-		)
-	}
-
-	defer func(file *os.File) {
-		err := file.Close()
+func (repo *mapBoxTileServerRepo) Delete(ctx context.Context, m domain.Map) error {
+	mapID := m.ID()
+	repo.logger.Info(ctx, "Deleting map from mapbox", "map", mapID.String())
+	found := false
+	// Tilesets published before MapID existed are named after the legacy format
+	for _, tilesetName := range []string{mapID.String(), mapID.LegacyName()} {
+		deleted, err := repo.client.deleteTileset(ctx, tilesetName)
 		if err != nil {
-			repo.logger.Error(ctx, "Error when trying to close the file we uploaded")
+			repo.logger.Error(ctx, "Error when deleting the tileset from mapbox", "tileset", tilesetName, "error", err)
+			return err
 		}
-	}(file)
-
-	if _, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(tempAWSCreds.Bucket),
-		Key:    aws.String(tempAWSCreds.Key),
-		Body:   file,
-	}); err != nil {
-		repo.logger.Fatal(
-			ctx,
-			"There was an error when attempting to upload the file to mapbox's s3 bucket.",
-			"error",
-			err,
-		)
+		found = found || deleted
+	}
+	if !found {
+		repo.logger.Info(ctx, "The tileset was not found in mapbox", "map", mapID.String())
 	}
+	return nil
 }
 
-func (repo *mapBoxTileServerRepo) uploadToMapbox(
-	ctx context.Context,
-	tempAWSCreds mapBoxTempCreds,
-	accessToken string,
-	username string,
-	tilesetName string,
-) (string, error) {
-	url := fmt.Sprintf("https://api.mapbox.com/uploads/v1/%s", username)
-
-	//  tileset: the name passed along to the frontend
-	//  name: what is shown in the mapbox ui
-	repo.logger.Debug(ctx, "Uploading file to mapbox", "tilesetName", tilesetName, "username", username)
-	requestBody, err := json.Marshal(map[string]string{
-		"url":     fmt.Sprintf("http://%s.s3.amazonaws.com/%s", tempAWSCreds.Bucket, tempAWSCreds.Key),
-		"tileset": fmt.Sprintf("%s.%s", username, tilesetName),
-		"name":    tilesetName,
-	})
+func (repo *mapBoxTileServerRepo) PlanDelete(ctx context.Context, m domain.Map) ([]string, error) {
+	publishedMaps, err := repo.List(ctx)
 	if err != nil {
-		repo.logger.Error(ctx, "when formatting the request body.", "error", err)
-		return "", err
+		return nil, err
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-	if err != nil {
-		repo.logger.Error(ctx, "when creating the HTTP request", "error", err)
-		return "", err
+	var tilesetIDs []string
+	for _, publishedMap := range publishedMaps {
+		if publishedMap.Map.ID() == m.ID() {
+			tilesetIDs = append(tilesetIDs, strings.TrimPrefix(publishedMap.Url, "mapbox://"))
+		}
 	}
+	return tilesetIDs, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	query := req.URL.Query()
-	query.Add("access_token", accessToken)
-	req.URL.RawQuery = query.Encode()
-
-	client := http.DefaultClient
-	resp, err := client.Do(req)
-	if err != nil {
-		repo.logger.Error(ctx, "when sending the HTTP request", "error", err)
-		return "", err
-	}
-	defer func(Body io.ReadCloser) {
-		if err := Body.Close(); err != nil {
-			repo.logger.Error(
-				ctx,
-				"There was an error when trying to close the response body from the mapbox api",
-				"error",
-				err,
-			)
+// List returns every tileset of the mapbox user that is named after a map, other tilesets are ignored
+func (repo *mapBoxTileServerRepo) List(ctx context.Context) ([]domain.PublishedMap, error) {
+	pageURL := repo.client.firstTilesetsPage()
+	var publishedMaps []domain.PublishedMap
+	for pageURL != "" {
+		tilesets, next, err := repo.client.listTilesetsPage(ctx, pageURL)
+		if err != nil {
+			repo.logger.Error(ctx, "Error when listing the mapbox tilesets", "error", err)
+			return nil, err
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != 201 {
-		repo.logger.Error(
-			ctx,
-			"Uploading the tif file via the mapbox api resulted in an unexpected http status code",
-			"statusCode",
-			resp.StatusCode,
-		)
-		return "", errors.New("unexpected http status code was returned from mapbox api")
-	}
-	var uploadStatus uploadStatus
-	if err = json.NewDecoder(resp.Body).Decode(&uploadStatus); err != nil {
-		repo.logger.Error(ctx, "when trying to unmarshal the response body.", "error", err)
-		return "", err
+		for _, tileset := range tilesets {
+			_, tilesetName, _ := strings.Cut(tileset.ID, ".")
+			id, err := domain.ParseMapIDOrLegacyName(tilesetName)
+			if err != nil {
+				repo.logger.Debug(ctx, "Ignoring a tileset that is not named after a map", "tileset", tileset.ID)
+				continue
+			}
+			publishedMaps = append(publishedMaps, domain.PublishedMap{
+				Map: id.ToMap(""),
+				Url: fmt.Sprintf("mapbox://%s", tileset.ID),
+			})
+		}
+		pageURL = next
 	}
-	return uploadStatus.Tileset, nil
+	return publishedMaps, nil
 }
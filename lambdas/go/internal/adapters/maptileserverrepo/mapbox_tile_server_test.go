@@ -0,0 +1,115 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPollingTestRepo() *mapBoxTileServerRepo {
+	return &mapBoxTileServerRepo{
+		logger:            fakes.NewLogger(),
+		uploadPollBackoff: backoff{initial: time.Millisecond, max: 4 * time.Millisecond},
+	}
+}
+
+// statusSequence answers the status requests with the statuses in order, and with the last one once they run out
+func statusSequence(statuses ...uploadStatus) (func(context.Context, string) (*uploadStatus, error), *int) {
+	requests := 0
+	return func(_ context.Context, uploadID string) (*uploadStatus, error) {
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		status.ID = uploadID
+		return &status, nil
+	}, &requests
+}
+
+func TestWaitForUpload_PollsUntilComplete(t *testing.T) {
+	repo := newPollingTestRepo()
+	getStatus, requests := statusSequence(
+		uploadStatus{Progress: 0.5},
+		uploadStatus{Complete: true, Progress: 1, Tileset: "user.eog-mon-b1-20230401"},
+	)
+
+	status, err := repo.waitForUpload(context.Background(), &uploadStatus{ID: "upload-1"}, getStatus)
+
+	require.NoError(t, err)
+	assert.Equal(t, "user.eog-mon-b1-20230401", status.Tileset)
+	assert.Equal(t, 2, *requests)
+}
+
+func TestWaitForUpload_FailsWhenMapboxReportsAnError(t *testing.T) {
+	repo := newPollingTestRepo()
+	message := "the tif has no georeference"
+	getStatus, _ := statusSequence(uploadStatus{Progress: 0.2}, uploadStatus{Error: &message})
+
+	_, err := repo.waitForUpload(context.Background(), &uploadStatus{ID: "upload-1"}, getStatus)
+
+	assert.EqualError(t, err, "mapbox failed to process the upload upload-1: the tif has no georeference")
+}
+
+func TestWaitForUpload_KeepsPollingAfterAFailedStatusRequest(t *testing.T) {
+	repo := newPollingTestRepo()
+	failed := false
+	getStatus := func(_ context.Context, uploadID string) (*uploadStatus, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("connection reset")
+		}
+		return &uploadStatus{ID: uploadID, Complete: true}, nil
+	}
+
+	status, err := repo.waitForUpload(context.Background(), &uploadStatus{ID: "upload-1"}, getStatus)
+
+	require.NoError(t, err)
+	assert.True(t, status.Complete)
+	assert.Len(t, repo.logger.(*fakes.Logger).Entries("warn"), 1)
+}
+
+func TestWaitForUpload_StopsAtTheDeadline(t *testing.T) {
+	repo := newPollingTestRepo()
+	getStatus, _ := statusSequence(uploadStatus{Progress: 0.25})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := repo.waitForUpload(ctx, &uploadStatus{ID: "upload-1"}, getStatus)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "the upload upload-1 was 25% processed")
+}
+
+func TestWithDeadline(t *testing.T) {
+	invocationDeadline := time.Now().Add(100 * time.Second)
+	invocationCtx, cancel := context.WithDeadline(context.Background(), invocationDeadline)
+	defer cancel()
+
+	t.Run("the deadline of the invocation leaves a margin", func(t *testing.T) {
+		ctx, cancel := withDeadline(invocationCtx, uploadTimeout)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Equal(t, invocationDeadline.Add(-deadlineMargin), deadline)
+	})
+
+	t.Run("a shorter timeout is kept", func(t *testing.T) {
+		ctx, cancel := withDeadline(invocationCtx, time.Second)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+	})
+
+	t.Run("without a deadline the timeout is used", func(t *testing.T) {
+		ctx, cancel := withDeadline(context.Background(), uploadTimeout)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		assert.WithinDuration(t, time.Now().Add(uploadTimeout), deadline, 100*time.Millisecond)
+	})
+}
@@ -0,0 +1,220 @@
+package maptileserverrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"strings"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/tiles"
+)
+
+const (
+	// tilesPrefix holds the tiles of every map below tiles/<map id>/{z}/{x}/{y}.png
+	tilesPrefix = "tiles/"
+	// tilesetsPrefix holds a tilejson per published map, a map is only listed once its tiles were all uploaded
+	tilesetsPrefix = "tilesets/"
+	// tileWorkers is how many tiles are rendered and uploaded, or deleted, at the same time
+	tileWorkers = 16
+)
+
+type xyzTileServerRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+	baseURL    string
+	minZoom    int
+	maxZoom    int
+}
+
+// tileJSON describes a published map, see https://github.com/mapbox/tilejson-spec/tree/master/3.0.0
+type tileJSON struct {
+	TileJSON string     `json:"tilejson"`
+	Name     string     `json:"name"`
+	Tiles    []string   `json:"tiles"`
+	MinZoom  int        `json:"minzoom"`
+	MaxZoom  int        `json:"maxzoom"`
+	Bounds   [4]float64 `json:"bounds"`
+}
+
+// NewXYZTileServerRepo hosts the maps as png tiles in the bucket, baseURL is where the bucket is served from, e.g. the
+// cdn, the tiles are cut from minZoom to maxZoom and the frontend scales the deepest ones up when zooming further in
+func NewXYZTileServerRepo(
+	logger ports.Logger,
+	bucketName, baseURL string,
+	minZoom, maxZoom int,
+	awsClient awsclient.AWSClient,
+) ports.MapTileServerRepo {
+	return &xyzTileServerRepo{
+		logger:     logger,
+		awsClient:  awsClient,
+		bucketName: bucketName,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		minZoom:    minZoom,
+		maxZoom:    maxZoom,
+	}
+}
+
+func (repo *xyzTileServerRepo) Publish(ctx context.Context, m domain.LocalMap) (*domain.PublishedMap, error) {
+	r, err := raster.ReadFile(m.Filepath)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when reading the map to cut it into tiles", "filepath", m.Filepath, "error", err)
+		return nil, err
+	}
+	west, south, east, north, err := tiles.Bounds(r)
+	if err != nil {
+		return nil, err
+	}
+	covering, err := tiles.Covering(r, repo.minZoom, repo.maxZoom)
+	if err != nil {
+		return nil, err
+	}
+	mapID := m.Map.ID()
+	uploaded, err := repo.uploadTiles(ctx, r, mapID, covering)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when uploading the tiles", "map", mapID.String(), "error", err)
+		return nil, err
+	}
+	url := repo.tileURL(mapID)
+	data, err := json.Marshal(tileJSON{
+		TileJSON: "3.0.0",
+		Name:     mapID.String(),
+		Tiles:    []string{url},
+		MinZoom:  repo.minZoom,
+		MaxZoom:  repo.maxZoom,
+		Bounds:   [4]float64{west, south, east, north},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = repo.awsClient.UploadToS3WithContentType(ctx, repo.bucketName, tilesetKey(mapID), "application/json",
+		bytes.NewReader(data))
+	if err != nil {
+		repo.logger.Error(ctx, "Error when uploading the tilejson", "map", mapID.String(), "error", err)
+		return nil, err
+	}
+	repo.logger.Info(ctx, "The map was cut into tiles", "map", mapID.String(), "tiles", uploaded)
+	return &domain.PublishedMap{Map: m.Map, Url: url}, nil
+}
+
+// uploadTiles renders and uploads the tiles with a pool of workers, tiles without data are skipped. The first error
+// stops the remaining tiles and is returned
+func (repo *xyzTileServerRepo) uploadTiles(
+	ctx context.Context,
+	r *raster.Raster,
+	mapID domain.MapID,
+	covering []tiles.Tile,
+) (int, error) {
+	rendered, err := infrastructure.MapConcurrently(ctx, covering, tileWorkers,
+		func(ctx context.Context, tile tiles.Tile) (bool, error) {
+			return repo.uploadTile(ctx, r, mapID, tile)
+		})
+	if err != nil {
+		return 0, err
+	}
+	uploaded := 0
+	for _, ok := range rendered {
+		if ok {
+			uploaded++
+		}
+	}
+	return uploaded, nil
+}
+
+// uploadTile returns false for a tile without data, it is not uploaded
+func (repo *xyzTileServerRepo) uploadTile(
+	ctx context.Context,
+	r *raster.Raster,
+	mapID domain.MapID,
+	tile tiles.Tile,
+) (bool, error) {
+	img, empty, err := tiles.Render(r, tile)
+	if err != nil || empty {
+		return false, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return false, err
+	}
+	key := tilePrefix(mapID) + tile.Path() + ".png"
+	if err := repo.awsClient.UploadToS3WithContentType(ctx, repo.bucketName, key, "image/png", &buf); err != nil {
+		return false, fmt.Errorf("uploading tile %s: %w", tile.Path(), err)
+	}
+	return true, nil
+}
+
+// List returns the maps that have a tilejson, other objects in the tilesets prefix are ignored
+func (repo *xyzTileServerRepo) List(ctx context.Context) ([]domain.PublishedMap, error) {
+	keys, err := repo.awsClient.ListObjectsInS3(ctx, repo.bucketName, tilesetsPrefix)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when listing the tilesets", "bucket", repo.bucketName, "error", err)
+		return nil, err
+	}
+	var publishedMaps []domain.PublishedMap
+	for _, key := range keys {
+		id, err := domain.ParseMapID(strings.TrimSuffix(strings.TrimPrefix(key, tilesetsPrefix), ".json"))
+		if err != nil {
+			repo.logger.Debug(ctx, "Ignoring a tileset that is not named after a map", "key", key)
+			continue
+		}
+		publishedMaps = append(publishedMaps, domain.PublishedMap{Map: id.ToMap(""), Url: repo.tileURL(id)})
+	}
+	return publishedMaps, nil
+}
+
+// Delete removes the tilejson first so a map whose tiles are half deleted is no longer listed
+func (repo *xyzTileServerRepo) Delete(ctx context.Context, m domain.Map) error {
+	mapID := m.ID()
+	repo.logger.Info(ctx, "Deleting the tiles of the map", "map", mapID.String())
+	if err := repo.awsClient.DeleteFromS3(ctx, repo.bucketName, tilesetKey(mapID)); err != nil {
+		repo.logger.Error(ctx, "Error when deleting the tilejson", "map", mapID.String(), "error", err)
+		return err
+	}
+	keys, err := repo.awsClient.ListObjectsInS3(ctx, repo.bucketName, tilePrefix(mapID))
+	if err != nil {
+		repo.logger.Error(ctx, "Error when listing the tiles", "map", mapID.String(), "error", err)
+		return err
+	}
+	deleteTile := func(ctx context.Context, key string) (struct{}, error) {
+		return struct{}{}, repo.awsClient.DeleteFromS3(ctx, repo.bucketName, key)
+	}
+	if _, err := infrastructure.MapConcurrently(ctx, keys, tileWorkers, deleteTile); err != nil {
+		repo.logger.Error(ctx, "Error when deleting the tiles", "map", mapID.String(), "error", err)
+		return err
+	}
+	return nil
+}
+
+func (repo *xyzTileServerRepo) PlanDelete(ctx context.Context, m domain.Map) ([]string, error) {
+	mapID := m.ID()
+	var planned []string
+	for _, prefix := range []string{tilesetKey(mapID), tilePrefix(mapID)} {
+		exists, err := repo.awsClient.AnyObjectInS3(ctx, repo.bucketName, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			planned = append(planned, fmt.Sprintf("s3://%s/%s", repo.bucketName, prefix))
+		}
+	}
+	return planned, nil
+}
+
+func (repo *xyzTileServerRepo) tileURL(mapID domain.MapID) string {
+	return repo.baseURL + "/" + tilePrefix(mapID) + "{z}/{x}/{y}.png"
+}
+
+func tilePrefix(mapID domain.MapID) string {
+	return tilesPrefix + mapID.String() + "/"
+}
+
+func tilesetKey(mapID domain.MapID) string {
+	return tilesetsPrefix + mapID.String() + ".json"
+}
@@ -0,0 +1,139 @@
+package maptileserverrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testTileURL = "https://cdn.example.com/tiles/eog-day-b1-20220101/{z}/{x}/{y}.png"
+
+func testMapID(t *testing.T) domain.MapID {
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+	return id
+}
+
+// writeTestMap writes a processed map of one by one degree around kyiv
+func writeTestMap(t *testing.T) string {
+	r, err := raster.New(100, 100, raster.DataTypeUint8)
+	require.NoError(t, err)
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			r.Set(x, y, 100)
+		}
+	}
+	r.Transform = raster.GeoTransform{30, 0.01, 0, 51, 0, -0.01}
+	r.GeoKeys = raster.NewGeographicGeoKeys(raster.EPSGWGS84)
+	path := filepath.Join(t.TempDir(), "map.tif")
+	require.NoError(t, raster.WriteFile(path, r, nil))
+	return path
+}
+
+func TestXYZTileServerRepo_Publish(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewXYZTileServerRepo(fakes.NewLogger(), "cdn", "https://cdn.example.com/", 5, 6, mockAWSClient)
+	var mu sync.Mutex
+	var tileKeys []string
+	var uploadedTileJSON tileJSON
+
+	mockAWSClient.On("UploadToS3WithContentType", mock.Anything, "cdn", mock.Anything, "image/png", mock.Anything).
+		Run(func(args mock.Arguments) {
+			// The tiles are uploaded concurrently
+			mu.Lock()
+			defer mu.Unlock()
+			tileKeys = append(tileKeys, args.String(2))
+		}).Return(nil)
+	mockAWSClient.On("UploadToS3WithContentType", ctx, "cdn", "tilesets/eog-day-b1-20220101.json",
+		"application/json", mock.Anything).
+		Run(func(args mock.Arguments) {
+			data, err := io.ReadAll(args.Get(4).(io.Reader))
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(data, &uploadedTileJSON))
+		}).Return(nil)
+
+	published, err := repo.Publish(ctx, domain.LocalMap{Filepath: writeTestMap(t), Map: testMapID(t).ToMap("")})
+
+	require.NoError(t, err)
+	assert.Equal(t, testTileURL, published.Url)
+	assert.ElementsMatch(t, []string{
+		"tiles/eog-day-b1-20220101/5/18/10.png",
+		"tiles/eog-day-b1-20220101/6/37/21.png",
+	}, tileKeys)
+	assert.Equal(t, []string{testTileURL}, uploadedTileJSON.Tiles)
+	assert.Equal(t, [4]float64{30, 50, 31, 51}, uploadedTileJSON.Bounds)
+}
+
+func TestXYZTileServerRepo_PublishStopsAtTheFirstFailedTile(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewXYZTileServerRepo(fakes.NewLogger(), "cdn", "https://cdn.example.com", 5, 6, mockAWSClient)
+
+	mockAWSClient.On("UploadToS3WithContentType", mock.Anything, "cdn", mock.Anything, "image/png", mock.Anything).
+		Return(errors.New("access denied"))
+
+	_, err := repo.Publish(ctx, domain.LocalMap{Filepath: writeTestMap(t), Map: testMapID(t).ToMap("")})
+
+	assert.ErrorContains(t, err, "access denied")
+	mockAWSClient.AssertNotCalled(t, "UploadToS3WithContentType", mock.Anything, "cdn",
+		"tilesets/eog-day-b1-20220101.json", mock.Anything, mock.Anything)
+}
+
+func TestXYZTileServerRepo_List(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewXYZTileServerRepo(fakes.NewLogger(), "cdn", "https://cdn.example.com", 5, 6, mockAWSClient)
+
+	mockAWSClient.On("ListObjectsInS3", ctx, "cdn", "tilesets/").
+		Return([]string{"tilesets/eog-day-b1-20220101.json", "tilesets/README.md"}, nil)
+
+	published, err := repo.List(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, []domain.PublishedMap{{Map: testMapID(t).ToMap(""), Url: testTileURL}}, published)
+}
+
+func TestXYZTileServerRepo_Delete(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewXYZTileServerRepo(fakes.NewLogger(), "cdn", "https://cdn.example.com", 5, 6, mockAWSClient)
+	tileKeys := []string{"tiles/eog-day-b1-20220101/5/18/10.png", "tiles/eog-day-b1-20220101/6/37/21.png"}
+
+	mockAWSClient.On("DeleteFromS3", ctx, "cdn", "tilesets/eog-day-b1-20220101.json").Return(nil)
+	mockAWSClient.On("ListObjectsInS3", ctx, "cdn", "tiles/eog-day-b1-20220101/").Return(tileKeys, nil)
+	mockAWSClient.On("DeleteFromS3", mock.Anything, "cdn", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "tiles/")
+	})).Return(nil)
+
+	require.NoError(t, repo.Delete(ctx, testMapID(t).ToMap("")))
+
+	mockAWSClient.AssertNumberOfCalls(t, "DeleteFromS3", 3)
+}
+
+func TestXYZTileServerRepo_PlanDelete(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewXYZTileServerRepo(fakes.NewLogger(), "cdn", "https://cdn.example.com", 5, 6, mockAWSClient)
+
+	mockAWSClient.On("AnyObjectInS3", ctx, "cdn", "tilesets/eog-day-b1-20220101.json").Return(false, nil)
+	mockAWSClient.On("AnyObjectInS3", ctx, "cdn", "tiles/eog-day-b1-20220101/").Return(true, nil)
+
+	planned, err := repo.PlanDelete(ctx, testMapID(t).ToMap(""))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3://cdn/tiles/eog-day-b1-20220101/"}, planned)
+}
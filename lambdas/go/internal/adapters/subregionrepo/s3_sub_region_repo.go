@@ -0,0 +1,66 @@
+package subregionrepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/geojson"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const keyPrefix = "sub-regions/"
+
+// s3SubRegionRepo stores the sub-regions of each bounds as a GeoJSON feature collection, so they can also be opened
+// in QGIS, next to the shape files of the bounds
+type s3SubRegionRepo struct {
+	logger     ports.Logger
+	awsClient  awsclient.AWSClient
+	bucketName string
+}
+
+func NewS3SubRegionRepo(logger ports.Logger, bucketName string, awsClient awsclient.AWSClient) ports.SubRegionRepo {
+	return &s3SubRegionRepo{logger: logger, bucketName: bucketName, awsClient: awsClient}
+}
+
+func (repo *s3SubRegionRepo) List(ctx context.Context, bounds domain.Bounds) ([]domain.SubRegion, error) {
+	object, err := repo.awsClient.GetFromS3(ctx, repo.bucketName, createKeyFromBounds(bounds))
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		repo.logger.Error(ctx, "Error when attempting to get the sub-regions from s3", "bounds", bounds, "error", err)
+		return nil, err
+	}
+	regions, err := geojson.ParseSubRegions(bounds, object)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when parsing the sub-regions", "bounds", bounds, "error", err)
+		return nil, err
+	}
+	return regions, nil
+}
+
+func (repo *s3SubRegionRepo) Replace(ctx context.Context, bounds domain.Bounds, regions []domain.SubRegion) error {
+	if err := domain.ValidateSubRegions(bounds, regions); err != nil {
+		return err
+	}
+	data, err := geojson.EncodeSubRegions(regions)
+	if err != nil {
+		repo.logger.Error(ctx, "Error when encoding the sub-regions", "bounds", bounds, "error", err)
+		return err
+	}
+	err = repo.awsClient.UploadToS3(ctx, repo.bucketName, createKeyFromBounds(bounds), bytes.NewReader(data))
+	if err != nil {
+		repo.logger.Error(ctx, "Error when uploading the sub-regions to s3", "bounds", bounds, "error", err)
+		return err
+	}
+	return nil
+}
+
+func createKeyFromBounds(bounds domain.Bounds) string {
+	return keyPrefix + bounds.String() + ".geojson"
+}
@@ -0,0 +1,49 @@
+package subregionrepo
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3SubRegionRepo_ReplaceAndList(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := awsclient.NewMockAWSClient(t)
+	repo := NewS3SubRegionRepo(ports.NewMockLogger(t), "test-bucket", mockAWSClient)
+	key := "sub-regions/BoundsUkraineAndAround.geojson"
+	regions := []domain.SubRegion{{
+		ID:           "kharkiv",
+		Name:         "Kharkiv",
+		Bounds:       domain.BoundsUkraineAndAround,
+		MultiPolygon: [][][][2]float64{{{{35, 49}, {37, 49}, {37, 50}, {35, 49}}}},
+	}}
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", key).Return(nil, &types.NoSuchKey{}).Once()
+	listed, err := repo.List(ctx, domain.BoundsUkraineAndAround)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+
+	var uploaded []byte
+	mockAWSClient.On("UploadToS3", ctx, "test-bucket", key, mock.Anything).
+		Run(func(args mock.Arguments) {
+			uploaded, err = io.ReadAll(args.Get(3).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(nil)
+	require.NoError(t, repo.Replace(ctx, domain.BoundsUkraineAndAround, regions))
+
+	mockAWSClient.On("GetFromS3", ctx, "test-bucket", key).Return(uploaded, nil).Once()
+	listed, err = repo.List(ctx, domain.BoundsUkraineAndAround)
+	require.NoError(t, err)
+	assert.Equal(t, regions, listed)
+
+	assert.Error(t, repo.Replace(ctx, domain.BoundsGazaAndAround, regions))
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Anomaly flags a suspected mass outage, the mean radiance of a map dropped significantly below the baseline of the
+// bounds or of one of its sub-regions
+type Anomaly struct {
+	ID MapID
+	// RegionID and RegionName are empty when the anomaly is about the whole bounds
+	RegionID     string
+	RegionName   string
+	MeanRadiance float64
+	// RollingBaseline is the mean radiance of the preceding maps and SeasonalBaseline the one of the same calendar
+	// month in prior years, a baseline is 0 when there were not enough maps to compute it
+	RollingBaseline  float64
+	SeasonalBaseline float64
+	// Change is relative to the lowest baseline, e.g. -0.4 is a drop of 40%
+	Change     float64
+	DetectedAt time.Time
+}
+
+// AnomalyConfig decides when a drop in radiance is significant enough to be flagged
+type AnomalyConfig struct {
+	// RollingWindow is the number of preceding maps of the same type the rolling baseline is taken over
+	RollingWindow int
+	// MinBaselineMaps is the number of preceding maps that is needed before a map can be flagged at all
+	MinBaselineMaps int
+	// DropThreshold is the relative drop below every baseline that is flagged, e.g. 0.3 flags drops of 30% or more
+	DropThreshold float64
+	// MinCoverage leaves out maps with little data, e.g. months that were mostly cloudy, they would look like outages
+	MinCoverage float64
+	// AttachToFrontend also adds the anomalies to the map options of the frontend json
+	AttachToFrontend bool
+}
+
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{RollingWindow: 3, MinBaselineMaps: 2, DropThreshold: 0.3, MinCoverage: 0.5}
+}
+
+func (c AnomalyConfig) Validate() error {
+	if c.RollingWindow < 1 {
+		return errors.New("the rolling window must contain at least one map")
+	}
+	if c.MinBaselineMaps < 1 || c.MinBaselineMaps > c.RollingWindow {
+		return errors.New("the minimum number of baseline maps must be between 1 and the rolling window")
+	}
+	if c.DropThreshold <= 0 || c.DropThreshold >= 1 {
+		return errors.New("the drop threshold must be between 0 and 1")
+	}
+	if c.MinCoverage < 0 || c.MinCoverage > 1 {
+		return errors.New("the minimum coverage must be between 0 and 1")
+	}
+	return nil
+}
+
+// DetectAnomaly compares the map against the maps of the series that came before it. The series has to be of a
+// single bounds or sub-region, maps of another type than the current one are ignored. Only monthly maps have a
+// seasonal baseline, a map is flagged when it dropped below every baseline it has
+func DetectAnomaly(current MapStats, series []MapStats, config AnomalyConfig) (Anomaly, bool) {
+	anomaly := Anomaly{ID: current.ID, MeanRadiance: current.MeanRadiance}
+	if current.Coverage < config.MinCoverage {
+		return anomaly, false
+	}
+	start := periodStart(current.ID.Date)
+	var preceding []MapStats
+	for _, s := range series {
+		if s.ID.MapType != current.ID.MapType || s.ID.Bounds != current.ID.Bounds ||
+			s.Coverage < config.MinCoverage || !periodStart(s.ID.Date).Before(start) {
+			continue
+		}
+		preceding = append(preceding, s)
+	}
+	if len(preceding) < config.MinBaselineMaps {
+		return anomaly, false
+	}
+	sort.Slice(preceding, func(i, j int) bool {
+		return periodStart(preceding[i].ID.Date).Before(periodStart(preceding[j].ID.Date))
+	})
+
+	anomaly.RollingBaseline = meanRadiance(preceding[max(0, len(preceding)-config.RollingWindow):])
+	baselines := []float64{anomaly.RollingBaseline}
+	if current.ID.MapType == MapTypeMonthly {
+		var sameMonth []MapStats
+		for _, s := range preceding {
+			if s.ID.Date.Month == current.ID.Date.Month {
+				sameMonth = append(sameMonth, s)
+			}
+		}
+		if len(sameMonth) > 0 {
+			anomaly.SeasonalBaseline = meanRadiance(sameMonth)
+			baselines = append(baselines, anomaly.SeasonalBaseline)
+		}
+	}
+
+	lowest := baselines[0]
+	for _, baseline := range baselines[1:] {
+		lowest = min(lowest, baseline)
+	}
+	if lowest <= 0 {
+		return anomaly, false
+	}
+	anomaly.Change = current.MeanRadiance/lowest - 1
+	return anomaly, anomaly.Change <= -config.DropThreshold
+}
+
+func meanRadiance(series []MapStats) float64 {
+	var sum float64
+	for _, s := range series {
+		sum += s.MeanRadiance
+	}
+	return sum / float64(len(series))
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMonthlyStats(year int, month time.Month, mean float64) MapStats {
+	return MapStats{
+		ID: MapID{
+			Provider: MapProviderEogdata,
+			MapType:  MapTypeMonthly,
+			Bounds:   BoundsUkraineAndAround,
+			Date:     Date{Day: 1, Month: month, Year: year},
+		},
+		MeanRadiance: mean,
+		Coverage:     0.9,
+	}
+}
+
+func TestDetectAnomaly(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	series := []MapStats{
+		newMonthlyStats(2021, time.October, 10),
+		newMonthlyStats(2022, time.July, 10),
+		newMonthlyStats(2022, time.August, 10),
+		newMonthlyStats(2022, time.September, 10),
+	}
+
+	t.Run("a drop below every baseline is flagged", func(t *testing.T) {
+		anomaly, flagged := DetectAnomaly(newMonthlyStats(2022, time.October, 5), series, config)
+
+		assert.True(t, flagged)
+		assert.Equal(t, 10.0, anomaly.RollingBaseline)
+		assert.Equal(t, 10.0, anomaly.SeasonalBaseline)
+		assert.InDelta(t, -0.5, anomaly.Change, 1e-9)
+	})
+
+	t.Run("a small drop is not flagged", func(t *testing.T) {
+		_, flagged := DetectAnomaly(newMonthlyStats(2022, time.October, 8), series, config)
+
+		assert.False(t, flagged)
+	})
+
+	t.Run("a drop that is seasonal is not flagged", func(t *testing.T) {
+		seasonal := append([]MapStats{newMonthlyStats(2021, time.November, 5)}, series...)
+		anomaly, flagged := DetectAnomaly(newMonthlyStats(2022, time.November, 5), seasonal, config)
+
+		assert.False(t, flagged)
+		assert.Equal(t, 5.0, anomaly.SeasonalBaseline)
+	})
+
+	t.Run("the rolling baseline only uses the latest maps", func(t *testing.T) {
+		rising := append([]MapStats{newMonthlyStats(2022, time.June, 1)}, series...)
+		anomaly, _ := DetectAnomaly(newMonthlyStats(2022, time.December, 5), rising, config)
+
+		assert.Equal(t, 10.0, anomaly.RollingBaseline)
+		assert.Equal(t, 0.0, anomaly.SeasonalBaseline)
+	})
+
+	t.Run("maps after the current one are ignored", func(t *testing.T) {
+		later := append([]MapStats{newMonthlyStats(2023, time.January, 100)}, series...)
+		anomaly, flagged := DetectAnomaly(newMonthlyStats(2022, time.August, 5), later, config)
+
+		assert.True(t, flagged)
+		assert.Equal(t, 10.0, anomaly.RollingBaseline)
+	})
+
+	t.Run("maps with little coverage are neither flagged nor part of the baseline", func(t *testing.T) {
+		cloudy := newMonthlyStats(2022, time.October, 1)
+		cloudy.Coverage = 0.2
+		_, flagged := DetectAnomaly(cloudy, series, config)
+		assert.False(t, flagged)
+
+		cloudySeries := append([]MapStats{cloudy}, series...)
+		anomaly, _ := DetectAnomaly(newMonthlyStats(2022, time.November, 10), cloudySeries, config)
+		assert.Equal(t, 10.0, anomaly.RollingBaseline)
+	})
+}
+
+func TestAnomalyConfig_Validate(t *testing.T) {
+	assert.NoError(t, DefaultAnomalyConfig().Validate())
+
+	config := DefaultAnomalyConfig()
+	config.DropThreshold = 1
+	assert.Error(t, config.Validate())
+
+	config = DefaultAnomalyConfig()
+	config.MinBaselineMaps = config.RollingWindow + 1
+	assert.Error(t, config.Validate())
+}
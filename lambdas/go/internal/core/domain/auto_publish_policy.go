@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// AutoPublishPolicy decides which processed maps are published as soon as they are created, BoundsUnspecified and
+// MapTypeUnspecified allow every bounds and map type, empty lists allow nothing
+type AutoPublishPolicy struct {
+	Bounds   []Bounds
+	MapTypes []MapType
+}
+
+// ParseAutoPublishPolicy parses comma separated lists, e.g. bounds "UkraineAndAround,GazaAndAround" and map types
+// "monthly,annual", a * allows everything
+func ParseAutoPublishPolicy(bounds string, mapTypes string) (AutoPublishPolicy, error) {
+	var policy AutoPublishPolicy
+	for _, s := range splitList(bounds) {
+		b := BoundsUnspecified
+		if s != "*" {
+			if b = StringToBounds(s); b == BoundsUnspecified {
+				return AutoPublishPolicy{}, fmt.Errorf("unknown bounds in the auto publish policy: %s", s)
+			}
+		}
+		policy.Bounds = append(policy.Bounds, b)
+	}
+	for _, s := range splitList(mapTypes) {
+		mapType := MapTypeUnspecified
+		if s != "*" {
+			if mapType = StringToMapType("MapType" + strings.TrimPrefix(s, "MapType")); mapType == MapTypeUnspecified {
+				return AutoPublishPolicy{}, fmt.Errorf("unknown map type in the auto publish policy: %s", s)
+			}
+		}
+		policy.MapTypes = append(policy.MapTypes, mapType)
+	}
+	return policy, nil
+}
+
+func (p AutoPublishPolicy) Allows(m Map) bool {
+	boundsAllowed := slices.Contains(p.Bounds, BoundsUnspecified) || slices.Contains(p.Bounds, m.Bounds)
+	mapTypeAllowed := slices.Contains(p.MapTypes, MapTypeUnspecified) || slices.Contains(p.MapTypes, m.MapType)
+	return boundsAllowed && mapTypeAllowed
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoPublishPolicy_Allows(t *testing.T) {
+	monthlyUkraine := Map{Bounds: BoundsUkraineAndAround, MapType: MapTypeMonthly}
+	dailyGaza := Map{Bounds: BoundsGazaAndAround, MapType: MapTypeDaily}
+
+	policy, err := ParseAutoPublishPolicy("UkraineAndAround", "monthly, annual")
+	require.NoError(t, err)
+	assert.True(t, policy.Allows(monthlyUkraine))
+	assert.False(t, policy.Allows(dailyGaza))
+
+	policy, err = ParseAutoPublishPolicy("*", "MapTypeDaily")
+	require.NoError(t, err)
+	assert.False(t, policy.Allows(monthlyUkraine))
+	assert.True(t, policy.Allows(dailyGaza))
+
+	policy, err = ParseAutoPublishPolicy("", "*")
+	require.NoError(t, err)
+	assert.False(t, policy.Allows(monthlyUkraine))
+}
+
+func TestParseAutoPublishPolicy_Invalid(t *testing.T) {
+	_, err := ParseAutoPublishPolicy("Atlantis", "*")
+	assert.Error(t, err)
+	_, err = ParseAutoPublishPolicy("*", "weekly")
+	assert.Error(t, err)
+}
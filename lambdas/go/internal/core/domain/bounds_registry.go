@@ -0,0 +1,155 @@
+package domain
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+)
+
+//go:embed bounds.json
+var defaultBoundsDefinitions []byte
+
+var eogdataTilePattern = regexp.MustCompile("^[0-9]{2}[NS][0-9]{3}[EW]$")
+
+// BoundsDefinition describes a region we crop the source maps to.
+//
+//	The ID is what is persisted (proto, frontend json) so it must never be reused for a different region,
+//	the Name is used when building storage keys and must therefore also be stable.
+type BoundsDefinition struct {
+	ID          Bounds `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	// BBox follows the GeoJSON bbox convention: [minLon, minLat, maxLon, maxLat]
+	BBox [4]float64 `json:"bbox"`
+	// Polygon is optional and follows the GeoJSON Polygon coordinates convention, when it is not provided the BBox
+	// is used as the outline of the region
+	Polygon     [][][2]float64 `json:"polygon,omitempty"`
+	EogdataTile string         `json:"eogdataTile"`
+}
+
+// BoundsRegistry holds every region the application knows about
+type BoundsRegistry struct {
+	definitions []BoundsDefinition
+	byID        map[Bounds]BoundsDefinition
+}
+
+func NewBoundsRegistry(definitions []BoundsDefinition) (*BoundsRegistry, error) {
+	registry := &BoundsRegistry{byID: make(map[Bounds]BoundsDefinition, len(definitions))}
+	names := make(map[string]Bounds, len(definitions))
+	for _, d := range definitions {
+		if err := d.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := registry.byID[d.ID]; ok {
+			return nil, fmt.Errorf("the bounds id %d is defined more than once", d.ID)
+		}
+		cleanName := infrastructure.CleanStrings(d.Name)
+		if other, ok := names[cleanName]; ok {
+			return nil, fmt.Errorf("the bounds name %s is used by both id %d and id %d", d.Name, other, d.ID)
+		}
+		names[cleanName] = d.ID
+		registry.byID[d.ID] = d
+		registry.definitions = append(registry.definitions, d)
+	}
+	sort.Slice(registry.definitions, func(i, j int) bool {
+		return registry.definitions[i].ID < registry.definitions[j].ID
+	})
+	return registry, nil
+}
+
+// ParseBoundsRegistry creates a registry from a json array of BoundsDefinition
+func ParseBoundsRegistry(data []byte) (*BoundsRegistry, error) {
+	var definitions []BoundsDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to parse the bounds definitions: %w", err)
+	}
+	return NewBoundsRegistry(definitions)
+}
+
+func LoadBoundsRegistryFromFile(path string) (*BoundsRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the bounds config file %s: %w", path, err)
+	}
+	return ParseBoundsRegistry(data)
+}
+
+func (r *BoundsRegistry) Get(b Bounds) (BoundsDefinition, bool) {
+	d, ok := r.byID[b]
+	return d, ok
+}
+
+// All returns every definition ordered by id
+func (r *BoundsRegistry) All() []BoundsDefinition {
+	out := make([]BoundsDefinition, len(r.definitions))
+	copy(out, r.definitions)
+	return out
+}
+
+// FromString accepts either the name of the bounds or the name prefixed with Bounds, in any casing
+// and with or without underscores, i.e. GazaAndAround, BoundsGazaAndAround and bounds_gaza_and_around are equal
+func (r *BoundsRegistry) FromString(s string) Bounds {
+	cleaned := infrastructure.CleanStrings(s)
+	for _, d := range r.definitions {
+		if cleaned == infrastructure.CleanStrings(d.Name) ||
+			cleaned == infrastructure.CleanStrings(boundsPrefix+d.Name) {
+			return d.ID
+		}
+	}
+	return BoundsUnspecified
+}
+
+func (d BoundsDefinition) validate() error {
+	if d.ID <= BoundsUnspecified || d.ID > math.MaxInt32 {
+		return fmt.Errorf("the bounds %s must have an id between 1 and %d", d.Name, math.MaxInt32)
+	}
+	if d.Name == "" {
+		return fmt.Errorf("the bounds with id %d must have a name", d.ID)
+	}
+	if d.BBox[0] >= d.BBox[2] || d.BBox[1] >= d.BBox[3] {
+		return fmt.Errorf("the bbox of the bounds %s must be ordered as [minLon, minLat, maxLon, maxLat]", d.Name)
+	}
+	if !eogdataTilePattern.MatchString(d.EogdataTile) {
+		return fmt.Errorf("the eogdata tile %s of the bounds %s is not valid", d.EogdataTile, d.Name)
+	}
+	for _, ring := range d.Polygon {
+		if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("the polygon of the bounds %s must be made of closed rings", d.Name)
+		}
+	}
+	return nil
+}
+
+var (
+	boundsRegistryMu sync.RWMutex
+	boundsRegistry   = mustParseDefaultBoundsRegistry()
+)
+
+func mustParseDefaultBoundsRegistry() *BoundsRegistry {
+	registry, err := ParseBoundsRegistry(defaultBoundsDefinitions)
+	if err != nil {
+		panic("the embedded bounds definitions are invalid: " + err.Error())
+	}
+	return registry
+}
+
+// GetBoundsRegistry returns the registry that Bounds.String and StringToBounds resolve through
+func GetBoundsRegistry() *BoundsRegistry {
+	boundsRegistryMu.RLock()
+	defer boundsRegistryMu.RUnlock()
+	return boundsRegistry
+}
+
+// SetBoundsRegistry replaces the registry loaded from the embedded bounds.json, this should be called once on startup
+func SetBoundsRegistry(registry *BoundsRegistry) {
+	boundsRegistryMu.Lock()
+	defer boundsRegistryMu.Unlock()
+	boundsRegistry = registry
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBoundsConfig = `[
+  {"id": 1, "name": "UkraineAndAround", "displayName": "Ukraine", "bbox": [21.0, 43.3, 41.3, 54.5], "eogdataTile": "75N060W"},
+  {"id": 3, "name": "Sudan", "displayName": "Sudan", "bbox": [21.8, 8.7, 38.6, 22.2], "eogdataTile": "75N060W"}
+]`
+
+func TestParseBoundsRegistry(t *testing.T) {
+	registry, err := ParseBoundsRegistry([]byte(testBoundsConfig))
+	require.NoError(t, err)
+
+	sudan, ok := registry.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, "Sudan", sudan.DisplayName)
+	assert.Equal(t, "75N060W", sudan.EogdataTile)
+	assert.Equal(t, [4]float64{21.8, 8.7, 38.6, 22.2}, sudan.BBox)
+
+	assert.Equal(t, Bounds(3), registry.FromString("Sudan"))
+	assert.Equal(t, Bounds(3), registry.FromString("bounds_sudan"))
+	assert.Equal(t, BoundsUkraineAndAround, registry.FromString("BoundsUkraineAndAround"))
+	assert.Equal(t, BoundsUnspecified, registry.FromString("BoundsGazaAndAround"))
+	assert.Len(t, registry.All(), 2)
+}
+
+func TestParseBoundsRegistry_Invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+	}{
+		{
+			name:   "duplicate id",
+			config: `[{"id": 1, "name": "A", "bbox": [0, 0, 1, 1], "eogdataTile": "75N060W"}, {"id": 1, "name": "B", "bbox": [0, 0, 1, 1], "eogdataTile": "75N060W"}]`,
+		},
+		{
+			name:   "duplicate name",
+			config: `[{"id": 1, "name": "A", "bbox": [0, 0, 1, 1], "eogdataTile": "75N060W"}, {"id": 2, "name": "a", "bbox": [0, 0, 1, 1], "eogdataTile": "75N060W"}]`,
+		},
+		{
+			name:   "unspecified id",
+			config: `[{"id": 0, "name": "A", "bbox": [0, 0, 1, 1], "eogdataTile": "75N060W"}]`,
+		},
+		{
+			name:   "inverted bbox",
+			config: `[{"id": 1, "name": "A", "bbox": [1, 1, 0, 0], "eogdataTile": "75N060W"}]`,
+		},
+		{
+			name:   "invalid tile",
+			config: `[{"id": 1, "name": "A", "bbox": [0, 0, 1, 1], "eogdataTile": "tile2"}]`,
+		},
+		{
+			name:   "open polygon",
+			config: `[{"id": 1, "name": "A", "bbox": [0, 0, 1, 1], "polygon": [[[0, 0], [1, 0], [1, 1], [0, 1]]], "eogdataTile": "75N060W"}]`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseBoundsRegistry([]byte(tc.config))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBounds_StringResolvesThroughRegistry(t *testing.T) {
+	registry, err := ParseBoundsRegistry([]byte(testBoundsConfig))
+	require.NoError(t, err)
+	defaultRegistry := GetBoundsRegistry()
+	SetBoundsRegistry(registry)
+	t.Cleanup(func() { SetBoundsRegistry(defaultRegistry) })
+
+	assert.Equal(t, "BoundsSudan", Bounds(3).String())
+	assert.Equal(t, Bounds(3), StringToBounds("BoundsSudan"))
+	assert.Equal(t, "Bounds(2)", BoundsGazaAndAround.String())
+	assert.Equal(t, "BoundsUnspecified", BoundsUnspecified.String())
+
+	id := MapID{Bounds: 3, MapType: MapTypeMonthly, Date: Date{Day: 1, Month: 1, Year: 2023}}
+	assert.Equal(t, "Monthly-Sudan_2023-1-1", id.LegacyName())
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DeleteOutcome is what happened to a map in a single repository
+type DeleteOutcome struct {
+	Repository string
+	// Targets are the s3 keys, tileset ids or frontend entries a dry run would remove, a real delete does not plan
+	Targets []string
+	Error   string
+}
+
+// DeleteResult is the outcome of deleting a map from every repository
+type DeleteResult struct {
+	ID       MapID
+	DryRun   bool
+	Outcomes []DeleteOutcome
+}
+
+// Err aggregates the errors of every repository, it is nil when the map was deleted everywhere
+func (r DeleteResult) Err() error {
+	var errs []error
+	for _, outcome := range r.Outcomes {
+		if outcome.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", outcome.Repository, outcome.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
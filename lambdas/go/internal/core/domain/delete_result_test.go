@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteResult_Err(t *testing.T) {
+	result := DeleteResult{Outcomes: []DeleteOutcome{
+		{Repository: "tile server", Targets: []string{"user.eog-mon-b1-20220101"}},
+		{Repository: "frontend"},
+	}}
+	assert.NoError(t, result.Err())
+
+	result.Outcomes = append(result.Outcomes, DeleteOutcome{Repository: "raw internal", Error: "access denied"})
+	assert.EqualError(t, result.Err(), "raw internal: access denied")
+}
@@ -2,7 +2,6 @@ package domain
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
@@ -16,9 +15,7 @@ type Map struct {
 }
 
 func (m *Map) String() string {
-	mapTypeString := strings.Replace(m.MapType.String(), "MapType", "", 1)
-	boundsString := strings.Replace(m.Bounds.String(), "Bounds", "", 1)
-	return fmt.Sprintf("%s-%s_%d-%d-%d", mapTypeString, boundsString[:10], m.Date.Year, m.Date.Month, m.Date.Day)
+	return m.ID().String()
 }
 
 type LocalMap struct {
@@ -29,11 +26,17 @@ type LocalMap struct {
 type PublishedMap struct {
 	Url string
 	Map Map
+	// CloudFreeCoverage is nil when the coverage of the map was not recorded
+	CloudFreeCoverage *float64
+	// LowConfidence is set when the map was published even though its cloud-free coverage is below the minimum
+	LowConfidence bool
 }
 
 type MapSource struct {
 	URL         string
 	MapProvider MapProvider
+	// CoverageURL points at the cloud-free coverage raster of the composite, it is empty when the provider has none
+	CoverageURL string
 }
 
 type Date struct {
@@ -42,6 +45,11 @@ type Date struct {
 	Year  int
 }
 
+// NewAnnualDate is the date used by MapTypeAnnual maps, they cover a whole year so the month and day carry no meaning
+func NewAnnualDate(year int) Date {
+	return Date{Day: 1, Month: time.January, Year: year}
+}
+
 //go:generate stringer -type=MapProvider
 type MapProvider int
 
@@ -66,10 +74,11 @@ const (
 	MapTypeUnspecified MapType = iota
 	MapTypeDaily
 	MapTypeMonthly
+	MapTypeAnnual
 )
 
 func StringToMapType(s string) MapType {
-	for i := MapTypeUnspecified; i <= MapTypeMonthly; i++ {
+	for i := MapTypeUnspecified; i <= MapTypeAnnual; i++ {
 		if infrastructure.CleanStrings(i.String()) == infrastructure.CleanStrings(s) {
 			return i
 		}
@@ -80,30 +89,31 @@ func StringToMapType(s string) MapType {
 // Bounds is how the internal map was cropped from the source map
 //
 //	this is implemented via a shp file, to prevent the specific implementation of
-//	geodata from bleeding into our domain you'll need to map the shape file name to the tile id
-//	in the externalmapsrepo
-//
-//go:generate stringer -type=Bounds
+//	geodata from bleeding into our domain the shape file name and the tile id it belongs to
+//	are defined in the BoundsRegistry
 type Bounds int
 
+const boundsPrefix = "Bounds"
+
+// The bounds that existed before the BoundsRegistry, new bounds should only be added to the registry
 const (
 	BoundsUnspecified Bounds = iota
 	BoundsUkraineAndAround
 	BoundsGazaAndAround
 )
 
-func StringToBounds(s string) Bounds {
-	for i := BoundsUnspecified; i <= BoundsGazaAndAround; i++ {
-		if infrastructure.CleanStrings(i.String()) == infrastructure.CleanStrings(s) {
-			return i
-		}
+func (b Bounds) String() string {
+	if b == BoundsUnspecified {
+		return boundsPrefix + "Unspecified"
 	}
-	return BoundsUnspecified
+	if d, ok := GetBoundsRegistry().Get(b); ok {
+		return boundsPrefix + d.Name
+	}
+	return fmt.Sprintf("%s(%d)", boundsPrefix, int(b))
 }
 
-type SelectedDates struct {
-	Months []time.Month
-	Years  []int
+func StringToBounds(s string) Bounds {
+	return GetBoundsRegistry().FromString(s)
 }
 
 type SyncMapRequest struct {
@@ -37,5 +37,5 @@ func TestMap_String(t *testing.T) {
 		},
 	}
 	s := m.String()
-	assert.Equal(t, s, "Monthly-UkraineAnd_2022-1-1")
+	assert.Equal(t, s, "eog-mon-b1-20220101")
 }
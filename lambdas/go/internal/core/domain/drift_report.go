@@ -0,0 +1,30 @@
+package domain
+
+// DriftReport is how the raw and processed internal repos, the frontend and the tile server disagree with each other
+type DriftReport struct {
+	// ProcessedNotPublished are processed maps the frontend does not show, they are fixed by publishing them
+	ProcessedNotPublished []Map
+	// DanglingFrontendEntries point to tilesets that do not exist, they are fixed by removing them from the frontend
+	DanglingFrontendEntries []PublishedMap
+	// RawWithoutProcessed are raw maps that were never processed
+	RawWithoutProcessed []Map
+	// UnreferencedTilesets are hosted by the tile server but not shown in the frontend
+	UnreferencedTilesets []PublishedMap
+	// PublishedWithoutProcessed are shown in the frontend while the processed map is gone
+	PublishedWithoutProcessed []PublishedMap
+	// Unpublished are processed maps that were hidden from the frontend on purpose, they are not drift
+	Unpublished []Map
+
+	// Applied is true when the fixable drift was repaired, Fixed and FixErrors are only filled in that case
+	Applied   bool
+	Fixed     []MapID
+	FixErrors map[MapID]string
+}
+
+func (r DriftReport) HasDrift() bool {
+	return len(r.ProcessedNotPublished) > 0 ||
+		len(r.DanglingFrontendEntries) > 0 ||
+		len(r.RawWithoutProcessed) > 0 ||
+		len(r.UnreferencedTilesets) > 0 ||
+		len(r.PublishedWithoutProcessed) > 0
+}
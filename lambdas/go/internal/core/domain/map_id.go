@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxMapIDLength is the length a MapID is guaranteed to fit in, Mapbox limits tileset names to 32 characters
+const MaxMapIDLength = 32
+
+// MapID is the identity of a map, two maps with the same MapID are the same map even when their source differs.
+//
+//	It is encoded as provider-mapType-bounds-date, e.g. eog-mon-b1-20220101, which round trips through ParseMapID.
+type MapID struct {
+	Provider MapProvider
+	MapType  MapType
+	Bounds   Bounds
+	Date     Date
+}
+
+var mapProviderCodes = map[MapProvider]string{
+	MapProviderUnspecified: "unk",
+	MapProviderEogdata:     "eog",
+}
+
+var mapTypeCodes = map[MapType]string{
+	MapTypeUnspecified: "unk",
+	MapTypeDaily:       "day",
+	MapTypeMonthly:     "mon",
+	MapTypeAnnual:      "ann",
+}
+
+func (m Map) ID() MapID {
+	return MapID{Provider: m.Source.MapProvider, MapType: m.MapType, Bounds: m.Bounds, Date: m.Date}
+}
+
+// ToMap creates a map from its identity and the url it was sourced from
+func (id MapID) ToMap(sourceURL string) Map {
+	return Map{
+		Source:  MapSource{URL: sourceURL, MapProvider: id.Provider},
+		Date:    id.Date,
+		MapType: id.MapType,
+		Bounds:  id.Bounds,
+	}
+}
+
+func (id MapID) String() string {
+	return fmt.Sprintf(
+		"%s-%s-b%d-%04d%02d%02d",
+		mapProviderCodes[id.Provider],
+		mapTypeCodes[id.MapType],
+		id.Bounds,
+		id.Date.Year,
+		id.Date.Month,
+		id.Date.Day,
+	)
+}
+
+// Validate checks that the id can be encoded within MaxMapIDLength and parsed back
+func (id MapID) Validate() error {
+	if _, ok := mapProviderCodes[id.Provider]; !ok {
+		return fmt.Errorf("unknown map provider: %d", id.Provider)
+	}
+	if _, ok := mapTypeCodes[id.MapType]; !ok {
+		return fmt.Errorf("unknown map type: %d", id.MapType)
+	}
+	if id.Bounds < BoundsUnspecified || id.Bounds > math.MaxInt32 {
+		return fmt.Errorf("the bounds %d are out of range", id.Bounds)
+	}
+	d := id.Date
+	if d.Year < 0 || d.Year > 9999 || d.Month < 0 || d.Month > time.December || d.Day < 0 || d.Day > 31 {
+		return fmt.Errorf("the date %d-%d-%d is out of range", d.Year, d.Month, d.Day)
+	}
+	return nil
+}
+
+func ParseMapID(s string) (MapID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 {
+		return MapID{}, fmt.Errorf("the map id %s is not in the format provider-mapType-bounds-date", s)
+	}
+	var id MapID
+	provider, ok := lookupCode(mapProviderCodes, parts[0])
+	if !ok {
+		return MapID{}, fmt.Errorf("the map id %s has an unknown provider", s)
+	}
+	mapType, ok := lookupCode(mapTypeCodes, parts[1])
+	if !ok {
+		return MapID{}, fmt.Errorf("the map id %s has an unknown map type", s)
+	}
+	id.Provider, id.MapType = provider, mapType
+
+	if !strings.HasPrefix(parts[2], "b") {
+		return MapID{}, fmt.Errorf("the map id %s has malformed bounds", s)
+	}
+	bounds, err := strconv.ParseUint(parts[2][1:], 10, 31)
+	if err != nil {
+		return MapID{}, fmt.Errorf("the map id %s has malformed bounds: %w", s, err)
+	}
+	id.Bounds = Bounds(bounds)
+
+	date := parts[3]
+	if len(date) != 8 {
+		return MapID{}, fmt.Errorf("the map id %s does not have a date in the format yyyymmdd", s)
+	}
+	year, yearErr := strconv.Atoi(date[:4])
+	month, monthErr := strconv.Atoi(date[4:6])
+	day, dayErr := strconv.Atoi(date[6:])
+	if err := errors.Join(yearErr, monthErr, dayErr); err != nil {
+		return MapID{}, fmt.Errorf("the map id %s has a malformed date: %w", s, err)
+	}
+	id.Date = Date{Day: day, Month: time.Month(month), Year: year}
+	if err := id.Validate(); err != nil {
+		return MapID{}, err
+	}
+	return id, nil
+}
+
+func (id MapID) MarshalText() ([]byte, error) {
+	if err := id.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(id.String()), nil
+}
+
+func (id *MapID) UnmarshalText(text []byte) error {
+	parsed, err := ParseMapID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// LegacyName is how a map was identified before MapID existed, e.g. Monthly-UkraineAnd_2022-1-1, it is only used to
+// find the tilesets and frontend entries that were published back then
+func (id MapID) LegacyName() string {
+	mapTypeString := strings.Replace(id.MapType.String(), "MapType", "", 1)
+	boundsString := strings.Replace(id.Bounds.String(), boundsPrefix, "", 1)
+	if len(boundsString) > 10 {
+		boundsString = boundsString[:10]
+	}
+	return fmt.Sprintf("%s-%s_%d-%d-%d", mapTypeString, boundsString, id.Date.Year, id.Date.Month, id.Date.Day)
+}
+
+// ParseLegacyName is the inverse of LegacyName, the bounds are resolved through the registry and legacy names were
+// only ever created for eogdata maps
+func ParseLegacyName(s string) (MapID, error) {
+	nameAndDate := strings.Split(s, "_")
+	if len(nameAndDate) != 2 {
+		return MapID{}, fmt.Errorf("the legacy name %s is not in the format mapType-bounds_year-month-day", s)
+	}
+	mapTypeString, boundsString, ok := strings.Cut(nameAndDate[0], "-")
+	if !ok {
+		return MapID{}, fmt.Errorf("the legacy name %s does not contain a map type and bounds", s)
+	}
+	mapType := StringToMapType("MapType" + mapTypeString)
+	if mapType == MapTypeUnspecified {
+		return MapID{}, fmt.Errorf("the legacy name %s has an unknown map type", s)
+	}
+	var matches []Bounds
+	for _, d := range GetBoundsRegistry().All() {
+		if d.Name == boundsString || (len(boundsString) == 10 && strings.HasPrefix(d.Name, boundsString)) {
+			matches = append(matches, d.ID)
+		}
+	}
+	if len(matches) != 1 {
+		return MapID{}, fmt.Errorf("the bounds of the legacy name %s matched %d bounds", s, len(matches))
+	}
+	dateParts := strings.Split(nameAndDate[1], "-")
+	if len(dateParts) != 3 {
+		return MapID{}, fmt.Errorf("the legacy name %s does not have a date in the format year-month-day", s)
+	}
+	year, yearErr := strconv.Atoi(dateParts[0])
+	month, monthErr := strconv.Atoi(dateParts[1])
+	day, dayErr := strconv.Atoi(dateParts[2])
+	if err := errors.Join(yearErr, monthErr, dayErr); err != nil {
+		return MapID{}, fmt.Errorf("the legacy name %s has a malformed date: %w", s, err)
+	}
+	id := MapID{
+		Provider: MapProviderEogdata,
+		MapType:  mapType,
+		Bounds:   matches[0],
+		Date:     Date{Day: day, Month: time.Month(month), Year: year},
+	}
+	if err := id.Validate(); err != nil {
+		return MapID{}, err
+	}
+	return id, nil
+}
+
+// ParseMapIDOrLegacyName accepts both the MapID encoding and the legacy name
+func ParseMapIDOrLegacyName(s string) (MapID, error) {
+	id, err := ParseMapID(s)
+	if err == nil {
+		return id, nil
+	}
+	if legacyID, legacyErr := ParseLegacyName(s); legacyErr == nil {
+		return legacyID, nil
+	}
+	return MapID{}, err
+}
+
+func lookupCode[T comparable](codes map[T]string, code string) (T, bool) {
+	for value, c := range codes {
+		if c == code {
+			return value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
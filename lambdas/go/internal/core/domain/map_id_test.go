@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapID_RoundTrip(t *testing.T) {
+	ids := []MapID{
+		{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround, Date: Date{1, 1, 2022}},
+		{Provider: MapProviderEogdata, MapType: MapTypeDaily, Bounds: BoundsGazaAndAround, Date: Date{31, 12, 2023}},
+		{Provider: MapProviderEogdata, MapType: MapTypeAnnual, Bounds: 17, Date: NewAnnualDate(2021)},
+		{Provider: MapProviderUnspecified, MapType: MapTypeUnspecified, Bounds: BoundsUnspecified, Date: Date{}},
+		{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: math.MaxInt32, Date: Date{0, 1, 9999}},
+	}
+	for _, id := range ids {
+		t.Run(id.String(), func(t *testing.T) {
+			assert.LessOrEqual(t, len(id.String()), MaxMapIDLength)
+			parsed, err := ParseMapID(id.String())
+			require.NoError(t, err)
+			assert.Equal(t, id, parsed)
+		})
+	}
+}
+
+func TestMapID_String(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeDaily, Bounds: BoundsGazaAndAround, Date: Date{2, 3, 2023}}
+	assert.Equal(t, "eog-day-b2-20230302", id.String())
+}
+
+func TestMapID_IgnoresSource(t *testing.T) {
+	a := Map{
+		Source:  MapSource{URL: "https://example.com/a.tgz", MapProvider: MapProviderEogdata},
+		Date:    Date{Day: 1, Month: time.February, Year: 2023},
+		MapType: MapTypeMonthly,
+		Bounds:  BoundsUkraineAndAround,
+	}
+	b := a
+	b.Source.URL = "https://example.com/b.tgz"
+	assert.Equal(t, a.ID(), b.ID())
+
+	c := a
+	c.Bounds = BoundsGazaAndAround
+	assert.NotEqual(t, a.ID(), c.ID())
+
+	assert.Equal(t, b, a.ID().ToMap("https://example.com/b.tgz"))
+}
+
+func TestMapID_DistinguishesBoundsWithTheSamePrefix(t *testing.T) {
+	registry, err := NewBoundsRegistry([]BoundsDefinition{
+		{ID: 3, Name: "UkraineAndAroundNorth", BBox: [4]float64{0, 0, 1, 1}, EogdataTile: "75N060W"},
+		{ID: 4, Name: "UkraineAndAroundSouth", BBox: [4]float64{0, 0, 1, 1}, EogdataTile: "75N060W"},
+	})
+	require.NoError(t, err)
+	previous := GetBoundsRegistry()
+	SetBoundsRegistry(registry)
+	defer SetBoundsRegistry(previous)
+
+	north := MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: 3, Date: Date{1, 1, 2022}}
+	south := north
+	south.Bounds = 4
+	assert.Equal(t, north.LegacyName(), south.LegacyName())
+	assert.NotEqual(t, north.String(), south.String())
+}
+
+func TestParseMapID_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"Monthly-UkraineAnd_2022-1-1",
+		"xyz-mon-b1-20220101",
+		"eog-xyz-b1-20220101",
+		"eog-mon-1-20220101",
+		"eog-mon-b-20220101",
+		"eog-mon-b1-2022011",
+		"eog-mon-b1-20221301",
+		"eog-mon-b1-2022010a",
+	} {
+		_, err := ParseMapID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMapID_JSON(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround, Date: Date{1, 1, 2022}}
+	data, err := json.Marshal(map[MapID]string{id: "published"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"eog-mon-b1-20220101": "published"}`, string(data))
+
+	var decoded map[MapID]string
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "published", decoded[id])
+}
+
+func TestMapID_LegacyName(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround, Date: Date{1, 1, 2022}}
+	assert.Equal(t, "Monthly-UkraineAnd_2022-1-1", id.LegacyName())
+}
+
+func TestParseLegacyName(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeDaily, Bounds: BoundsGazaAndAround, Date: Date{2, 3, 2023}}
+	parsed, err := ParseLegacyName(id.LegacyName())
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	parsed, err = ParseMapIDOrLegacyName("Monthly-UkraineAnd_2022-1-1")
+	require.NoError(t, err)
+	assert.Equal(t, BoundsUkraineAndAround, parsed.Bounds)
+
+	_, err = ParseLegacyName("Monthly-Unknown_2022-1-1")
+	assert.Error(t, err)
+	_, err = ParseMapIDOrLegacyName("not-a-map")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// ProcessedRadianceUpperClip is the radiance the processed maps are clipped at before they are log scaled to
+	// the 0-255 range, it has to match the python lambda
+	ProcessedRadianceUpperClip = 2000
+	// LitRadianceThreshold is the radiance in nW/cm²/sr above which a pixel counts as lit
+	LitRadianceThreshold = 0.5
+)
+
+// MapStats are the aggregates of a processed map, they are the points of the radiance time series per bounds
+type MapStats struct {
+	ID MapID
+	// SumRadiance and MeanRadiance are in nW/cm²/sr, the mean is taken over the pixels with data
+	SumRadiance     float64
+	MeanRadiance    float64
+	LitPixelCount   int
+	ValidPixelCount int
+	PixelCount      int
+	// Coverage is the fraction of the bounds observed without clouds when the provider records it, otherwise the
+	// fraction of the pixels that have data
+	Coverage   float64
+	ComputedAt time.Time
+}
+
+// ProcessedValueToRadiance reverts the log scaling the python lambda applies to the raw radiance, the result is only
+// as precise as the 256 levels of the processed map allow
+func ProcessedValueToRadiance(value uint8) float64 {
+	return math.Pow(10, float64(value)*math.Log10(ProcessedRadianceUpperClip)/math.MaxUint8) - 1
+}
+
+// ComputeMapStats aggregates the pixels of a processed map, pixels equal to noData are skipped, noData can be nil
+func ComputeMapStats(id MapID, pixels []uint8, noData *float64, computedAt time.Time) MapStats {
+	stats := MapStats{ID: id, PixelCount: len(pixels), ComputedAt: computedAt}
+	// The radiance only depends on the value, so the 256 possible values are counted instead of converting every pixel
+	var histogram [math.MaxUint8 + 1]int
+	for _, pixel := range pixels {
+		histogram[pixel]++
+	}
+	for value, count := range histogram {
+		if count == 0 || (noData != nil && float64(value) == *noData) {
+			continue
+		}
+		radiance := ProcessedValueToRadiance(uint8(value))
+		stats.ValidPixelCount += count
+		stats.SumRadiance += radiance * float64(count)
+		if radiance >= LitRadianceThreshold {
+			stats.LitPixelCount += count
+		}
+	}
+	if stats.ValidPixelCount > 0 {
+		stats.MeanRadiance = stats.SumRadiance / float64(stats.ValidPixelCount)
+	}
+	if stats.PixelCount > 0 {
+		stats.Coverage = float64(stats.ValidPixelCount) / float64(stats.PixelCount)
+	}
+	return stats
+}
+
+// WithCloudFreeCoverage uses the cloud-free coverage of the raw map as the coverage, the processed maps have no nodata
+// so their pixels alone count every map as fully covered. Nil keeps the coverage of the pixels
+func (s MapStats) WithCloudFreeCoverage(cloudFreeCoverage *float64) MapStats {
+	if cloudFreeCoverage != nil {
+		s.Coverage = *cloudFreeCoverage
+	}
+	return s
+}
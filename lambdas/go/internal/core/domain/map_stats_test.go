@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedValueToRadiance(t *testing.T) {
+	assert.Equal(t, 0.0, ProcessedValueToRadiance(0))
+	assert.InDelta(t, ProcessedRadianceUpperClip-1, ProcessedValueToRadiance(math.MaxUint8), 1e-9)
+	assert.Less(t, ProcessedValueToRadiance(100), ProcessedValueToRadiance(101))
+}
+
+func TestComputeMapStats(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround}
+	computedAt := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	// A pixel without light, one just above and one below the lit threshold and the brightest possible pixel
+	pixels := []uint8{0, 0, 30, 10, math.MaxUint8}
+
+	t.Run("without nodata", func(t *testing.T) {
+		stats := ComputeMapStats(id, pixels, nil, computedAt)
+
+		expectedSum := ProcessedValueToRadiance(30) + ProcessedValueToRadiance(10) + ProcessedValueToRadiance(255)
+		assert.Equal(t, id, stats.ID)
+		assert.Equal(t, 5, stats.PixelCount)
+		assert.Equal(t, 5, stats.ValidPixelCount)
+		assert.Equal(t, 2, stats.LitPixelCount)
+		assert.InDelta(t, expectedSum, stats.SumRadiance, 1e-9)
+		assert.InDelta(t, expectedSum/5, stats.MeanRadiance, 1e-9)
+		assert.Equal(t, 1.0, stats.Coverage)
+		assert.Equal(t, computedAt, stats.ComputedAt)
+	})
+
+	t.Run("with nodata", func(t *testing.T) {
+		noData := 0.0
+		stats := ComputeMapStats(id, pixels, &noData, computedAt)
+
+		assert.Equal(t, 3, stats.ValidPixelCount)
+		assert.InDelta(t, stats.SumRadiance/3, stats.MeanRadiance, 1e-9)
+		assert.InDelta(t, 0.6, stats.Coverage, 1e-9)
+	})
+
+	t.Run("without valid pixels", func(t *testing.T) {
+		noData := 0.0
+		stats := ComputeMapStats(id, []uint8{0, 0}, &noData, computedAt)
+
+		assert.Equal(t, 0.0, stats.MeanRadiance)
+		assert.Equal(t, 0.0, stats.Coverage)
+	})
+}
+
+func TestMapStats_WithCloudFreeCoverage(t *testing.T) {
+	stats := ComputeMapStats(MapID{}, []uint8{0, 10, 20}, nil, time.Time{})
+	cloudFreeCoverage := 0.3
+
+	assert.Equal(t, 0.3, stats.WithCloudFreeCoverage(&cloudFreeCoverage).Coverage)
+	assert.Equal(t, 1.0, stats.WithCloudFreeCoverage(nil).Coverage)
+}
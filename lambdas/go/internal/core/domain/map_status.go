@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+)
+
+// MapStage is where a map is in the pipeline, the stages are ordered so a later stage implies the earlier ones
+//
+//go:generate stringer -type=MapStage
+type MapStage int
+
+const mapStagePrefix = "MapStage"
+
+const (
+	MapStageUnspecified MapStage = iota
+	MapStageDiscovered
+	MapStageDownloadRequested
+	MapStageRaw
+	MapStageProcessed
+	MapStagePublished
+	// MapStageUnpublished is a map that was hidden from the frontend on purpose, its raw and processed tifs are kept
+	MapStageUnpublished
+	MapStageFailed
+)
+
+// StringToMapStage accepts the stage with or without the MapStage prefix, i.e. DownloadRequested,
+// download-requested and MapStageDownloadRequested are equal
+func StringToMapStage(s string) MapStage {
+	stage, _ := parseMapStage(s)
+	return stage
+}
+
+func parseMapStage(s string) (MapStage, bool) {
+	cleaned := infrastructure.CleanStrings(s)
+	for i := MapStageUnspecified; i <= MapStageFailed; i++ {
+		if infrastructure.CleanStrings(i.String()) == cleaned ||
+			infrastructure.CleanStrings(i.String()) == infrastructure.CleanStrings(mapStagePrefix+s) {
+			return i, true
+		}
+	}
+	return MapStageUnspecified, false
+}
+
+func (s MapStage) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *MapStage) UnmarshalText(text []byte) error {
+	stage, ok := parseMapStage(string(text))
+	if !ok {
+		return fmt.Errorf("unknown map stage: %s", text)
+	}
+	*s = stage
+	return nil
+}
+
+// MapStatus is the lifecycle of a single map across the pipeline
+type MapStatus struct {
+	ID    MapID
+	Stage MapStage
+	// Transitions is when the map entered each stage, the latest time is kept when a stage is entered more than once
+	Transitions map[MapStage]time.Time
+	UpdatedAt   time.Time
+	// FailedStage is the stage the map was moving to when it last failed, it is MapStageUnspecified when the map
+	// failed while being deleted
+	FailedStage MapStage
+	LastError   string
+	LastErrorAt *time.Time
+}
+
+func NewMapStatus(id MapID) MapStatus {
+	return MapStatus{ID: id, Transitions: make(map[MapStage]time.Time)}
+}
+
+// Transition moves the map to the stage, the last error is kept so it can still be inspected after a retry succeeded
+func (s *MapStatus) Transition(stage MapStage, at time.Time) {
+	if s.Transitions == nil {
+		s.Transitions = make(map[MapStage]time.Time)
+	}
+	s.Stage = stage
+	s.Transitions[stage] = at
+	s.UpdatedAt = at
+}
+
+// Fail marks the map as failed while it was moving to the stage
+func (s *MapStatus) Fail(stage MapStage, err error, at time.Time) {
+	s.Transition(MapStageFailed, at)
+	s.FailedStage = stage
+	s.LastError = err.Error()
+	s.LastErrorAt = &at
+}
+
+// Reached returns true when the map has been in the stage, or a stage after it, at some point
+func (s MapStatus) Reached(stage MapStage) bool {
+	for reached := range s.Transitions {
+		if reached != MapStageFailed && reached >= stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Unpublished returns true when the map was hidden on purpose and not published since, it does not depend on the
+// current stage so a failure recorded after the map was unpublished does not make it publishable again
+func (s MapStatus) Unpublished() bool {
+	unpublishedAt, ok := s.Transitions[MapStageUnpublished]
+	return ok && !unpublishedAt.Before(s.Transitions[MapStagePublished])
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapStatus_Transitions(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround, Date: Date{1, 1, 2022}}
+	status := NewMapStatus(id)
+	discoveredAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	failedAt := discoveredAt.Add(time.Hour)
+	rawAt := failedAt.Add(time.Hour)
+
+	status.Transition(MapStageDiscovered, discoveredAt)
+	assert.True(t, status.Reached(MapStageDiscovered))
+	assert.False(t, status.Reached(MapStageRaw))
+
+	status.Fail(MapStageDownloadRequested, errors.New("queue unavailable"), failedAt)
+	assert.Equal(t, MapStageFailed, status.Stage)
+	assert.Equal(t, MapStageDownloadRequested, status.FailedStage)
+	assert.Equal(t, "queue unavailable", status.LastError)
+	assert.Equal(t, failedAt, *status.LastErrorAt)
+	assert.False(t, status.Reached(MapStageDownloadRequested))
+
+	status.Transition(MapStageRaw, rawAt)
+	assert.Equal(t, MapStageRaw, status.Stage)
+	assert.Equal(t, rawAt, status.UpdatedAt)
+	assert.True(t, status.Reached(MapStageDownloadRequested))
+	assert.Equal(t, "queue unavailable", status.LastError)
+}
+
+func TestMapStatus_UnpublishedMapWasProcessed(t *testing.T) {
+	status := NewMapStatus(MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround})
+	status.Transition(MapStageUnpublished, time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, MapStageUnpublished, status.Stage)
+	assert.True(t, status.Reached(MapStageProcessed))
+}
+
+func TestMapStatus_Unpublished(t *testing.T) {
+	status := NewMapStatus(MapID{Provider: MapProviderEogdata, MapType: MapTypeMonthly, Bounds: BoundsUkraineAndAround})
+	publishedAt := time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC)
+	status.Transition(MapStagePublished, publishedAt)
+	assert.False(t, status.Unpublished())
+
+	status.Transition(MapStageUnpublished, publishedAt.Add(time.Hour))
+	status.Fail(MapStageUnpublished, errors.New("mapbox is down"), publishedAt.Add(2*time.Hour))
+	assert.True(t, status.Unpublished(), "a later failure does not clear the unpublished flag")
+
+	status.Transition(MapStagePublished, publishedAt.Add(3*time.Hour))
+	assert.False(t, status.Unpublished())
+}
+
+func TestMapStatus_JSON(t *testing.T) {
+	id := MapID{Provider: MapProviderEogdata, MapType: MapTypeDaily, Bounds: BoundsGazaAndAround, Date: Date{2, 3, 2023}}
+	status := NewMapStatus(id)
+	status.Transition(MapStagePublished, time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC))
+
+	data, err := json.Marshal(status)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ID":"eog-day-b2-20230302"`)
+	assert.Contains(t, string(data), `"MapStagePublished":"2023-03-03T00:00:00Z"`)
+
+	var decoded MapStatus
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, status, decoded)
+}
+
+func TestStringToMapStage(t *testing.T) {
+	assert.Equal(t, MapStageDownloadRequested, StringToMapStage("download-requested"))
+	assert.Equal(t, MapStageDownloadRequested, StringToMapStage("MapStageDownloadRequested"))
+	assert.Equal(t, MapStageFailed, StringToMapStage("failed"))
+	assert.Equal(t, MapStageUnpublished, StringToMapStage("unpublished"))
+	assert.Equal(t, MapStageUnspecified, StringToMapStage("unknown"))
+
+	var stage MapStage
+	assert.Error(t, stage.UnmarshalText([]byte("unknown")))
+}
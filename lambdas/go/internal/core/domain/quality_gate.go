@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInsufficientCoverage is returned for maps that may not be published because too much of the bounds was cloudy
+var ErrInsufficientCoverage = errors.New("the cloud-free coverage is below the minimum")
+
+// CoverageAction is what happens to a map whose cloud-free coverage is below the minimum
+type CoverageAction int
+
+const (
+	// CoverageActionFlag publishes the map but marks it as low confidence
+	CoverageActionFlag CoverageAction = iota
+	// CoverageActionRefuse does not publish the map
+	CoverageActionRefuse
+)
+
+// QualityGate checks the cloud-free coverage of a map over its bounds before it is published
+type QualityGate struct {
+	// MinCloudFreeCoverage is the fraction of the bounds that has to be observed without clouds, 0 disables the gate
+	MinCloudFreeCoverage float64
+	Action               CoverageAction
+}
+
+// ParseQualityGate parses the minimum coverage, e.g. "0.4", and the action, "flag" or "refuse". An empty minimum
+// disables the gate and an empty action flags
+func ParseQualityGate(minCloudFreeCoverage string, action string) (QualityGate, error) {
+	var gate QualityGate
+	if minCloudFreeCoverage = strings.TrimSpace(minCloudFreeCoverage); minCloudFreeCoverage != "" {
+		minimum, err := strconv.ParseFloat(minCloudFreeCoverage, 64)
+		if err != nil || minimum < 0 || minimum > 1 {
+			return QualityGate{}, fmt.Errorf("the minimum cloud-free coverage must be between 0 and 1, got %q",
+				minCloudFreeCoverage)
+		}
+		gate.MinCloudFreeCoverage = minimum
+	}
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "", "flag":
+		gate.Action = CoverageActionFlag
+	case "refuse":
+		gate.Action = CoverageActionRefuse
+	default:
+		return QualityGate{}, fmt.Errorf("unknown coverage action %q, expected flag or refuse", action)
+	}
+	return gate, nil
+}
+
+// Check returns whether the map has to be marked as low confidence, or ErrInsufficientCoverage when it may not be
+// published at all. Maps whose coverage was not recorded, e.g. the ones processed before the coverage was, pass
+func (g QualityGate) Check(cloudFreeCoverage *float64) (bool, error) {
+	if g.MinCloudFreeCoverage == 0 || cloudFreeCoverage == nil || *cloudFreeCoverage >= g.MinCloudFreeCoverage {
+		return false, nil
+	}
+	if g.Action == CoverageActionRefuse {
+		return false, fmt.Errorf("%w, %.1f%% of the bounds was observed without clouds while %.1f%% is required",
+			ErrInsufficientCoverage, *cloudFreeCoverage*100, g.MinCloudFreeCoverage*100)
+	}
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQualityGate(t *testing.T) {
+	gate, err := ParseQualityGate("", "")
+	require.NoError(t, err)
+	assert.Equal(t, QualityGate{}, gate)
+
+	gate, err = ParseQualityGate("0.4", "Refuse")
+	require.NoError(t, err)
+	assert.Equal(t, QualityGate{MinCloudFreeCoverage: 0.4, Action: CoverageActionRefuse}, gate)
+
+	_, err = ParseQualityGate("40", "flag")
+	assert.Error(t, err)
+	_, err = ParseQualityGate("0.4", "skip")
+	assert.Error(t, err)
+}
+
+func TestQualityGate_Check(t *testing.T) {
+	low, high := 0.2, 0.6
+
+	t.Run("disabled", func(t *testing.T) {
+		lowConfidence, err := QualityGate{}.Check(&low)
+		assert.NoError(t, err)
+		assert.False(t, lowConfidence)
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		gate := QualityGate{MinCloudFreeCoverage: 0.4, Action: CoverageActionFlag}
+
+		lowConfidence, err := gate.Check(&low)
+		assert.NoError(t, err)
+		assert.True(t, lowConfidence)
+
+		lowConfidence, err = gate.Check(&high)
+		assert.NoError(t, err)
+		assert.False(t, lowConfidence)
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		gate := QualityGate{MinCloudFreeCoverage: 0.4, Action: CoverageActionRefuse}
+
+		_, err := gate.Check(&low)
+		assert.ErrorIs(t, err, ErrInsufficientCoverage)
+
+		lowConfidence, err := gate.Check(nil)
+		assert.NoError(t, err, "maps without a recorded coverage pass")
+		assert.False(t, lowConfidence)
+	})
+}
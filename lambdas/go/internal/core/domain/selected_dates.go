@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// SelectedDates decides which maps a SyncMapRequest is interested in, a map is selected when any of the selectors
+// matches it.
+type SelectedDates struct {
+	// Months and Years select every month of every year given, i.e. their cartesian product
+	Months []time.Month `validate:"dive,min=1,max=12"`
+	Years  []int        `validate:"dive,gte=1"`
+	Range  *DateRange
+	// Relative is resolved when the request is handled, so a static request always selects the newest maps
+	Relative *RelativeDates
+}
+
+// DateRange selects every map that overlaps the period between From and To, both are inclusive.
+//
+//	A Day of 0 means the whole month and a Month of 0 the whole year,
+//	i.e. From: {Year: 2022, Month: 11} To: {Year: 2023, Month: 3} selects Nov 2022 through Mar 2023.
+type DateRange struct {
+	From Date
+	To   Date
+}
+
+// RelativeDates selects the last N days, months or years up to and including the current one
+type RelativeDates struct {
+	LastDays   int `validate:"gte=0"`
+	LastMonths int `validate:"gte=0"`
+	LastYears  int `validate:"gte=0"`
+}
+
+func (s SelectedDates) Validate() error {
+	if (len(s.Months) == 0) != (len(s.Years) == 0) {
+		return errors.New("months and years must be selected together")
+	}
+	if s.Range != nil {
+		if s.Range.From.Year == 0 || s.Range.To.Year == 0 {
+			return errors.New("both the start and the end of the date range need a year")
+		}
+		if periodStart(s.Range.From).After(periodEnd(s.Range.To)) {
+			return errors.New("the start of the date range is after the end")
+		}
+	}
+	if s.Relative != nil && s.Relative.LastDays == 0 && s.Relative.LastMonths == 0 && s.Relative.LastYears == 0 {
+		return errors.New("the relative dates did not select anything")
+	}
+	if len(s.Months) == 0 && s.Range == nil && s.Relative == nil {
+		return errors.New("no dates were selected")
+	}
+	return nil
+}
+
+// Includes reports whether a map of the given type and date is selected, now is used to resolve the relative dates
+func (s SelectedDates) Includes(d Date, mapType MapType, now time.Time) bool {
+	// Annual maps do not have a meaningful month, so they are only selected by their year
+	isSelectedMonth := mapType == MapTypeAnnual || slices.Contains(s.Months, d.Month)
+	if isSelectedMonth && slices.Contains(s.Years, d.Year) {
+		return true
+	}
+	start, end := mapPeriod(d, mapType)
+	for _, r := range s.ranges(now) {
+		if !start.After(periodEnd(r.To)) && !end.Before(periodStart(r.From)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SelectedDates) ranges(now time.Time) []DateRange {
+	var ranges []DateRange
+	if s.Range != nil {
+		ranges = append(ranges, *s.Range)
+	}
+	if s.Relative == nil {
+		return ranges
+	}
+	today := Date{Day: now.Day(), Month: now.Month(), Year: now.Year()}
+	if n := s.Relative.LastDays; n > 0 {
+		from := now.AddDate(0, 0, -(n - 1))
+		ranges = append(ranges, DateRange{
+			From: Date{Day: from.Day(), Month: from.Month(), Year: from.Year()},
+			To:   today,
+		})
+	}
+	if n := s.Relative.LastMonths; n > 0 {
+		from := time.Date(now.Year(), now.Month()-time.Month(n-1), 1, 0, 0, 0, 0, time.UTC)
+		ranges = append(ranges, DateRange{From: Date{Month: from.Month(), Year: from.Year()}, To: today})
+	}
+	if n := s.Relative.LastYears; n > 0 {
+		ranges = append(ranges, DateRange{From: Date{Year: now.Year() - (n - 1)}, To: today})
+	}
+	return ranges
+}
+
+// mapPeriod is the period that a map covers
+func mapPeriod(d Date, mapType MapType) (time.Time, time.Time) {
+	switch mapType {
+	case MapTypeAnnual:
+		return periodStart(Date{Year: d.Year}), periodEnd(Date{Year: d.Year})
+	case MapTypeMonthly:
+		return periodStart(Date{Month: d.Month, Year: d.Year}), periodEnd(Date{Month: d.Month, Year: d.Year})
+	default:
+		return periodStart(d), periodEnd(d)
+	}
+}
+
+func periodStart(d Date) time.Time {
+	month, day := d.Month, d.Day
+	if month == 0 {
+		month, day = time.January, 1
+	}
+	if day == 0 {
+		day = 1
+	}
+	return time.Date(d.Year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func periodEnd(d Date) time.Time {
+	switch {
+	case d.Month == 0:
+		return time.Date(d.Year+1, time.January, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	case d.Day == 0:
+		return time.Date(d.Year, d.Month+1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	default:
+		return time.Date(d.Year, d.Month, d.Day+1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+	}
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectedDates_Includes(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		selectedDates SelectedDates
+		date          Date
+		mapType       MapType
+		expected      bool
+	}{
+		{
+			name:          "cartesian product of months and years",
+			selectedDates: SelectedDates{Months: []time.Month{time.February}, Years: []int{2022, 2023}},
+			date:          Date{Day: 1, Month: time.February, Year: 2022},
+			mapType:       MapTypeMonthly,
+			expected:      true,
+		},
+		{
+			name:          "annual maps ignore the selected months",
+			selectedDates: SelectedDates{Months: []time.Month{time.February}, Years: []int{2022}},
+			date:          NewAnnualDate(2022),
+			mapType:       MapTypeAnnual,
+			expected:      true,
+		},
+		{
+			name: "monthly map inside a range of months",
+			selectedDates: SelectedDates{Range: &DateRange{
+				From: Date{Month: time.November, Year: 2022},
+				To:   Date{Month: time.March, Year: 2023},
+			}},
+			date:     Date{Day: 1, Month: time.January, Year: 2023},
+			mapType:  MapTypeMonthly,
+			expected: true,
+		},
+		{
+			name: "monthly map after a range of months",
+			selectedDates: SelectedDates{Range: &DateRange{
+				From: Date{Month: time.November, Year: 2022},
+				To:   Date{Month: time.March, Year: 2023},
+			}},
+			date:     Date{Day: 1, Month: time.April, Year: 2023},
+			mapType:  MapTypeMonthly,
+			expected: false,
+		},
+		{
+			name: "monthly map overlapping the start of a range of days",
+			selectedDates: SelectedDates{Range: &DateRange{
+				From: Date{Day: 20, Month: time.November, Year: 2022},
+				To:   Date{Day: 5, Month: time.December, Year: 2022},
+			}},
+			date:     Date{Day: 1, Month: time.November, Year: 2022},
+			mapType:  MapTypeMonthly,
+			expected: true,
+		},
+		{
+			name: "daily map before a range of days",
+			selectedDates: SelectedDates{Range: &DateRange{
+				From: Date{Day: 20, Month: time.November, Year: 2022},
+				To:   Date{Day: 5, Month: time.December, Year: 2022},
+			}},
+			date:     Date{Day: 19, Month: time.November, Year: 2022},
+			mapType:  MapTypeDaily,
+			expected: false,
+		},
+		{
+			name:          "last months includes the current month",
+			selectedDates: SelectedDates{Relative: &RelativeDates{LastMonths: 3}},
+			date:          Date{Day: 1, Month: time.January, Year: 2023},
+			mapType:       MapTypeMonthly,
+			expected:      true,
+		},
+		{
+			name:          "last months excludes older months",
+			selectedDates: SelectedDates{Relative: &RelativeDates{LastMonths: 3}},
+			date:          Date{Day: 1, Month: time.December, Year: 2022},
+			mapType:       MapTypeMonthly,
+			expected:      false,
+		},
+		{
+			name:          "last days",
+			selectedDates: SelectedDates{Relative: &RelativeDates{LastDays: 7}},
+			date:          Date{Day: 9, Month: time.March, Year: 2023},
+			mapType:       MapTypeDaily,
+			expected:      true,
+		},
+		{
+			name:          "last years",
+			selectedDates: SelectedDates{Relative: &RelativeDates{LastYears: 2}},
+			date:          NewAnnualDate(2021),
+			mapType:       MapTypeAnnual,
+			expected:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NoError(t, tc.selectedDates.Validate())
+			assert.Equal(t, tc.expected, tc.selectedDates.Includes(tc.date, tc.mapType, now))
+		})
+	}
+}
+
+func TestSelectedDates_Validate(t *testing.T) {
+	assert.Error(t, SelectedDates{}.Validate())
+	assert.Error(t, SelectedDates{Months: []time.Month{time.January}}.Validate())
+	assert.Error(t, SelectedDates{Relative: &RelativeDates{}}.Validate())
+	assert.Error(t, SelectedDates{Range: &DateRange{
+		From: Date{Month: time.March, Year: 2023},
+		To:   Date{Month: time.November, Year: 2022},
+	}}.Validate())
+	assert.NoError(t, SelectedDates{Range: &DateRange{
+		From: Date{Month: time.March, Year: 2023},
+		To:   Date{Month: time.March, Year: 2023},
+	}}.Validate())
+}
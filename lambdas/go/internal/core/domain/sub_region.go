@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var subRegionIDPattern = regexp.MustCompile("^[a-z0-9][a-z0-9_-]*$")
+
+// SubRegion is an area inside of the bounds, e.g. an oblast, the radiance of every processed map of the bounds is
+// also aggregated per sub-region
+type SubRegion struct {
+	// ID is part of the region stats document so it has to stay the same, e.g. kharkiv
+	ID     string
+	Name   string
+	Bounds Bounds
+	// MultiPolygon follows the GeoJSON MultiPolygon coordinates convention, i.e. [lon, lat] in WGS84
+	MultiPolygon [][][][2]float64
+}
+
+// RegionStats are the aggregates of a processed map within a single sub-region, Stats.ID is the map
+type RegionStats struct {
+	RegionID   string
+	RegionName string
+	Stats      MapStats
+}
+
+func (r SubRegion) Validate() error {
+	if !subRegionIDPattern.MatchString(r.ID) {
+		return fmt.Errorf("the sub-region id %q may only contain lowercase letters, digits, - and _", r.ID)
+	}
+	if r.Bounds == BoundsUnspecified {
+		return fmt.Errorf("the sub-region %s must belong to a bounds", r.ID)
+	}
+	if len(r.MultiPolygon) == 0 {
+		return fmt.Errorf("the sub-region %s has no polygon", r.ID)
+	}
+	for _, polygon := range r.MultiPolygon {
+		if len(polygon) == 0 {
+			return fmt.Errorf("the sub-region %s has an empty polygon", r.ID)
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return fmt.Errorf("the polygons of the sub-region %s must be made of closed rings", r.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateSubRegions validates the sub-regions of a single bounds, their ids have to be unique
+func ValidateSubRegions(bounds Bounds, regions []SubRegion) error {
+	ids := make(map[string]struct{}, len(regions))
+	for _, region := range regions {
+		if err := region.Validate(); err != nil {
+			return err
+		}
+		if region.Bounds != bounds {
+			return fmt.Errorf("the sub-region %s belongs to %s instead of %s", region.ID, region.Bounds, bounds)
+		}
+		if _, ok := ids[region.ID]; ok {
+			return fmt.Errorf("the sub-region id %s is used more than once", region.ID)
+		}
+		ids[region.ID] = struct{}{}
+	}
+	return nil
+}
@@ -25,12 +25,21 @@ type InternalMapRepo interface {
 	Create(ctx context.Context, m domain.Map) error
 	Download(ctx context.Context, m domain.Map) (*domain.LocalMap, error)
 	Delete(ctx context.Context, m domain.Map) error
+	// PlanDelete returns what Delete would remove for the map without removing anything
+	PlanDelete(ctx context.Context, m domain.Map) ([]string, error)
+	// CloudFreeCoverage returns the fraction of the bounds that was observed without clouds, nil when the coverage
+	// of the map was not recorded
+	CloudFreeCoverage(ctx context.Context, m domain.Map) (*float64, error)
 }
 
 // MapTileServerRepo is the interface for interacting with the (currently) external service where our maps are hosted, that the frontend can display
 type MapTileServerRepo interface {
 	Publish(ctx context.Context, m domain.LocalMap) (*domain.PublishedMap, error)
+	// List returns the maps the tile server hosts, only the identity of the maps is known
+	List(ctx context.Context) ([]domain.PublishedMap, error)
 	Delete(ctx context.Context, m domain.Map) error
+	// PlanDelete returns what Delete would remove for the map without removing anything
+	PlanDelete(ctx context.Context, m domain.Map) ([]string, error)
 }
 
 // FrontendMapDataRepo is an interface for interacting with the pointers to the published maps the frontend can display
@@ -38,4 +47,54 @@ type FrontendMapDataRepo interface {
 	Upsert(ctx context.Context, m domain.PublishedMap) error
 	List(ctx context.Context) ([]domain.PublishedMap, error)
 	Delete(ctx context.Context, m domain.Map) error
+	// PlanDelete returns what Delete would remove for the map without removing anything
+	PlanDelete(ctx context.Context, m domain.Map) ([]string, error)
+	// SetAnomalies replaces the anomalies attached to the published maps, maps without anomalies are cleared
+	SetAnomalies(ctx context.Context, anomalies []domain.Anomaly) error
+}
+
+// MapStatusRepo is an interface for interacting with the lifecycle state of each map in the pipeline
+type MapStatusRepo interface {
+	// Get returns nil when the status of the map was never recorded
+	Get(ctx context.Context, id domain.MapID) (*domain.MapStatus, error)
+	List(ctx context.Context) ([]domain.MapStatus, error)
+	Upsert(ctx context.Context, status domain.MapStatus) error
+	Delete(ctx context.Context, id domain.MapID) error
+}
+
+// MapStatsRepo is an interface for interacting with the statistics of the processed maps, the frontend charts them
+type MapStatsRepo interface {
+	List(ctx context.Context) ([]domain.MapStats, error)
+	Upsert(ctx context.Context, stats domain.MapStats) error
+	// Replace overwrites every statistic, it is used when the whole series is rebuilt
+	Replace(ctx context.Context, stats []domain.MapStats) error
+	Delete(ctx context.Context, id domain.MapID) error
+}
+
+// SubRegionRepo is an interface for interacting with the sub-regions, e.g. oblasts, that are registered per bounds
+type SubRegionRepo interface {
+	// List returns no sub-regions when none were registered for the bounds
+	List(ctx context.Context, bounds domain.Bounds) ([]domain.SubRegion, error)
+	// Replace overwrites every sub-region of the bounds
+	Replace(ctx context.Context, bounds domain.Bounds, regions []domain.SubRegion) error
+}
+
+// RegionStatsRepo is an interface for interacting with the statistics of the processed maps per sub-region
+type RegionStatsRepo interface {
+	List(ctx context.Context) ([]domain.RegionStats, error)
+	// UpsertMap replaces the statistics of every sub-region for the map the stats belong to
+	UpsertMap(ctx context.Context, id domain.MapID, stats []domain.RegionStats) error
+	// Replace overwrites every statistic, it is used when the whole series is rebuilt
+	Replace(ctx context.Context, stats []domain.RegionStats) error
+	DeleteMap(ctx context.Context, id domain.MapID) error
+}
+
+// AnomalyRepo is an interface for interacting with the anomalies that were flagged in the radiance series
+type AnomalyRepo interface {
+	List(ctx context.Context) ([]domain.Anomaly, error)
+	// UpsertMap replaces the anomalies of the map, no anomalies removes the ones it had
+	UpsertMap(ctx context.Context, id domain.MapID, anomalies []domain.Anomaly) error
+	// Replace overwrites every anomaly, it is used when the whole series is analysed again
+	Replace(ctx context.Context, anomalies []domain.Anomaly) error
+	DeleteMap(ctx context.Context, id domain.MapID) error
 }
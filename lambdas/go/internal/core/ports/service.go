@@ -10,7 +10,30 @@ type OrchestratorService interface {
 	SyncInternalWithExternalMaps(ctx context.Context, request domain.SyncMapRequest) (*int, error)
 	ListRawInternalMaps(ctx context.Context) ([]domain.Map, error)
 	ListProcessedInternalMaps(ctx context.Context) ([]domain.Map, error)
+	// DownloadProcessedMap fetches the processed tif of the map, e.g. to render it locally
+	DownloadProcessedMap(ctx context.Context, m domain.Map) (*domain.LocalMap, error)
 	ListPublishedMaps(ctx context.Context) ([]domain.PublishedMap, error)
 	PublishMap(ctx context.Context, m domain.Map) error
-	DeleteMap(ctx context.Context, m domain.Map)
+	// DeleteMap returns the outcome of every repository, the error aggregates the repositories that failed
+	DeleteMap(ctx context.Context, m domain.Map, dryRun bool) (*domain.DeleteResult, error)
+	// UnpublishMap removes the map from the frontend, and from the tile server when removeFromTileServer is true, the
+	// internal repos are left intact so the map can be published again later
+	UnpublishMap(ctx context.Context, m domain.Map, removeFromTileServer bool) (*domain.DeleteResult, error)
+	// ListMapStatuses lists where each map is in the pipeline, MapStageUnspecified lists every map and
+	// MapStageUnpublished also lists the unpublished maps that failed since
+	ListMapStatuses(ctx context.Context, stage domain.MapStage) ([]domain.MapStatus, error)
+	// UpdateMapStats computes the statistics of a processed map and checks it for anomalies without publishing it
+	UpdateMapStats(ctx context.Context, m domain.Map) error
+	// RebuildMapStats recomputes the statistics of every processed map that is not unpublished, the maps that failed
+	// are left out of the series and reported in the error
+	RebuildMapStats(ctx context.Context) ([]domain.MapStats, error)
+	// RegisterSubRegions replaces the sub-regions of the bounds whose radiance is aggregated for every processed map
+	RegisterSubRegions(ctx context.Context, bounds domain.Bounds, regions []domain.SubRegion) error
+	ListSubRegions(ctx context.Context, bounds domain.Bounds) ([]domain.SubRegion, error)
+	// DetectAnomalies checks every map of the series again for a significant drop in radiance, per bounds and per
+	// sub-region, and replaces the anomalies that were flagged before
+	DetectAnomalies(ctx context.Context) ([]domain.Anomaly, error)
+	ListAnomalies(ctx context.Context) ([]domain.Anomaly, error)
+	// Reconcile reports the drift between the repositories, and repairs what it can when apply is true
+	Reconcile(ctx context.Context, apply bool) (*domain.DriftReport, error)
 }
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+)
+
+// DetectAnomalies analyses the whole series again, e.g. after the series was rebuilt or the config changed
+func (srv *service) DetectAnomalies(ctx context.Context) ([]domain.Anomaly, error) {
+	series, err := srv.mapStatsRepo.List(ctx)
+	if err != nil {
+		return nil, errors.New("listing the map stats has failed, error: " + err.Error())
+	}
+	regionSeries, err := srv.regionStatsRepo.List(ctx)
+	if err != nil {
+		return nil, errors.New("listing the region stats has failed, error: " + err.Error())
+	}
+	regionStatsPerMap := make(map[domain.MapID][]domain.RegionStats)
+	for _, stats := range regionSeries {
+		regionStatsPerMap[stats.Stats.ID] = append(regionStatsPerMap[stats.Stats.ID], stats)
+	}
+
+	at := time.Now().UTC()
+	var anomalies []domain.Anomaly
+	for _, stats := range series {
+		flagged := srv.detectAnomalies(stats, regionStatsPerMap[stats.ID], series, regionSeries, at)
+		anomalies = append(anomalies, flagged...)
+	}
+	if err := srv.anomalyRepo.Replace(ctx, anomalies); err != nil {
+		return nil, errors.New("storing the anomalies has failed, error: " + err.Error())
+	}
+	srv.logger.Info(ctx, "Detected the anomalies of the series", "maps", len(series), "anomalies", len(anomalies))
+	srv.attachAnomalies(ctx)
+	return anomalies, nil
+}
+
+func (srv *service) ListAnomalies(ctx context.Context) ([]domain.Anomaly, error) {
+	return srv.anomalyRepo.List(ctx)
+}
+
+// updateAnomalies checks a newly published map against the series, failures are only logged like the ones of the
+// statistics it is based on
+func (srv *service) updateAnomalies(ctx context.Context, stats domain.MapStats, regionStats []domain.RegionStats) {
+	series, err := srv.mapStatsRepo.List(ctx)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not list the map stats", "map", stats.ID.String(), "error", err)
+		return
+	}
+	regionSeries, err := srv.regionStatsRepo.List(ctx)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not list the region stats", "map", stats.ID.String(), "error", err)
+		return
+	}
+	anomalies := srv.detectAnomalies(stats, regionStats, series, regionSeries, time.Now().UTC())
+	for _, anomaly := range anomalies {
+		srv.logger.Warn(ctx, "Flagged a suspected outage",
+			"map", anomaly.ID.String(), "region", anomaly.RegionID, "change", anomaly.Change)
+	}
+	if err := srv.anomalyRepo.UpsertMap(ctx, stats.ID, anomalies); err != nil {
+		srv.logger.Warn(ctx, "Could not update the anomalies", "map", stats.ID.String(), "error", err)
+		return
+	}
+	srv.attachAnomalies(ctx)
+}
+
+// detectAnomalies flags the map when the whole bounds dropped and once more for every sub-region that dropped
+func (srv *service) detectAnomalies(
+	stats domain.MapStats,
+	regionStats []domain.RegionStats,
+	series []domain.MapStats,
+	regionSeries []domain.RegionStats,
+	at time.Time,
+) []domain.Anomaly {
+	var anomalies []domain.Anomaly
+	if anomaly, flagged := domain.DetectAnomaly(stats, series, srv.anomalyConfig); flagged {
+		anomaly.DetectedAt = at
+		anomalies = append(anomalies, anomaly)
+	}
+	for _, region := range regionStats {
+		var history []domain.MapStats
+		for _, s := range regionSeries {
+			if s.RegionID == region.RegionID {
+				history = append(history, s.Stats)
+			}
+		}
+		if anomaly, flagged := domain.DetectAnomaly(region.Stats, history, srv.anomalyConfig); flagged {
+			anomaly.RegionID, anomaly.RegionName, anomaly.DetectedAt = region.RegionID, region.RegionName, at
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies
+}
+
+// attachAnomalies copies every anomaly to the frontend json when that is enabled
+func (srv *service) attachAnomalies(ctx context.Context) {
+	if !srv.anomalyConfig.AttachToFrontend {
+		return
+	}
+	anomalies, err := srv.anomalyRepo.List(ctx)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not list the anomalies", "error", err)
+		return
+	}
+	if err := srv.frontendMapDataRepo.SetAnomalies(ctx, anomalies); err != nil {
+		srv.logger.Warn(ctx, "Could not attach the anomalies to the frontend", "error", err)
+	}
+}
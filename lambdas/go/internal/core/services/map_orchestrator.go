@@ -8,7 +8,6 @@ import (
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
 	"github.com/go-playground/validator/v10"
-	"golang.org/x/exp/slices"
 )
 
 type service struct {
@@ -18,6 +17,13 @@ type service struct {
 	processedInternalMapRepo ports.InternalMapRepo
 	frontendMapDataRepo      ports.FrontendMapDataRepo
 	mapTileServerRepo        ports.MapTileServerRepo
+	mapStatusRepo            ports.MapStatusRepo
+	mapStatsRepo             ports.MapStatsRepo
+	subRegionRepo            ports.SubRegionRepo
+	regionStatsRepo          ports.RegionStatsRepo
+	anomalyRepo              ports.AnomalyRepo
+	anomalyConfig            domain.AnomalyConfig
+	qualityGate              domain.QualityGate
 }
 
 func NewOrchestratorService(
@@ -27,6 +33,13 @@ func NewOrchestratorService(
 	processedInternalMapRepo ports.InternalMapRepo,
 	frontendMapDataRepo ports.FrontendMapDataRepo,
 	mapTileServerRepo ports.MapTileServerRepo,
+	mapStatusRepo ports.MapStatusRepo,
+	mapStatsRepo ports.MapStatsRepo,
+	subRegionRepo ports.SubRegionRepo,
+	regionStatsRepo ports.RegionStatsRepo,
+	anomalyRepo ports.AnomalyRepo,
+	anomalyConfig domain.AnomalyConfig,
+	qualityGate domain.QualityGate,
 ) ports.OrchestratorService {
 	return &service{
 		logger:                   logger,
@@ -35,6 +48,13 @@ func NewOrchestratorService(
 		rawInternalMapRepo:       rawInternalMapRepo,
 		frontendMapDataRepo:      frontendMapDataRepo,
 		mapTileServerRepo:        mapTileServerRepo,
+		mapStatusRepo:            mapStatusRepo,
+		mapStatsRepo:             mapStatsRepo,
+		subRegionRepo:            subRegionRepo,
+		regionStatsRepo:          regionStatsRepo,
+		anomalyRepo:              anomalyRepo,
+		anomalyConfig:            anomalyConfig,
+		qualityGate:              qualityGate,
 	}
 }
 
@@ -71,11 +91,34 @@ func (srv *service) ListProcessedInternalMaps(ctx context.Context) ([]domain.Map
 	)
 }
 
+func (srv *service) DownloadProcessedMap(ctx context.Context, m domain.Map) (*domain.LocalMap, error) {
+	localMap, err := srv.processedInternalMapRepo.Download(ctx, m)
+	if err != nil {
+		return nil, errors.New("downloading the processed map has failed, error: " + err.Error())
+	}
+	return localMap, nil
+}
+
 func (srv *service) ListPublishedMaps(ctx context.Context) ([]domain.PublishedMap, error) {
 	return srv.frontendMapDataRepo.List(ctx)
 }
 
 func (srv *service) PublishMap(ctx context.Context, m domain.Map) error {
+	if err := srv.publishMap(ctx, m); err != nil {
+		srv.failMapStage(ctx, m.ID(), domain.MapStagePublished, err)
+		return err
+	}
+	srv.setMapStage(ctx, m.ID(), domain.MapStagePublished)
+	return nil
+}
+
+func (srv *service) publishMap(ctx context.Context, m domain.Map) error {
+	cloudFreeCoverage := srv.cloudFreeCoverage(ctx, m)
+	lowConfidence, err := srv.qualityGate.Check(cloudFreeCoverage)
+	if err != nil {
+		srv.logger.Warn(ctx, "The map did not pass the quality gate", "map", m.ID().String(), "error", err)
+		return err
+	}
 	theMap, err := srv.processedInternalMapRepo.Download(ctx, m)
 	if err != nil {
 		return err
@@ -85,6 +128,7 @@ func (srv *service) PublishMap(ctx context.Context, m domain.Map) error {
 		srv.logger.Error(ctx, msg, "map", m)
 		return errors.New(msg)
 	}
+	srv.observeMapStage(ctx, m.ID(), domain.MapStageProcessed)
 	publishedMap, err := srv.mapTileServerRepo.Publish(ctx, *theMap)
 	if err != nil {
 		srv.logger.Error(ctx, "While publishing the map", "map", m, "error", err)
@@ -95,34 +139,129 @@ func (srv *service) PublishMap(ctx context.Context, m domain.Map) error {
 		srv.logger.Error(ctx, msg)
 		return errors.New(msg)
 	}
-	if err != srv.frontendMapDataRepo.Upsert(ctx, *publishedMap) {
+	publishedMap.CloudFreeCoverage, publishedMap.LowConfidence = cloudFreeCoverage, lowConfidence
+	if lowConfidence {
+		srv.logger.Warn(ctx, "Publishing a map with a low cloud-free coverage", "map", m.ID().String(),
+			"cloudFreeCoverage", *cloudFreeCoverage)
+	}
+	if err := srv.frontendMapDataRepo.Upsert(ctx, *publishedMap); err != nil {
 		return err
 	}
+	srv.updateMapStats(ctx, *theMap, cloudFreeCoverage)
 	return nil
 }
 
-// DeleteMap deletes a map from all the repos
-func (srv *service) DeleteMap(ctx context.Context, m domain.Map) {
-	if err := srv.mapTileServerRepo.Delete(ctx, m); err != nil {
-		srv.logger.Error(ctx, "Map was not deleted from the tile server repo", "error", err)
-	} else {
-		srv.logger.Debug(ctx, "Map was deleted from the tile server repo", "map", m)
+// cloudFreeCoverage is recorded on the raw map when it is cropped, a map whose coverage cannot be read is treated like
+// a map whose coverage was never recorded
+func (srv *service) cloudFreeCoverage(ctx context.Context, m domain.Map) *float64 {
+	cloudFreeCoverage, err := srv.rawInternalMapRepo.CloudFreeCoverage(ctx, m)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not read the cloud-free coverage", "map", m.ID().String(), "error", err)
+		return nil
 	}
-	if err := srv.frontendMapDataRepo.Delete(ctx, m); err != nil {
-		srv.logger.Error(ctx, "Map was not deleted from the frontend repo", "error", err)
-	} else {
-		srv.logger.Debug(ctx, "Map was deleted from the frontend repo", "map", m)
+	return cloudFreeCoverage
+}
+
+func (srv *service) ListMapStatuses(ctx context.Context, stage domain.MapStage) ([]domain.MapStatus, error) {
+	statuses, err := srv.mapStatusRepo.List(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if err := srv.processedInternalMapRepo.Delete(ctx, m); err != nil {
-		srv.logger.Error(ctx, "Map was not deleted from the processed internal repo", "error", err)
-	} else {
-		srv.logger.Debug(ctx, "Map was deleted from the processed internal repo", "map", m)
+	if stage == domain.MapStageUnspecified {
+		return statuses, nil
 	}
-	if err := srv.rawInternalMapRepo.Delete(ctx, m); err != nil {
-		srv.logger.Error(ctx, "Map was not deleted from the raw internal repo", "error", err)
-	} else {
-		srv.logger.Debug(ctx, "Map was deleted from the raw internal repo", "map", m)
+	filtered := make([]domain.MapStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Stage == stage || (stage == domain.MapStageUnpublished && status.Unpublished()) {
+			filtered = append(filtered, status)
+		}
 	}
+	return filtered, nil
+}
+
+// DeleteMap deletes a map from all the repos, a failure in one repo does not stop the map from being deleted from
+// the others. With dryRun nothing is deleted and the result only lists what would be removed
+func (srv *service) DeleteMap(ctx context.Context, m domain.Map, dryRun bool) (*domain.DeleteResult, error) {
+	result := srv.deleteFromRepos(ctx, m, dryRun, []namedDeletableRepo{
+		{name: "tile server", repo: srv.mapTileServerRepo},
+		{name: "frontend", repo: srv.frontendMapDataRepo},
+		{name: "processed internal", repo: srv.processedInternalMapRepo},
+		{name: "raw internal", repo: srv.rawInternalMapRepo},
+	})
+	if !dryRun {
+		srv.deleteMapStatus(ctx, m.ID(), result.Err())
+		if result.Err() == nil {
+			srv.deleteMapStats(ctx, m.ID())
+		}
+	}
+	return &result, result.Err()
+}
+
+// UnpublishMap hides a map without touching the internal repos, so it can be published again without downloading
+// it from the external map provider
+func (srv *service) UnpublishMap(
+	ctx context.Context,
+	m domain.Map,
+	removeFromTileServer bool,
+) (*domain.DeleteResult, error) {
+	// The intent is recorded before anything is removed, without it reconcile would publish the map again
+	if err := srv.recordMapStage(ctx, m.ID(), domain.MapStageUnpublished); err != nil {
+		srv.logger.Error(ctx, "Could not record that the map is unpublished", "map", m.ID().String(), "error", err)
+		return nil, errors.New("recording the unpublished map status has failed, error: " + err.Error())
+	}
+	repos := []namedDeletableRepo{{name: "frontend", repo: srv.frontendMapDataRepo}}
+	if removeFromTileServer {
+		repos = append(repos, namedDeletableRepo{name: "tile server", repo: srv.mapTileServerRepo})
+	}
+	result := srv.deleteFromRepos(ctx, m, false, repos)
+	if err := result.Err(); err != nil {
+		srv.failMapStage(ctx, m.ID(), domain.MapStageUnpublished, err)
+		return &result, err
+	}
+	srv.deleteMapStats(ctx, m.ID())
+	return &result, nil
+}
+
+// deletableRepo is the part of the repository ports DeleteMap and UnpublishMap rely on
+type deletableRepo interface {
+	Delete(ctx context.Context, m domain.Map) error
+	PlanDelete(ctx context.Context, m domain.Map) ([]string, error)
+}
+
+type namedDeletableRepo struct {
+	name string
+	repo deletableRepo
+}
+
+func (srv *service) deleteFromRepos(
+	ctx context.Context,
+	m domain.Map,
+	dryRun bool,
+	repos []namedDeletableRepo,
+) domain.DeleteResult {
+	result := domain.DeleteResult{ID: m.ID(), DryRun: dryRun}
+	for _, r := range repos {
+		outcome := domain.DeleteOutcome{Repository: r.name}
+		// Planning lists the tilesets and downloads the frontend json, so it is only done for a dry run
+		if dryRun {
+			targets, err := r.repo.PlanDelete(ctx, m)
+			if err != nil {
+				srv.logger.Warn(ctx, "Could not determine what would be deleted from the "+r.name+" repo", "error", err)
+				outcome.Error = err.Error()
+			}
+			outcome.Targets = targets
+			result.Outcomes = append(result.Outcomes, outcome)
+			continue
+		}
+		if err := r.repo.Delete(ctx, m); err != nil {
+			srv.logger.Error(ctx, "Map was not deleted from the "+r.name+" repo", "error", err)
+			outcome.Error = err.Error()
+		} else {
+			srv.logger.Debug(ctx, "Map was deleted from the "+r.name+" repo", "map", m)
+		}
+		result.Outcomes = append(result.Outcomes, outcome)
+	}
+	return result
 }
 
 func (srv *service) findNewMaps(
@@ -135,12 +274,16 @@ func (srv *service) findNewMaps(
 	if err := validate.Struct(selectedDates); err != nil {
 		return nil, errors.New("the selected dates were not valid")
 	}
+	if err := selectedDates.Validate(); err != nil {
+		return nil, errors.New("the selected dates were not valid, error: " + err.Error())
+	}
 	provider := srv.externalMapsRepo.GetProvider()
 	internalMaps, err := srv.rawInternalMapRepo.List(ctx, provider, cropper, mapType)
 	if err != nil {
 		return nil, errors.New("get internal maps from internalMapRepository has failed, error: " + err.Error())
 	}
 	srv.logger.Debug(ctx, "Successfully extracted internal maps", "internalMaps", internalMaps)
+	srv.observeMapsStage(ctx, internalMaps, domain.MapStageRaw)
 
 	sourceMaps, err := srv.externalMapsRepo.List(ctx, cropper, mapType)
 	if err != nil {
@@ -148,11 +291,17 @@ func (srv *service) findNewMaps(
 	}
 	srv.logger.Debug(ctx, "Successfully obtained maps from the source.")
 
+	// The source url of a map can change between scrapes, maps are therefore compared by their identity
+	internalMapIDs := make(map[domain.MapID]struct{}, len(internalMaps))
+	for _, internalMap := range internalMaps {
+		internalMapIDs[internalMap.ID()] = struct{}{}
+	}
+
+	now := time.Now().UTC()
 	var newMaps []domain.Map
 	for _, sourceMap := range sourceMaps {
-		if slices.Contains(selectedDates.Months, time.Month(sourceMap.Date.Month)) &&
-			slices.Contains(selectedDates.Years, sourceMap.Date.Year) &&
-			!slices.Contains(internalMaps, sourceMap) {
+		_, exists := internalMapIDs[sourceMap.ID()]
+		if selectedDates.Includes(sourceMap.Date, sourceMap.MapType, now) && !exists {
 			srv.logger.Debug(ctx, "Found a map we do not have in our internal repo", "sourceMap", sourceMap)
 			newMaps = append(newMaps, sourceMap)
 		}
@@ -163,10 +312,13 @@ func (srv *service) findNewMaps(
 func (srv *service) addNewMaps(ctx context.Context, newMaps []domain.Map) (int, error) {
 	var count int
 	for _, newMap := range newMaps {
+		srv.setMapStage(ctx, newMap.ID(), domain.MapStageDiscovered)
 		err := srv.rawInternalMapRepo.Create(ctx, newMap)
 		if err != nil {
 			srv.logger.Error(ctx, "Error when adding a new map", "error", err)
+			srv.failMapStage(ctx, newMap.ID(), domain.MapStageDownloadRequested, err)
 		} else {
+			srv.setMapStage(ctx, newMap.ID(), domain.MapStageDownloadRequested)
 			count += 1
 		}
 	}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// harness wires the service to fakes, the stages the python lambdas handle outside the orchestrator, downloading and
+// processing a map, are played by the test with Put
+type harness struct {
+	logger    *fakes.Logger
+	external  *fakes.ExternalMapProviderRepo
+	raw       *fakes.InternalMapRepo
+	processed *fakes.InternalMapRepo
+	// processedDir is where the processed maps are downloaded to
+	processedDir string
+	tileServer   *fakes.MapTileServerRepo
+	frontend     *fakes.FrontendMapDataRepo
+	statuses     *fakes.MapStatusRepo
+	mapStats     *fakes.MapStatsRepo
+	subRegions   *fakes.SubRegionRepo
+	regionStats  *fakes.RegionStatsRepo
+	anomalies    *fakes.AnomalyRepo
+	srv          ports.OrchestratorService
+}
+
+func newHarness(t *testing.T, anomalyConfig domain.AnomalyConfig, qualityGate domain.QualityGate) *harness {
+	processedDir := t.TempDir()
+	h := &harness{
+		logger:      fakes.NewLogger(),
+		external:    fakes.NewExternalMapProviderRepo(domain.MapProviderEogdata),
+		raw:         fakes.NewInternalMapRepo(t.TempDir()),
+		processed:   fakes.NewInternalMapRepo(processedDir),
+		tileServer:  fakes.NewMapTileServerRepo(),
+		frontend:    fakes.NewFrontendMapDataRepo(),
+		statuses:    fakes.NewMapStatusRepo(),
+		mapStats:    fakes.NewMapStatsRepo(),
+		subRegions:  fakes.NewSubRegionRepo(),
+		regionStats: fakes.NewRegionStatsRepo(),
+		anomalies:   fakes.NewAnomalyRepo(),
+	}
+	h.processedDir = processedDir
+	h.srv = NewOrchestratorService(
+		h.logger,
+		h.external,
+		h.raw,
+		h.processed,
+		h.frontend,
+		h.tileServer,
+		h.statuses,
+		h.mapStats,
+		h.subRegions,
+		h.regionStats,
+		h.anomalies,
+		anomalyConfig,
+		qualityGate,
+	)
+	return h
+}
+
+// process stores the processed tif of a raw map, every pixel has the value
+func (h *harness) process(t *testing.T, m domain.Map, value uint8) {
+	require.True(t, h.raw.Has(m.ID()), "only downloaded maps can be processed")
+	r, err := raster.New(4, 4, raster.DataTypeUint8)
+	require.NoError(t, err)
+	r.Transform = raster.GeoTransform{30, 1, 0, 50, 0, -1}
+	r.GeoKeys = raster.NewGeographicGeoKeys(raster.EPSGWGS84)
+	for i := range r.Uint8s() {
+		r.Uint8s()[i] = value
+	}
+	var buf bytes.Buffer
+	require.NoError(t, raster.Encode(&buf, r, nil))
+	h.processed.Put(m, buf.Bytes(), nil)
+}
+
+func (h *harness) stage(t *testing.T, m domain.Map) domain.MapStage {
+	status, err := h.statuses.Get(context.Background(), m.ID())
+	require.NoError(t, err)
+	if status == nil {
+		return domain.MapStageUnspecified
+	}
+	return status.Stage
+}
+
+func monthlyMap(year int, month time.Month) domain.Map {
+	return domain.Map{
+		Date:    domain.Date{Day: 1, Month: month, Year: year},
+		MapType: domain.MapTypeMonthly,
+		Bounds:  domain.BoundsUkraineAndAround,
+		Source: domain.MapSource{
+			MapProvider: domain.MapProviderEogdata,
+			URL:         "https://eogdata.example/" + time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Format("200601"),
+		},
+	}
+}
+
+func syncRequest(year int, months ...time.Month) domain.SyncMapRequest {
+	return domain.SyncMapRequest{
+		SelectedDates: domain.SelectedDates{Months: months, Years: []int{year}},
+		MapType:       domain.MapTypeMonthly,
+		Bounds:        domain.BoundsUkraineAndAround,
+	}
+}
+
+func TestOrchestrator_SyncProcessPublishDelete(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january, february, march := monthlyMap(2023, 1), monthlyMap(2023, 2), monthlyMap(2023, 3)
+	h.external.SetMaps(january, february, march)
+	h.raw.Put(january, []byte("raw"), nil)
+
+	count, err := h.srv.SyncInternalWithExternalMaps(ctx, syncRequest(2023, 1, 2, 3))
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, *count)
+	assert.Equal(t, []domain.Map{february, march}, h.raw.Requested())
+	assert.Equal(t, domain.MapStageRaw, h.stage(t, january))
+	assert.Equal(t, domain.MapStageDownloadRequested, h.stage(t, february))
+	assert.Equal(t, domain.MapStageDownloadRequested, h.stage(t, march))
+
+	coverage := 0.9
+	h.raw.Put(february, []byte("raw"), &coverage)
+	h.process(t, february, 120)
+	require.NoError(t, h.srv.PublishMap(ctx, february))
+
+	assert.True(t, h.tileServer.Has(february.ID()))
+	published := h.frontend.Get(february.ID())
+	require.NotNil(t, published)
+	assert.Equal(t, "tiles://"+february.ID().String(), published.Url)
+	assert.Equal(t, &coverage, published.CloudFreeCoverage)
+	assert.False(t, published.LowConfidence)
+	assert.Equal(t, domain.MapStagePublished, h.stage(t, february))
+	stats, err := h.mapStats.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, february.ID(), stats[0].ID)
+	assert.Equal(t, 16, stats[0].ValidPixelCount)
+
+	result, err := h.srv.DeleteMap(ctx, february, false)
+
+	require.NoError(t, err)
+	assert.Len(t, result.Outcomes, 4)
+	assert.False(t, h.tileServer.Has(february.ID()))
+	assert.Nil(t, h.frontend.Get(february.ID()))
+	assert.False(t, h.processed.Has(february.ID()))
+	assert.False(t, h.raw.Has(february.ID()))
+	assert.Equal(t, domain.MapStageUnspecified, h.stage(t, february))
+	stats, err = h.mapStats.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+	assert.True(t, h.raw.Has(january.ID()), "the other maps are left alone")
+}
+
+func TestOrchestrator_SyncContinuesWhenARequestFails(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january, february := monthlyMap(2023, 1), monthlyMap(2023, 2)
+	h.external.SetMaps(january, february)
+	h.raw.FailOnMap("Create", january.ID(), errors.New("the queue is unavailable"))
+
+	count, err := h.srv.SyncInternalWithExternalMaps(ctx, syncRequest(2023, 1, 2))
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, *count)
+	assert.Equal(t, []domain.Map{february}, h.raw.Requested())
+	status, err := h.statuses.Get(ctx, january.ID())
+	require.NoError(t, err)
+	assert.Equal(t, domain.MapStageFailed, status.Stage)
+	assert.Equal(t, domain.MapStageDownloadRequested, status.FailedStage)
+	assert.Equal(t, "the queue is unavailable", status.LastError)
+}
+
+func TestOrchestrator_SyncFailsWhenTheProviderFails(t *testing.T) {
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	h.external.FailOn("List", errors.New("eogdata is down"))
+
+	_, err := h.srv.SyncInternalWithExternalMaps(context.Background(), syncRequest(2023, 1))
+
+	assert.Error(t, err)
+	assert.Empty(t, h.raw.Requested())
+}
+
+func TestOrchestrator_PublishRecoversAfterAFailure(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	h.tileServer.FailOn("Publish", errors.New("mapbox is down"))
+
+	require.Error(t, h.srv.PublishMap(ctx, january))
+	assert.Nil(t, h.frontend.Get(january.ID()))
+	status, err := h.statuses.Get(ctx, january.ID())
+	require.NoError(t, err)
+	assert.Equal(t, domain.MapStageFailed, status.Stage)
+	assert.Equal(t, domain.MapStagePublished, status.FailedStage)
+
+	h.tileServer.ClearFailures()
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	assert.NotNil(t, h.frontend.Get(january.ID()))
+	assert.Equal(t, domain.MapStagePublished, h.stage(t, january))
+}
+
+func TestOrchestrator_PublishQualityGate(t *testing.T) {
+	ctx := context.Background()
+	cloudy := 0.2
+	for _, action := range []string{"flag", "refuse"} {
+		t.Run(action, func(t *testing.T) {
+			qualityGate, err := domain.ParseQualityGate("0.5", action)
+			require.NoError(t, err)
+			h := newHarness(t, domain.DefaultAnomalyConfig(), qualityGate)
+			january := monthlyMap(2023, 1)
+			h.raw.Put(january, []byte("raw"), &cloudy)
+			h.process(t, january, 100)
+
+			err = h.srv.PublishMap(ctx, january)
+
+			if action == "refuse" {
+				assert.ErrorIs(t, err, domain.ErrInsufficientCoverage)
+				assert.False(t, h.tileServer.Has(january.ID()))
+				assert.Nil(t, h.frontend.Get(january.ID()))
+				return
+			}
+			require.NoError(t, err)
+			published := h.frontend.Get(january.ID())
+			require.NotNil(t, published)
+			assert.True(t, published.LowConfidence)
+		})
+	}
+}
+
+func TestOrchestrator_PublishFlagsAnOutage(t *testing.T) {
+	ctx := context.Background()
+	config := domain.DefaultAnomalyConfig()
+	config.AttachToFrontend = true
+	h := newHarness(t, config, domain.QualityGate{})
+	january, february, march := monthlyMap(2023, 1), monthlyMap(2023, 2), monthlyMap(2023, 3)
+	for m, value := range map[domain.Map]uint8{january: 200, february: 200, march: 60} {
+		h.raw.Put(m, []byte("raw"), nil)
+		h.process(t, m, value)
+	}
+
+	for _, m := range []domain.Map{january, february, march} {
+		require.NoError(t, h.srv.PublishMap(ctx, m))
+	}
+
+	anomalies, err := h.srv.ListAnomalies(ctx)
+	require.NoError(t, err)
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, march.ID(), anomalies[0].ID)
+	assert.Less(t, anomalies[0].Change, -config.DropThreshold)
+	assert.Len(t, h.frontend.Anomalies(march.ID()), 1)
+	assert.Empty(t, h.frontend.Anomalies(february.ID()))
+}
+
+func TestOrchestrator_UpdateMapStatsFlagsAnOutageWithoutPublishing(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january, february, march := monthlyMap(2023, 1), monthlyMap(2023, 2), monthlyMap(2023, 3)
+	for m, value := range map[domain.Map]uint8{january: 200, february: 200, march: 60} {
+		h.raw.Put(m, []byte("raw"), nil)
+		h.process(t, m, value)
+	}
+
+	for _, m := range []domain.Map{january, february, march} {
+		require.NoError(t, h.srv.UpdateMapStats(ctx, m))
+	}
+
+	anomalies, err := h.srv.ListAnomalies(ctx)
+	require.NoError(t, err)
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, march.ID(), anomalies[0].ID)
+	assert.Nil(t, h.frontend.Get(march.ID()))
+	assert.Equal(t, domain.MapStageProcessed, h.stage(t, march))
+}
+
+func TestOrchestrator_MapStatsRemoveTheDownloadedMaps(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january, february := monthlyMap(2023, 1), monthlyMap(2023, 2)
+	for _, m := range []domain.Map{january, february} {
+		h.raw.Put(m, []byte("raw"), nil)
+		h.process(t, m, 200)
+	}
+
+	require.NoError(t, h.srv.UpdateMapStats(ctx, january))
+	_, err := h.srv.RebuildMapStats(ctx)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(h.processedDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestOrchestrator_UpdateMapStatsUsesTheCloudFreeCoverage(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january, february, march := monthlyMap(2023, 1), monthlyMap(2023, 2), monthlyMap(2023, 3)
+	clear, cloudy := 0.9, 0.2
+	for m, coverage := range map[domain.Map]*float64{january: &clear, february: &clear, march: &cloudy} {
+		h.raw.Put(m, []byte("raw"), coverage)
+	}
+	h.process(t, january, 200)
+	h.process(t, february, 200)
+	h.process(t, march, 60)
+
+	for _, m := range []domain.Map{january, february, march} {
+		require.NoError(t, h.srv.UpdateMapStats(ctx, m))
+	}
+
+	stats, err := h.mapStats.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	assert.Equal(t, cloudy, stats[2].Coverage)
+	anomalies, err := h.srv.ListAnomalies(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, anomalies, "a cloudy month is not an outage")
+}
+
+func TestOrchestrator_UpdateMapStatsSkipsAnUnpublishedMap(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	_, err := h.srv.UnpublishMap(ctx, january, false)
+	require.NoError(t, err)
+
+	require.NoError(t, h.srv.UpdateMapStats(ctx, january))
+
+	stats, err := h.mapStats.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+}
+
+func TestOrchestrator_DeleteContinuesWhenARepoFails(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	h.tileServer.FailOn("Delete", errors.New("mapbox is down"))
+
+	result, err := h.srv.DeleteMap(ctx, january, false)
+
+	require.Error(t, err)
+	assert.Equal(t, "mapbox is down", result.Outcomes[0].Error)
+	assert.True(t, h.tileServer.Has(january.ID()))
+	assert.Nil(t, h.frontend.Get(january.ID()))
+	assert.False(t, h.processed.Has(january.ID()))
+	assert.False(t, h.raw.Has(january.ID()))
+	assert.Equal(t, domain.MapStageFailed, h.stage(t, january), "the map is marked so it can be cleaned up")
+	stats, err := h.mapStats.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, stats, 1, "the stats are kept until the map is deleted everywhere")
+}
+
+func TestOrchestrator_DeleteDryRun(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+
+	result, err := h.srv.DeleteMap(ctx, january, true)
+
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"tiles://" + january.ID().String()}, result.Outcomes[0].Targets)
+	assert.True(t, h.tileServer.Has(january.ID()))
+	assert.NotNil(t, h.frontend.Get(january.ID()))
+	assert.True(t, h.processed.Has(january.ID()))
+	assert.True(t, h.raw.Has(january.ID()))
+	assert.Equal(t, domain.MapStagePublished, h.stage(t, january))
+}
+
+func TestOrchestrator_DeleteDoesNotPlan(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	h.tileServer.FailOn("PlanDelete", errors.New("listing the tilesets is slow"))
+
+	result, err := h.srv.DeleteMap(ctx, january, false)
+
+	require.NoError(t, err)
+	for _, outcome := range result.Outcomes {
+		assert.Empty(t, outcome.Targets, outcome.Repository)
+	}
+	assert.False(t, h.tileServer.Has(january.ID()))
+}
+
+func TestOrchestrator_ReconcileKeepsAnUnpublishedMapHiddenAfterAFailure(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	h.tileServer.FailOn("Delete", errors.New("mapbox is down"))
+
+	_, err := h.srv.UnpublishMap(ctx, january, true)
+	require.Error(t, err)
+	assert.Equal(t, domain.MapStageFailed, h.stage(t, january))
+
+	report, err := h.srv.Reconcile(ctx, true)
+
+	require.NoError(t, err)
+	assert.Empty(t, report.ProcessedNotPublished)
+	assert.Len(t, report.Unpublished, 1)
+	assert.Nil(t, h.frontend.Get(january.ID()), "reconcile does not publish the unpublished map again")
+}
+
+func TestOrchestrator_UnpublishFailsWhenTheStatusCannotBeRecorded(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	h.statuses.FailOn("Upsert", errors.New("s3 is down"))
+
+	_, err := h.srv.UnpublishMap(ctx, january, false)
+
+	require.Error(t, err)
+	assert.NotNil(t, h.frontend.Get(january.ID()), "the map stays published so reconcile cannot republish it")
+}
+
+func TestOrchestrator_PublishAfterUnpublish(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, domain.DefaultAnomalyConfig(), domain.QualityGate{})
+	january := monthlyMap(2023, 1)
+	h.raw.Put(january, []byte("raw"), nil)
+	h.process(t, january, 100)
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	_, err := h.srv.UnpublishMap(ctx, january, false)
+	require.NoError(t, err)
+	assert.Nil(t, h.frontend.Get(january.ID()))
+
+	require.NoError(t, h.srv.PublishMap(ctx, january))
+	report, err := h.srv.Reconcile(ctx, false)
+
+	require.NoError(t, err)
+	assert.Empty(t, report.Unpublished)
+	assert.NotNil(t, h.frontend.Get(january.ID()))
+}
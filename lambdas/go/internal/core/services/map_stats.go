@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+)
+
+// RebuildMapStats recomputes the whole series, the maps that were unpublished on purpose are left out just like
+// they are left out of the frontend. The statistics per sub-region are rebuilt along with it
+func (srv *service) RebuildMapStats(ctx context.Context) ([]domain.MapStats, error) {
+	processedMaps, err := srv.ListProcessedInternalMaps(ctx)
+	if err != nil {
+		return nil, errors.New("listing the processed internal maps has failed, error: " + err.Error())
+	}
+	unpublishedIDs, err := srv.unpublishedMapIDs(ctx)
+	if err != nil {
+		return nil, errors.New("listing the map statuses has failed, error: " + err.Error())
+	}
+
+	var series []domain.MapStats
+	var regionSeries []domain.RegionStats
+	var errs []error
+	subRegions := make(map[domain.Bounds][]domain.SubRegion)
+	for _, m := range processedMaps {
+		if _, ok := unpublishedIDs[m.ID()]; ok {
+			continue
+		}
+		regions, ok := subRegions[m.Bounds]
+		if !ok {
+			if regions, err = srv.subRegionRepo.List(ctx, m.Bounds); err != nil {
+				return nil, errors.New("listing the sub-regions has failed, error: " + err.Error())
+			}
+			subRegions[m.Bounds] = regions
+		}
+		localMap, err := srv.processedInternalMapRepo.Download(ctx, m)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.ID().String(), err))
+			continue
+		}
+		r, err := readProcessedMap(*localMap)
+		// Every map of the series is downloaded, the tif is not kept once it is decoded
+		_ = os.Remove(localMap.Filepath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.ID().String(), err))
+			continue
+		}
+		at := time.Now().UTC()
+		cloudFreeCoverage := srv.cloudFreeCoverage(ctx, m)
+		series = append(series,
+			domain.ComputeMapStats(m.ID(), r.Uint8s(), r.NoData, at).WithCloudFreeCoverage(cloudFreeCoverage))
+		stats, err := computeRegionStats(m.ID(), r, regions, cloudFreeCoverage, at)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.ID().String(), err))
+			continue
+		}
+		regionSeries = append(regionSeries, stats...)
+	}
+	if err := srv.mapStatsRepo.Replace(ctx, series); err != nil {
+		return nil, errors.New("storing the map stats has failed, error: " + err.Error())
+	}
+	if err := srv.regionStatsRepo.Replace(ctx, regionSeries); err != nil {
+		return nil, errors.New("storing the region stats has failed, error: " + err.Error())
+	}
+	srv.logger.Info(ctx, "Rebuilt the map stats",
+		"maps", len(series), "regionStats", len(regionSeries), "failed", len(errs))
+	return series, errors.Join(errs...)
+}
+
+// RegisterSubRegions replaces the sub-regions of the bounds, the statistics of the maps that are already published
+// are only computed for them by RebuildMapStats
+func (srv *service) RegisterSubRegions(ctx context.Context, bounds domain.Bounds, regions []domain.SubRegion) error {
+	if err := domain.ValidateSubRegions(bounds, regions); err != nil {
+		return err
+	}
+	if err := srv.subRegionRepo.Replace(ctx, bounds, regions); err != nil {
+		return errors.New("storing the sub-regions has failed, error: " + err.Error())
+	}
+	srv.logger.Info(ctx, "Registered the sub-regions", "bounds", bounds.String(), "count", len(regions))
+	return nil
+}
+
+func (srv *service) ListSubRegions(ctx context.Context, bounds domain.Bounds) ([]domain.SubRegion, error) {
+	return srv.subRegionRepo.List(ctx, bounds)
+}
+
+// UpdateMapStats adds a processed map that is not published to the series and checks it for anomalies, the maps
+// that were unpublished on purpose are left out like RebuildMapStats leaves them out
+func (srv *service) UpdateMapStats(ctx context.Context, m domain.Map) error {
+	status, err := srv.mapStatusRepo.Get(ctx, m.ID())
+	if err != nil {
+		return errors.New("getting the map status has failed, error: " + err.Error())
+	}
+	if status != nil && status.Unpublished() {
+		srv.logger.Info(ctx, "Leaving the unpublished map out of the map stats", "map", m.ID().String())
+		return nil
+	}
+	localMap, err := srv.processedInternalMapRepo.Download(ctx, m)
+	if err != nil {
+		return errors.New("downloading the processed map has failed, error: " + err.Error())
+	}
+	if localMap == nil {
+		return errors.New("a map was somehow nil after it was downloaded")
+	}
+	defer os.Remove(localMap.Filepath)
+	srv.observeMapStage(ctx, m.ID(), domain.MapStageProcessed)
+	srv.updateMapStats(ctx, *localMap, srv.cloudFreeCoverage(ctx, m))
+	return nil
+}
+
+// updateMapStats adds the map to the series and checks it for anomalies, like the status the statistics are
+// secondary to the map itself so failures are only logged
+func (srv *service) updateMapStats(ctx context.Context, localMap domain.LocalMap, cloudFreeCoverage *float64) {
+	id := localMap.Map.ID()
+	r, err := readProcessedMap(localMap)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not compute the map stats", "map", id.String(), "error", err)
+		return
+	}
+	at := time.Now().UTC()
+	stats := domain.ComputeMapStats(id, r.Uint8s(), r.NoData, at).WithCloudFreeCoverage(cloudFreeCoverage)
+	if err := srv.mapStatsRepo.Upsert(ctx, stats); err != nil {
+		srv.logger.Warn(ctx, "Could not update the map stats", "map", id.String(), "error", err)
+	}
+	regionStats := srv.updateRegionStats(ctx, localMap.Map, r, cloudFreeCoverage, at)
+	srv.updateAnomalies(ctx, stats, regionStats)
+}
+
+// updateRegionStats returns the statistics of the sub-regions of the map, nil when they could not be computed
+func (srv *service) updateRegionStats(
+	ctx context.Context,
+	m domain.Map,
+	r *raster.Raster,
+	cloudFreeCoverage *float64,
+	at time.Time,
+) []domain.RegionStats {
+	regions, err := srv.subRegionRepo.List(ctx, m.Bounds)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not list the sub-regions", "map", m.ID().String(), "error", err)
+		return nil
+	}
+	stats, err := computeRegionStats(m.ID(), r, regions, cloudFreeCoverage, at)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not compute the region stats", "map", m.ID().String(), "error", err)
+		return nil
+	}
+	if err := srv.regionStatsRepo.UpsertMap(ctx, m.ID(), stats); err != nil {
+		srv.logger.Warn(ctx, "Could not update the region stats", "map", m.ID().String(), "error", err)
+	}
+	return stats
+}
+
+func (srv *service) deleteMapStats(ctx context.Context, id domain.MapID) {
+	if err := srv.mapStatsRepo.Delete(ctx, id); err != nil {
+		srv.logger.Warn(ctx, "Could not delete the map stats", "map", id.String(), "error", err)
+	}
+	if err := srv.regionStatsRepo.DeleteMap(ctx, id); err != nil {
+		srv.logger.Warn(ctx, "Could not delete the region stats", "map", id.String(), "error", err)
+	}
+	if err := srv.anomalyRepo.DeleteMap(ctx, id); err != nil {
+		srv.logger.Warn(ctx, "Could not delete the anomalies", "map", id.String(), "error", err)
+	}
+}
+
+func readProcessedMap(localMap domain.LocalMap) (*raster.Raster, error) {
+	r, err := raster.ReadFile(localMap.Filepath)
+	if err != nil {
+		return nil, errors.New("reading the processed map has failed, error: " + err.Error())
+	}
+	if r.DataType != raster.DataTypeUint8 {
+		return nil, fmt.Errorf("processed maps are expected to be uint8, got %s", r.DataType)
+	}
+	return r, nil
+}
+
+// computeRegionStats aggregates the pixels whose center lies within each sub-region, the cloud-free coverage is only
+// known for the whole bounds so every sub-region gets the coverage of the bounds
+func computeRegionStats(
+	id domain.MapID,
+	r *raster.Raster,
+	regions []domain.SubRegion,
+	cloudFreeCoverage *float64,
+	at time.Time,
+) ([]domain.RegionStats, error) {
+	pixels := r.Uint8s()
+	stats := make([]domain.RegionStats, 0, len(regions))
+	for _, region := range regions {
+		indices, err := r.PixelsInPolygons(region.MultiPolygon)
+		if err != nil {
+			return nil, fmt.Errorf("masking the sub-region %s has failed: %w", region.ID, err)
+		}
+		regionPixels := make([]uint8, len(indices))
+		for i, index := range indices {
+			regionPixels[i] = pixels[index]
+		}
+		stats = append(stats, domain.RegionStats{
+			RegionID:   region.ID,
+			RegionName: region.Name,
+			Stats:      domain.ComputeMapStats(id, regionPixels, r.NoData, at).WithCloudFreeCoverage(cloudFreeCoverage),
+		})
+	}
+	return stats, nil
+}
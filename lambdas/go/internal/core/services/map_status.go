@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+)
+
+// The status of a map is bookkeeping, failing to record it is only logged so it can never break the pipeline itself
+
+func (srv *service) setMapStage(ctx context.Context, id domain.MapID, stage domain.MapStage) {
+	srv.updateMapStatus(ctx, id, func(status *domain.MapStatus) bool {
+		status.Transition(stage, time.Now().UTC())
+		return true
+	})
+}
+
+// recordMapStage is setMapStage for the stages the pipeline relies on later, the error is returned instead of logged
+func (srv *service) recordMapStage(ctx context.Context, id domain.MapID, stage domain.MapStage) error {
+	status, err := srv.mapStatusRepo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		newStatus := domain.NewMapStatus(id)
+		status = &newStatus
+	}
+	status.Transition(stage, time.Now().UTC())
+	return srv.mapStatusRepo.Upsert(ctx, *status)
+}
+
+func (srv *service) failMapStage(ctx context.Context, id domain.MapID, stage domain.MapStage, cause error) {
+	srv.updateMapStatus(ctx, id, func(status *domain.MapStatus) bool {
+		status.Fail(stage, cause, time.Now().UTC())
+		return true
+	})
+}
+
+// observeMapStage records the stage when the map has not reached it yet, the raw and processed stages happen
+// outside the orchestrator so they are only noticed when the map shows up in the internal repos
+func (srv *service) observeMapStage(ctx context.Context, id domain.MapID, stage domain.MapStage) {
+	srv.updateMapStatus(ctx, id, func(status *domain.MapStatus) bool {
+		if status.Reached(stage) {
+			return false
+		}
+		status.Transition(stage, time.Now().UTC())
+		return true
+	})
+}
+
+// observeMapsStage is observeMapStage for many maps, it lists the statuses once instead of getting them one by one
+func (srv *service) observeMapsStage(ctx context.Context, maps []domain.Map, stage domain.MapStage) {
+	if len(maps) == 0 {
+		return
+	}
+	statuses, err := srv.mapStatusRepo.List(ctx)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not list the map statuses", "error", err)
+		return
+	}
+	byID := make(map[domain.MapID]domain.MapStatus, len(statuses))
+	for _, status := range statuses {
+		byID[status.ID] = status
+	}
+	now := time.Now().UTC()
+	for _, m := range maps {
+		status, ok := byID[m.ID()]
+		if !ok {
+			status = domain.NewMapStatus(m.ID())
+		}
+		if status.Reached(stage) {
+			continue
+		}
+		status.Transition(stage, now)
+		if err := srv.mapStatusRepo.Upsert(ctx, status); err != nil {
+			srv.logger.Warn(ctx, "Could not update the map status", "map", m.ID().String(), "error", err)
+		}
+	}
+}
+
+// unpublishedMapIDs are the maps that were hidden on purpose, whatever stage they are in now
+func (srv *service) unpublishedMapIDs(ctx context.Context) (map[domain.MapID]struct{}, error) {
+	statuses, err := srv.mapStatusRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[domain.MapID]struct{})
+	for _, status := range statuses {
+		if status.Unpublished() {
+			ids[status.ID] = struct{}{}
+		}
+	}
+	return ids, nil
+}
+
+// deleteMapStatus forgets the map once it was deleted everywhere, when some of the repos failed the map is marked
+// as failed instead so it shows up when looking for broken maps
+func (srv *service) deleteMapStatus(ctx context.Context, id domain.MapID, cause error) {
+	if cause != nil {
+		srv.failMapStage(ctx, id, domain.MapStageUnspecified, cause)
+		return
+	}
+	if err := srv.mapStatusRepo.Delete(ctx, id); err != nil {
+		srv.logger.Warn(ctx, "Could not delete the map status", "map", id.String(), "error", err)
+	}
+}
+
+func (srv *service) updateMapStatus(
+	ctx context.Context,
+	id domain.MapID,
+	update func(status *domain.MapStatus) bool,
+) {
+	status, err := srv.mapStatusRepo.Get(ctx, id)
+	if err != nil {
+		srv.logger.Warn(ctx, "Could not get the map status", "map", id.String(), "error", err)
+		return
+	}
+	if status == nil {
+		newStatus := domain.NewMapStatus(id)
+		status = &newStatus
+	}
+	if !update(status) {
+		return
+	}
+	if err := srv.mapStatusRepo.Upsert(ctx, *status); err != nil {
+		srv.logger.Warn(ctx, "Could not update the map status", "map", id.String(), "error", err)
+	}
+}
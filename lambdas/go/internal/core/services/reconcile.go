@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+)
+
+// Reconcile compares the raw and processed internal repos, the frontend and the tile server. When apply is true the
+// processed maps that are not published, and were not unpublished on purpose, get published and dangling frontend
+// entries are repaired, the other drift is only reported since fixing it requires reprocessing or deleting maps
+func (srv *service) Reconcile(ctx context.Context, apply bool) (*domain.DriftReport, error) {
+	rawMaps, err := srv.ListRawInternalMaps(ctx)
+	if err != nil {
+		return nil, errors.New("listing the raw internal maps has failed, error: " + err.Error())
+	}
+	processedMaps, err := srv.ListProcessedInternalMaps(ctx)
+	if err != nil {
+		return nil, errors.New("listing the processed internal maps has failed, error: " + err.Error())
+	}
+	frontendMaps, err := srv.frontendMapDataRepo.List(ctx)
+	if err != nil {
+		return nil, errors.New("listing the frontend maps has failed, error: " + err.Error())
+	}
+	tilesets, err := srv.mapTileServerRepo.List(ctx)
+	if err != nil {
+		return nil, errors.New("listing the tile server maps has failed, error: " + err.Error())
+	}
+	// Without the statuses the unpublished maps cannot be told apart from drift, apply would publish them again
+	unpublishedIDs, err := srv.unpublishedMapIDs(ctx)
+	if err != nil {
+		return nil, errors.New("listing the map statuses has failed, error: " + err.Error())
+	}
+
+	processedByID := make(map[domain.MapID]domain.Map, len(processedMaps))
+	for _, m := range processedMaps {
+		processedByID[m.ID()] = m
+	}
+	frontendIDs := publishedMapIDs(frontendMaps)
+	tilesetIDs := publishedMapIDs(tilesets)
+
+	var report domain.DriftReport
+	for _, m := range rawMaps {
+		if _, ok := processedByID[m.ID()]; !ok {
+			report.RawWithoutProcessed = append(report.RawWithoutProcessed, m)
+		}
+	}
+	for _, m := range processedMaps {
+		if _, ok := frontendIDs[m.ID()]; ok {
+			continue
+		}
+		if _, ok := unpublishedIDs[m.ID()]; ok {
+			report.Unpublished = append(report.Unpublished, m)
+		} else {
+			report.ProcessedNotPublished = append(report.ProcessedNotPublished, m)
+		}
+	}
+	for _, m := range frontendMaps {
+		if _, ok := tilesetIDs[m.Map.ID()]; !ok {
+			report.DanglingFrontendEntries = append(report.DanglingFrontendEntries, m)
+		}
+		if _, ok := processedByID[m.Map.ID()]; !ok {
+			report.PublishedWithoutProcessed = append(report.PublishedWithoutProcessed, m)
+		}
+	}
+	for _, m := range tilesets {
+		if _, ok := frontendIDs[m.Map.ID()]; !ok {
+			report.UnreferencedTilesets = append(report.UnreferencedTilesets, m)
+		}
+	}
+	srv.logger.Info(
+		ctx,
+		"Reconciled the repositories",
+		"processedNotPublished", len(report.ProcessedNotPublished),
+		"danglingFrontendEntries", len(report.DanglingFrontendEntries),
+		"rawWithoutProcessed", len(report.RawWithoutProcessed),
+		"unreferencedTilesets", len(report.UnreferencedTilesets),
+		"publishedWithoutProcessed", len(report.PublishedWithoutProcessed),
+		"unpublished", len(report.Unpublished),
+	)
+	if !apply {
+		return &report, nil
+	}
+	return &report, srv.applyDriftFixes(ctx, &report, processedByID)
+}
+
+// applyDriftFixes publishes the processed maps that are missing from the frontend, a dangling frontend entry is
+// republished when its processed map still exists and removed otherwise
+func (srv *service) applyDriftFixes(
+	ctx context.Context,
+	report *domain.DriftReport,
+	processedByID map[domain.MapID]domain.Map,
+) error {
+	report.Applied = true
+	report.FixErrors = make(map[domain.MapID]string)
+	fix := func(id domain.MapID, err error) {
+		if err != nil {
+			srv.logger.Error(ctx, "Could not fix the drift of a map", "map", id.String(), "error", err)
+			report.FixErrors[id] = err.Error()
+			return
+		}
+		report.Fixed = append(report.Fixed, id)
+	}
+
+	for _, m := range report.ProcessedNotPublished {
+		fix(m.ID(), srv.PublishMap(ctx, m))
+	}
+	for _, m := range report.DanglingFrontendEntries {
+		if processed, ok := processedByID[m.Map.ID()]; ok {
+			fix(m.Map.ID(), srv.PublishMap(ctx, processed))
+		} else {
+			fix(m.Map.ID(), srv.frontendMapDataRepo.Delete(ctx, m.Map))
+		}
+	}
+	if len(report.FixErrors) > 0 {
+		return fmt.Errorf("the drift of %d maps could not be fixed", len(report.FixErrors))
+	}
+	return nil
+}
+
+func publishedMapIDs(publishedMaps []domain.PublishedMap) map[domain.MapID]struct{} {
+	ids := make(map[domain.MapID]struct{}, len(publishedMaps))
+	for _, m := range publishedMaps {
+		ids[m.Map.ID()] = struct{}{}
+	}
+	return ids
+}
@@ -3,15 +3,21 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	conflict_nightlightv1 "github.com/BaronBonet/conflict-nightlight/generated/conflict_nightlight/v1"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
 	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/geojson"
 	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/prototransformers"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -21,7 +27,20 @@ type CliHandler struct {
 	app *cli.App
 }
 
-func NewCLIHandler(ctx context.Context, productService ports.OrchestratorService) *CliHandler {
+// ManifestRebuilder is implemented by the internal map repos that index their maps in a manifest
+type ManifestRebuilder interface {
+	// RebuildManifest indexes every map of the repo again and returns how many maps were indexed
+	RebuildManifest(ctx context.Context) (int, error)
+}
+
+// NewCLIHandler creates the cli, tileHandler is what the serve command serves. rawManifest and processedManifest
+// are nil when the repos have no manifest
+func NewCLIHandler(
+	ctx context.Context,
+	productService ports.OrchestratorService,
+	tileHandler http.Handler,
+	rawManifest, processedManifest ManifestRebuilder,
+) *CliHandler {
 	app := &cli.App{
 		Name:                 "Map Controller",
 		EnableBashCompletion: true,
@@ -76,6 +95,35 @@ func NewCLIHandler(ctx context.Context, productService ports.OrchestratorService
 					return printPublishedMapsToTerminal(maps)
 				},
 			},
+			{
+				Name:  "listMapStatuses",
+				Usage: "List where each map is in the pipeline",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "stage",
+						Usage: "only list the maps in this stage, e.g. failed or download-requested",
+					},
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					stage := domain.MapStageUnspecified
+					if c.String("stage") != "" {
+						if err := stage.UnmarshalText([]byte(c.String("stage"))); err != nil {
+							return err
+						}
+					}
+					statuses, err := productService.ListMapStatuses(ctx, stage)
+					if err != nil {
+						return err
+					}
+					if c.Bool("json") {
+						return printSliceAsJson(statuses)
+					}
+					return printMapStatusesAsTable(statuses)
+				},
+			},
 			{
 				Name:      "publishMap",
 				Usage:     "publish a map, pass in a map that is copied from the output of listProcessedMaps --json, i.e. you need to use the json flag and copy the whole object you want to publish.",
@@ -141,12 +189,252 @@ func NewCLIHandler(ctx context.Context, productService ports.OrchestratorService
 				Name:      "deleteMap",
 				Usage:     "Delete a map from our entire system, including the tile server",
 				ArgsUsage: "[map]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only show the s3 keys, tileset ids and frontend entries that would be removed",
+					},
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
 				Action: func(c *cli.Context) error {
 					m, err := getMapFromArgs(c)
 					if err != nil {
 						return err
 					}
-					productService.DeleteMap(ctx, m)
+					result, err := productService.DeleteMap(ctx, m, c.Bool("dry-run"))
+					if result == nil {
+						return err
+					}
+					if c.Bool("json") {
+						if printErr := printAsJson(result); printErr != nil {
+							return printErr
+						}
+					} else {
+						verb := "Deleted"
+						if result.DryRun {
+							verb = "Would delete"
+						}
+						printDeleteResult(verb, *result)
+					}
+					return err
+				},
+			},
+			{
+				Name:      "unpublishMap",
+				Usage:     "Hide a map from the frontend but keep its tifs, publishMap shows it again",
+				ArgsUsage: "[map]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "remove-tileset",
+						Usage: "also delete the tileset from the tile server",
+					},
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					m, err := getMapFromArgs(c)
+					if err != nil {
+						return err
+					}
+					result, err := productService.UnpublishMap(ctx, m, c.Bool("remove-tileset"))
+					if result == nil {
+						return err
+					}
+					if c.Bool("json") {
+						if printErr := printAsJson(result); printErr != nil {
+							return printErr
+						}
+					} else {
+						printDeleteResult("Unpublished", *result)
+					}
+					return err
+				},
+			},
+			{
+				Name:  "rebuildMapStats",
+				Usage: "Recompute the radiance statistics of every processed map that the frontend charts",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					series, err := productService.RebuildMapStats(ctx)
+					if series == nil && err != nil {
+						return err
+					}
+					if c.Bool("json") {
+						if printErr := printSliceAsJson(series); printErr != nil {
+							return printErr
+						}
+					} else if printErr := printMapStatsAsTable(series); printErr != nil {
+						return printErr
+					}
+					return err
+				},
+			},
+			{
+				Name:    "rebuildManifest",
+				Aliases: []string{"rebuild-manifest"},
+				Usage:   "Index the tifs of the raw and processed buckets again, e.g. when a manifest is missing",
+				Action: func(c *cli.Context) error {
+					if rawManifest == nil || processedManifest == nil {
+						return errors.New("only the maps in the s3 buckets are indexed in a manifest")
+					}
+					rawMaps, err := rawManifest.RebuildManifest(ctx)
+					if err != nil {
+						return fmt.Errorf("rebuilding the manifest of the raw maps has failed: %w", err)
+					}
+					processedMaps, err := processedManifest.RebuildManifest(ctx)
+					if err != nil {
+						return fmt.Errorf("rebuilding the manifest of the processed maps has failed: %w", err)
+					}
+					fmt.Printf("Indexed %d raw maps and %d processed maps\n", rawMaps, processedMaps)
+					return nil
+				},
+			},
+			{
+				Name:  "anomalies",
+				Usage: "List the months that were flagged as suspected outages, per bounds and per sub-region",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "detect",
+						Usage: "analyse the whole series again before listing, e.g. after rebuildMapStats",
+					},
+					&cli.StringFlag{
+						Name:  "bounds",
+						Usage: "only list the anomalies of the bounds, e.g. UkraineAndAround",
+					},
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					var anomalies []domain.Anomaly
+					var err error
+					if c.Bool("detect") {
+						anomalies, err = productService.DetectAnomalies(ctx)
+					} else {
+						anomalies, err = productService.ListAnomalies(ctx)
+					}
+					if err != nil {
+						return err
+					}
+					if c.String("bounds") != "" {
+						bounds := domain.StringToBounds(c.String("bounds"))
+						if bounds == domain.BoundsUnspecified {
+							return fmt.Errorf("unknown bounds %q", c.String("bounds"))
+						}
+						filtered := anomalies[:0]
+						for _, anomaly := range anomalies {
+							if anomaly.ID.Bounds == bounds {
+								filtered = append(filtered, anomaly)
+							}
+						}
+						anomalies = filtered
+					}
+					if c.Bool("json") {
+						return printSliceAsJson(anomalies)
+					}
+					return printAnomaliesAsTable(anomalies)
+				},
+			},
+			{
+				Name:      "registerSubRegions",
+				Usage:     "Replace the sub-regions of the bounds with the polygons of a GeoJSON feature collection",
+				ArgsUsage: "[bounds] [file.geojson]",
+				Action: func(c *cli.Context) error {
+					bounds, err := getBoundsFromArgs(c)
+					if err != nil {
+						return err
+					}
+					data, err := os.ReadFile(c.Args().Get(1))
+					if err != nil {
+						return err
+					}
+					regions, err := geojson.ParseSubRegions(bounds, data)
+					if err != nil {
+						return err
+					}
+					if err := productService.RegisterSubRegions(ctx, bounds, regions); err != nil {
+						return err
+					}
+					fmt.Printf("Registered %d sub-regions for %s, run rebuildMapStats to compute their statistics\n",
+						len(regions), bounds.String())
+					return nil
+				},
+			},
+			{
+				Name:      "listSubRegions",
+				Usage:     "List the sub-regions that are registered for the bounds",
+				ArgsUsage: "[bounds]",
+				Action: func(c *cli.Context) error {
+					bounds, err := getBoundsFromArgs(c)
+					if err != nil {
+						return err
+					}
+					regions, err := productService.ListSubRegions(ctx, bounds)
+					if err != nil {
+						return err
+					}
+					for _, region := range regions {
+						fmt.Printf("%s\t%s\t%d polygons\n", region.ID, region.Name, len(region.MultiPolygon))
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "reconcile",
+				Usage: "Report the drift between the raw and processed maps, the frontend and the tile server",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "apply",
+						Usage: "publish the processed maps that are not published and repair dangling frontend entries",
+					},
+					&cli.BoolFlag{
+						Name: "json",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					report, err := productService.Reconcile(ctx, c.Bool("apply"))
+					if report == nil {
+						return err
+					}
+					if c.Bool("json") {
+						if printErr := printAsJson(report); printErr != nil {
+							return printErr
+						}
+					} else {
+						printDriftReport(*report)
+					}
+					return err
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "Serve the processed maps as xyz tiles and the frontend json, for developing the frontend",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Value: "localhost:8080",
+						Usage: "the address to listen on",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					serveCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+					defer stop()
+					server := &http.Server{Addr: c.String("addr"), Handler: tileHandler}
+					go func() {
+						<-serveCtx.Done()
+						_ = server.Shutdown(context.WithoutCancel(serveCtx))
+					}()
+					fmt.Printf("Serving the frontend json at http://%s%s\n", c.String("addr"), MapOptionsPath)
+					if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+						return err
+					}
 					return nil
 				},
 			},
@@ -196,13 +484,30 @@ func getMapFromArgs(c *cli.Context) (domain.Map, error) {
 	if c.NArg() < 1 {
 		return domain.Map{}, fmt.Errorf("the map argument is required")
 	}
+	arg := c.Args().Get(0)
+	// A map can also be referenced by its id, e.g. eog-mon-b1-20230401
+	if !strings.HasPrefix(strings.TrimSpace(arg), "{") {
+		id, err := domain.ParseMapID(arg)
+		if err != nil {
+			return domain.Map{}, err
+		}
+		return id.ToMap(""), nil
+	}
 	m := domain.Map{}
-	if err := json.Unmarshal([]byte(c.Args().Get(0)), &m); err != nil {
+	if err := json.Unmarshal([]byte(arg), &m); err != nil {
 		return domain.Map{}, err
 	}
 	return m, nil
 }
 
+func getBoundsFromArgs(c *cli.Context) (domain.Bounds, error) {
+	bounds := domain.StringToBounds(c.Args().Get(0))
+	if bounds == domain.BoundsUnspecified {
+		return bounds, fmt.Errorf("unknown bounds %q", c.Args().Get(0))
+	}
+	return bounds, nil
+}
+
 func printSliceAsJson[T any](items []T) error {
 	jsonData, err := json.MarshalIndent(items, "", "  ")
 	if err != nil {
@@ -212,6 +517,15 @@ func printSliceAsJson[T any](items []T) error {
 	return nil
 }
 
+func printAsJson(item any) error {
+	jsonData, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
 func printMapsAsJson(maps []domain.Map) error {
 	return printSliceAsJson(maps)
 }
@@ -223,7 +537,7 @@ func printPublishedMapsToTerminal(maps []domain.PublishedMap) error {
 func printMapsAsTable(maps []domain.Map) error {
 	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent)
 
-	_, err := fmt.Fprintln(writer, "Date\tMap Type\tBounds\tSource_MapProvider")
+	_, err := fmt.Fprintln(writer, "ID\tDate\tMap Type\tBounds\tSource_MapProvider")
 	if err != nil {
 		return err
 	}
@@ -233,7 +547,8 @@ func printMapsAsTable(maps []domain.Map) error {
 		boundsStr := m.Bounds.String()
 		_, err := fmt.Fprintf(
 			writer,
-			"%d-%02d-%02d\t%s\t%s\t%s\n",
+			"%s\t%d-%02d-%02d\t%s\t%s\t%s\n",
+			m.ID().String(),
 			m.Date.Year,
 			m.Date.Month,
 			m.Date.Day,
@@ -248,3 +563,163 @@ func printMapsAsTable(maps []domain.Map) error {
 
 	return writer.Flush()
 }
+
+func printMapStatusesAsTable(statuses []domain.MapStatus) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent)
+
+	_, err := fmt.Fprintln(writer, "ID\tStage\tUpdated At\tFailed Stage\tLast Error")
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		failedStage := ""
+		if status.LastError != "" {
+			failedStage = status.FailedStage.String()
+		}
+		_, err := fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\n",
+			status.ID.String(),
+			status.Stage.String(),
+			status.UpdatedAt.Format(time.RFC3339),
+			failedStage,
+			status.LastError,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func printMapStatsAsTable(series []domain.MapStats) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent)
+
+	_, err := fmt.Fprintln(writer, "ID\tSum Radiance\tMean Radiance\tLit Pixels\tCoverage")
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range series {
+		_, err := fmt.Fprintf(
+			writer,
+			"%s\t%.1f\t%.3f\t%d\t%.1f%%\n",
+			stats.ID.String(),
+			stats.SumRadiance,
+			stats.MeanRadiance,
+			stats.LitPixelCount,
+			stats.Coverage*100,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func printAnomaliesAsTable(anomalies []domain.Anomaly) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.TabIndent)
+
+	_, err := fmt.Fprintln(writer, "ID\tRegion\tMean Radiance\tRolling Baseline\tSeasonal Baseline\tChange")
+	if err != nil {
+		return err
+	}
+
+	for _, anomaly := range anomalies {
+		region, seasonal := anomaly.RegionName, "-"
+		if region == "" {
+			region = "(whole bounds)"
+		}
+		if anomaly.SeasonalBaseline > 0 {
+			seasonal = fmt.Sprintf("%.3f", anomaly.SeasonalBaseline)
+		}
+		_, err := fmt.Fprintf(
+			writer,
+			"%s\t%s\t%.3f\t%.3f\t%s\t%.1f%%\n",
+			anomaly.ID.String(),
+			region,
+			anomaly.MeanRadiance,
+			anomaly.RollingBaseline,
+			seasonal,
+			anomaly.Change*100,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func printDriftReport(report domain.DriftReport) {
+	printDriftCategory("Processed maps that are not published", mapIDs(report.ProcessedNotPublished))
+	printDriftCategory("Frontend entries without a tileset", publishedMapIDs(report.DanglingFrontendEntries))
+	printDriftCategory("Raw maps that were never processed", mapIDs(report.RawWithoutProcessed))
+	printDriftCategory("Tilesets that are not in the frontend", publishedMapIDs(report.UnreferencedTilesets))
+	printDriftCategory("Published maps without a processed map", publishedMapIDs(report.PublishedWithoutProcessed))
+	printDriftCategory("Unpublished maps, these are not drift", mapIDs(report.Unpublished))
+	if !report.HasDrift() {
+		fmt.Println("No drift was found")
+	}
+	if !report.Applied {
+		return
+	}
+	fmt.Printf("Fixed %d maps\n", len(report.Fixed))
+	failed := make([]domain.MapID, 0, len(report.FixErrors))
+	for id := range report.FixErrors {
+		failed = append(failed, id)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].String() < failed[j].String() })
+	for _, id := range failed {
+		fmt.Printf("  %s: %s\n", id.String(), report.FixErrors[id])
+	}
+}
+
+func printDriftCategory(title string, ids []domain.MapID) {
+	if len(ids) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(ids))
+	for _, id := range ids {
+		fmt.Printf("  %s\n", id.String())
+	}
+}
+
+func mapIDs(maps []domain.Map) []domain.MapID {
+	ids := make([]domain.MapID, len(maps))
+	for i, m := range maps {
+		ids[i] = m.ID()
+	}
+	return ids
+}
+
+func publishedMapIDs(maps []domain.PublishedMap) []domain.MapID {
+	ids := make([]domain.MapID, len(maps))
+	for i, m := range maps {
+		ids[i] = m.Map.ID()
+	}
+	return ids
+}
+
+func printDeleteResult(verb string, result domain.DeleteResult) {
+	fmt.Printf("%s %s\n", verb, result.ID.String())
+	for _, outcome := range result.Outcomes {
+		status := "ok"
+		if outcome.Error != "" {
+			status = "failed: " + outcome.Error
+		}
+		fmt.Printf("  %s (%s)\n", outcome.Repository, status)
+		if !result.DryRun {
+			continue
+		}
+		if len(outcome.Targets) == 0 {
+			fmt.Println("    nothing found")
+		}
+		for _, target := range outcome.Targets {
+			fmt.Printf("    %s\n", target)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/mapkeys"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ProcessedMapCreatedLambdaEventHandler publishes the maps that are written to the processed tif bucket, it is
+// invoked by the s3 event notifications of the bucket. The maps it does not publish are still analysed
+type ProcessedMapCreatedLambdaEventHandler struct {
+	logger ports.Logger
+	srv    ports.OrchestratorService
+	policy domain.AutoPublishPolicy
+}
+
+func NewProcessedMapCreatedLambdaHandler(
+	logger ports.Logger,
+	srv ports.OrchestratorService,
+	policy domain.AutoPublishPolicy,
+) *ProcessedMapCreatedLambdaEventHandler {
+	return &ProcessedMapCreatedLambdaEventHandler{logger: logger, srv: srv, policy: policy}
+}
+
+// HandleEvent publishes every map of the event the policy allows, an error is returned when any of them failed so
+// lambda retries the event. The statistics of the other maps are computed and checked for anomalies, publishing
+// does the same for the published maps
+func (handler *ProcessedMapCreatedLambdaEventHandler) HandleEvent(ctx context.Context, event events.S3Event) error {
+	var errs []error
+	for _, record := range event.Records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			handler.logger.Debug(ctx, "Ignoring an event that did not create an object", "eventName", record.EventName)
+			continue
+		}
+		// The keys in s3 event notifications are url encoded
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			handler.logger.Error(ctx, "The objectKey could not be decoded", "objectKey", record.S3.Object.Key)
+			errs = append(errs, err)
+			continue
+		}
+		id, err := mapkeys.ToMapID(key)
+		if err != nil {
+			handler.logger.Warn(ctx, "The objectKey is not a map", "objectKey", key, "error", err)
+			continue
+		}
+		m := id.ToMap("")
+		if !handler.policy.Allows(m) {
+			handler.logger.Info(ctx, "The auto publish policy does not allow publishing the map", "map", id.String())
+			// The statistics are secondary to the map, a failure is not worth retrying the event for
+			if err := handler.srv.UpdateMapStats(ctx, m); err != nil {
+				handler.logger.Warn(ctx, "Could not update the map stats", "map", id.String(), "error", err)
+			}
+			continue
+		}
+		if err := handler.srv.PublishMap(ctx, m); err != nil {
+			handler.logger.Error(ctx, "Error while publishing map.", "map", id.String(), "error", err)
+			errs = append(errs, fmt.Errorf("publishing %s failed: %w", id.String(), err))
+			continue
+		}
+		handler.logger.Info(ctx, "Map published successfully.", "map", id.String())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/tiles"
+)
+
+// MapOptionsPath is where the frontend json is served, the same name it has in the cdn bucket
+const MapOptionsPath = "/conflict-nightlight-bounded-map-options.json"
+
+// MapOptionsEncoder returns the frontend json of the maps
+type MapOptionsEncoder func(maps []domain.PublishedMap) ([]byte, error)
+
+// TileHTTPHandler serves the processed maps as xyz tiles that are rendered on request, together with a frontend json
+// that points at them, so the frontend can be developed without publishing to a tile server
+type TileHTTPHandler struct {
+	logger           ports.Logger
+	srv              ports.OrchestratorService
+	encodeMapOptions MapOptionsEncoder
+	mux              *http.ServeMux
+
+	mu      sync.Mutex
+	rasters map[domain.MapID]*cachedRaster
+}
+
+// cachedRaster is downloaded and decoded once, the requests for the tiles of a map that arrive meanwhile wait for it
+type cachedRaster struct {
+	once   sync.Once
+	raster *raster.Raster
+	err    error
+}
+
+func NewTileHTTPHandler(
+	logger ports.Logger,
+	srv ports.OrchestratorService,
+	encodeMapOptions MapOptionsEncoder,
+) *TileHTTPHandler {
+	handler := &TileHTTPHandler{
+		logger:           logger,
+		srv:              srv,
+		encodeMapOptions: encodeMapOptions,
+		mux:              http.NewServeMux(),
+		rasters:          make(map[domain.MapID]*cachedRaster),
+	}
+	handler.mux.HandleFunc("GET "+MapOptionsPath, handler.serveMapOptions)
+	handler.mux.HandleFunc("GET /tiles/{mapID}/{z}/{x}/{y}", handler.serveTile)
+	return handler
+}
+
+func (handler *TileHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The frontend runs on a port of its own
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	handler.mux.ServeHTTP(w, r)
+}
+
+// serveMapOptions lists every processed map, the urls point back at the host the request was sent to
+func (handler *TileHTTPHandler) serveMapOptions(w http.ResponseWriter, r *http.Request) {
+	maps, err := handler.srv.ListProcessedInternalMaps(r.Context())
+	if err != nil {
+		handler.logger.Error(r.Context(), "Error when listing the processed maps", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	publishedMaps := make([]domain.PublishedMap, 0, len(maps))
+	for _, m := range maps {
+		publishedMaps = append(publishedMaps, domain.PublishedMap{
+			Map: m,
+			Url: fmt.Sprintf("http://%s/tiles/%s/{z}/{x}/{y}.png", r.Host, m.ID().String()),
+		})
+	}
+	data, err := handler.encodeMapOptions(publishedMaps)
+	if err != nil {
+		handler.logger.Error(r.Context(), "Error when encoding the map options", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// serveTile renders the tile, a tile outside the map is served as a transparent png so the frontend does not log
+// failed requests
+func (handler *TileHTTPHandler) serveTile(w http.ResponseWriter, r *http.Request) {
+	mapID, err := domain.ParseMapID(r.PathValue("mapID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tile, err := parseTile(r.PathValue("z"), r.PathValue("x"), strings.TrimSuffix(r.PathValue("y"), ".png"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mapRaster, err := handler.raster(r.Context(), mapID)
+	if err != nil {
+		handler.logger.Error(r.Context(), "Error when loading the processed map", "map", mapID.String(), "error", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	img, _, err := tiles.Render(mapRaster, tile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// raster returns the decoded processed map, a map that failed to load is tried again on the next request
+func (handler *TileHTTPHandler) raster(ctx context.Context, mapID domain.MapID) (*raster.Raster, error) {
+	handler.mu.Lock()
+	cached, ok := handler.rasters[mapID]
+	if !ok {
+		cached = &cachedRaster{}
+		handler.rasters[mapID] = cached
+	}
+	handler.mu.Unlock()
+
+	cached.once.Do(func() {
+		// The download is shared by the waiting requests, so it must not stop when the first request is cancelled
+		localMap, err := handler.srv.DownloadProcessedMap(context.WithoutCancel(ctx), mapID.ToMap(""))
+		if err != nil {
+			cached.err = err
+			return
+		}
+		// The decoded raster is what is cached, the downloaded tif is not needed anymore
+		defer os.Remove(localMap.Filepath)
+		cached.raster, cached.err = raster.ReadFile(localMap.Filepath)
+	})
+	if cached.err != nil {
+		handler.mu.Lock()
+		if handler.rasters[mapID] == cached {
+			delete(handler.rasters, mapID)
+		}
+		handler.mu.Unlock()
+	}
+	return cached.raster, cached.err
+}
+
+func parseTile(z, x, y string) (tiles.Tile, error) {
+	var coordinates [3]int
+	for i, s := range []string{z, x, y} {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return tiles.Tile{}, fmt.Errorf("invalid tile %s/%s/%s", z, x, y)
+		}
+		coordinates[i] = n
+	}
+	tile := tiles.Tile{Z: coordinates[0], X: coordinates[1], Y: coordinates[2]}
+	if tile.Z > tiles.MaxZoom || tile.X >= 1<<tile.Z || tile.Y >= 1<<tile.Z {
+		return tiles.Tile{}, fmt.Errorf("invalid tile %s/%s/%s", z, x, y)
+	}
+	return tile, nil
+}
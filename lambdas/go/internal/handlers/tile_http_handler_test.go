@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/services"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/BaronBonet/conflict-nightlight/internal/testing/fakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTileHandler serves a processed map of one by one degree around kyiv where every pixel is 100, the map is
+// downloaded to writeDir
+func newTestTileHandler(t *testing.T, writeDir string) (*TileHTTPHandler, domain.Map) {
+	processed := fakes.NewInternalMapRepo(writeDir)
+	srv := services.NewOrchestratorService(
+		fakes.NewLogger(),
+		fakes.NewExternalMapProviderRepo(domain.MapProviderEogdata),
+		fakes.NewInternalMapRepo(t.TempDir()),
+		processed,
+		fakes.NewFrontendMapDataRepo(),
+		fakes.NewMapTileServerRepo(),
+		fakes.NewMapStatusRepo(),
+		fakes.NewMapStatsRepo(),
+		fakes.NewSubRegionRepo(),
+		fakes.NewRegionStatsRepo(),
+		fakes.NewAnomalyRepo(),
+		domain.DefaultAnomalyConfig(),
+		domain.QualityGate{},
+	)
+	id, err := domain.ParseMapID("eog-day-b1-20220101")
+	require.NoError(t, err)
+	m := id.ToMap("")
+	r, err := raster.New(100, 100, raster.DataTypeUint8)
+	require.NoError(t, err)
+	for i := range r.Uint8s() {
+		r.Uint8s()[i] = 100
+	}
+	r.Transform = raster.GeoTransform{30, 0.01, 0, 51, 0, -0.01}
+	r.GeoKeys = raster.NewGeographicGeoKeys(raster.EPSGWGS84)
+	var buf bytes.Buffer
+	require.NoError(t, raster.Encode(&buf, r, nil))
+	processed.Put(m, buf.Bytes(), nil)
+
+	encode := func(maps []domain.PublishedMap) ([]byte, error) {
+		return json.Marshal(maps)
+	}
+	return NewTileHTTPHandler(fakes.NewLogger(), srv, encode), m
+}
+
+func TestTileHTTPHandler_ServesTheMapOptions(t *testing.T) {
+	handler, m := newTestTileHandler(t, t.TempDir())
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+MapOptionsPath, nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	var maps []domain.PublishedMap
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &maps))
+	require.Len(t, maps, 1)
+	assert.Equal(t, m.ID(), maps[0].Map.ID())
+	assert.Equal(t, "http://localhost:8080/tiles/eog-day-b1-20220101/{z}/{x}/{y}.png", maps[0].Url)
+}
+
+func TestTileHTTPHandler_RendersTiles(t *testing.T) {
+	handler, _ := newTestTileHandler(t, t.TempDir())
+
+	tests := []struct {
+		name  string
+		path  string
+		code  int
+		alpha uint32
+	}{
+		{name: "a tile of the map", path: "/tiles/eog-day-b1-20220101/6/37/21.png", code: http.StatusOK, alpha: 0xffff},
+		{name: "a tile outside the map", path: "/tiles/eog-day-b1-20220101/6/0/0.png", code: http.StatusOK},
+		{name: "a tile outside the world", path: "/tiles/eog-day-b1-20220101/1/2/0.png", code: http.StatusBadRequest},
+		{name: "an unknown map", path: "/tiles/eog-day-b1-20220102/6/37/21.png", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+			img, err := png.Decode(w.Body)
+			require.NoError(t, err)
+			// The map covers the pixels 85 to 131 of the columns and 109 to 180 of the rows of 6/37/21
+			_, _, _, alpha := img.At(108, 145).RGBA()
+			assert.Equal(t, tt.alpha, alpha)
+		})
+	}
+}
+
+func TestTileHTTPHandler_RemovesTheDownloadedMap(t *testing.T) {
+	writeDir := t.TempDir()
+	handler, _ := newTestTileHandler(t, writeDir)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tiles/eog-day-b1-20220101/6/37/21.png", nil))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	entries, err := os.ReadDir(writeDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,140 @@
+// Package geojson converts sub-regions from and to GeoJSON feature collections, e.g. the oblasts exported from QGIS
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+)
+
+const (
+	typeFeatureCollection = "FeatureCollection"
+	typeFeature           = "Feature"
+	typePolygon           = "Polygon"
+	typeMultiPolygon      = "MultiPolygon"
+)
+
+var (
+	nonIDCharacters = regexp.MustCompile("[^a-z0-9]+")
+	// derivableName is a name the id can be derived from without losing letters, e.g. not the Cyrillic name of an
+	// oblast which would leave an empty id or only its digits
+	derivableName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._'-]*$`)
+)
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+	Geometry   *geometry      `json:"geometry"`
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParseSubRegions reads the features of a feature collection as sub-regions of the bounds. The name is taken from
+// the name property and the id from the id property, the id falls back to the name in lowercase, e.g. "Kharkiv
+// Oblast" becomes kharkiv-oblast. A name with other characters, e.g. Cyrillic, needs an explicit id
+func ParseSubRegions(bounds domain.Bounds, data []byte) ([]domain.SubRegion, error) {
+	var collection featureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("the geojson could not be parsed: %w", err)
+	}
+	if collection.Type != typeFeatureCollection {
+		return nil, fmt.Errorf("expected a %s, got %q", typeFeatureCollection, collection.Type)
+	}
+	regions := make([]domain.SubRegion, 0, len(collection.Features))
+	for i, f := range collection.Features {
+		if f.Type != typeFeature || f.Geometry == nil {
+			return nil, fmt.Errorf("feature %d is not a feature with a geometry", i)
+		}
+		name, _ := f.Properties["name"].(string)
+		id, _ := f.Properties["id"].(string)
+		if id == "" {
+			if !derivableName.MatchString(name) {
+				return nil, fmt.Errorf("feature %d (%q) needs an id property, it cannot be derived from the name",
+					i, name)
+			}
+			id = strings.Trim(nonIDCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+		}
+		multiPolygon, err := parseGeometry(*f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d (%s): %w", i, name, err)
+		}
+		regions = append(regions, domain.SubRegion{ID: id, Name: name, Bounds: bounds, MultiPolygon: multiPolygon})
+	}
+	if err := domain.ValidateSubRegions(bounds, regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// EncodeSubRegions writes the sub-regions as a feature collection that ParseSubRegions reads back
+func EncodeSubRegions(regions []domain.SubRegion) ([]byte, error) {
+	collection := featureCollection{Type: typeFeatureCollection, Features: make([]feature, 0, len(regions))}
+	for _, region := range regions {
+		coordinates, err := json.Marshal(region.MultiPolygon)
+		if err != nil {
+			return nil, err
+		}
+		collection.Features = append(collection.Features, feature{
+			Type:       typeFeature,
+			Properties: map[string]any{"id": region.ID, "name": region.Name},
+			Geometry:   &geometry{Type: typeMultiPolygon, Coordinates: coordinates},
+		})
+	}
+	return json.Marshal(collection)
+}
+
+// parseGeometry returns polygons as a multi polygon, positions with an altitude are cut to [lon, lat]
+func parseGeometry(g geometry) ([][][][2]float64, error) {
+	switch g.Type {
+	case typePolygon:
+		var polygon [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		converted, err := toPolygon(polygon)
+		if err != nil {
+			return nil, err
+		}
+		return [][][][2]float64{converted}, nil
+	case typeMultiPolygon:
+		var multiPolygon [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &multiPolygon); err != nil {
+			return nil, err
+		}
+		converted := make([][][][2]float64, len(multiPolygon))
+		for i, polygon := range multiPolygon {
+			var err error
+			if converted[i], err = toPolygon(polygon); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry %q, only polygons and multi polygons are supported", g.Type)
+	}
+}
+
+func toPolygon(rings [][][]float64) ([][][2]float64, error) {
+	polygon := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		polygon[i] = make([][2]float64, len(ring))
+		for j, position := range ring {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("a position needs at least a longitude and a latitude")
+			}
+			polygon[i][j] = [2]float64{position[0], position[1]}
+		}
+	}
+	return polygon, nil
+}
@@ -0,0 +1,95 @@
+package geojson
+
+import (
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFeatureCollection = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"name": "Kharkiv Oblast"},
+      "geometry": {"type": "Polygon", "coordinates": [[[35, 49], [37, 49], [37, 50, 120], [35, 49]]]}
+    },
+    {
+      "type": "Feature",
+      "properties": {"id": "lviv", "name": "Lviv"},
+      "geometry": {
+        "type": "MultiPolygon",
+        "coordinates": [[[[23, 49], [24, 49], [24, 50], [23, 49]]], [[[25, 49], [26, 49], [26, 50], [25, 49]]]]
+      }
+    }
+  ]
+}`
+
+func TestParseSubRegions(t *testing.T) {
+	regions, err := ParseSubRegions(domain.BoundsUkraineAndAround, []byte(testFeatureCollection))
+
+	require.NoError(t, err)
+	require.Len(t, regions, 2)
+	assert.Equal(t, domain.SubRegion{
+		ID:           "kharkiv-oblast",
+		Name:         "Kharkiv Oblast",
+		Bounds:       domain.BoundsUkraineAndAround,
+		MultiPolygon: [][][][2]float64{{{{35, 49}, {37, 49}, {37, 50}, {35, 49}}}},
+	}, regions[0])
+	assert.Equal(t, "lviv", regions[1].ID)
+	assert.Len(t, regions[1].MultiPolygon, 2)
+}
+
+func TestParseSubRegions_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not a collection": `{"type": "Feature"}`,
+		"point":            `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "a"}, "geometry": {"type": "Point", "coordinates": [1, 2]}}]}`,
+		"open ring":        `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[1, 2], [2, 2], [2, 3], [1, 3]]]}}]}`,
+		"duplicate ids":    `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "a"}, "geometry": {"type": "Polygon", "coordinates": [[[1, 2], [2, 2], [2, 3], [1, 2]]]}}, {"type": "Feature", "properties": {"name": "A"}, "geometry": {"type": "Polygon", "coordinates": [[[1, 2], [2, 2], [2, 3], [1, 2]]]}}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSubRegions(domain.BoundsUkraineAndAround, []byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseSubRegions_IDCannotBeDerived(t *testing.T) {
+	names := []string{"Харківська область", "Київ 2", ""}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			data := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "` + name +
+				`"}, "geometry": {"type": "Polygon", "coordinates": [[[1, 2], [2, 2], [2, 3], [1, 2]]]}}]}`
+
+			_, err := ParseSubRegions(domain.BoundsUkraineAndAround, []byte(data))
+
+			assert.ErrorContains(t, err, "needs an id property")
+		})
+	}
+}
+
+func TestParseSubRegions_ExplicitIDForACyrillicName(t *testing.T) {
+	data := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"id": "kharkiv", "name": ` +
+		`"Харківська область"}, "geometry": {"type": "Polygon", "coordinates": [[[1, 2], [2, 2], [2, 3], [1, 2]]]}}]}`
+
+	regions, err := ParseSubRegions(domain.BoundsUkraineAndAround, []byte(data))
+
+	require.NoError(t, err)
+	assert.Equal(t, "kharkiv", regions[0].ID)
+	assert.Equal(t, "Харківська область", regions[0].Name)
+}
+
+func TestEncodeSubRegions(t *testing.T) {
+	regions, err := ParseSubRegions(domain.BoundsUkraineAndAround, []byte(testFeatureCollection))
+	require.NoError(t, err)
+
+	data, err := EncodeSubRegions(regions)
+	require.NoError(t, err)
+	decoded, err := ParseSubRegions(domain.BoundsUkraineAndAround, data)
+
+	require.NoError(t, err)
+	assert.Equal(t, regions, decoded)
+}
@@ -0,0 +1,57 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+)
+
+// MapConcurrently calls do for every item with at most workers goroutines and returns the results in the order of the
+// items. The first error cancels the items that were not started yet and is returned
+func MapConcurrently[T, R any](
+	ctx context.Context,
+	items []T,
+	workers int,
+	do func(ctx context.Context, item T) (R, error),
+) ([]R, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]R, len(items))
+	indexes := make(chan int)
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(items)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result, err := do(ctx, items[index])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[index] = result
+			}
+		}()
+	}
+feed:
+	for index := range items {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapConcurrently(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8}
+
+	results, err := MapConcurrently(context.Background(), items, 3, func(_ context.Context, item int) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		return item * 10, nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 20, 30, 40, 50, 60, 70, 80}, results)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+}
+
+func TestMapConcurrently_ReturnsTheFirstError(t *testing.T) {
+	var calls atomic.Int32
+
+	results, err := MapConcurrently(context.Background(), make([]int, 100), 1,
+		func(_ context.Context, _ int) (int, error) {
+			calls.Add(1)
+			return 0, errors.New("access denied")
+		})
+
+	assert.EqualError(t, err, "access denied")
+	assert.Nil(t, results)
+	assert.Less(t, calls.Load(), int32(100))
+}
+
+func TestMapConcurrently_NoItems(t *testing.T) {
+	results, err := MapConcurrently(context.Background(), nil, 4, func(_ context.Context, item int) (int, error) {
+		return item, nil
+	})
+
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
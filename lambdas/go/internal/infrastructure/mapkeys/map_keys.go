@@ -0,0 +1,133 @@
+// Package mapkeys is the s3 key scheme of the internal maps, provider/bounds/mapType/year_month_day.tif, the python
+// lambda writes the maps with the same scheme (see construct_key)
+package mapkeys
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+)
+
+const extension = ".tif"
+
+func FromMapID(id domain.MapID) string {
+	d := id.Date
+	if id.MapType == domain.MapTypeAnnual {
+		return fmt.Sprintf(
+			"%s/%s/%s/%d%s",
+			id.Provider.String(),
+			id.Bounds.String(),
+			id.MapType.String(),
+			d.Year,
+			extension,
+		)
+	}
+	s := fmt.Sprintf(
+		"%s/%s/%s/%d_%d_%d%s",
+		id.Provider.String(),
+		id.Bounds.String(),
+		id.MapType.String(),
+		d.Year,
+		d.Month,
+		d.Day,
+		extension,
+	)
+	return s
+}
+
+// Prefix returns the longest prefix all the keys of the matching maps share, unspecified values match anything so
+// the prefix stops at the first one, e.g. MapProviderEogdata/BoundsUkraineAndAround/ when the map type is unspecified
+func Prefix(provider domain.MapProvider, bounds domain.Bounds, mapType domain.MapType) string {
+	if provider == domain.MapProviderUnspecified {
+		return ""
+	}
+	prefix := provider.String() + "/"
+	if bounds == domain.BoundsUnspecified {
+		return prefix
+	}
+	prefix += bounds.String() + "/"
+	if mapType == domain.MapTypeUnspecified {
+		return prefix
+	}
+	return prefix + mapType.String() + "/"
+}
+
+// ToMapID decodes a key created with FromMapID
+func ToMapID(key string) (domain.MapID, error) {
+	directory := filepath.Dir(key)
+	if directory == "." || filepath.Ext(key) != extension {
+		return domain.MapID{}, fmt.Errorf("the key %s was not in the expected format", key)
+	}
+	provider, bounds, mapType, err := ParseDirectory(directory)
+	if err != nil {
+		return domain.MapID{}, err
+	}
+	date, err := ParseDate(key)
+	if err != nil {
+		return domain.MapID{}, err
+	}
+	return domain.MapID{Provider: *provider, MapType: *mapType, Bounds: *bounds, Date: *date}, nil
+}
+
+// ParseDirectory decodes the provider/bounds/mapType part of a key, a provider, bounds or map type that is not known
+// is an error so objects that are not maps are never taken for one
+func ParseDirectory(directory string) (*domain.MapProvider, *domain.Bounds, *domain.MapType, error) {
+	parts := strings.Split(directory, "/")
+	if len(parts) != 3 {
+		return nil, nil, nil, errors.New("the objectKey was not in the expected format")
+	}
+	objProvider, objBounds, objMapType := parts[0], parts[1], parts[2]
+	provider := domain.StringToMapProvider(objProvider)
+	if provider == domain.MapProviderUnspecified {
+		return nil, nil, nil, fmt.Errorf("unknown map provider %s in the objectKey", objProvider)
+	}
+	bounds := domain.StringToBounds(objBounds)
+	if bounds == domain.BoundsUnspecified {
+		return nil, nil, nil, fmt.Errorf("unknown bounds %s in the objectKey", objBounds)
+	}
+	mapType := domain.StringToMapType(objMapType)
+	if mapType == domain.MapTypeUnspecified {
+		return nil, nil, nil, fmt.Errorf("unknown map type %s in the objectKey", objMapType)
+	}
+	return &provider, &bounds, &mapType, nil
+}
+
+// ParseDate decodes the file name of a key
+func ParseDate(key string) (*domain.Date, error) {
+	fileName := filepath.Base(key)
+	parts := strings.Split(fileName, ".")[0]
+	dateParts := strings.Split(parts, "_")
+
+	year, err := strconv.Atoi(dateParts[0])
+	if err != nil {
+		return nil, errors.New("failed to parse year. error: " + err.Error())
+	}
+	// Annual maps are only keyed by their year
+	if len(dateParts) == 1 {
+		date := domain.NewAnnualDate(year)
+		return &date, nil
+	}
+	if len(dateParts) != 3 {
+		return nil, errors.New("the key did not contain a date in the format year_month_day: " + fileName)
+	}
+
+	month, err := strconv.Atoi(dateParts[1])
+	if err != nil {
+		return nil, errors.New("failed to parse month. error: " + err.Error())
+	}
+
+	day, err := strconv.Atoi(dateParts[2])
+	if err != nil {
+		return nil, errors.New("failed to parse day. error: " + err.Error())
+	}
+	return &domain.Date{
+		Day:   day,
+		Month: time.Month(month),
+		Year:  year,
+	}, nil
+}
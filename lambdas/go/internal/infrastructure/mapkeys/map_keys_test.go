@@ -0,0 +1,86 @@
+package mapkeys
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	d, _ := ParseDate("2022_1_1.tif")
+	assert.Equal(t, &domain.Date{
+		Day:   1,
+		Month: 1,
+		Year:  2022,
+	}, d)
+}
+
+func TestFromMapID(t *testing.T) {
+	n := FromMapID(
+		domain.Map{MapType: domain.MapTypeDaily, Bounds: domain.BoundsUkraineAndAround, Source: domain.MapSource{
+			MapProvider: domain.MapProviderEogdata,
+			URL:         "test.com",
+		}, Date: domain.Date{
+			Day:   0,
+			Month: 1,
+			Year:  2022,
+		}}.ID(),
+	)
+	assert.Equal(t, n, "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2022_1_0.tif")
+}
+
+func TestAnnualKeyRoundTrip(t *testing.T) {
+	m := domain.Map{
+		MapType: domain.MapTypeAnnual,
+		Bounds:  domain.BoundsGazaAndAround,
+		Source:  domain.MapSource{MapProvider: domain.MapProviderEogdata},
+		Date:    domain.NewAnnualDate(2022),
+	}
+	key := FromMapID(m.ID())
+	assert.Equal(t, "MapProviderEogdata/BoundsGazaAndAround/MapTypeAnnual/2022.tif", key)
+
+	d, err := ParseDate(key)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Date, *d)
+}
+
+func TestToMapID(t *testing.T) {
+	id := domain.MapID{
+		Provider: domain.MapProviderEogdata,
+		MapType:  domain.MapTypeMonthly,
+		Bounds:   domain.BoundsUkraineAndAround,
+		Date:     domain.Date{Day: 1, Month: 4, Year: 2023},
+	}
+	parsed, err := ToMapID(FromMapID(id))
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	for _, key := range []string{
+		"2023_4_1.tif",
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2023_4_1.json",
+		"MapProviderEogdata/MapTypeMonthly/2023_4_1.tif",
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeMonthly/2023_april_1.tif",
+		"MapProviderNasa/BoundsUkraineAndAround/MapTypeMonthly/2023_4_1.tif",
+		"MapProviderEogdata/BoundsAtlantis/MapTypeMonthly/2023_4_1.tif",
+		"MapProviderEogdata/BoundsUkraineAndAround/MapTypeWeekly/2023_4_1.tif",
+		"exports/backup/old/2023_4_1.tif",
+	} {
+		_, err := ToMapID(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	eogdata, ukraine, daily := domain.MapProviderEogdata, domain.BoundsUkraineAndAround, domain.MapTypeDaily
+
+	assert.Equal(t, "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/", Prefix(eogdata, ukraine, daily))
+	assert.Equal(t, "MapProviderEogdata/BoundsUkraineAndAround/", Prefix(eogdata, ukraine, domain.MapTypeUnspecified))
+	assert.Equal(t, "MapProviderEogdata/", Prefix(eogdata, domain.BoundsUnspecified, daily))
+	assert.Equal(t, "", Prefix(domain.MapProviderUnspecified, ukraine, daily))
+
+	key := FromMapID(domain.MapID{Provider: eogdata, Bounds: ukraine, MapType: daily, Date: domain.Date{Year: 2022}})
+	assert.True(t, strings.HasPrefix(key, Prefix(eogdata, ukraine, daily)))
+}
@@ -18,34 +18,57 @@ func DomainToProto(m domain.Map) conflict_nightlightv1.Map {
 			Year:  uint32(m.Date.Year),
 		},
 		MapType: conflict_nightlightv1.MapType(conflict_nightlightv1.MapType_value[fmt.Sprintf(strings.ToUpper(camelToSnakeCase(m.MapType.String())))]),
-		Bounds:  conflict_nightlightv1.Bounds(conflict_nightlightv1.Bounds_value[fmt.Sprintf(strings.ToUpper(camelToSnakeCase(m.Bounds.String())))]),
+		Bounds:  conflict_nightlightv1.Bounds(m.Bounds),
 		MapSource: &conflict_nightlightv1.MapSource{
 			MapProvider: conflict_nightlightv1.MapProvider(conflict_nightlightv1.MapProvider_value[fmt.Sprintf(strings.ToUpper(camelToSnakeCase(m.Source.MapProvider.String())))]),
 			Url:         m.Source.URL,
+			CoverageUrl: m.Source.CoverageURL,
 		},
 	}
 }
 
 func ProtoToDomain(mp *conflict_nightlightv1.Map) domain.Map {
 	return domain.Map{
-		Date:    domain.Date{Day: int(mp.Date.Day), Month: time.Month(mp.Date.Month), Year: int(mp.Date.Year)},
-		MapType: domain.MapType(mp.MapType),
-		Bounds:  domain.Bounds(mp.Bounds),
-		Source:  domain.MapSource{MapProvider: domain.MapProvider(mp.MapSource.MapProvider), URL: mp.MapSource.Url},
+		Date:    protoToDomainDate(mp.GetDate()),
+		MapType: domain.MapType(mp.GetMapType()),
+		Bounds:  domain.Bounds(mp.GetBounds()),
+		Source: domain.MapSource{
+			MapProvider: domain.MapProvider(mp.GetMapSource().GetMapProvider()),
+			URL:         mp.GetMapSource().GetUrl(),
+			CoverageURL: mp.GetMapSource().GetCoverageUrl(),
+		},
 	}
 }
 
 func ProtoToSyncMapsRequest(mp *conflict_nightlightv1.SyncMapRequest) domain.SyncMapRequest {
+	selectedDates := domain.SelectedDates{
+		Years:  ConvertInts[int](mp.SelectedYears),
+		Months: ConvertInts[time.Month](mp.SelectedMonths),
+	}
+	if dateRange := mp.GetDateRange(); dateRange != nil {
+		selectedDates.Range = &domain.DateRange{
+			From: protoToDomainDate(dateRange.GetFrom()),
+			To:   protoToDomainDate(dateRange.GetTo()),
+		}
+	}
+	if relativeDates := mp.GetRelativeDates(); relativeDates != nil {
+		selectedDates.Relative = &domain.RelativeDates{
+			LastDays:   int(relativeDates.GetLastDays()),
+			LastMonths: int(relativeDates.GetLastMonths()),
+			LastYears:  int(relativeDates.GetLastYears()),
+		}
+	}
 	return domain.SyncMapRequest{
-		SelectedDates: domain.SelectedDates{
-			Years:  ConvertInts[int](mp.SelectedYears),
-			Months: ConvertInts[time.Month](mp.SelectedMonths),
-		},
-		MapType: domain.MapType(mp.MapType),
-		Bounds:  domain.Bounds(mp.Bounds),
+		SelectedDates: selectedDates,
+		MapType:       domain.MapType(mp.MapType),
+		Bounds:        domain.Bounds(mp.Bounds),
 	}
 }
 
+func protoToDomainDate(d *conflict_nightlightv1.Date) domain.Date {
+	return domain.Date{Day: int(d.GetDay()), Month: time.Month(d.GetMonth()), Year: int(d.GetYear())}
+}
+
 func camelToSnakeCase(str string) string {
 	var matchAllCap = regexp.MustCompile("([a-z0-9])([A-Z])")
 	snake := matchAllCap.ReplaceAllString(str, "${1}_${2}")
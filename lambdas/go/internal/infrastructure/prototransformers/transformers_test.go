@@ -13,7 +13,11 @@ func TestDomainToDownloadAndCropTifRequest(t *testing.T) {
 		Date:    domain.Date{Month: 1, Year: 2022},
 		MapType: domain.MapTypeDaily,
 		Bounds:  domain.BoundsUkraineAndAround,
-		Source:  domain.MapSource{URL: "http://example.com", MapProvider: domain.MapProviderEogdata},
+		Source: domain.MapSource{
+			URL:         "http://example.com",
+			MapProvider: domain.MapProviderEogdata,
+			CoverageURL: "http://example.com/cf_cvg.tif",
+		},
 	}
 	p := DomainToProto(m)
 	assert.Equal(t, p.MapType.String(), "MAP_TYPE_DAILY")
@@ -21,6 +25,8 @@ func TestDomainToDownloadAndCropTifRequest(t *testing.T) {
 	assert.Equal(t, p.MapSource.MapProvider.String(), "MAP_PROVIDER_EOGDATA")
 	assert.Equal(t, int(p.Date.Month), 1)
 	assert.Equal(t, int(p.Date.Year), 2022)
+	assert.Equal(t, p.MapSource.CoverageUrl, "http://example.com/cf_cvg.tif")
+	assert.Equal(t, ProtoToDomain(&p), m)
 }
 
 func TestToSnakeCase(t *testing.T) {
@@ -74,4 +80,24 @@ func TestProtoToSyncMapsRequest(t *testing.T) {
 
 		assert.Equal(t, domainReq, expectedDomainReq)
 	})
+
+	t.Run("Conversion of the date range and relative dates", func(t *testing.T) {
+		protoReq := &conflict_nightlightv1.SyncMapRequest{
+			Bounds:  conflict_nightlightv1.Bounds_BOUNDS_GAZA_AND_AROUND,
+			MapType: conflict_nightlightv1.MapType_MAP_TYPE_MONTHLY,
+			DateRange: &conflict_nightlightv1.DateRange{
+				From: &conflict_nightlightv1.Date{Month: 11, Year: 2022},
+				To:   &conflict_nightlightv1.Date{Month: 3, Year: 2023},
+			},
+			RelativeDates: &conflict_nightlightv1.RelativeDates{LastMonths: 3},
+		}
+
+		domainReq := ProtoToSyncMapsRequest(protoReq)
+
+		assert.Equal(t, domainReq.SelectedDates.Range, &domain.DateRange{
+			From: domain.Date{Month: time.November, Year: 2022},
+			To:   domain.Date{Month: time.March, Year: 2023},
+		})
+		assert.Equal(t, domainReq.SelectedDates.Relative, &domain.RelativeDates{LastMonths: 3})
+	})
 }
@@ -0,0 +1,244 @@
+package raster
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// decompress returns exactly size bytes, chunks that are shorter, e.g. the last strip of an image, are padded with
+// zeros
+func decompress(compression Compression, data []byte, size int) ([]byte, error) {
+	var decoded []byte
+	var err error
+	switch compression {
+	case CompressionNone:
+		decoded = data
+	case CompressionDeflate, compressionAdobeDeflate:
+		decoded, err = inflate(data, size)
+	case CompressionLZW:
+		decoded, err = decodeLZW(data, size)
+	case CompressionPackBits:
+		decoded, err = decodePackBits(data, size)
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	copy(out, decoded)
+	return out, nil
+}
+
+func compress(compression Compression, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionDeflate:
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("writing with compression %d is not supported", compression)
+	}
+}
+
+func inflate(data []byte, size int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out := make([]byte, 0, size)
+	buf := bytes.NewBuffer(out)
+	if _, err := io.Copy(buf, io.LimitReader(r, int64(size))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const (
+	lzwClearCode = 256
+	lzwEOICode   = 257
+	lzwFirstCode = 258
+	lzwMinWidth  = 9
+	lzwMaxWidth  = 12
+)
+
+// decodeLZW decodes the tiff flavour of lzw, the codes are read most significant bit first and the code width grows
+// one code earlier than in gif. compress/lzw does not support the latter
+func decodeLZW(data []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	table := make([][]byte, lzwFirstCode, 1<<lzwMaxWidth)
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+	width := lzwMinWidth
+	var prev []byte
+	var bitBuf uint32
+	bitCount := 0
+	pos := 0
+	for len(out) < size {
+		for bitCount < width {
+			if pos >= len(data) {
+				// Some writers leave out the end of information code
+				return out, nil
+			}
+			bitBuf = bitBuf<<8 | uint32(data[pos])
+			pos++
+			bitCount += 8
+		}
+		code := int(bitBuf>>(bitCount-width)) & (1<<width - 1)
+		bitCount -= width
+
+		switch {
+		case code == lzwEOICode:
+			return out, nil
+		case code == lzwClearCode:
+			table = table[:lzwFirstCode]
+			width = lzwMinWidth
+			prev = nil
+			continue
+		}
+		var entry []byte
+		switch {
+		case code < len(table):
+			entry = table[code]
+			if prev != nil && len(table) < cap(table) {
+				table = append(table, concat(prev, entry[0]))
+			}
+		case code == len(table) && prev != nil:
+			entry = concat(prev, prev[0])
+			if len(table) < cap(table) {
+				table = append(table, entry)
+			}
+		default:
+			return nil, fmt.Errorf("invalid lzw code %d", code)
+		}
+		out = append(out, entry...)
+		prev = entry
+		if len(table)+1 >= 1<<width && width < lzwMaxWidth {
+			width++
+		}
+	}
+	return out, nil
+}
+
+func concat(prefix []byte, suffix byte) []byte {
+	entry := make([]byte, len(prefix)+1)
+	copy(entry, prefix)
+	entry[len(prefix)] = suffix
+	return entry
+}
+
+func decodePackBits(data []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	for i := 0; i < len(data) && len(out) < size; {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(data) {
+				return nil, errors.New("the packbits data is truncated")
+			}
+			out = append(out, data[i:i+n+1]...)
+			i += n + 1
+		case n != -128:
+			if i >= len(data) {
+				return nil, errors.New("the packbits data is truncated")
+			}
+			out = append(out, bytes.Repeat(data[i:i+1], 1-n)...)
+			i++
+		}
+	}
+	return out, nil
+}
+
+// undoPredictor reverts the differencing that was applied to every row of the chunk before it was compressed
+func undoPredictor(predictor uint64, dataType DataType, chunk []byte, rowSize int, order binary.ByteOrder) error {
+	switch predictor {
+	case predictorNone:
+		return nil
+	case predictorHorizontal:
+		if dataType != DataTypeUint8 {
+			return fmt.Errorf("the horizontal predictor is not supported for %s", dataType)
+		}
+		for row := 0; row+rowSize <= len(chunk); row += rowSize {
+			for i := row + 1; i < row+rowSize; i++ {
+				chunk[i] += chunk[i-1]
+			}
+		}
+		return nil
+	case predictorFloat:
+		if dataType != DataTypeFloat32 {
+			return fmt.Errorf("the floating point predictor is not supported for %s", dataType)
+		}
+		undoFloatPredictor(chunk, rowSize, order)
+		return nil
+	default:
+		return fmt.Errorf("unsupported predictor %d", predictor)
+	}
+}
+
+// undoFloatPredictor reverts the floating point predictor, the bytes of a row are differenced and then stored as
+// byte planes, the most significant bytes of all pixels first regardless of the byte order of the file
+func undoFloatPredictor(chunk []byte, rowSize int, order binary.ByteOrder) {
+	width := rowSize / 4
+	planes := make([]byte, rowSize)
+	for row := 0; row+rowSize <= len(chunk); row += rowSize {
+		copy(planes, chunk[row:row+rowSize])
+		for i := 1; i < rowSize; i++ {
+			planes[i] += planes[i-1]
+		}
+		for x := 0; x < width; x++ {
+			bits := uint32(planes[x])<<24 | uint32(planes[width+x])<<16 |
+				uint32(planes[2*width+x])<<8 | uint32(planes[3*width+x])
+			order.PutUint32(chunk[row+4*x:], bits)
+		}
+	}
+}
+
+// applyPredictor is the inverse of undoPredictor with the predictor from predictorFor
+func applyPredictor(dataType DataType, chunk []byte, rowSize int, order binary.ByteOrder) {
+	if dataType == DataTypeUint8 {
+		for row := 0; row+rowSize <= len(chunk); row += rowSize {
+			for i := row + rowSize - 1; i > row; i-- {
+				chunk[i] -= chunk[i-1]
+			}
+		}
+		return
+	}
+	width := rowSize / 4
+	planes := make([]byte, rowSize)
+	for row := 0; row+rowSize <= len(chunk); row += rowSize {
+		for x := 0; x < width; x++ {
+			bits := order.Uint32(chunk[row+4*x:])
+			planes[x] = byte(bits >> 24)
+			planes[width+x] = byte(bits >> 16)
+			planes[2*width+x] = byte(bits >> 8)
+			planes[3*width+x] = byte(bits)
+		}
+		for i := rowSize - 1; i > 0; i-- {
+			planes[i] -= planes[i-1]
+		}
+		copy(chunk[row:row+rowSize], planes)
+	}
+}
+
+// predictorFor returns the predictor that fits the data type
+func predictorFor(dataType DataType) uint16 {
+	if dataType == DataTypeFloat32 {
+		return predictorFloat
+	}
+	return predictorHorizontal
+}
@@ -0,0 +1,374 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadFile reads the first image of a GeoTIFF
+func ReadFile(path string) (*Raster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(bytes.NewReader(data))
+}
+
+// Decode reads the first image of a GeoTIFF, it supports strips and tiles that are uncompressed, deflate, lzw or
+// packbits compressed, with or without a predictor
+func Decode(reader io.Reader) (*Raster, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	d, err := newDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.decode()
+}
+
+type decoder struct {
+	data    []byte
+	order   binary.ByteOrder
+	entries map[uint16]ifdEntry
+}
+
+func newDecoder(data []byte) (*decoder, error) {
+	if len(data) < tiffHeaderSize {
+		return nil, errors.New("the file is too small to be a tiff")
+	}
+	d := &decoder{data: data, entries: make(map[uint16]ifdEntry)}
+	switch string(data[:2]) {
+	case "II":
+		d.order = binary.LittleEndian
+	case "MM":
+		d.order = binary.BigEndian
+	default:
+		return nil, errors.New("the file is not a tiff")
+	}
+	switch d.order.Uint16(data[2:]) {
+	case tiffMagic:
+	case bigTIFFMagic:
+		return nil, errors.New("bigtiff files are not supported")
+	default:
+		return nil, errors.New("the file is not a tiff")
+	}
+	if err := d.readIFD(int64(d.order.Uint32(data[4:]))); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readIFD reads the entries of the image file directory at the offset, values that do not fit in the entry itself
+// are resolved so the entries can be used without knowing where they came from
+func (d *decoder) readIFD(offset int64) error {
+	if offset+2 > int64(len(d.data)) {
+		return errors.New("the image file directory is outside of the file")
+	}
+	count := int64(d.order.Uint16(d.data[offset:]))
+	if offset+2+count*ifdEntrySize > int64(len(d.data)) {
+		return errors.New("the image file directory is truncated")
+	}
+	for i := int64(0); i < count; i++ {
+		raw := d.data[offset+2+i*ifdEntrySize:]
+		tag := d.order.Uint16(raw)
+		entry := ifdEntry{dataType: d.order.Uint16(raw[2:]), count: d.order.Uint32(raw[4:])}
+		size, ok := dataTypeSizes[entry.dataType]
+		if !ok {
+			// Unknown types have to be skipped according to the spec
+			continue
+		}
+		length := int64(size) * int64(entry.count)
+		if length <= 4 {
+			entry.value = raw[8 : 8+length]
+		} else {
+			valueOffset := int64(d.order.Uint32(raw[8:]))
+			if valueOffset+length > int64(len(d.data)) {
+				return fmt.Errorf("the value of tag %d is outside of the file", tag)
+			}
+			entry.value = d.data[valueOffset : valueOffset+length]
+		}
+		d.entries[tag] = entry
+	}
+	return nil
+}
+
+func (d *decoder) uints(tag uint16) ([]uint64, error) {
+	entry, ok := d.entries[tag]
+	if !ok {
+		return nil, fmt.Errorf("the required tag %d is missing", tag)
+	}
+	return entry.uints(d.order)
+}
+
+// uint returns the first value of the tag or the default when the tag is missing
+func (d *decoder) uint(tag uint16, defaultValue uint64) (uint64, error) {
+	if _, ok := d.entries[tag]; !ok {
+		return defaultValue, nil
+	}
+	values, err := d.uints(tag)
+	if err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("tag %d has no value", tag)
+	}
+	// Tags like BitsPerSample have a value per sample, they are all equal for the images that are supported
+	return values[0], nil
+}
+
+func (d *decoder) floats(tag uint16) ([]float64, bool, error) {
+	entry, ok := d.entries[tag]
+	if !ok {
+		return nil, false, nil
+	}
+	values, err := entry.floats(d.order)
+	return values, true, err
+}
+
+func (d *decoder) decode() (*Raster, error) {
+	width, err := d.uint(tagImageWidth, 0)
+	if err != nil {
+		return nil, err
+	}
+	height, err := d.uint(tagImageLength, 0)
+	if err != nil {
+		return nil, err
+	}
+	if width == 0 || height == 0 || width*height > math.MaxInt32 {
+		return nil, fmt.Errorf("unsupported image size %dx%d", width, height)
+	}
+	dataType, err := d.dataType()
+	if err != nil {
+		return nil, err
+	}
+	r, err := New(int(width), int(height), dataType)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.readPixels(r); err != nil {
+		return nil, err
+	}
+	if r.GeoKeys, err = d.geoKeys(); err != nil {
+		return nil, err
+	}
+	if r.Transform, err = d.transform(r.GeoKeys); err != nil {
+		return nil, err
+	}
+	if entry, ok := d.entries[tagGDALNoData]; ok {
+		noData, err := strconv.ParseFloat(strings.TrimSpace(entry.ascii()), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodata value %q", entry.ascii())
+		}
+		r.SetNoData(noData)
+	}
+	return r, nil
+}
+
+func (d *decoder) dataType() (DataType, error) {
+	samplesPerPixel, err := d.uint(tagSamplesPerPixel, 1)
+	if err != nil {
+		return DataTypeUnspecified, err
+	}
+	if samplesPerPixel != 1 {
+		return DataTypeUnspecified, fmt.Errorf("only single band images are supported, got %d bands", samplesPerPixel)
+	}
+	bitsPerSample, err := d.uint(tagBitsPerSample, 1)
+	if err != nil {
+		return DataTypeUnspecified, err
+	}
+	sampleFormat, err := d.uint(tagSampleFormat, sampleFormatUint)
+	if err != nil {
+		return DataTypeUnspecified, err
+	}
+	switch {
+	case sampleFormat == sampleFormatUint && bitsPerSample == 8:
+		return DataTypeUint8, nil
+	case sampleFormat == sampleFormatFloat && bitsPerSample == 32:
+		return DataTypeFloat32, nil
+	default:
+		return DataTypeUnspecified, fmt.Errorf(
+			"unsupported sample format %d with %d bits per sample, only uint8 and float32 are supported",
+			sampleFormat,
+			bitsPerSample,
+		)
+	}
+}
+
+// readPixels decodes the strips or tiles into the raster, a strip is handled as a tile that is as wide as the image
+func (d *decoder) readPixels(r *Raster) error {
+	compression, err := d.uint(tagCompression, uint64(CompressionNone))
+	if err != nil {
+		return err
+	}
+	predictor, err := d.uint(tagPredictor, predictorNone)
+	if err != nil {
+		return err
+	}
+	chunkWidth, chunkHeight := uint64(r.Width), uint64(r.Height)
+	offsetsTag, byteCountsTag := tagStripOffsets, tagStripByteCounts
+	if _, tiled := d.entries[tagTileWidth]; tiled {
+		offsetsTag, byteCountsTag = tagTileOffsets, tagTileByteCounts
+		if chunkWidth, err = d.uint(tagTileWidth, 0); err != nil {
+			return err
+		}
+		if chunkHeight, err = d.uint(tagTileLength, 0); err != nil {
+			return err
+		}
+	} else if chunkHeight, err = d.uint(tagRowsPerStrip, uint64(r.Height)); err != nil {
+		return err
+	}
+	if chunkWidth == 0 || chunkHeight == 0 {
+		return errors.New("the strips or tiles have no size")
+	}
+	chunkHeight = min(chunkHeight, uint64(r.Height))
+	offsets, err := d.uints(offsetsTag)
+	if err != nil {
+		return err
+	}
+	byteCounts, err := d.uints(byteCountsTag)
+	if err != nil {
+		return err
+	}
+	across := (uint64(r.Width) + chunkWidth - 1) / chunkWidth
+	down := (uint64(r.Height) + chunkHeight - 1) / chunkHeight
+	if uint64(len(offsets)) < across*down || len(byteCounts) < len(offsets) {
+		return fmt.Errorf("expected %d strips or tiles, got %d", across*down, len(offsets))
+	}
+
+	bytesPerSample := r.DataType.bytesPerSample()
+	rowSize := int(chunkWidth) * bytesPerSample
+	for i := uint64(0); i < across*down; i++ {
+		start, end := offsets[i], offsets[i]+byteCounts[i]
+		if end > uint64(len(d.data)) {
+			return fmt.Errorf("strip or tile %d is outside of the file", i)
+		}
+		chunk, err := decompress(Compression(compression), d.data[start:end], rowSize*int(chunkHeight))
+		if err != nil {
+			return fmt.Errorf("strip or tile %d could not be decompressed: %w", i, err)
+		}
+		if err := undoPredictor(predictor, r.DataType, chunk, rowSize, d.order); err != nil {
+			return err
+		}
+		x0, y0 := int(i%across*chunkWidth), int(i/across*chunkHeight)
+		d.copyChunk(r, chunk, x0, y0, int(chunkWidth), int(chunkHeight))
+	}
+	return nil
+}
+
+// copyChunk copies the pixels of a decompressed chunk into the raster, the parts of edge tiles that fall outside of
+// the image are padding and are skipped
+func (d *decoder) copyChunk(r *Raster, chunk []byte, x0, y0, chunkWidth, chunkHeight int) {
+	width := min(chunkWidth, r.Width-x0)
+	height := min(chunkHeight, r.Height-y0)
+	for row := 0; row < height; row++ {
+		src := chunk[row*chunkWidth*r.DataType.bytesPerSample():]
+		dst := (y0+row)*r.Width + x0
+		if r.DataType == DataTypeUint8 {
+			copy(r.uint8s[dst:dst+width], src)
+			continue
+		}
+		for col := 0; col < width; col++ {
+			r.float32s[dst+col] = math.Float32frombits(d.order.Uint32(src[4*col:]))
+		}
+	}
+}
+
+func (d *decoder) geoKeys() (GeoKeys, error) {
+	entry, ok := d.entries[tagGeoKeyDirectory]
+	if !ok {
+		return nil, nil
+	}
+	directory, err := entry.uints(d.order)
+	if err != nil {
+		return nil, err
+	}
+	if len(directory) < 4 {
+		return nil, errors.New("the geokey directory is truncated")
+	}
+	doubles, _, err := d.floats(tagGeoDoubleParams)
+	if err != nil {
+		return nil, err
+	}
+	ascii := d.entries[tagGeoASCIIParams].ascii()
+
+	keys := make(GeoKeys)
+	count := int(directory[3])
+	if len(directory) < 4+4*count {
+		return nil, errors.New("the geokey directory is truncated")
+	}
+	for i := 0; i < count; i++ {
+		key := directory[4+4*i : 8+4*i]
+		id, location, valueCount, valueOffset := uint16(key[0]), uint16(key[1]), int(key[2]), int(key[3])
+		switch location {
+		case 0:
+			keys[id] = GeoKeyValue{Shorts: []uint16{uint16(valueOffset)}}
+		case tagGeoKeyDirectory:
+			if valueOffset+valueCount > len(directory) {
+				return nil, fmt.Errorf("the value of geokey %d is outside of the directory", id)
+			}
+			shorts := make([]uint16, valueCount)
+			for j := range shorts {
+				shorts[j] = uint16(directory[valueOffset+j])
+			}
+			keys[id] = GeoKeyValue{Shorts: shorts}
+		case tagGeoDoubleParams:
+			if valueOffset+valueCount > len(doubles) {
+				return nil, fmt.Errorf("the value of geokey %d is outside of the double params", id)
+			}
+			keys[id] = GeoKeyValue{Doubles: append([]float64(nil), doubles[valueOffset:valueOffset+valueCount]...)}
+		case tagGeoASCIIParams:
+			if valueOffset+valueCount > len(ascii) {
+				return nil, fmt.Errorf("the value of geokey %d is outside of the ascii params", id)
+			}
+			// The strings in the ascii params are terminated by a |
+			keys[id] = GeoKeyValue{ASCII: strings.TrimSuffix(ascii[valueOffset:valueOffset+valueCount], "|")}
+		}
+	}
+	return keys, nil
+}
+
+// transform builds the geo transform from either the model transformation or the tiepoint and pixel scale, like
+// GDAL the origin is moved to the corner of the first pixel when the coordinates point to the pixel centers
+func (d *decoder) transform(keys GeoKeys) (GeoTransform, error) {
+	gt := GeoTransform{0, 1, 0, 0, 0, 1}
+	if matrix, ok, err := d.floats(tagModelTransformation); err != nil {
+		return gt, err
+	} else if ok {
+		if len(matrix) != 16 {
+			return gt, errors.New("the model transformation should have 16 values")
+		}
+		gt = GeoTransform{matrix[3], matrix[0], matrix[1], matrix[7], matrix[4], matrix[5]}
+	} else {
+		tiepoint, hasTiepoint, err := d.floats(tagModelTiepoint)
+		if err != nil {
+			return gt, err
+		}
+		scale, hasScale, err := d.floats(tagModelPixelScale)
+		if err != nil {
+			return gt, err
+		}
+		if !hasTiepoint || !hasScale {
+			return gt, nil
+		}
+		if len(tiepoint) < 6 || len(scale) < 2 {
+			return gt, errors.New("the tiepoint or pixel scale is truncated")
+		}
+		originX := tiepoint[3] - tiepoint[0]*scale[0]
+		originY := tiepoint[4] + tiepoint[1]*scale[1]
+		gt = GeoTransform{originX, scale[0], 0, originY, 0, -scale[1]}
+	}
+	if keys.RasterType() == RasterTypePixelIsPoint {
+		gt[0] -= 0.5*gt[1] + 0.5*gt[2]
+		gt[3] -= 0.5*gt[4] + 0.5*gt[5]
+	}
+	return gt, nil
+}
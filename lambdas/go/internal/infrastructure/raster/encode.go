@@ -0,0 +1,359 @@
+package raster
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// EncodeOptions configures how a raster is written, the zero value writes uncompressed strips like rasterio does
+type EncodeOptions struct {
+	// Compression is either CompressionNone or CompressionDeflate
+	Compression Compression
+	// Predictor improves the compression of smooth rasters, it is only used with deflate
+	Predictor bool
+	// TileSize writes square tiles of this size instead of strips, it has to be a multiple of 16
+	TileSize int
+}
+
+// defaultStripSize is roughly the size of each strip, GDAL uses the same size
+const defaultStripSize = 8192
+
+// WriteFile writes the raster as a GeoTIFF, opts can be nil
+func WriteFile(path string, r *Raster, opts *EncodeOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := Encode(w, r, opts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Encode writes the raster as a little endian GeoTIFF, opts can be nil
+func Encode(w io.Writer, r *Raster, opts *EncodeOptions) error {
+	e := encoder{order: binary.LittleEndian}
+	if opts != nil {
+		e.opts = *opts
+	}
+	return e.encode(w, r)
+}
+
+type encoder struct {
+	order binary.ByteOrder
+	opts  EncodeOptions
+}
+
+// tagValue is a tag that is written to the image file directory
+type tagValue struct {
+	tag      uint16
+	dataType uint16
+	count    uint32
+	value    []byte
+}
+
+func (e encoder) encode(w io.Writer, r *Raster) error {
+	if err := e.validate(r); err != nil {
+		return err
+	}
+	compression := e.opts.Compression
+	if compression == 0 {
+		compression = CompressionNone
+	}
+	chunks, chunkWidth, chunkHeight, err := e.chunks(r, compression)
+	if err != nil {
+		return err
+	}
+
+	tags := e.tags(r, compression, chunkWidth, chunkHeight)
+	offsetsTag, byteCountsTag := tagStripOffsets, tagStripByteCounts
+	if e.opts.TileSize > 0 {
+		offsetsTag, byteCountsTag = tagTileOffsets, tagTileByteCounts
+	}
+	byteCounts := make([]uint32, len(chunks))
+	for i, chunk := range chunks {
+		byteCounts[i] = uint32(len(chunk))
+	}
+	tags = append(tags, e.longs(byteCountsTag, byteCounts))
+	// The offsets depend on the size of the directory, a placeholder with the final size is replaced below
+	tags = append(tags, e.longs(offsetsTag, make([]uint32, len(chunks))))
+	sort.Slice(tags, func(i, j int) bool { return tags[i].tag < tags[j].tag })
+
+	ifdSize := 2 + len(tags)*ifdEntrySize + 4
+	valuesSize := 0
+	for _, t := range tags {
+		if len(t.value) > 4 {
+			valuesSize += len(t.value) + len(t.value)%2
+		}
+	}
+	dataOffset := tiffHeaderSize + ifdSize + valuesSize
+	offsets := make([]uint32, len(chunks))
+	for i, chunk := range chunks {
+		if uint64(dataOffset)+uint64(len(chunk)) > math.MaxUint32 {
+			return errors.New("the raster is too large for a tiff, bigtiff is not supported")
+		}
+		offsets[i] = uint32(dataOffset)
+		dataOffset += len(chunk)
+	}
+	for i := range tags {
+		if tags[i].tag == offsetsTag {
+			tags[i] = e.longs(offsetsTag, offsets)
+		}
+	}
+
+	header := make([]byte, tiffHeaderSize)
+	if e.order == binary.BigEndian {
+		copy(header, "MM")
+	} else {
+		copy(header, "II")
+	}
+	e.order.PutUint16(header[2:], tiffMagic)
+	e.order.PutUint32(header[4:], tiffHeaderSize)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(e.ifd(tags, tiffHeaderSize+ifdSize)); err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if len(t.value) <= 4 {
+			continue
+		}
+		if _, err := w.Write(t.value); err != nil {
+			return err
+		}
+		// Values have to start on a word boundary
+		if len(t.value)%2 == 1 {
+			if _, err := w.Write([]byte{0}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, chunk := range chunks {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e encoder) validate(r *Raster) error {
+	if r == nil || r.Width <= 0 || r.Height <= 0 {
+		return errors.New("the raster is empty")
+	}
+	switch {
+	case r.DataType == DataTypeUint8 && len(r.uint8s) == r.Width*r.Height:
+	case r.DataType == DataTypeFloat32 && len(r.float32s) == r.Width*r.Height:
+	default:
+		return fmt.Errorf("the %s raster does not have %dx%d pixels", r.DataType, r.Width, r.Height)
+	}
+	if e.opts.TileSize < 0 || e.opts.TileSize%16 != 0 {
+		return fmt.Errorf("the tile size %d is not a multiple of 16", e.opts.TileSize)
+	}
+	if e.opts.Predictor && e.opts.Compression != CompressionDeflate {
+		return errors.New("a predictor can only be used with deflate compression")
+	}
+	return nil
+}
+
+// chunks splits the pixels in compressed strips or tiles, edge tiles are padded with zeros
+func (e encoder) chunks(r *Raster, compression Compression) ([][]byte, int, int, error) {
+	bytesPerSample := r.DataType.bytesPerSample()
+	chunkWidth, chunkHeight := r.Width, max(1, min(r.Height, defaultStripSize/(r.Width*bytesPerSample)))
+	if e.opts.TileSize > 0 {
+		chunkWidth, chunkHeight = e.opts.TileSize, e.opts.TileSize
+	}
+	rowSize := chunkWidth * bytesPerSample
+	var chunks [][]byte
+	for y0 := 0; y0 < r.Height; y0 += chunkHeight {
+		for x0 := 0; x0 < r.Width; x0 += chunkWidth {
+			height := chunkHeight
+			if e.opts.TileSize == 0 {
+				// Only the last strip is allowed to be shorter
+				height = min(chunkHeight, r.Height-y0)
+			}
+			chunk := make([]byte, rowSize*height)
+			for row := 0; row < height && y0+row < r.Height; row++ {
+				e.putRow(r, chunk[row*rowSize:], x0, y0+row, min(chunkWidth, r.Width-x0))
+			}
+			if e.opts.Predictor {
+				applyPredictor(r.DataType, chunk, rowSize, e.order)
+			}
+			compressed, err := compress(compression, chunk)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			chunks = append(chunks, compressed)
+		}
+	}
+	return chunks, chunkWidth, chunkHeight, nil
+}
+
+func (e encoder) putRow(r *Raster, dst []byte, x0, y, width int) {
+	start := y*r.Width + x0
+	if r.DataType == DataTypeUint8 {
+		copy(dst, r.uint8s[start:start+width])
+		return
+	}
+	for i, v := range r.float32s[start : start+width] {
+		e.order.PutUint32(dst[4*i:], math.Float32bits(v))
+	}
+}
+
+func (e encoder) tags(r *Raster, compression Compression, chunkWidth, chunkHeight int) []tagValue {
+	bitsPerSample, sampleFormat := uint16(8), uint16(sampleFormatUint)
+	if r.DataType == DataTypeFloat32 {
+		bitsPerSample, sampleFormat = 32, sampleFormatFloat
+	}
+	tags := []tagValue{
+		e.longs(tagImageWidth, []uint32{uint32(r.Width)}),
+		e.longs(tagImageLength, []uint32{uint32(r.Height)}),
+		e.shorts(tagBitsPerSample, []uint16{bitsPerSample}),
+		e.shorts(tagCompression, []uint16{uint16(compression)}),
+		e.shorts(tagPhotometric, []uint16{photometricMinZero}),
+		e.shorts(tagSamplesPerPixel, []uint16{1}),
+		e.shorts(tagPlanarConfiguration, []uint16{planarContiguous}),
+		e.shorts(tagSampleFormat, []uint16{sampleFormat}),
+	}
+	if e.opts.TileSize > 0 {
+		tags = append(tags,
+			e.longs(tagTileWidth, []uint32{uint32(chunkWidth)}),
+			e.longs(tagTileLength, []uint32{uint32(chunkHeight)}),
+		)
+	} else {
+		tags = append(tags, e.longs(tagRowsPerStrip, []uint32{uint32(chunkHeight)}))
+	}
+	if e.opts.Predictor {
+		tags = append(tags, e.shorts(tagPredictor, []uint16{predictorFor(r.DataType)}))
+	}
+	tags = append(tags, e.geoTags(r)...)
+	if r.NoData != nil {
+		tags = append(tags, e.ascii(tagGDALNoData, strconv.FormatFloat(*r.NoData, 'g', -1, 64)))
+	}
+	return tags
+}
+
+// geoTags writes the transform as a tiepoint and pixel scale when the raster is north up, which is what most readers
+// expect, and as a model transformation otherwise
+func (e encoder) geoTags(r *Raster) []tagValue {
+	gt := r.Transform
+	if r.GeoKeys.RasterType() == RasterTypePixelIsPoint {
+		gt[0] += 0.5*gt[1] + 0.5*gt[2]
+		gt[3] += 0.5*gt[4] + 0.5*gt[5]
+	}
+	var tags []tagValue
+	if gt[2] == 0 && gt[4] == 0 {
+		tags = append(tags,
+			e.doubles(tagModelPixelScale, []float64{gt[1], -gt[5], 0}),
+			e.doubles(tagModelTiepoint, []float64{0, 0, 0, gt[0], gt[3], 0}),
+		)
+	} else {
+		tags = append(tags, e.doubles(tagModelTransformation, []float64{
+			gt[1], gt[2], 0, gt[0],
+			gt[4], gt[5], 0, gt[3],
+			0, 0, 0, 0,
+			0, 0, 0, 1,
+		}))
+	}
+	if len(r.GeoKeys) == 0 {
+		return tags
+	}
+
+	var keys, values []uint16
+	var doubles []float64
+	var ascii string
+	ids := r.GeoKeys.sortedIDs()
+	for _, id := range ids {
+		value := r.GeoKeys[id]
+		switch {
+		case len(value.Doubles) > 0:
+			keys = append(keys, id, tagGeoDoubleParams, uint16(len(value.Doubles)), uint16(len(doubles)))
+			doubles = append(doubles, value.Doubles...)
+		case len(value.Shorts) == 1:
+			keys = append(keys, id, 0, 1, value.Shorts[0])
+		case len(value.Shorts) > 1:
+			// The offset is relative to the start of the directory, it is fixed once the size of the keys is known
+			keys = append(keys, id, tagGeoKeyDirectory, uint16(len(value.Shorts)), uint16(len(values)))
+			values = append(values, value.Shorts...)
+		default:
+			keys = append(keys, id, tagGeoASCIIParams, uint16(len(value.ASCII)+1), uint16(len(ascii)))
+			ascii += value.ASCII + "|"
+		}
+	}
+	for i := 0; i < len(keys); i += 4 {
+		if keys[i+1] == tagGeoKeyDirectory {
+			keys[i+3] += uint16(4 + len(keys))
+		}
+	}
+	directory := append([]uint16{1, 1, 0, uint16(len(ids))}, keys...)
+	tags = append(tags, e.shorts(tagGeoKeyDirectory, append(directory, values...)))
+	if len(doubles) > 0 {
+		tags = append(tags, e.doubles(tagGeoDoubleParams, doubles))
+	}
+	if ascii != "" {
+		tags = append(tags, e.ascii(tagGeoASCIIParams, ascii))
+	}
+	return tags
+}
+
+// ifd encodes the directory, the values that do not fit in an entry are written right after it at valuesOffset
+func (e encoder) ifd(tags []tagValue, valuesOffset int) []byte {
+	ifd := make([]byte, 2+len(tags)*ifdEntrySize+4)
+	e.order.PutUint16(ifd, uint16(len(tags)))
+	for i, t := range tags {
+		entry := ifd[2+i*ifdEntrySize:]
+		e.order.PutUint16(entry, t.tag)
+		e.order.PutUint16(entry[2:], t.dataType)
+		e.order.PutUint32(entry[4:], t.count)
+		if len(t.value) <= 4 {
+			copy(entry[8:12], t.value)
+			continue
+		}
+		e.order.PutUint32(entry[8:], uint32(valuesOffset))
+		valuesOffset += len(t.value) + len(t.value)%2
+	}
+	// The offset of the next directory is 0 since only a single image is written
+	return ifd
+}
+
+func (e encoder) shorts(tag uint16, values []uint16) tagValue {
+	value := make([]byte, 2*len(values))
+	for i, v := range values {
+		e.order.PutUint16(value[2*i:], v)
+	}
+	return tagValue{tag: tag, dataType: dataTypeShort, count: uint32(len(values)), value: value}
+}
+
+func (e encoder) longs(tag uint16, values []uint32) tagValue {
+	value := make([]byte, 4*len(values))
+	for i, v := range values {
+		e.order.PutUint32(value[4*i:], v)
+	}
+	return tagValue{tag: tag, dataType: dataTypeLong, count: uint32(len(values)), value: value}
+}
+
+func (e encoder) doubles(tag uint16, values []float64) tagValue {
+	value := make([]byte, 8*len(values))
+	for i, v := range values {
+		e.order.PutUint64(value[8*i:], math.Float64bits(v))
+	}
+	return tagValue{tag: tag, dataType: dataTypeDouble, count: uint32(len(values)), value: value}
+}
+
+func (e encoder) ascii(tag uint16, s string) tagValue {
+	value := append([]byte(s), 0)
+	return tagValue{tag: tag, dataType: dataTypeASCII, count: uint32(len(value)), value: value}
+}
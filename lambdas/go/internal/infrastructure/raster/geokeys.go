@@ -0,0 +1,85 @@
+package raster
+
+import "sort"
+
+// The geokeys needed to describe the coordinate reference systems of the maps, any other key is kept as is when a
+// raster is read and written again
+const (
+	GeoKeyModelType        uint16 = 1024
+	GeoKeyRasterType       uint16 = 1025
+	GeoKeyCitation         uint16 = 1026
+	GeoKeyGeographicType   uint16 = 2048
+	GeoKeyGeogCitation     uint16 = 2049
+	GeoKeyGeogAngularUnits uint16 = 2054
+	GeoKeyProjectedCSType  uint16 = 3072
+	GeoKeyProjLinearUnits  uint16 = 3076
+)
+
+const (
+	ModelTypeProjected     uint16 = 1
+	ModelTypeGeographic    uint16 = 2
+	RasterTypePixelIsArea  uint16 = 1
+	RasterTypePixelIsPoint uint16 = 2
+	EPSGWGS84              uint16 = 4326
+)
+
+const (
+	userDefinedGeoKeyValue uint16 = 32767
+	angularUnitDegree      uint16 = 9102
+)
+
+// GeoKeyValue holds the value of a single geokey, only one of the fields is set
+type GeoKeyValue struct {
+	Shorts  []uint16
+	Doubles []float64
+	ASCII   string
+}
+
+// GeoKeys are the keys of the GeoKeyDirectory, they describe the coordinate reference system of the raster
+type GeoKeys map[uint16]GeoKeyValue
+
+// NewGeographicGeoKeys describes a raster in a geographic coordinate system, e.g. EPSGWGS84 which is what the
+// eogdata maps use
+func NewGeographicGeoKeys(epsg uint16) GeoKeys {
+	return GeoKeys{
+		GeoKeyModelType:        {Shorts: []uint16{ModelTypeGeographic}},
+		GeoKeyRasterType:       {Shorts: []uint16{RasterTypePixelIsArea}},
+		GeoKeyGeographicType:   {Shorts: []uint16{epsg}},
+		GeoKeyGeogAngularUnits: {Shorts: []uint16{angularUnitDegree}},
+	}
+}
+
+// EPSG returns the code of the projected or geographic coordinate system, 0 when it is unknown or user defined
+func (k GeoKeys) EPSG() int {
+	for _, key := range []uint16{GeoKeyProjectedCSType, GeoKeyGeographicType} {
+		if code := k.short(key); code != 0 && code != userDefinedGeoKeyValue {
+			return int(code)
+		}
+	}
+	return 0
+}
+
+// RasterType returns RasterTypePixelIsArea unless the file says the coordinates point to the pixel centers
+func (k GeoKeys) RasterType() uint16 {
+	if k.short(GeoKeyRasterType) == RasterTypePixelIsPoint {
+		return RasterTypePixelIsPoint
+	}
+	return RasterTypePixelIsArea
+}
+
+func (k GeoKeys) short(key uint16) uint16 {
+	value, ok := k[key]
+	if !ok || len(value.Shorts) != 1 {
+		return 0
+	}
+	return value.Shorts[0]
+}
+
+func (k GeoKeys) sortedIDs() []uint16 {
+	ids := make([]uint16, 0, len(k))
+	for id := range k {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,97 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PixelsInPolygons returns the indices, row by row, of the pixels whose center lies inside any of the polygons. The
+// polygons follow the GeoJSON MultiPolygon convention, i.e. an outer ring followed by its holes, in the coordinates
+// of the raster
+func (r *Raster) PixelsInPolygons(multiPolygon [][][][2]float64) ([]int, error) {
+	gt := r.Transform
+	if gt[2] != 0 || gt[4] != 0 {
+		return nil, errors.New("polygons can only be masked on rasters that are not rotated")
+	}
+	if gt[1] == 0 || gt[5] == 0 {
+		return nil, errors.New("the raster has no pixel size")
+	}
+	if epsg := r.GeoKeys.EPSG(); epsg != 0 && epsg != int(EPSGWGS84) {
+		return nil, fmt.Errorf("polygons are in WGS84 while the raster uses EPSG:%d", epsg)
+	}
+
+	// Every polygon is scanned on its own and the resulting column ranges are merged per row, so overlapping
+	// polygons do not count a pixel twice
+	rows := make(map[int][][2]int)
+	for _, polygon := range multiPolygon {
+		var edges [][2][2]float64
+		minY, maxY := math.Inf(1), math.Inf(-1)
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				from := [2]float64{(ring[i][0] - gt[0]) / gt[1], (ring[i][1] - gt[3]) / gt[5]}
+				to := [2]float64{(ring[i+1][0] - gt[0]) / gt[1], (ring[i+1][1] - gt[3]) / gt[5]}
+				edges = append(edges, [2][2]float64{from, to})
+				minY, maxY = math.Min(minY, math.Min(from[1], to[1])), math.Max(maxY, math.Max(from[1], to[1]))
+			}
+		}
+		firstRow := max(0, int(math.Floor(minY)))
+		lastRow := min(r.Height-1, int(math.Ceil(maxY)))
+		for row := firstRow; row <= lastRow; row++ {
+			rows[row] = append(rows[row], r.scanRow(edges, row)...)
+		}
+	}
+
+	var indices []int
+	for row := 0; row < r.Height; row++ {
+		for _, columns := range mergeRanges(rows[row]) {
+			for col := columns[0]; col < columns[1]; col++ {
+				indices = append(indices, row*r.Width+col)
+			}
+		}
+	}
+	return indices, nil
+}
+
+// scanRow returns the column ranges, end exclusive, whose pixel centers are inside the polygon on the row, a pixel
+// is inside when a ray from its center crosses the edges an odd number of times
+func (r *Raster) scanRow(edges [][2][2]float64, row int) [][2]int {
+	center := float64(row) + 0.5
+	var crossings []float64
+	for _, edge := range edges {
+		from, to := edge[0], edge[1]
+		if (from[1] <= center) == (to[1] <= center) {
+			continue
+		}
+		crossings = append(crossings, from[0]+(center-from[1])*(to[0]-from[0])/(to[1]-from[1]))
+	}
+	sort.Float64s(crossings)
+	var ranges [][2]int
+	for i := 0; i+1 < len(crossings); i += 2 {
+		// The columns whose center, col + 0.5, lies between the two crossings
+		start := max(0, int(math.Ceil(crossings[i]-0.5)))
+		end := min(r.Width, int(math.Ceil(crossings[i+1]-0.5)))
+		if start < end {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+	return ranges
+}
+
+func mergeRanges(ranges [][2]int) [][2]int {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := [][2]int{ranges[0]}
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next[0] <= last[1] {
+			last[1] = max(last[1], next[1])
+			continue
+		}
+		merged = append(merged, next)
+	}
+	return merged
+}
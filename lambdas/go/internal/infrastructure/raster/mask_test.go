@@ -0,0 +1,57 @@
+package raster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// square returns a closed ring from the bottom left to the top right corner
+func square(minLon, minLat, maxLon, maxLat float64) [][2]float64 {
+	return [][2]float64{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat}}
+}
+
+func TestRaster_PixelsInPolygons(t *testing.T) {
+	r, err := New(10, 10, DataTypeUint8)
+	require.NoError(t, err)
+	r.Transform = GeoTransform{0, 1, 0, 10, 0, -1}
+	r.GeoKeys = NewGeographicGeoKeys(EPSGWGS84)
+
+	t.Run("square", func(t *testing.T) {
+		indices, err := r.PixelsInPolygons([][][][2]float64{{square(2, 3, 5, 7)}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{32, 33, 34, 42, 43, 44, 52, 53, 54, 62, 63, 64}, indices)
+	})
+
+	t.Run("hole", func(t *testing.T) {
+		indices, err := r.PixelsInPolygons([][][][2]float64{{square(0, 7, 3, 10), square(1, 8, 2, 9)}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 2, 10, 12, 20, 21, 22}, indices)
+	})
+
+	t.Run("overlapping polygons count a pixel once", func(t *testing.T) {
+		indices, err := r.PixelsInPolygons([][][][2]float64{{square(0, 9, 2, 10)}, {square(1, 9, 3, 10)}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 2}, indices)
+	})
+
+	t.Run("outside of the raster", func(t *testing.T) {
+		indices, err := r.PixelsInPolygons([][][][2]float64{{square(-5, 9, 1, 15)}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, indices)
+	})
+
+	t.Run("rotated raster", func(t *testing.T) {
+		rotated := *r
+		rotated.Transform = GeoTransform{0, 1, 0.1, 10, 0, -1}
+
+		_, err := rotated.PixelsInPolygons([][][][2]float64{{square(2, 3, 5, 7)}})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+// Package raster reads and writes single band GeoTIFFs, e.g. the processed maps, so they can be inspected without
+// the python lambda
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+type DataType int
+
+const (
+	DataTypeUnspecified DataType = iota
+	DataTypeUint8
+	DataTypeFloat32
+)
+
+func (t DataType) String() string {
+	switch t {
+	case DataTypeUint8:
+		return "uint8"
+	case DataTypeFloat32:
+		return "float32"
+	default:
+		return "unspecified"
+	}
+}
+
+func (t DataType) bytesPerSample() int {
+	switch t {
+	case DataTypeUint8:
+		return 1
+	case DataTypeFloat32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// GeoTransform maps pixel to model coordinates in the same order as GDAL, i.e. origin x, pixel width, row rotation,
+// origin y, column rotation and pixel height (negative for north up rasters). The origin is the corner of the top
+// left pixel
+type GeoTransform [6]float64
+
+// PixelToGeo returns the model coordinates of a position in the raster, use x+0.5 and y+0.5 for a pixel center
+func (gt GeoTransform) PixelToGeo(x, y float64) (float64, float64) {
+	return gt[0] + x*gt[1] + y*gt[2], gt[3] + x*gt[4] + y*gt[5]
+}
+
+// GeoToPixel is the inverse of PixelToGeo, it fails when the transform can not be inverted
+func (gt GeoTransform) GeoToPixel(geoX, geoY float64) (float64, float64, error) {
+	det := gt[1]*gt[5] - gt[2]*gt[4]
+	if det == 0 {
+		return 0, 0, errors.New("the geo transform can not be inverted")
+	}
+	dx, dy := geoX-gt[0], geoY-gt[3]
+	return (dx*gt[5] - dy*gt[2]) / det, (dy*gt[1] - dx*gt[4]) / det, nil
+}
+
+// Raster is a single band image, the pixels are stored row by row in the type of the file so an uint8 map is not
+// blown up to floats in memory
+type Raster struct {
+	Width     int
+	Height    int
+	DataType  DataType
+	Transform GeoTransform
+	GeoKeys   GeoKeys
+	// NoData is the value of the pixels without data, nil when every pixel is valid
+	NoData *float64
+
+	uint8s   []uint8
+	float32s []float32
+}
+
+func New(width, height int, dataType DataType) (*Raster, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid raster size %dx%d", width, height)
+	}
+	r := &Raster{Width: width, Height: height, DataType: dataType, Transform: GeoTransform{0, 1, 0, 0, 0, 1}}
+	switch dataType {
+	case DataTypeUint8:
+		r.uint8s = make([]uint8, width*height)
+	case DataTypeFloat32:
+		r.float32s = make([]float32, width*height)
+	default:
+		return nil, fmt.Errorf("unsupported data type: %s", dataType)
+	}
+	return r, nil
+}
+
+// At returns the value of the pixel in column x and row y
+func (r *Raster) At(x, y int) float64 {
+	i := y*r.Width + x
+	if r.DataType == DataTypeUint8 {
+		return float64(r.uint8s[i])
+	}
+	return float64(r.float32s[i])
+}
+
+// Set stores the value in the pixel, values are truncated to the data type of the raster
+func (r *Raster) Set(x, y int, v float64) {
+	i := y*r.Width + x
+	if r.DataType == DataTypeUint8 {
+		r.uint8s[i] = uint8(math.Max(0, math.Min(math.MaxUint8, v)))
+		return
+	}
+	r.float32s[i] = float32(v)
+}
+
+// Uint8s are the pixels of an uint8 raster row by row, the slice is shared with the raster
+func (r *Raster) Uint8s() []uint8 {
+	return r.uint8s
+}
+
+// Float32s are the pixels of a float32 raster row by row, the slice is shared with the raster
+func (r *Raster) Float32s() []float32 {
+	return r.float32s
+}
+
+// IsNoData returns true when the value marks a pixel without data, NaN is always treated as no data
+func (r *Raster) IsNoData(v float64) bool {
+	if math.IsNaN(v) {
+		return true
+	}
+	if r.NoData == nil {
+		return false
+	}
+	// The value is compared as stored in the file, e.g. a nodata of 0.1 for a float32 raster
+	if r.DataType == DataTypeFloat32 {
+		return float32(v) == float32(*r.NoData)
+	}
+	return v == *r.NoData
+}
+
+// SetNoData sets the nodata value, NaN is allowed for float32 rasters
+func (r *Raster) SetNoData(v float64) {
+	r.NoData = &v
+}
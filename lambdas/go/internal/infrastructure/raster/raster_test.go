@@ -0,0 +1,261 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRaster(t *testing.T, width, height int, dataType DataType) *Raster {
+	r, err := New(width, height, dataType)
+	require.NoError(t, err)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r.Set(x, y, float64((x*7+y*13)%251)+0.25)
+		}
+	}
+	r.Transform = GeoTransform{22.08, 0.004166666666666667, 0, 52.38, 0, -0.004166666666666667}
+	r.GeoKeys = NewGeographicGeoKeys(EPSGWGS84)
+	return r
+}
+
+func assertSameRaster(t *testing.T, expected, actual *Raster) {
+	require.Equal(t, expected.Width, actual.Width)
+	require.Equal(t, expected.Height, actual.Height)
+	assert.Equal(t, expected.DataType, actual.DataType)
+	assert.Equal(t, expected.Transform, actual.Transform)
+	assert.Equal(t, expected.GeoKeys, actual.GeoKeys)
+	assert.Equal(t, expected.NoData, actual.NoData)
+	assert.Equal(t, expected.Uint8s(), actual.Uint8s())
+	assert.Equal(t, expected.Float32s(), actual.Float32s())
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType DataType
+		order    binary.ByteOrder
+		opts     EncodeOptions
+	}{
+		{name: "uint8 strips", dataType: DataTypeUint8, order: binary.LittleEndian},
+		{name: "float32 strips", dataType: DataTypeFloat32, order: binary.LittleEndian},
+		{
+			name:     "uint8 deflate tiles with predictor",
+			dataType: DataTypeUint8,
+			order:    binary.LittleEndian,
+			opts:     EncodeOptions{Compression: CompressionDeflate, Predictor: true, TileSize: 16},
+		},
+		{
+			name:     "float32 big endian deflate tiles with predictor",
+			dataType: DataTypeFloat32,
+			order:    binary.BigEndian,
+			opts:     EncodeOptions{Compression: CompressionDeflate, Predictor: true, TileSize: 32},
+		},
+		{
+			name:     "float32 big endian deflate strips",
+			dataType: DataTypeFloat32,
+			order:    binary.BigEndian,
+			opts:     EncodeOptions{Compression: CompressionDeflate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The size is not a multiple of the tile size and needs more than one strip
+			expected := newTestRaster(t, 75, 130, tt.dataType)
+			expected.SetNoData(0)
+
+			var buf bytes.Buffer
+			require.NoError(t, encoder{order: tt.order, opts: tt.opts}.encode(&buf, expected))
+			actual, err := Decode(&buf)
+
+			require.NoError(t, err)
+			assertSameRaster(t, expected, actual)
+		})
+	}
+}
+
+func TestWriteFileReadFile(t *testing.T) {
+	expected := newTestRaster(t, 40, 20, DataTypeFloat32)
+	expected.SetNoData(math.NaN())
+	expected.GeoKeys[GeoKeyGeogCitation] = GeoKeyValue{ASCII: "WGS 84"}
+	expected.GeoKeys[GeoKeyCitation] = GeoKeyValue{ASCII: "processed by conflict nightlight"}
+	expected.GeoKeys[4097] = GeoKeyValue{Doubles: []float64{1.5, 2.5}}
+	expected.GeoKeys[4098] = GeoKeyValue{Shorts: []uint16{3, 4}}
+	path := filepath.Join(t.TempDir(), "map.tif")
+
+	require.NoError(t, WriteFile(path, expected, &EncodeOptions{Compression: CompressionDeflate}))
+	actual, err := ReadFile(path)
+
+	require.NoError(t, err)
+	require.NotNil(t, actual.NoData)
+	assert.True(t, math.IsNaN(*actual.NoData))
+	actual.NoData = expected.NoData
+	assertSameRaster(t, expected, actual)
+	assert.Equal(t, 4326, actual.GeoKeys.EPSG())
+}
+
+func TestEncodeDecode_Transform(t *testing.T) {
+	tests := []struct {
+		name       string
+		transform  GeoTransform
+		rasterType uint16
+	}{
+		{name: "north up", transform: GeoTransform{30, 0.5, 0, 50, 0, -0.5}, rasterType: RasterTypePixelIsArea},
+		{name: "pixel is point", transform: GeoTransform{30, 0.5, 0, 50, 0, -0.5}, rasterType: RasterTypePixelIsPoint},
+		{name: "rotated", transform: GeoTransform{30, 0.5, 0.1, 50, 0.2, -0.5}, rasterType: RasterTypePixelIsArea},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := newTestRaster(t, 4, 4, DataTypeUint8)
+			expected.Transform = tt.transform
+			expected.GeoKeys[GeoKeyRasterType] = GeoKeyValue{Shorts: []uint16{tt.rasterType}}
+
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, expected, nil))
+			actual, err := Decode(&buf)
+
+			require.NoError(t, err)
+			for i := range expected.Transform {
+				assert.InDelta(t, expected.Transform[i], actual.Transform[i], 1e-9)
+			}
+		})
+	}
+}
+
+func TestGeoTransform_GeoToPixel(t *testing.T) {
+	gt := GeoTransform{30, 0.5, 0.1, 50, 0.2, -0.5}
+	geoX, geoY := gt.PixelToGeo(3.5, 7.25)
+
+	x, y, err := gt.GeoToPixel(geoX, geoY)
+
+	require.NoError(t, err)
+	assert.InDelta(t, 3.5, x, 1e-9)
+	assert.InDelta(t, 7.25, y, 1e-9)
+	_, _, err = GeoTransform{}.GeoToPixel(1, 1)
+	assert.Error(t, err)
+}
+
+func TestRaster_IsNoData(t *testing.T) {
+	r := newTestRaster(t, 1, 1, DataTypeFloat32)
+	assert.False(t, r.IsNoData(0))
+	assert.True(t, r.IsNoData(math.NaN()))
+
+	r.SetNoData(0.1)
+	assert.True(t, r.IsNoData(float64(float32(0.1))))
+	assert.False(t, r.IsNoData(0.2))
+}
+
+func TestDecode_Errors(t *testing.T) {
+	_, err := Decode(bytes.NewReader([]byte("not a tiff at all")))
+	assert.Error(t, err)
+
+	_, err = Decode(bytes.NewReader([]byte{'I', 'I', 43, 0, 8, 0, 0, 0}))
+	assert.ErrorContains(t, err, "bigtiff")
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, newTestRaster(t, 2, 2, DataTypeUint8), nil))
+	data := buf.Bytes()
+	// Turn the SamplesPerPixel of the image into 3
+	e := encoder{order: binary.LittleEndian}
+	for i := 0; i < int(binary.LittleEndian.Uint16(data[tiffHeaderSize:])); i++ {
+		entry := data[tiffHeaderSize+2+i*ifdEntrySize:]
+		if binary.LittleEndian.Uint16(entry) == tagSamplesPerPixel {
+			copy(entry[8:], e.shorts(tagSamplesPerPixel, []uint16{3}).value)
+		}
+	}
+	_, err = Decode(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "single band")
+}
+
+func TestEncode_InvalidOptions(t *testing.T) {
+	r := newTestRaster(t, 2, 2, DataTypeUint8)
+	var buf bytes.Buffer
+
+	assert.Error(t, Encode(&buf, r, &EncodeOptions{TileSize: 10}))
+	assert.Error(t, Encode(&buf, r, &EncodeOptions{Predictor: true}))
+	assert.Error(t, Encode(&buf, r, &EncodeOptions{Compression: CompressionLZW}))
+}
+
+func TestDecodePackBits(t *testing.T) {
+	// The example from the TIFF 6.0 specification
+	packed := []byte{0xFE, 0xAA, 0x02, 0x80, 0x00, 0x2A, 0xFD, 0xAA, 0x03, 0x80, 0x00, 0x2A, 0x22, 0xF7, 0xAA}
+	expected := []byte{
+		0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA, 0x80, 0x00, 0x2A, 0x22,
+		0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA,
+	}
+
+	unpacked, err := decompress(CompressionPackBits, packed, len(expected))
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, unpacked)
+}
+
+func TestDecodeLZW(t *testing.T) {
+	// Enough random bytes for the code width to grow up to 12 bits
+	data := make([]byte, 3000)
+	random := rand.New(rand.NewSource(1))
+	for i := range data {
+		data[i] = byte(random.Intn(256))
+	}
+	// And a repetitive part that produces long codes
+	data = append(data, bytes.Repeat([]byte("nightlight"), 100)...)
+
+	decoded, err := decompress(CompressionLZW, encodeLZW(data), len(data))
+
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+}
+
+// encodeLZW is a minimal tiff lzw encoder, it grows the code width as soon as the next code does not fit anymore
+// which is one code earlier than the decoder notices since the decoder is always one entry behind
+func encodeLZW(data []byte) []byte {
+	var out []byte
+	var bitBuf uint64
+	bitCount, width := 0, lzwMinWidth
+	write := func(code int) {
+		bitBuf = bitBuf<<width | uint64(code)
+		bitCount += width
+		for bitCount >= 8 {
+			out = append(out, byte(bitBuf>>(bitCount-8)))
+			bitCount -= 8
+		}
+	}
+	codes := make(map[string]int)
+	for i := 0; i < 256; i++ {
+		codes[string([]byte{byte(i)})] = i
+	}
+	next := lzwFirstCode
+	addEntry := func() {
+		next++
+		if next >= 1<<width && width < lzwMaxWidth {
+			width++
+		}
+	}
+
+	write(lzwClearCode)
+	current := []byte{data[0]}
+	for _, c := range data[1:] {
+		candidate := append(append([]byte(nil), current...), c)
+		if _, ok := codes[string(candidate)]; ok {
+			current = candidate
+			continue
+		}
+		write(codes[string(current)])
+		codes[string(candidate)] = next
+		addEntry()
+		current = []byte{c}
+	}
+	write(codes[string(current)])
+	addEntry()
+	write(lzwEOICode)
+	if bitCount > 0 {
+		out = append(out, byte(bitBuf<<(8-bitCount)))
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package raster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	tagImageWidth          uint16 = 256
+	tagImageLength         uint16 = 257
+	tagBitsPerSample       uint16 = 258
+	tagCompression         uint16 = 259
+	tagPhotometric         uint16 = 262
+	tagStripOffsets        uint16 = 273
+	tagSamplesPerPixel     uint16 = 277
+	tagRowsPerStrip        uint16 = 278
+	tagStripByteCounts     uint16 = 279
+	tagPlanarConfiguration uint16 = 284
+	tagPredictor           uint16 = 317
+	tagTileWidth           uint16 = 322
+	tagTileLength          uint16 = 323
+	tagTileOffsets         uint16 = 324
+	tagTileByteCounts      uint16 = 325
+	tagSampleFormat        uint16 = 339
+	tagModelPixelScale     uint16 = 33550
+	tagModelTiepoint       uint16 = 33922
+	tagModelTransformation uint16 = 34264
+	tagGeoKeyDirectory     uint16 = 34735
+	tagGeoDoubleParams     uint16 = 34736
+	tagGeoASCIIParams      uint16 = 34737
+	tagGDALNoData          uint16 = 42113
+)
+
+const (
+	dataTypeByte      uint16 = 1
+	dataTypeASCII     uint16 = 2
+	dataTypeShort     uint16 = 3
+	dataTypeLong      uint16 = 4
+	dataTypeRational  uint16 = 5
+	dataTypeSByte     uint16 = 6
+	dataTypeUndefined uint16 = 7
+	dataTypeSShort    uint16 = 8
+	dataTypeSLong     uint16 = 9
+	dataTypeSRational uint16 = 10
+	dataTypeFloat     uint16 = 11
+	dataTypeDouble    uint16 = 12
+)
+
+var dataTypeSizes = map[uint16]int{
+	dataTypeByte:      1,
+	dataTypeASCII:     1,
+	dataTypeShort:     2,
+	dataTypeLong:      4,
+	dataTypeRational:  8,
+	dataTypeSByte:     1,
+	dataTypeUndefined: 1,
+	dataTypeSShort:    2,
+	dataTypeSLong:     4,
+	dataTypeSRational: 8,
+	dataTypeFloat:     4,
+	dataTypeDouble:    8,
+}
+
+const (
+	sampleFormatUint    = 1
+	sampleFormatInt     = 2
+	sampleFormatFloat   = 3
+	photometricMinZero  = 1
+	planarContiguous    = 1
+	predictorNone       = 1
+	predictorHorizontal = 2
+	predictorFloat      = 3
+	tiffMagic           = 42
+	bigTIFFMagic        = 43
+	tiffHeaderSize      = 8
+	ifdEntrySize        = 12
+)
+
+// Compression is how the pixels are compressed in the file
+type Compression uint16
+
+const (
+	CompressionNone     Compression = 1
+	CompressionLZW      Compression = 5
+	CompressionDeflate  Compression = 8
+	CompressionPackBits Compression = 32773
+	// compressionAdobeDeflate is the code older writers use for deflate
+	compressionAdobeDeflate Compression = 32946
+)
+
+// ifdEntry is a tag of the image file directory with its value still encoded in the byte order of the file
+type ifdEntry struct {
+	dataType uint16
+	count    uint32
+	value    []byte
+}
+
+func (e ifdEntry) uints(order binary.ByteOrder) ([]uint64, error) {
+	values := make([]uint64, e.count)
+	for i := range values {
+		switch e.dataType {
+		case dataTypeByte, dataTypeUndefined:
+			values[i] = uint64(e.value[i])
+		case dataTypeShort:
+			values[i] = uint64(order.Uint16(e.value[2*i:]))
+		case dataTypeLong:
+			values[i] = uint64(order.Uint32(e.value[4*i:]))
+		default:
+			return nil, fmt.Errorf("a tag of type %d is not an unsigned integer", e.dataType)
+		}
+	}
+	return values, nil
+}
+
+func (e ifdEntry) floats(order binary.ByteOrder) ([]float64, error) {
+	values := make([]float64, e.count)
+	for i := range values {
+		switch e.dataType {
+		case dataTypeDouble:
+			values[i] = math.Float64frombits(order.Uint64(e.value[8*i:]))
+		case dataTypeFloat:
+			values[i] = float64(math.Float32frombits(order.Uint32(e.value[4*i:])))
+		default:
+			ints, err := e.uints(order)
+			if err != nil {
+				return nil, fmt.Errorf("a tag of type %d is not a number", e.dataType)
+			}
+			values[i] = float64(ints[i])
+		}
+	}
+	return values, nil
+}
+
+func (e ifdEntry) ascii() string {
+	value := e.value
+	for len(value) > 0 && value[len(value)-1] == 0 {
+		value = value[:len(value)-1]
+	}
+	return string(value)
+}
@@ -0,0 +1,152 @@
+// Package tiles cuts geographic rasters into the XYZ tiles of web mercator, the scheme web maps like mapbox gl request
+// their raster layers in, see https://wiki.openstreetmap.org/wiki/Slippy_map_tilenames
+package tiles
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+)
+
+// Size is the width and height of a tile in pixels
+const Size = 256
+
+// maxLatitude is where web mercator ends, the world is a square at this latitude
+const maxLatitude = 85.0511287798066
+
+// MaxZoom is the deepest zoom level that can be cut, beyond it the tile coordinates would overflow
+const MaxZoom = 24
+
+// Tile is the tile in column X and row Y of zoom level Z, row 0 is the northernmost one
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+// Path returns the tile as z/x/y, the path of the tile in a {z}/{x}/{y} url template
+func (t Tile) Path() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Bounds returns the west, south, east and north edge of a raster in EPSG:4326, the only crs that can be tiled
+func Bounds(r *raster.Raster) (float64, float64, float64, float64, error) {
+	if r.GeoKeys.EPSG() != int(raster.EPSGWGS84) {
+		return 0, 0, 0, 0, fmt.Errorf("only rasters in EPSG:%d can be tiled, got EPSG:%d", raster.EPSGWGS84,
+			r.GeoKeys.EPSG())
+	}
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {float64(r.Width), 0}, {0, float64(r.Height)},
+		{float64(r.Width), float64(r.Height)}} {
+		lon, lat := r.Transform.PixelToGeo(corner[0], corner[1])
+		west, east = math.Min(west, lon), math.Max(east, lon)
+		south, north = math.Min(south, lat), math.Max(north, lat)
+	}
+	return west, south, east, north, nil
+}
+
+// Covering returns every tile from minZoom to maxZoom that overlaps the raster, ordered by zoom, column and row
+func Covering(r *raster.Raster, minZoom, maxZoom int) ([]Tile, error) {
+	if minZoom < 0 || maxZoom > MaxZoom || minZoom > maxZoom {
+		return nil, fmt.Errorf("invalid zoom range %d-%d, the zooms must be between 0 and %d", minZoom, maxZoom,
+			MaxZoom)
+	}
+	west, south, east, north, err := Bounds(r)
+	if err != nil {
+		return nil, err
+	}
+	var tiles []Tile
+	for z := minZoom; z <= maxZoom; z++ {
+		n := float64(int(1) << z)
+		// A raster that ends exactly on a tile edge does not reach into the next tile
+		minX, maxX := clamp(math.Floor(worldX(west)*n), n), clamp(math.Ceil(worldX(east)*n)-1, n)
+		minY, maxY := clamp(math.Floor(worldY(north)*n), n), clamp(math.Ceil(worldY(south)*n)-1, n)
+		for x := minX; x <= maxX; x++ {
+			for y := minY; y <= maxY; y++ {
+				tiles = append(tiles, Tile{Z: z, X: x, Y: y})
+			}
+		}
+	}
+	return tiles, nil
+}
+
+// Render samples the raster for every pixel of the tile, a pixel with data is drawn in gray with the value as its
+// brightness and a pixel without data is transparent. Empty is true when no pixel of the tile has data
+func Render(r *raster.Raster, t Tile) (img *image.NRGBA, empty bool, err error) {
+	n := float64(int(1) << t.Z)
+	// The columns of a tile share their longitude and the rows their latitude, so the raster positions are computed
+	// once per column and per row instead of once per pixel
+	columns := make([]int, Size)
+	for px := range columns {
+		lon := (float64(t.X)+(float64(px)+0.5)/Size)/n*360 - 180
+		columns[px], _, err = pixel(r, lon, r.Transform[3])
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	rows := make([]int, Size)
+	for py := range rows {
+		lat := latitude((float64(t.Y) + (float64(py)+0.5)/Size) / n)
+		_, rows[py], err = pixel(r, r.Transform[0], lat)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	img = image.NewNRGBA(image.Rect(0, 0, Size, Size))
+	empty = true
+	for py, y := range rows {
+		if y < 0 || y >= r.Height {
+			continue
+		}
+		for px, x := range columns {
+			if x < 0 || x >= r.Width {
+				continue
+			}
+			v := r.At(x, y)
+			if r.IsNoData(v) {
+				continue
+			}
+			gray := uint8(math.Round(math.Max(0, math.Min(255, v))))
+			img.SetNRGBA(px, py, color.NRGBA{R: gray, G: gray, B: gray, A: 255})
+			empty = false
+		}
+	}
+	return img, empty, nil
+}
+
+// pixel returns the column and row of the raster pixel that contains the coordinate, it can be outside the raster
+func pixel(r *raster.Raster, lon, lat float64) (int, int, error) {
+	if r.Transform[2] != 0 || r.Transform[4] != 0 {
+		return 0, 0, fmt.Errorf("rotated rasters can not be tiled")
+	}
+	x, y, err := r.Transform.GeoToPixel(lon, lat)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(math.Floor(x)), int(math.Floor(y)), nil
+}
+
+// worldX returns the position of the longitude between 0 (west edge) and 1 (east edge) of the world
+func worldX(lon float64) float64 {
+	return (lon + 180) / 360
+}
+
+// worldY returns the position of the latitude between 0 (north edge) and 1 (south edge) of the world
+func worldY(lat float64) float64 {
+	lat = math.Max(-maxLatitude, math.Min(maxLatitude, lat)) * math.Pi / 180
+	return (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2
+}
+
+// latitude is the inverse of worldY
+func latitude(y float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y))) * 180 / math.Pi
+}
+
+// clamp returns the tile of the position, limited to the n tiles of a zoom level
+func clamp(position, n float64) int {
+	return int(math.Max(0, math.Min(n-1, position)))
+}
@@ -0,0 +1,73 @@
+package tiles
+
+import (
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure/raster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRaster returns a raster of the quarter of the world north east of 0,0 where every pixel has the value of
+// its column, the last column has no data
+func newTestRaster(t *testing.T) *raster.Raster {
+	r, err := raster.New(180, 85, raster.DataTypeUint8)
+	require.NoError(t, err)
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			r.Set(x, y, float64(x))
+		}
+	}
+	r.SetNoData(179)
+	r.Transform = raster.GeoTransform{0, 1, 0, 85, 0, -1}
+	r.GeoKeys = raster.NewGeographicGeoKeys(raster.EPSGWGS84)
+	return r
+}
+
+func TestCovering(t *testing.T) {
+	tiles, err := Covering(newTestRaster(t), 0, 2)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Tile{
+		{Z: 0, X: 0, Y: 0},
+		{Z: 1, X: 1, Y: 0},
+		{Z: 2, X: 2, Y: 0}, {Z: 2, X: 2, Y: 1}, {Z: 2, X: 3, Y: 0}, {Z: 2, X: 3, Y: 1},
+	}, tiles)
+}
+
+func TestCovering_RejectsInvalidRasters(t *testing.T) {
+	_, err := Covering(newTestRaster(t), 3, 2)
+	assert.ErrorContains(t, err, "invalid zoom range")
+
+	projected := newTestRaster(t)
+	projected.GeoKeys = raster.GeoKeys{}
+	_, err = Covering(projected, 0, 2)
+	assert.ErrorContains(t, err, "only rasters in EPSG:4326 can be tiled")
+}
+
+func TestRender(t *testing.T) {
+	r := newTestRaster(t)
+
+	img, empty, err := Render(r, Tile{Z: 1, X: 1, Y: 0})
+
+	require.NoError(t, err)
+	assert.False(t, empty)
+	// The tile spans 0 to 180 degrees east, so a pixel covers 180/256 degrees
+	assert.Equal(t, [4]uint8{0, 0, 0, 255}, rgba(img.NRGBAAt(0, 200)))
+	assert.Equal(t, [4]uint8{90, 90, 90, 255}, rgba(img.NRGBAAt(128, 200)))
+	assert.Equal(t, uint8(0), img.NRGBAAt(255, 200).A, "nodata is transparent")
+	assert.Equal(t, uint8(0), img.NRGBAAt(128, 0).A, "north of the raster is transparent")
+
+	_, empty, err = Render(r, Tile{Z: 1, X: 0, Y: 0})
+	require.NoError(t, err)
+	assert.True(t, empty, "the tile west of the raster has no data")
+}
+
+func TestTile_Path(t *testing.T) {
+	assert.Equal(t, "5/18/10", Tile{Z: 5, X: 18, Y: 10}.Path())
+}
+
+func rgba(c interface{ RGBA() (r, g, b, a uint32) }) [4]uint8 {
+	r, g, b, a := c.RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
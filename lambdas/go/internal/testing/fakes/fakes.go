@@ -0,0 +1,146 @@
+// Package fakes has in-memory implementations of the ports, so the services can be driven end to end in tests
+// without AWS, mapbox or eogdata. Every repo embeds Failures to make any of its methods fail on demand
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// Failures injects errors into the methods of a fake, e.g. repo.FailOn("Download", errors.New("s3 is down")). A
+// failure stays in place until it is cleared
+type Failures struct {
+	mu      sync.Mutex
+	errs    map[string]error
+	mapErrs map[string]map[domain.MapID]error
+}
+
+// FailOn makes every call of the method return the error
+func (f *Failures) FailOn(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errs == nil {
+		f.errs = make(map[string]error)
+	}
+	f.errs[method] = err
+}
+
+// FailOnMap makes the calls of the method for a single map return the error
+func (f *Failures) FailOnMap(method string, id domain.MapID, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mapErrs == nil {
+		f.mapErrs = make(map[string]map[domain.MapID]error)
+	}
+	if f.mapErrs[method] == nil {
+		f.mapErrs[method] = make(map[domain.MapID]error)
+	}
+	f.mapErrs[method][id] = err
+}
+
+// ClearFailures removes every injected failure
+func (f *Failures) ClearFailures() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs, f.mapErrs = nil, nil
+}
+
+func (f *Failures) failure(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.errs[method]
+}
+
+func (f *Failures) failureFor(method string, id domain.MapID) error {
+	if err := f.failure(method); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mapErrs[method][id]
+}
+
+// LogEntry is a message the Logger received
+type LogEntry struct {
+	Level         string
+	Msg           string
+	KeysAndValues []interface{}
+}
+
+// Logger keeps every message so tests can assert on the warnings and errors the service logged
+type Logger struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+var _ ports.Logger = (*Logger)(nil)
+
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+func (l *Logger) Debug(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.log("debug", msg, keysAndValues)
+}
+
+func (l *Logger) Info(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.log("info", msg, keysAndValues)
+}
+
+func (l *Logger) Warn(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.log("warn", msg, keysAndValues)
+}
+
+func (l *Logger) Error(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.log("error", msg, keysAndValues)
+}
+
+// Fatal panics instead of exiting, so a test fails instead of the whole test binary
+func (l *Logger) Fatal(_ context.Context, msg string, keysAndValues ...interface{}) {
+	l.log("fatal", msg, keysAndValues)
+	panic(fmt.Sprintf("fatal: %s %v", msg, keysAndValues))
+}
+
+// Entries returns the messages of the level, every message when the level is empty
+func (l *Logger) Entries(level string) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []LogEntry
+	for _, entry := range l.entries {
+		if level == "" || entry.Level == level {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (l *Logger) log(level, msg string, keysAndValues []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, LogEntry{Level: level, Msg: msg, KeysAndValues: keysAndValues})
+}
+
+// sortedByID returns the values of the map ordered by map id, so the fakes list deterministically
+func sortedByID[T any](values map[domain.MapID]T) []T {
+	ids := make([]domain.MapID, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	sorted := make([]T, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, values[id])
+	}
+	return sorted
+}
+
+func matches(m domain.Map, provider domain.MapProvider, bounds domain.Bounds, mapType domain.MapType) bool {
+	return (provider == domain.MapProviderUnspecified || m.Source.MapProvider == provider) &&
+		(bounds == domain.BoundsUnspecified || m.Bounds == bounds) &&
+		(mapType == domain.MapTypeUnspecified || m.MapType == mapType)
+}
@@ -0,0 +1,188 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// ExternalMapProviderRepo serves a fixed list of maps, like eogdata does after it was scraped
+type ExternalMapProviderRepo struct {
+	Failures
+	mu       sync.Mutex
+	provider domain.MapProvider
+	maps     []domain.Map
+}
+
+var _ ports.ExternalMapProviderRepo = (*ExternalMapProviderRepo)(nil)
+
+func NewExternalMapProviderRepo(provider domain.MapProvider, maps ...domain.Map) *ExternalMapProviderRepo {
+	return &ExternalMapProviderRepo{provider: provider, maps: maps}
+}
+
+// SetMaps replaces the maps the provider serves, e.g. when a new month was published
+func (repo *ExternalMapProviderRepo) SetMaps(maps ...domain.Map) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.maps = maps
+}
+
+func (repo *ExternalMapProviderRepo) List(
+	_ context.Context,
+	bounds domain.Bounds,
+	mapType domain.MapType,
+) ([]domain.Map, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var maps []domain.Map
+	for _, m := range repo.maps {
+		if matches(m, domain.MapProviderUnspecified, bounds, mapType) {
+			maps = append(maps, m)
+		}
+	}
+	return maps, nil
+}
+
+func (repo *ExternalMapProviderRepo) GetProvider() domain.MapProvider {
+	return repo.provider
+}
+
+type storedMap struct {
+	m                 domain.Map
+	content           []byte
+	cloudFreeCoverage *float64
+}
+
+// InternalMapRepo keeps the tifs in memory, it is used for both the raw and the processed maps. Create only records
+// the request, the python lambda that downloads the map is played by OnCreate or by calling Put
+type InternalMapRepo struct {
+	Failures
+	// OnCreate is called after a map was requested, e.g. to Put the map right away
+	OnCreate func(m domain.Map)
+
+	mu        sync.Mutex
+	maps      map[domain.MapID]storedMap
+	requested []domain.Map
+	writeDir  string
+}
+
+var _ ports.InternalMapRepo = (*InternalMapRepo)(nil)
+
+// NewInternalMapRepo creates the repo, Download writes the tifs to the writeDir
+func NewInternalMapRepo(writeDir string) *InternalMapRepo {
+	return &InternalMapRepo{maps: make(map[domain.MapID]storedMap), writeDir: writeDir}
+}
+
+// Put stores a map, the cloud-free coverage can be nil like for a map whose coverage was not recorded
+func (repo *InternalMapRepo) Put(m domain.Map, content []byte, cloudFreeCoverage *float64) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.maps[m.ID()] = storedMap{m: m, content: content, cloudFreeCoverage: cloudFreeCoverage}
+}
+
+// Has returns true when the repo holds the map
+func (repo *InternalMapRepo) Has(id domain.MapID) bool {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	_, ok := repo.maps[id]
+	return ok
+}
+
+// Requested returns the maps Create was called with, in order
+func (repo *InternalMapRepo) Requested() []domain.Map {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return append([]domain.Map(nil), repo.requested...)
+}
+
+func (repo *InternalMapRepo) List(
+	_ context.Context,
+	provider domain.MapProvider,
+	bounds domain.Bounds,
+	mapType domain.MapType,
+) ([]domain.Map, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var maps []domain.Map
+	for _, stored := range sortedByID(repo.maps) {
+		if matches(stored.m, provider, bounds, mapType) {
+			maps = append(maps, stored.m)
+		}
+	}
+	return maps, nil
+}
+
+func (repo *InternalMapRepo) Create(_ context.Context, m domain.Map) error {
+	if err := repo.failureFor("Create", m.ID()); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	repo.requested = append(repo.requested, m)
+	onCreate := repo.OnCreate
+	repo.mu.Unlock()
+	if onCreate != nil {
+		onCreate(m)
+	}
+	return nil
+}
+
+func (repo *InternalMapRepo) Download(_ context.Context, m domain.Map) (*domain.LocalMap, error) {
+	if err := repo.failureFor("Download", m.ID()); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	stored, ok := repo.maps[m.ID()]
+	repo.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("the map %s does not exist", m.ID().String())
+	}
+	file, err := os.CreateTemp(repo.writeDir, "*.tif")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Write(stored.content); err != nil {
+		return nil, err
+	}
+	return &domain.LocalMap{Filepath: file.Name(), Map: stored.m}, nil
+}
+
+// Delete does not fail when the map does not exist, like deleting a missing s3 object
+func (repo *InternalMapRepo) Delete(_ context.Context, m domain.Map) error {
+	if err := repo.failureFor("Delete", m.ID()); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.maps, m.ID())
+	return nil
+}
+
+func (repo *InternalMapRepo) PlanDelete(_ context.Context, m domain.Map) ([]string, error) {
+	if err := repo.failureFor("PlanDelete", m.ID()); err != nil {
+		return nil, err
+	}
+	if !repo.Has(m.ID()) {
+		return nil, nil
+	}
+	return []string{"memory://" + m.ID().String()}, nil
+}
+
+func (repo *InternalMapRepo) CloudFreeCoverage(_ context.Context, m domain.Map) (*float64, error) {
+	if err := repo.failureFor("CloudFreeCoverage", m.ID()); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.maps[m.ID()].cloudFreeCoverage, nil
+}
@@ -0,0 +1,170 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// MapTileServerRepo hosts the published maps in memory, the url of a map is tiles://<map id>
+type MapTileServerRepo struct {
+	Failures
+	mu        sync.Mutex
+	published map[domain.MapID]domain.PublishedMap
+}
+
+var _ ports.MapTileServerRepo = (*MapTileServerRepo)(nil)
+
+func NewMapTileServerRepo() *MapTileServerRepo {
+	return &MapTileServerRepo{published: make(map[domain.MapID]domain.PublishedMap)}
+}
+
+// Has returns true when the tile server hosts the map
+func (repo *MapTileServerRepo) Has(id domain.MapID) bool {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	_, ok := repo.published[id]
+	return ok
+}
+
+func (repo *MapTileServerRepo) Publish(_ context.Context, m domain.LocalMap) (*domain.PublishedMap, error) {
+	if err := repo.failureFor("Publish", m.Map.ID()); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	published := domain.PublishedMap{Url: "tiles://" + m.Map.ID().String(), Map: m.Map}
+	repo.published[m.Map.ID()] = published
+	return &published, nil
+}
+
+// List only returns the identity of the maps like the mapbox tilesets do
+func (repo *MapTileServerRepo) List(_ context.Context) ([]domain.PublishedMap, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var maps []domain.PublishedMap
+	for _, published := range sortedByID(repo.published) {
+		maps = append(maps, domain.PublishedMap{Url: published.Url, Map: published.Map.ID().ToMap("")})
+	}
+	return maps, nil
+}
+
+func (repo *MapTileServerRepo) Delete(_ context.Context, m domain.Map) error {
+	if err := repo.failureFor("Delete", m.ID()); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.published, m.ID())
+	return nil
+}
+
+func (repo *MapTileServerRepo) PlanDelete(_ context.Context, m domain.Map) ([]string, error) {
+	if err := repo.failureFor("PlanDelete", m.ID()); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if published, ok := repo.published[m.ID()]; ok {
+		return []string{published.Url}, nil
+	}
+	return nil, nil
+}
+
+// FrontendMapDataRepo keeps the map options of the frontend json in memory
+type FrontendMapDataRepo struct {
+	Failures
+	mu        sync.Mutex
+	maps      map[domain.MapID]domain.PublishedMap
+	anomalies map[domain.MapID][]domain.Anomaly
+}
+
+var _ ports.FrontendMapDataRepo = (*FrontendMapDataRepo)(nil)
+
+func NewFrontendMapDataRepo() *FrontendMapDataRepo {
+	return &FrontendMapDataRepo{
+		maps:      make(map[domain.MapID]domain.PublishedMap),
+		anomalies: make(map[domain.MapID][]domain.Anomaly),
+	}
+}
+
+// Get returns the published map, nil when the frontend does not show it
+func (repo *FrontendMapDataRepo) Get(id domain.MapID) *domain.PublishedMap {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	published, ok := repo.maps[id]
+	if !ok {
+		return nil
+	}
+	return &published
+}
+
+// Anomalies returns the anomalies attached to the map
+func (repo *FrontendMapDataRepo) Anomalies(id domain.MapID) []domain.Anomaly {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.anomalies[id]
+}
+
+func (repo *FrontendMapDataRepo) Upsert(_ context.Context, m domain.PublishedMap) error {
+	if err := repo.failureFor("Upsert", m.Map.ID()); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.maps[m.Map.ID()] = m
+	return nil
+}
+
+func (repo *FrontendMapDataRepo) List(_ context.Context) ([]domain.PublishedMap, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return sortedByID(repo.maps), nil
+}
+
+func (repo *FrontendMapDataRepo) Delete(_ context.Context, m domain.Map) error {
+	if err := repo.failureFor("Delete", m.ID()); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.maps, m.ID())
+	delete(repo.anomalies, m.ID())
+	return nil
+}
+
+func (repo *FrontendMapDataRepo) PlanDelete(_ context.Context, m domain.Map) ([]string, error) {
+	if err := repo.failureFor("PlanDelete", m.ID()); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.maps[m.ID()]; ok {
+		return []string{m.ID().String()}, nil
+	}
+	return nil, nil
+}
+
+// SetAnomalies only attaches the anomalies of maps the frontend shows, like the frontend json does
+func (repo *FrontendMapDataRepo) SetAnomalies(_ context.Context, anomalies []domain.Anomaly) error {
+	if err := repo.failure("SetAnomalies"); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.anomalies = make(map[domain.MapID][]domain.Anomaly)
+	for _, anomaly := range anomalies {
+		if _, ok := repo.maps[anomaly.ID]; ok {
+			repo.anomalies[anomaly.ID] = append(repo.anomalies[anomaly.ID], anomaly)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatusrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+)
+
+// MapStatsRepo keeps the statistics series in memory
+type MapStatsRepo struct {
+	Failures
+	mu    sync.Mutex
+	stats map[domain.MapID]domain.MapStats
+}
+
+var _ ports.MapStatsRepo = (*MapStatsRepo)(nil)
+
+func NewMapStatsRepo() *MapStatsRepo {
+	return &MapStatsRepo{stats: make(map[domain.MapID]domain.MapStats)}
+}
+
+func (repo *MapStatsRepo) List(_ context.Context) ([]domain.MapStats, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return sortedByID(repo.stats), nil
+}
+
+func (repo *MapStatsRepo) Upsert(_ context.Context, stats domain.MapStats) error {
+	if err := repo.failureFor("Upsert", stats.ID); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.stats[stats.ID] = stats
+	return nil
+}
+
+func (repo *MapStatsRepo) Replace(_ context.Context, stats []domain.MapStats) error {
+	if err := repo.failure("Replace"); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.stats = make(map[domain.MapID]domain.MapStats, len(stats))
+	for _, s := range stats {
+		repo.stats[s.ID] = s
+	}
+	return nil
+}
+
+func (repo *MapStatsRepo) Delete(_ context.Context, id domain.MapID) error {
+	if err := repo.failureFor("Delete", id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.stats, id)
+	return nil
+}
+
+// SubRegionRepo keeps the registered sub-regions of every bounds in memory
+type SubRegionRepo struct {
+	Failures
+	mu      sync.Mutex
+	regions map[domain.Bounds][]domain.SubRegion
+}
+
+var _ ports.SubRegionRepo = (*SubRegionRepo)(nil)
+
+func NewSubRegionRepo() *SubRegionRepo {
+	return &SubRegionRepo{regions: make(map[domain.Bounds][]domain.SubRegion)}
+}
+
+func (repo *SubRegionRepo) List(_ context.Context, bounds domain.Bounds) ([]domain.SubRegion, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.regions[bounds], nil
+}
+
+func (repo *SubRegionRepo) Replace(_ context.Context, bounds domain.Bounds, regions []domain.SubRegion) error {
+	if err := repo.failure("Replace"); err != nil {
+		return err
+	}
+	if err := domain.ValidateSubRegions(bounds, regions); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.regions[bounds] = regions
+	return nil
+}
+
+// RegionStatsRepo keeps the statistics series of the sub-regions in memory
+type RegionStatsRepo struct {
+	Failures
+	mu    sync.Mutex
+	stats map[domain.MapID][]domain.RegionStats
+}
+
+var _ ports.RegionStatsRepo = (*RegionStatsRepo)(nil)
+
+func NewRegionStatsRepo() *RegionStatsRepo {
+	return &RegionStatsRepo{stats: make(map[domain.MapID][]domain.RegionStats)}
+}
+
+func (repo *RegionStatsRepo) List(_ context.Context) ([]domain.RegionStats, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var stats []domain.RegionStats
+	for _, perMap := range sortedByID(repo.stats) {
+		stats = append(stats, perMap...)
+	}
+	return stats, nil
+}
+
+func (repo *RegionStatsRepo) UpsertMap(_ context.Context, id domain.MapID, stats []domain.RegionStats) error {
+	if err := repo.failureFor("UpsertMap", id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(stats) == 0 {
+		delete(repo.stats, id)
+		return nil
+	}
+	repo.stats[id] = stats
+	return nil
+}
+
+func (repo *RegionStatsRepo) Replace(_ context.Context, stats []domain.RegionStats) error {
+	if err := repo.failure("Replace"); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.stats = make(map[domain.MapID][]domain.RegionStats)
+	for _, s := range stats {
+		repo.stats[s.Stats.ID] = append(repo.stats[s.Stats.ID], s)
+	}
+	return nil
+}
+
+func (repo *RegionStatsRepo) DeleteMap(_ context.Context, id domain.MapID) error {
+	if err := repo.failureFor("DeleteMap", id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.stats, id)
+	return nil
+}
+
+// AnomalyRepo keeps the flagged anomalies in memory
+type AnomalyRepo struct {
+	Failures
+	mu        sync.Mutex
+	anomalies map[domain.MapID][]domain.Anomaly
+}
+
+var _ ports.AnomalyRepo = (*AnomalyRepo)(nil)
+
+func NewAnomalyRepo() *AnomalyRepo {
+	return &AnomalyRepo{anomalies: make(map[domain.MapID][]domain.Anomaly)}
+}
+
+func (repo *AnomalyRepo) List(_ context.Context) ([]domain.Anomaly, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var anomalies []domain.Anomaly
+	for _, perMap := range sortedByID(repo.anomalies) {
+		anomalies = append(anomalies, perMap...)
+	}
+	return anomalies, nil
+}
+
+func (repo *AnomalyRepo) UpsertMap(_ context.Context, id domain.MapID, anomalies []domain.Anomaly) error {
+	if err := repo.failureFor("UpsertMap", id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(anomalies) == 0 {
+		delete(repo.anomalies, id)
+		return nil
+	}
+	repo.anomalies[id] = anomalies
+	return nil
+}
+
+func (repo *AnomalyRepo) Replace(_ context.Context, anomalies []domain.Anomaly) error {
+	if err := repo.failure("Replace"); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.anomalies = make(map[domain.MapID][]domain.Anomaly)
+	for _, anomaly := range anomalies {
+		repo.anomalies[anomaly.ID] = append(repo.anomalies[anomaly.ID], anomaly)
+	}
+	return nil
+}
+
+func (repo *AnomalyRepo) DeleteMap(_ context.Context, id domain.MapID) error {
+	if err := repo.failureFor("DeleteMap", id); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.anomalies, id)
+	return nil
+}
+
+// MapStatusRepo adds failure injection to the in-memory map status repo
+type MapStatusRepo struct {
+	Failures
+	repo ports.MapStatusRepo
+}
+
+var _ ports.MapStatusRepo = (*MapStatusRepo)(nil)
+
+func NewMapStatusRepo() *MapStatusRepo {
+	return &MapStatusRepo{repo: mapstatusrepo.NewInMemoryMapStatusRepo()}
+}
+
+func (repo *MapStatusRepo) Get(ctx context.Context, id domain.MapID) (*domain.MapStatus, error) {
+	if err := repo.failureFor("Get", id); err != nil {
+		return nil, err
+	}
+	return repo.repo.Get(ctx, id)
+}
+
+func (repo *MapStatusRepo) List(ctx context.Context) ([]domain.MapStatus, error) {
+	if err := repo.failure("List"); err != nil {
+		return nil, err
+	}
+	return repo.repo.List(ctx)
+}
+
+func (repo *MapStatusRepo) Upsert(ctx context.Context, status domain.MapStatus) error {
+	if err := repo.failureFor("Upsert", status.ID); err != nil {
+		return err
+	}
+	return repo.repo.Upsert(ctx, status)
+}
+
+func (repo *MapStatusRepo) Delete(ctx context.Context, id domain.MapID) error {
+	if err := repo.failureFor("Delete", id); err != nil {
+		return err
+	}
+	return repo.repo.Delete(ctx, id)
+}
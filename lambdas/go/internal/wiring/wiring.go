@@ -0,0 +1,75 @@
+// Package wiring reads the configuration every command shares from the environment, so a setting is read in one place
+// instead of in each main
+package wiring
+
+import (
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/anomalyrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/awsclient"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/mapstatsrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/adapters/subregionrepo"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/BaronBonet/conflict-nightlight/internal/core/ports"
+	"github.com/BaronBonet/conflict-nightlight/internal/infrastructure"
+)
+
+const cdnBucketName = "conflict-nightlight-cdn"
+
+// LoadBoundsRegistry replaces the embedded regions with the config at BOUNDS_CONFIG_PATH when it is set, the python
+// lambda reads the same file
+func LoadBoundsRegistry() error {
+	boundsConfigPath := infrastructure.GetEnvOrDefault("BOUNDS_CONFIG_PATH", "")
+	if boundsConfigPath == "" {
+		return nil
+	}
+	boundsRegistry, err := domain.LoadBoundsRegistryFromFile(boundsConfigPath)
+	if err != nil {
+		return err
+	}
+	domain.SetBoundsRegistry(boundsRegistry)
+	return nil
+}
+
+// NewSubRegionRepo keeps the sub-regions next to the shape files of the bounds
+func NewSubRegionRepo(logger ports.Logger, awsClient awsclient.AWSClient) ports.SubRegionRepo {
+	return subregionrepo.NewS3SubRegionRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("SHAPE_FILE_BUCKET", "conflict-nightlight-shape-files"),
+		awsClient,
+	)
+}
+
+// NewRegionStatsRepo keeps the statistics per sub-region in the cdn bucket, next to the statistics of the whole maps
+func NewRegionStatsRepo(logger ports.Logger, awsClient awsclient.AWSClient) ports.RegionStatsRepo {
+	return mapstatsrepo.NewS3RegionStatsRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", cdnBucketName),
+		infrastructure.GetEnvOrDefault("FRONTEND_REGION_STATS_JSON", "conflict-nightlight-region-stats.json"),
+		awsClient,
+	)
+}
+
+// NewAnomalyRepo keeps the suspected outages in the cdn bucket so the frontend can read them
+func NewAnomalyRepo(logger ports.Logger, awsClient awsclient.AWSClient) ports.AnomalyRepo {
+	return anomalyrepo.NewS3AnomalyRepo(
+		logger,
+		infrastructure.GetEnvOrDefault("CDN_BUCKET_NAME", cdnBucketName),
+		infrastructure.GetEnvOrDefault("FRONTEND_ANOMALIES_JSON", "conflict-nightlight-anomalies.json"),
+		awsClient,
+	)
+}
+
+// AnomalyConfig is the default config, the anomalies are only copied to the frontend json when
+// ATTACH_ANOMALIES_TO_FRONTEND is true
+func AnomalyConfig() domain.AnomalyConfig {
+	config := domain.DefaultAnomalyConfig()
+	config.AttachToFrontend = infrastructure.GetEnvOrDefault("ATTACH_ANOMALIES_TO_FRONTEND", "false") == "true"
+	return config
+}
+
+// QualityGate is disabled until a minimum is configured, e.g. 0.4 refuses or flags maps that were mostly cloudy
+func QualityGate() (domain.QualityGate, error) {
+	return domain.ParseQualityGate(
+		infrastructure.GetEnvOrDefault("MIN_CLOUD_FREE_COVERAGE", ""),
+		infrastructure.GetEnvOrDefault("LOW_COVERAGE_ACTION", "flag"),
+	)
+}
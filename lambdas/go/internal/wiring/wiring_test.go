@@ -0,0 +1,71 @@
+package wiring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BaronBonet/conflict-nightlight/internal/core/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBoundsRegistry(t *testing.T) {
+	defaultRegistry := domain.GetBoundsRegistry()
+	t.Cleanup(func() { domain.SetBoundsRegistry(defaultRegistry) })
+	path := filepath.Join(t.TempDir(), "bounds.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": 1, "name": "UkraineAndAround", "bbox": [21.0, 43.3, 41.3, 54.5], "eogdataTile": "75N060W"},
+		{"id": 3, "name": "KharkivAndAround", "bbox": [35.5, 49.5, 37.0, 50.5], "eogdataTile": "75N060W"}
+	]`), 0o600))
+
+	t.Setenv("BOUNDS_CONFIG_PATH", path)
+	require.NoError(t, LoadBoundsRegistry())
+
+	assert.Equal(t, domain.Bounds(3), domain.StringToBounds("KharkivAndAround"))
+	assert.Equal(t, domain.BoundsUnspecified, domain.StringToBounds("GazaAndAround"))
+}
+
+func TestLoadBoundsRegistry_KeepsTheEmbeddedRegionsWithoutAConfig(t *testing.T) {
+	defaultRegistry := domain.GetBoundsRegistry()
+	t.Setenv("BOUNDS_CONFIG_PATH", "")
+
+	require.NoError(t, LoadBoundsRegistry())
+
+	assert.Same(t, defaultRegistry, domain.GetBoundsRegistry())
+}
+
+func TestLoadBoundsRegistry_InvalidConfig(t *testing.T) {
+	t.Setenv("BOUNDS_CONFIG_PATH", filepath.Join(t.TempDir(), "missing.json"))
+
+	assert.ErrorContains(t, LoadBoundsRegistry(), "missing.json")
+}
+
+func TestAnomalyConfig(t *testing.T) {
+	t.Setenv("ATTACH_ANOMALIES_TO_FRONTEND", "true")
+
+	config := AnomalyConfig()
+
+	assert.True(t, config.AttachToFrontend)
+	assert.NoError(t, config.Validate())
+}
+
+func TestQualityGate(t *testing.T) {
+	t.Setenv("MIN_CLOUD_FREE_COVERAGE", "0.4")
+	t.Setenv("LOW_COVERAGE_ACTION", "refuse")
+
+	gate, err := QualityGate()
+
+	require.NoError(t, err)
+	cloudy := 0.3
+	_, err = gate.Check(&cloudy)
+	assert.Error(t, err)
+}
+
+func TestQualityGate_Invalid(t *testing.T) {
+	t.Setenv("MIN_CLOUD_FREE_COVERAGE", "most")
+
+	_, err := QualityGate()
+
+	assert.Error(t, err)
+}
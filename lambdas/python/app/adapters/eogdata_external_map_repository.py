@@ -1,5 +1,7 @@
-import tarfile
+import gzip
 import pathlib
+import shutil
+import tarfile
 import uuid
 
 import requests
@@ -21,25 +23,51 @@ class EogdataMapRepository(ExternalMapRepository):
         password = get_or_raise(secrets, "eogdataPassword")
 
         self.token = self._get_eog_auth_token(TOKEN_URL, username, password)
+        # The monthly archives hold both the radiance and the coverage raster, so an archive is only downloaded once
+        self._downloaded_files: dict[str, pathlib.Path] = {}
 
     def download(self, m: domain.Map) -> domain.LocalMap:
         self.logger.info("Starting download", download_url=m)
-        response = requests.get(m.map_source.url, headers={"Authorization": f"Bearer {self.token}"}, stream=True)
-
-        output_file_name = pathlib.Path(self.local_write_directory) / f"{uuid.uuid4()}.tgz"
-
-        with open(output_file_name, "wb") as f:
-            for chunk in response.iter_content(1024):
-                if chunk:
-                    f.write(chunk)
-
-        return domain.LocalMap(map=m, file_path=self._extract_tif(output_file_name))
-
-    def _extract_tif(self, file_to_unzip: pathlib.Path) -> pathlib.Path:
+        return domain.LocalMap(map=m, file_path=self._download(m.map_source.url, ".avg_rade9h"))
+
+    def download_coverage(self, m: domain.Map) -> pathlib.Path | None:
+        if not m.map_source.coverage_url:
+            return None
+        self.logger.info("Starting download of the coverage", coverage_url=m.map_source.coverage_url)
+        return self._download(m.map_source.coverage_url, ".cf_cvg")
+
+    def _download(self, url: str, archived_suffix: str) -> pathlib.Path:
+        # The nightly composites are published as tifs, the annual ones as gzipped tifs and the monthly ones are
+        # archived in a tgz
+        suffix = "tif" if url.endswith(".tif") else "tif.gz" if url.endswith(".tif.gz") else "tgz"
+        output_file_name = self._downloaded_files.get(url)
+        if output_file_name is None or not output_file_name.exists():
+            output_file_name = pathlib.Path(self.local_write_directory) / f"{uuid.uuid4()}.{suffix}"
+            response = requests.get(url, headers={"Authorization": f"Bearer {self.token}"}, stream=True)
+            with open(output_file_name, "wb") as f:
+                for chunk in response.iter_content(1024):
+                    if chunk:
+                        f.write(chunk)
+            self._downloaded_files[url] = output_file_name
+
+        match suffix:
+            case "tif":
+                return output_file_name
+            case "tif.gz":
+                return self._decompress_tif(output_file_name)
+        return self._extract_tif(output_file_name, archived_suffix)
+
+    def _decompress_tif(self, file_to_decompress: pathlib.Path) -> pathlib.Path:
+        decompressed_file = file_to_decompress.parent / file_to_decompress.name.removesuffix(".gz")
+        with gzip.open(file_to_decompress, "rb") as compressed, open(decompressed_file, "wb") as f:
+            shutil.copyfileobj(compressed, f)
+        return decompressed_file
+
+    def _extract_tif(self, file_to_unzip: pathlib.Path, archived_suffix: str) -> pathlib.Path:
         tar = tarfile.open(str(file_to_unzip))
         extracted_file = ""
         for item in tar:
-            if {*pathlib.Path(item.name).suffixes} == {".avg_rade9h", ".tif"}:
+            if {*pathlib.Path(item.name).suffixes} == {archived_suffix, ".tif"}:
                 tar.extract(item, path=self.local_write_directory)
                 extracted_file = item.name
 
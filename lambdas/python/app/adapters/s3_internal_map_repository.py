@@ -1,3 +1,6 @@
+import datetime
+import hashlib
+import json
 import os
 import pathlib
 from dataclasses import dataclass
@@ -14,13 +17,20 @@ from app.infrastructure.proto_transformers import (
     map_type_to_string,
     transform_map_domain_to_proto,
 )
-from generated.conflict_nightlight.v1 import CreateMapProductRequest, PublishMapProductRequest, RequestWrapper
+from generated.conflict_nightlight.v1 import CreateMapProductRequest, MapType, RequestWrapper
+
+# The object at the root of the bucket that indexes every tif in it, the go lambdas list the maps from it
+MANIFEST_KEY = "manifest.json"
+# How often the manifest is read and updated again after another lambda wrote it first
+MANIFEST_RETRIES = 5
+# The go lambdas read the checksum from the metadata when they rebuild the manifest
+CHECKSUM_KEY = "sha256"
 
 
 @dataclass
 class NewMessageNotificationQueue:
     queue_name: str
-    message_format: Optional[Type[CreateMapProductRequest] | Type[PublishMapProductRequest]]
+    message_format: Optional[Type[CreateMapProductRequest]]
 
     def validate(self):
         if not self.message_format:
@@ -38,6 +48,7 @@ class S3InternalMapRepository(InternalMapRepository):
         local_write_dir: pathlib.Path,
         new_message_notification: Optional[NewMessageNotificationQueue],
         source_url_key=os.getenv("SOURCE_URL_KEY", "source-url"),
+        cloud_free_coverage_key=os.getenv("CLOUD_FREE_COVERAGE_KEY", "cloud-free-coverage"),
     ):
         self.logger = logger
         self.s3_client = boto3.client("s3")
@@ -47,6 +58,7 @@ class S3InternalMapRepository(InternalMapRepository):
         self.bucket_name = bucket_name
         self.correlation_id = correlation_id
         self.source_url_key = source_url_key
+        self.cloud_free_coverage_key = cloud_free_coverage_key
 
     def download(self, m: domain.Map) -> domain.LocalMap:
         self.logger.info("Downloading map from s3", bucket_name=self.bucket_name, map=m)
@@ -58,22 +70,78 @@ class S3InternalMapRepository(InternalMapRepository):
 
     def save(self, m: domain.LocalMap):
         self.logger.info("Attempting to save map to s3", bucket_name=self.bucket_name, map=m)
+        if self.new_message_notification:
+            try:
+                self.new_message_notification.validate()
+            except Exception as e:
+                self.logger.fatal(str(e))
+        checksum = sha256_of_file(m.file_path)
+        metadata = {self.source_url_key: m.map.map_source.url, CHECKSUM_KEY: checksum}
+        if m.cloud_free_coverage is not None:
+            metadata[self.cloud_free_coverage_key] = f"{m.cloud_free_coverage:.4f}"
         try:
-            self.new_message_notification.validate()
-        except Exception as e:
-            self.logger.fatal(str(e))
-        try:
+            key = construct_key(m.map)
             self.s3_client.upload_file(
                 Filename=str(m.file_path),
                 Bucket=self.bucket_name,
-                Key=construct_key(m.map),
-                ExtraArgs={"Metadata": {self.source_url_key: m.map.map_source.url}},
+                Key=key,
+                ExtraArgs={"Metadata": metadata},
             )
         except ErrorConstructKey as e:
             self.logger.fatal(str(e))
         except Exception as e:
             self.logger.fatal("Error when trying to upload map", map=m, bucket=self.bucket_name, error=e)
-        self._send_sqs_message(m.map)
+        self._add_to_manifest(key, m, checksum)
+        # Without a queue the map is only saved, e.g. the processed maps are published from their s3 event
+        if self.new_message_notification:
+            self._send_sqs_message(m.map)
+
+    def _add_to_manifest(self, key: str, m: domain.LocalMap, checksum: str):
+        """
+        The go lambdas list the maps from the manifest, so it is only written while it is unchanged since it was read.
+        When another lambda wrote it first it is read and updated again, and the message is retried when that keeps
+        failing
+        """
+        for attempt in range(1, MANIFEST_RETRIES + 1):
+            try:
+                response = self.s3_client.get_object(Bucket=self.bucket_name, Key=MANIFEST_KEY)
+                manifest, etag = json.loads(response["Body"].read()), response["ETag"]
+            except self.s3_client.exceptions.NoSuchKey:
+                manifest, etag = {"maps": {}}, None
+            now = datetime.datetime.now(datetime.timezone.utc).isoformat()
+            previous = manifest["maps"].get(key, {})
+            manifest["maps"][key] = {
+                "key": key,
+                "source_url": m.map.map_source.url,
+                "size": m.file_path.stat().st_size,
+                "sha256": checksum,
+                "created_at": previous.get("created_at", now),
+                "updated_at": now,
+            }
+            try:
+                self._put_manifest(json.dumps(manifest), etag)
+                return
+            except ClientError as e:
+                if e.response["Error"]["Code"] not in ("PreconditionFailed", "ConditionalRequestConflict"):
+                    self.logger.fatal(
+                        "Error when trying to update the manifest", key=key, bucket=self.bucket_name, error=e
+                    )
+                self.logger.info("The manifest was changed by another writer, the update is retried", attempt=attempt)
+        self.logger.fatal("The manifest kept changing while it was updated", key=key, bucket=self.bucket_name)
+
+    def _put_manifest(self, body: str, etag: Optional[str]):
+        # The pinned botocore does not know the conditional parameters of PutObject, so the header is added to the
+        # request. Without an etag the manifest is only created when it does not exist yet
+        header, value = ("If-Match", etag) if etag else ("If-None-Match", "*")
+
+        def add_condition(request, **_):
+            request.headers[header] = value
+
+        self.s3_client.meta.events.register("before-sign.s3.PutObject", add_condition)
+        try:
+            self.s3_client.put_object(Bucket=self.bucket_name, Key=MANIFEST_KEY, Body=body)
+        finally:
+            self.s3_client.meta.events.unregister("before-sign.s3.PutObject", add_condition)
 
     def _download_file(self, object_key: str) -> pathlib.Path:
         self.local_write_dir.mkdir(parents=True, exist_ok=True)
@@ -112,14 +180,18 @@ class S3InternalMapRepository(InternalMapRepository):
                 return RequestWrapper(
                     create_map_product_request=CreateMapProductRequest(transform_map_domain_to_proto(m))
                 )
-            case PublishMapProductRequest():
-                return RequestWrapper(
-                    publish_map_product_request=PublishMapProductRequest(transform_map_domain_to_proto(m))
-                )
             case _:
                 self.logger.fatal("Unknown message format", message_format=self.new_message_notification.message_format)
 
 
+def sha256_of_file(file_path: pathlib.Path) -> str:
+    digest = hashlib.sha256()
+    with open(file_path, "rb") as f:
+        for chunk in iter(lambda: f.read(1024 * 1024), b""):
+            digest.update(chunk)
+    return digest.hexdigest()
+
+
 class ErrorConstructKey(Exception):
     pass
 
@@ -132,4 +204,7 @@ def construct_key(m: domain.Map) -> str:
         raise ErrorConstructKey(
             f"The key could not be constructed, provider={provider}, map_type={map_type}, bounds={bounds}"
         )
+    # Annual maps are only keyed by their year, their month and day carry no meaning
+    if m.map_type == MapType.MAP_TYPE_ANNUAL:
+        return f"{provider}/{bounds}/{map_type}/{m.date.year}.tif"
     return f"{provider}/{bounds}/{map_type}/{m.date.year}_{m.date.month}_{m.date.day}.tif"
@@ -9,13 +9,16 @@ class Map:
     date: datetime.date
     map_type: v1.MapType
     map_source: v1.MapSource
-    bounds: v1.Bounds
+    # The id of a region in the bounds registry, see app.infrastructure.bounds_registry
+    bounds: int
 
 
 @dataclass
 class LocalMap:
     map: Map
     file_path: pathlib.Path
+    # The fraction of the bounds that was observed without clouds, None when the provider has no coverage raster
+    cloud_free_coverage: float | None = None
 
 
 class KillProcess(Exception):
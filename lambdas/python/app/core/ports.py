@@ -11,6 +11,15 @@ class ExternalMapRepository(ABC):
     def download(self, m: domain.Map) -> domain.LocalMap:
         pass
 
+    @abstractmethod
+    def download_coverage(self, m: domain.Map) -> pathlib.Path | None:
+        """
+        Downloads the cloud-free coverage raster of the map
+
+        Returns:
+            The location on the local file system of the raster, None when the map has no coverage raster
+        """
+
 
 class InternalMapRepository(ABC):
     """Driven port defining the interface for internal maps"""
@@ -1,11 +1,13 @@
 import pathlib
 
 import geopandas as gpd
+import numpy as np
 import rasterio.mask
 from shapely.geometry import mapping
 
 from app.core import domain, ports
 from app.core.ports import BoundsRepository, ExternalMapRepository, InternalMapRepository
+from app.infrastructure.proto_transformers import bounds_to_shape_file_name
 
 
 class RawMapProcessorService:
@@ -22,10 +24,33 @@ class RawMapProcessorService:
         self.logger = logger
 
     def download_crop_save(self, m: domain.Map):
-        boundary_file = self.bounds_repository.download(m.bounds.name)
+        shape_file_name = bounds_to_shape_file_name(m.bounds)
+        if not shape_file_name:
+            self.logger.fatal("The bounds are not in the bounds registry", bounds=m.bounds)
+        boundary_file = self.bounds_repository.download(shape_file_name)
         raw_internal_map = self.external_map_repository.download(m=m)
         cropped_map_location = self._crop_raw_file(raw_internal_map.file_path, boundary_file)
-        self.internal_map_repository.save(domain.LocalMap(file_path=cropped_map_location, map=m))
+        cloud_free_coverage = self._get_cloud_free_coverage(m, boundary_file)
+        self.internal_map_repository.save(
+            domain.LocalMap(file_path=cropped_map_location, map=m, cloud_free_coverage=cloud_free_coverage)
+        )
+
+    def _get_cloud_free_coverage(self, m: domain.Map, boundary_file: pathlib.Path) -> float | None:
+        coverage_file = self.external_map_repository.download_coverage(m)
+        if coverage_file is None:
+            self.logger.debug("The map has no coverage raster", map=m)
+            return None
+        crop_boundaries = self._get_crop_boundaries(boundary_file)
+        with rasterio.open(str(coverage_file)) as coverage_data:
+            cropped_coverage, _ = rasterio.mask.mask(
+                dataset=coverage_data,
+                shapes=[crop_boundaries],
+                crop=True,
+                filled=False,
+            )
+        cloud_free_coverage = cloud_free_fraction(cropped_coverage[0])
+        self.logger.info("Computed the cloud-free coverage", map=m, cloud_free_coverage=cloud_free_coverage)
+        return cloud_free_coverage
 
     def _crop_raw_file(self, raw_map_file: pathlib.Path, boundary_file: pathlib.Path) -> pathlib.Path:
         self.logger.debug("Starting to crop file", raw_map_file=raw_map_file, boundary_file=boundary_file)
@@ -72,3 +97,19 @@ class RawMapProcessorService:
         self.logger.debug("Extracting crop boundaries", boundary_shp_file=boundary_shp_file)
         crop_extent = gpd.read_file(boundary_shp_file)
         return mapping(crop_extent["geometry"][0])
+
+
+def cloud_free_fraction(coverage: np.ma.MaskedArray) -> float | None:
+    """
+    The fraction of the pixels within the bounds that were observed without clouds at least once.
+
+    Args:
+        coverage: The number of cloud-free observations per pixel, the pixels outside of the bounds are masked.
+
+    Returns:
+        The fraction between 0 and 1, None when no pixel lies within the bounds.
+    """
+    pixels_within_bounds = coverage.count()
+    if pixels_within_bounds == 0:
+        return None
+    return float((coverage > 0).sum() / pixels_within_bounds)
@@ -12,7 +12,7 @@ from app.core.services.product import MapProductService
 from app.core.services.raw_processor import RawMapProcessorService
 from app.infrastructure.get_secrets_from_aws_secret_manager import get_secrets_from_aws_secrets_manager
 from app.infrastructure.proto_transformers import proto_map_to_domain
-from generated.conflict_nightlight.v1 import CreateMapProductRequest, PublishMapProductRequest, RequestWrapper
+from generated.conflict_nightlight.v1 import CreateMapProductRequest, RequestWrapper
 
 
 def handle_event(event: dict[str, str], correlation_id: str):
@@ -35,11 +35,6 @@ def handle_event(event: dict[str, str], correlation_id: str):
 
 def create_new_map_product(logger: ports.Logger, request: RequestWrapper, correlation_id: str, write_dir: pathlib.Path):
     logger.debug("Configuring service to handle new map product request", request=request.to_json())
-    message_notification_queue = NewMessageNotificationQueue(
-        queue_name=os.getenv("PUBLISH_MAP_PRODUCT_REQUEST_QUEUE", "conflict-nightlight-publish-map-product-request"),
-        message_format=PublishMapProductRequest,
-    )
-    message_notification_queue.validate()
     service = MapProductService(
         raw_map_repository=S3InternalMapRepository(
             bucket_name=os.getenv("RAW_TIF_BUCKET", "conflict-nightlight-raw-tif"),
@@ -52,7 +47,9 @@ def create_new_map_product(logger: ports.Logger, request: RequestWrapper, correl
             logger=logger,
             bucket_name=os.getenv("PROCESSED_TIF_BUCKET_NAME", "conflict-nightlight-processed-tif"),
             correlation_id=correlation_id,
-            new_message_notification=message_notification_queue,
+            # The map auto publisher publishes the map from the s3 event of the processed tif, a message would
+            # publish it a second time
+            new_message_notification=None,
             local_write_dir=write_dir,
         ),
     )
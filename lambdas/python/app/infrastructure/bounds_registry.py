@@ -0,0 +1,65 @@
+import functools
+import json
+import os
+import re
+from dataclasses import dataclass
+
+from generated.conflict_nightlight.v1 import Bounds
+
+BOUNDS_PREFIX = "Bounds"
+
+
+@dataclass(frozen=True)
+class BoundsDefinition:
+    """A region the maps are cropped to, the same definition the go lambdas read from bounds.json"""
+
+    id: int
+    # The name is part of the s3 keys, e.g. UkraineAndAround
+    name: str
+
+    def key_name(self) -> str:
+        return f"{BOUNDS_PREFIX}{self.name}"
+
+    def shape_file_name(self) -> str:
+        """The prefix of the shape files in the shape file bucket, e.g. BOUNDS_UKRAINE_AND_AROUND"""
+        return f"{BOUNDS_PREFIX}_{re.sub(r'(?<!^)(?=[A-Z])', '_', self.name)}".upper()
+
+
+class BoundsRegistry:
+    def __init__(self, definitions: list[BoundsDefinition]):
+        self._by_id = {d.id: d for d in definitions}
+
+    def get(self, bounds_id: int) -> BoundsDefinition | None:
+        return self._by_id.get(int(bounds_id))
+
+
+def parse_bounds_registry(data: str) -> BoundsRegistry:
+    """Parses the json array of bounds definitions, the fields only the go lambdas need are ignored"""
+    return BoundsRegistry([BoundsDefinition(id=d["id"], name=d["name"]) for d in json.loads(data)])
+
+
+def default_bounds_registry() -> BoundsRegistry:
+    """The regions of the Bounds enum, they match the bounds.json embedded in the go lambdas"""
+    return BoundsRegistry(
+        [
+            BoundsDefinition(
+                id=b.value,
+                name="".join(word.capitalize() for word in b.name.removeprefix("BOUNDS_").split("_")),
+            )
+            for b in Bounds
+            if b != Bounds.BOUNDS_UNSPECIFIED
+        ]
+    )
+
+
+@functools.cache
+def get_bounds_registry() -> BoundsRegistry:
+    """
+    The registry is read from BOUNDS_CONFIG_PATH, the config the go lambdas use, so a region can be added without a
+    code change. Without the variable the regions of the Bounds enum are known
+    """
+    path = os.getenv("BOUNDS_CONFIG_PATH", "")
+    if not path:
+        return default_bounds_registry()
+    with open(path) as f:
+        return parse_bounds_registry(f.read())
@@ -1,7 +1,8 @@
 import datetime
 
 from app.core import domain
-from generated.conflict_nightlight.v1 import Bounds, Date, Map, MapProvider, MapSource, MapType
+from app.infrastructure.bounds_registry import get_bounds_registry
+from generated.conflict_nightlight.v1 import Date, Map, MapProvider, MapSource, MapType
 
 
 def transform_map_domain_to_proto(m: domain.Map) -> Map:
@@ -26,15 +27,20 @@ def map_type_to_string(t: MapType) -> str | None:
             return "MapTypeMonthly"
         case MapType.MAP_TYPE_DAILY:
             return "MapTypeDaily"
+        case MapType.MAP_TYPE_ANNUAL:
+            return "MapTypeAnnual"
     return None
 
 
-def bounds_to_string(b: Bounds) -> str | None:
-    match b:
-        case Bounds.BOUNDS_UKRAINE_AND_AROUND:
-            return "BoundsUkraineAndAround"
-        case Bounds.BOUNDS_GAZA_AND_AROUND:
-            return "BoundsGazaAndAround"
+def bounds_to_string(b: int) -> str | None:
+    if definition := get_bounds_registry().get(b):
+        return definition.key_name()
+    return None
+
+
+def bounds_to_shape_file_name(b: int) -> str | None:
+    if definition := get_bounds_registry().get(b):
+        return definition.shape_file_name()
     return None
 
 
@@ -42,6 +48,11 @@ def proto_map_to_domain(m: Map) -> domain.Map:
     return domain.Map(
         date=datetime.date(m.date.year, m.date.month, m.date.day),
         map_type=MapType(m.map_type),
-        map_source=MapSource(map_provider=MapProvider(m.map_source.map_provider), url=m.map_source.url),
-        bounds=Bounds(m.bounds),
+        map_source=MapSource(
+            map_provider=MapProvider(m.map_source.map_provider),
+            url=m.map_source.url,
+            coverage_url=m.map_source.coverage_url,
+        ),
+        # The regions added through the bounds config are not part of the Bounds enum, so the id is kept as is
+        bounds=int(m.bounds),
     )
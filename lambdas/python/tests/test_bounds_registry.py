@@ -0,0 +1,41 @@
+import json
+import pathlib
+
+from app.infrastructure.bounds_registry import default_bounds_registry, get_bounds_registry
+
+GO_BOUNDS_CONFIG = pathlib.Path(__file__).parents[2] / "go" / "internal" / "core" / "domain" / "bounds.json"
+
+
+def test_default_bounds_registry_matches_the_go_config():
+    registry = default_bounds_registry()
+    for d in json.loads(GO_BOUNDS_CONFIG.read_text()):
+        assert registry.get(d["id"]).name == d["name"]
+
+
+def test_default_bounds_registry_names():
+    definition = default_bounds_registry().get(1)
+
+    assert definition.key_name() == "BoundsUkraineAndAround"
+    assert definition.shape_file_name() == "BOUNDS_UKRAINE_AND_AROUND"
+
+
+def test_get_bounds_registry_reads_the_bounds_config(tmp_path, monkeypatch):
+    config = tmp_path / "bounds.json"
+    config.write_text(
+        json.dumps(
+            [
+                {"id": 1, "name": "UkraineAndAround", "bbox": [21.0, 43.3, 41.3, 54.5], "eogdataTile": "75N060W"},
+                {"id": 3, "name": "KharkivAndAround", "bbox": [35.5, 49.5, 37.0, 50.5], "eogdataTile": "75N060W"},
+            ]
+        )
+    )
+    monkeypatch.setenv("BOUNDS_CONFIG_PATH", str(config))
+    get_bounds_registry.cache_clear()
+    try:
+        registry = get_bounds_registry()
+    finally:
+        get_bounds_registry.cache_clear()
+
+    assert registry.get(3).key_name() == "BoundsKharkivAndAround"
+    assert registry.get(3).shape_file_name() == "BOUNDS_KHARKIV_AND_AROUND"
+    assert registry.get(2) is None
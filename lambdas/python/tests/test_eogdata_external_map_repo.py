@@ -0,0 +1,73 @@
+import datetime
+import io
+import pathlib
+import tarfile
+
+import structlog
+from pytest import fixture
+
+from app.adapters import eogdata_external_map_repository
+from app.adapters.eogdata_external_map_repository import EogdataMapRepository
+from app.core import domain
+from generated.conflict_nightlight import v1
+
+LOGGER = structlog.getLogger()
+
+MONTHLY_URL = (
+    "https://eogdata.mines.edu/nighttime_light/monthly/v10/2023/202301/vcmcfg/"
+    "SVDNB_npp_20230101-20230131_75N060W_vcmcfg_v10_c202302080600.tgz"
+)
+ARCHIVED_NAME = "SVDNB_npp_20230101-20230131_75N060W_vcmcfg_v10_c202302080600"
+
+
+class FakeResponse:
+    def __init__(self, content: bytes):
+        self.content = content
+
+    def iter_content(self, chunk_size: int):
+        for i in range(0, len(self.content), chunk_size):
+            yield self.content[i : i + chunk_size]
+
+
+def _monthly_archive() -> bytes:
+    buffer = io.BytesIO()
+    with tarfile.open(fileobj=buffer, mode="w:gz") as tar:
+        for suffix, content in [(".avg_rade9h.tif", b"radiance"), (".cf_cvg.tif", b"coverage")]:
+            info = tarfile.TarInfo(ARCHIVED_NAME + suffix)
+            info.size = len(content)
+            tar.addfile(info, io.BytesIO(content))
+    return buffer.getvalue()
+
+
+@fixture
+def monthly_map() -> domain.Map:
+    # The monthly coverage raster is archived next to the radiance, so the archive is also the coverage url
+    return domain.Map(
+        date=datetime.date(day=1, month=1, year=2023),
+        map_type=v1.MapType.MAP_TYPE_MONTHLY,
+        bounds=v1.Bounds.BOUNDS_UKRAINE_AND_AROUND,
+        map_source=v1.MapSource(
+            map_provider=v1.MapProvider.MAP_PROVIDER_EOGDATA,
+            url=MONTHLY_URL,
+            coverage_url=MONTHLY_URL,
+        ),
+    )
+
+
+def test_monthly_coverage_is_extracted_from_the_downloaded_archive(monthly_map, tmp_path, monkeypatch):
+    requested_urls = []
+
+    def fake_get(url, **kwargs):
+        requested_urls.append(url)
+        return FakeResponse(_monthly_archive())
+
+    monkeypatch.setattr(EogdataMapRepository, "_get_eog_auth_token", lambda *args: "token")
+    monkeypatch.setattr(eogdata_external_map_repository.requests, "get", fake_get)
+    repo = EogdataMapRepository(tmp_path, LOGGER, {"eogdataUsername": "user", "eogdataPassword": "password"})
+
+    local_map = repo.download(monthly_map)
+    coverage_file = repo.download_coverage(monthly_map)
+
+    assert pathlib.Path(local_map.file_path).read_bytes() == b"radiance"
+    assert pathlib.Path(coverage_file).read_bytes() == b"coverage"
+    assert requested_urls == [MONTHLY_URL]
@@ -1,15 +1,24 @@
 import datetime
+import json
 import pathlib
 import tempfile
 
 import boto3
 import structlog
+from botocore.exceptions import ClientError
 from pytest import fixture
 from moto import mock_sqs, mock_s3
 
-from app.adapters.s3_internal_map_repository import S3InternalMapRepository, NewMessageNotificationQueue, construct_key
+from app.adapters.s3_internal_map_repository import (
+    MANIFEST_KEY,
+    NewMessageNotificationQueue,
+    S3InternalMapRepository,
+    construct_key,
+    sha256_of_file,
+)
 from app.core import domain
 from app.core.domain import LocalMap
+from app.infrastructure.bounds_registry import get_bounds_registry
 from generated.conflict_nightlight import v1
 from generated.conflict_nightlight.v1 import CreateMapProductRequest
 
@@ -69,13 +78,74 @@ def test_s3_internal_map_repository_save(test_map, temp_example_tif):
     )
     messages = queue.receive_messages()
     assert len(messages) == 1
-    assert sum(1 for _ in bucket.objects.all()) == 1
+    assert sorted(o.key for o in bucket.objects.all()) == sorted([MANIFEST_KEY, construct_key(test_map)])
     assert [
         f.key.split("/")[-1]
         for f in bucket.objects.filter(Prefix="MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily")
     ][0] == "2023_2_1.tif"
 
 
+@mock_s3
+@mock_sqs
+def test_s3_internal_map_repository_save_cloud_free_coverage(test_map, temp_example_tif):
+    sqs_client = boto3.resource("sqs")
+    sqs_client.create_queue(QueueName=QUEUE_NAME)
+    s3_client = boto3.client("s3")
+    s3_client.create_bucket(Bucket=BUCKET_NAME, CreateBucketConfiguration={"LocationConstraint": "eu-central-1"})
+
+    repo = S3InternalMapRepository(
+        logger=LOGGER,
+        bucket_name=BUCKET_NAME,
+        correlation_id="1",
+        local_write_dir=pathlib.Path("/tmp"),
+        new_message_notification=NewMessageNotificationQueue(
+            queue_name=QUEUE_NAME, message_format=CreateMapProductRequest
+        ),
+    )
+    repo.save(LocalMap(map=test_map, file_path=temp_example_tif, cloud_free_coverage=0.61234))
+
+    metadata = s3_client.head_object(Bucket=BUCKET_NAME, Key=construct_key(test_map))["Metadata"]
+    assert metadata == {
+        "source-url": "http://example.com",
+        "cloud-free-coverage": "0.6123",
+        "sha256": sha256_of_file(temp_example_tif),
+    }
+
+
+@mock_s3
+@mock_sqs
+def test_s3_internal_map_repository_save_adds_the_map_to_the_manifest(test_map, temp_example_tif):
+    boto3.resource("sqs").create_queue(QueueName=QUEUE_NAME)
+    s3_client = boto3.client("s3")
+    s3_client.create_bucket(Bucket=BUCKET_NAME, CreateBucketConfiguration={"LocationConstraint": "eu-central-1"})
+    other_key = "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2023_1_1.tif"
+    s3_client.put_object(
+        Bucket=BUCKET_NAME,
+        Key=MANIFEST_KEY,
+        Body=json.dumps({"maps": {other_key: {"key": other_key, "created_at": "2023-01-02T00:00:00+00:00"}}}),
+    )
+
+    repo = S3InternalMapRepository(
+        logger=LOGGER,
+        bucket_name=BUCKET_NAME,
+        correlation_id="1",
+        local_write_dir=pathlib.Path("/tmp"),
+        new_message_notification=NewMessageNotificationQueue(
+            queue_name=QUEUE_NAME, message_format=CreateMapProductRequest
+        ),
+    )
+    repo.save(LocalMap(map=test_map, file_path=temp_example_tif))
+    repo.save(LocalMap(map=test_map, file_path=temp_example_tif))
+
+    manifest = json.loads(s3_client.get_object(Bucket=BUCKET_NAME, Key=MANIFEST_KEY)["Body"].read())
+    assert sorted(manifest["maps"]) == sorted([other_key, construct_key(test_map)])
+    entry = manifest["maps"][construct_key(test_map)]
+    assert entry["source_url"] == "http://example.com"
+    assert entry["size"] == len("Sample content")
+    assert entry["sha256"] == sha256_of_file(temp_example_tif)
+    assert entry["created_at"] <= entry["updated_at"]
+
+
 @mock_s3
 def test_download(test_map, temp_example_tif):
     s3_client = boto3.client("s3")
@@ -99,3 +169,71 @@ def test_download(test_map, temp_example_tif):
     assert expected_local_map == result
     assert expected_local_map.file_path.exists()
     expected_local_map.file_path.unlink()  # Clean up the downloaded file
+
+
+def test_construct_key_of_a_region_from_the_bounds_config(test_map, tmp_path, monkeypatch):
+    config = tmp_path / "bounds.json"
+    config.write_text(json.dumps([{"id": 3, "name": "KharkivAndAround"}]))
+    monkeypatch.setenv("BOUNDS_CONFIG_PATH", str(config))
+    get_bounds_registry.cache_clear()
+    test_map.bounds = 3
+    try:
+        key = construct_key(test_map)
+    finally:
+        get_bounds_registry.cache_clear()
+
+    assert key == "MapProviderEogdata/BoundsKharkivAndAround/MapTypeDaily/2023_2_1.tif"
+
+
+@mock_s3
+@mock_sqs
+def test_s3_internal_map_repository_save_without_a_queue(test_map, temp_example_tif):
+    queue = boto3.resource("sqs").create_queue(QueueName=QUEUE_NAME)
+    s3_client = boto3.client("s3")
+    s3_client.create_bucket(Bucket=BUCKET_NAME, CreateBucketConfiguration={"LocationConstraint": "eu-central-1"})
+
+    repo = S3InternalMapRepository(
+        logger=LOGGER,
+        bucket_name=BUCKET_NAME,
+        correlation_id="1",
+        local_write_dir=pathlib.Path("/tmp"),
+        new_message_notification=None,
+    )
+    repo.save(LocalMap(map=test_map, file_path=temp_example_tif))
+
+    assert queue.receive_messages() == []
+    assert s3_client.head_object(Bucket=BUCKET_NAME, Key=construct_key(test_map))
+
+
+@mock_s3
+def test_s3_internal_map_repository_save_retries_when_the_manifest_was_changed(test_map, temp_example_tif):
+    s3_client = boto3.client("s3")
+    s3_client.create_bucket(Bucket=BUCKET_NAME, CreateBucketConfiguration={"LocationConstraint": "eu-central-1"})
+    other_key = "MapProviderEogdata/BoundsUkraineAndAround/MapTypeDaily/2023_1_1.tif"
+
+    repo = S3InternalMapRepository(
+        logger=LOGGER,
+        bucket_name=BUCKET_NAME,
+        correlation_id="1",
+        local_write_dir=pathlib.Path("/tmp"),
+        new_message_notification=None,
+    )
+    put_object = repo.s3_client.put_object
+    conflicts = []
+
+    def put_object_after_another_writer(**kwargs):
+        if kwargs["Key"] == MANIFEST_KEY and not conflicts:
+            # Another lambda saved a map after the manifest was read
+            conflicts.append(kwargs)
+            s3_client.put_object(
+                Bucket=BUCKET_NAME, Key=MANIFEST_KEY, Body=json.dumps({"maps": {other_key: {"key": other_key}}})
+            )
+            raise ClientError({"Error": {"Code": "PreconditionFailed"}}, "PutObject")
+        return put_object(**kwargs)
+
+    repo.s3_client.put_object = put_object_after_another_writer
+    repo.save(LocalMap(map=test_map, file_path=temp_example_tif))
+
+    manifest = json.loads(s3_client.get_object(Bucket=BUCKET_NAME, Key=MANIFEST_KEY)["Body"].read())
+    assert len(conflicts) == 1
+    assert sorted(manifest["maps"]) == sorted([other_key, construct_key(test_map)])